@@ -0,0 +1,112 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sykell/website-analyzer/models"
+	"github.com/sykell/website-analyzer/services"
+)
+
+// maxAssertions limits how many assertions a website can have
+const maxAssertions = 100
+
+// GetAssertions retrieves the assertions of a website
+func GetAssertions(c *gin.Context) {
+	// Get the website ID from the URL parameter
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid website ID"})
+		return
+	}
+
+	// Get the user ID from the context (set by the AuthMiddleware)
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	// Get the website from the database
+	website, err := models.GetWebsiteByID(id)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Check if the website belongs to the authenticated user
+	if website.UserID != userID.(int) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "You don't have permission to access this website"})
+		return
+	}
+
+	// Get the assertions from the database
+	assertions, err := models.GetAssertions(website.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Return the assertions
+	c.JSON(http.StatusOK, assertions)
+}
+
+// UpdateAssertions replaces the assertions of a website
+func UpdateAssertions(c *gin.Context) {
+	// Get the website ID from the URL parameter
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid website ID"})
+		return
+	}
+
+	// Bind the request body to the list of assertions
+	var assertions []models.Assertion
+	if err := c.ShouldBindJSON(&assertions); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if len(assertions) > maxAssertions {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("A website can have at most %d assertions", maxAssertions)})
+		return
+	}
+
+	// Check the assertions and fill in their defaults
+	for i := range assertions {
+		if err := services.ValidateAssertion(&assertions[i]); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Assertion %d: %v", i+1, err)})
+			return
+		}
+	}
+
+	// Get the user ID from the context (set by the AuthMiddleware)
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	// Get the website from the database
+	website, err := models.GetWebsiteByID(id)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Check if the website belongs to the authenticated user
+	if website.UserID != userID.(int) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "You don't have permission to modify this website"})
+		return
+	}
+
+	// Save the assertions
+	if err := models.SaveAssertions(website.ID, assertions); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Return the saved assertions
+	c.JSON(http.StatusOK, assertions)
+}
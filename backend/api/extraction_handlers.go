@@ -0,0 +1,332 @@
+package api
+
+import (
+	"bytes"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sykell/website-analyzer/models"
+	"github.com/sykell/website-analyzer/services"
+)
+
+// GetExtractionRules retrieves the extraction rules applied to every website of the account
+func GetExtractionRules(c *gin.Context) {
+	// Get the user ID from the context (set by the AuthMiddleware)
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	// Get the rules from the database
+	rules, err := models.GetExtractionRules(userID.(int), nil)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Return the rules
+	c.JSON(http.StatusOK, rules)
+}
+
+// CreateExtractionRule creates an extraction rule applied to every website of the account
+func CreateExtractionRule(c *gin.Context) {
+	// Get the user ID from the context (set by the AuthMiddleware)
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	createExtractionRule(c, userID.(int), nil)
+}
+
+// GetWebsiteExtractionRules retrieves the extraction rules of a website
+func GetWebsiteExtractionRules(c *gin.Context) {
+	// Get the website ID from the URL parameter
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid website ID"})
+		return
+	}
+
+	// Get the user ID from the context (set by the AuthMiddleware)
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	// Get the website from the database
+	website, err := models.GetWebsiteByID(id)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Check if the website belongs to the authenticated user
+	if website.UserID != userID.(int) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "You don't have permission to access this website"})
+		return
+	}
+
+	// Get the rules from the database
+	rules, err := models.GetExtractionRules(website.UserID, &website.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Return the rules
+	c.JSON(http.StatusOK, rules)
+}
+
+// CreateWebsiteExtractionRule creates an extraction rule for a website
+func CreateWebsiteExtractionRule(c *gin.Context) {
+	// Get the website ID from the URL parameter
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid website ID"})
+		return
+	}
+
+	// Get the user ID from the context (set by the AuthMiddleware)
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	// Get the website from the database
+	website, err := models.GetWebsiteByID(id)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Check if the website belongs to the authenticated user
+	if website.UserID != userID.(int) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "You don't have permission to modify this website"})
+		return
+	}
+
+	createExtractionRule(c, website.UserID, &website.ID)
+}
+
+// createExtractionRule binds, validates and creates a rule for the account or one of its websites
+func createExtractionRule(c *gin.Context, userID int, websiteID *int) {
+	// Bind the request body to the rule struct
+	var rule models.ExtractionRule
+	if err := c.ShouldBindJSON(&rule); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	rule.ID = 0
+	rule.UserID = userID
+	rule.WebsiteID = websiteID
+
+	// Check that the selector and regex compile
+	if err := services.ValidateExtractionRule(&rule); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Create the rule
+	if err := models.CreateExtractionRule(&rule); err != nil {
+		if errors.Is(err, models.ErrDuplicateRuleName) {
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Return the created rule
+	c.JSON(http.StatusCreated, rule)
+}
+
+// UpdateExtractionRule replaces the definition of an extraction rule
+func UpdateExtractionRule(c *gin.Context) {
+	// Get the rule ID from the URL parameter
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid rule ID"})
+		return
+	}
+
+	// Bind the request body to the rule struct
+	var rule models.ExtractionRule
+	if err := c.ShouldBindJSON(&rule); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Get the user ID from the context (set by the AuthMiddleware)
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	// Get the rule from the database
+	existing, err := models.GetExtractionRuleByID(id)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Check if the rule belongs to the authenticated user
+	if existing.UserID != userID.(int) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "You don't have permission to modify this rule"})
+		return
+	}
+
+	// Rules keep their owner and scope
+	rule.ID = existing.ID
+	rule.UserID = existing.UserID
+	rule.WebsiteID = existing.WebsiteID
+	rule.CreatedAt = existing.CreatedAt
+
+	// Check that the selector and regex compile
+	if err := services.ValidateExtractionRule(&rule); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Save the rule
+	if err := models.UpdateExtractionRule(&rule); err != nil {
+		if errors.Is(err, models.ErrDuplicateRuleName) {
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Return the saved rule
+	c.JSON(http.StatusOK, rule)
+}
+
+// DeleteExtractionRule deletes an extraction rule
+func DeleteExtractionRule(c *gin.Context) {
+	// Get the rule ID from the URL parameter
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid rule ID"})
+		return
+	}
+
+	// Get the user ID from the context (set by the AuthMiddleware)
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	// Get the rule from the database
+	rule, err := models.GetExtractionRuleByID(id)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Check if the rule belongs to the authenticated user
+	if rule.UserID != userID.(int) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "You don't have permission to delete this rule"})
+		return
+	}
+
+	// Delete the rule
+	if err := models.DeleteExtractionRule(rule.ID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Return success
+	c.JSON(http.StatusOK, gin.H{"message": "Extraction rule deleted successfully"})
+}
+
+// GetExtractions returns the extraction results of a website as JSON or CSV
+func GetExtractions(c *gin.Context) {
+	// Get the website ID from the URL parameter
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid website ID"})
+		return
+	}
+
+	// Get the export format from the query parameters
+	format := c.DefaultQuery("format", "json")
+	if format != "json" && format != "csv" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid format, expected json or csv"})
+		return
+	}
+
+	// Get the user ID from the context (set by the AuthMiddleware)
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	// Get the website from the database
+	website, err := models.GetWebsiteByID(id)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Check if the website belongs to the authenticated user
+	if website.UserID != userID.(int) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "You don't have permission to access this website"})
+		return
+	}
+
+	// Return the results in the requested format
+	if format == "json" {
+		c.JSON(http.StatusOK, website.Extractions)
+		return
+	}
+	data, err := extractionsCSV(website)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.Header("Content-Disposition", "attachment; filename=extractions-"+strconv.Itoa(website.ID)+".csv")
+	c.Data(http.StatusOK, "text/csv", data)
+}
+
+// extractionsCSV renders the extraction results with one row per extracted value
+func extractionsCSV(website *models.Website) ([]byte, error) {
+	var buf bytes.Buffer
+	writer := csv.NewWriter(&buf)
+	writer.Write([]string{"website_id", "url", "rule_name", "data_type", "value", "error"})
+
+	for _, result := range website.Extractions {
+		values, ok := result.Value.([]interface{})
+		if !ok {
+			values = []interface{}{result.Value}
+		}
+		if len(values) == 0 {
+			values = []interface{}{nil}
+		}
+		for _, value := range values {
+			cell := ""
+			switch v := value.(type) {
+			case nil:
+			case float64:
+				cell = strconv.FormatFloat(v, 'f', -1, 64)
+			default:
+				cell = fmt.Sprint(v)
+			}
+			writer.Write([]string{strconv.Itoa(website.ID), website.URL, result.RuleName, result.DataType, cell, result.Error})
+		}
+	}
+
+	writer.Flush()
+	return buf.Bytes(), writer.Error()
+}
@@ -0,0 +1,65 @@
+package api
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sykell/website-analyzer/models"
+)
+
+// GetLinkGraph returns the internal link graph of a website as JSON, GraphML or DOT
+func GetLinkGraph(c *gin.Context) {
+	// Get the website ID from the URL parameter
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid website ID"})
+		return
+	}
+
+	// Get the export format from the query parameters
+	format := c.DefaultQuery("format", "json")
+	if format != "json" && format != "graphml" && format != "dot" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid format, expected json, graphml or dot"})
+		return
+	}
+
+	// Get the user ID from the context (set by the AuthMiddleware)
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	// Get the website from the database
+	website, err := models.GetWebsiteByID(id)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Check if the website belongs to the authenticated user
+	if website.UserID != userID.(int) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "You don't have permission to access this website"})
+		return
+	}
+
+	// Get the graph from the database
+	graph, err := models.GetLinkGraph(website.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Return the graph in the requested format
+	switch format {
+	case "graphml":
+		c.Header("Content-Disposition", "attachment; filename=links-"+strconv.Itoa(website.ID)+".graphml")
+		c.Data(http.StatusOK, "application/graphml+xml", graph.GraphML())
+	case "dot":
+		c.Header("Content-Disposition", "attachment; filename=links-"+strconv.Itoa(website.ID)+".dot")
+		c.Data(http.StatusOK, "text/vnd.graphviz", []byte(graph.DOT()))
+	default:
+		c.JSON(http.StatusOK, graph)
+	}
+}
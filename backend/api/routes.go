@@ -39,12 +39,30 @@ func RegisterRoutes(r *gin.Engine) {
 		{
 			websites.POST("", CreateWebsite)
 			websites.GET("", GetWebsites)
+			websites.GET("/expiring-certificates", GetExpiringCertificates)
 			websites.GET("/:id", GetWebsite)
 			websites.DELETE("/:id", DeleteWebsite)
 			websites.POST("/:id/start", StartAnalysis)
 			websites.POST("/:id/stop", StopAnalysis)
+			websites.GET("/:id/settings", GetCrawlSettings)
+			websites.PUT("/:id/settings", UpdateCrawlSettings)
+			websites.GET("/:id/graph", GetLinkGraph)
+			websites.GET("/:id/extraction-rules", GetWebsiteExtractionRules)
+			websites.POST("/:id/extraction-rules", CreateWebsiteExtractionRule)
+			websites.GET("/:id/extractions", GetExtractions)
+			websites.GET("/:id/assertions", GetAssertions)
+			websites.PUT("/:id/assertions", UpdateAssertions)
 			websites.POST("/bulk-delete", BulkDeleteWebsites)
 			websites.POST("/bulk-start", BulkStartAnalysis)
 		}
+
+		// Extraction rules applied to every website of the account
+		extractionRules := protected.Group("/extraction-rules")
+		{
+			extractionRules.GET("", GetExtractionRules)
+			extractionRules.POST("", CreateExtractionRule)
+			extractionRules.PUT("/:id", UpdateExtractionRule)
+			extractionRules.DELETE("/:id", DeleteExtractionRule)
+		}
 	}
 } 
\ No newline at end of file
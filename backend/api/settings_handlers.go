@@ -0,0 +1,111 @@
+package api
+
+import (
+	"net/http"
+	"regexp"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sykell/website-analyzer/models"
+)
+
+// GetCrawlSettings retrieves the crawl settings of a website
+func GetCrawlSettings(c *gin.Context) {
+	// Get the website ID from the URL parameter
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid website ID"})
+		return
+	}
+
+	// Get the user ID from the context (set by the AuthMiddleware)
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	// Get the website from the database
+	website, err := models.GetWebsiteByID(id)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Check if the website belongs to the authenticated user
+	if website.UserID != userID.(int) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "You don't have permission to access this website"})
+		return
+	}
+
+	// Get the settings from the database
+	settings, err := models.GetCrawlSettings(website.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Return the settings without exposing secrets
+	c.JSON(http.StatusOK, settings.Redacted())
+}
+
+// UpdateCrawlSettings replaces the crawl settings of a website
+func UpdateCrawlSettings(c *gin.Context) {
+	// Get the website ID from the URL parameter
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid website ID"})
+		return
+	}
+
+	// Bind the request body to the settings struct
+	var settings models.CrawlSettings
+	if err := c.ShouldBindJSON(&settings); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// The link graph exclude pattern must be a valid regular expression
+	if _, err := regexp.Compile(settings.GraphExclude); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid graph exclude pattern: " + err.Error()})
+		return
+	}
+
+	// Get the user ID from the context (set by the AuthMiddleware)
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	// Get the website from the database
+	website, err := models.GetWebsiteByID(id)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Check if the website belongs to the authenticated user
+	if website.UserID != userID.(int) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "You don't have permission to modify this website"})
+		return
+	}
+
+	// Keep the stored secrets when the client sends back masked values
+	existing, err := models.GetCrawlSettings(website.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	settings.KeepSecrets(existing)
+	settings.WebsiteID = website.ID
+
+	// Save the settings
+	if err := models.SaveCrawlSettings(&settings); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Return the saved settings without exposing secrets
+	c.JSON(http.StatusOK, settings.Redacted())
+}
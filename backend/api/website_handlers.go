@@ -93,8 +93,15 @@ func GetWebsites(c *gin.Context) {
 		pageSize = 50
 	}
 
+	// Get the filters from the query parameters
+	filter := models.WebsiteFilter{
+		Technology: c.Query("technology"),
+		Category:   c.Query("category"),
+		Health:     c.Query("health"),
+	}
+
 	// Get the websites from the database
-	websites, totalCount, err := models.GetWebsitesByUserID(userID.(int), page, pageSize)
+	websites, totalCount, err := models.GetWebsitesByUserID(userID.(int), filter, page, pageSize)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
@@ -171,7 +178,7 @@ func StartAnalysis(c *gin.Context) {
 
 	// Return the website status
 	c.JSON(http.StatusOK, gin.H{
-		"status": "running",
+		"status":  "running",
 		"message": "Website analysis started",
 	})
 }
@@ -220,7 +227,7 @@ func StopAnalysis(c *gin.Context) {
 
 	// Return the website status
 	c.JSON(http.StatusOK, gin.H{
-		"status": "stopped",
+		"status":  "stopped",
 		"message": "Website analysis stopped",
 	})
 }
@@ -366,4 +373,34 @@ func BulkStartAnalysis(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{
 		"message": "Websites analysis started",
 	})
-} 
\ No newline at end of file
+}
+
+// GetExpiringCertificates lists the user's websites whose certificate expires soon
+func GetExpiringCertificates(c *gin.Context) {
+	// Get the user ID from the context (set by the AuthMiddleware)
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	// Get the alerting window from the query parameters
+	days, err := strconv.Atoi(c.DefaultQuery("days", "30"))
+	if err != nil || days < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid number of days"})
+		return
+	}
+
+	// Get the expiring certificates from the database
+	certificates, err := models.GetExpiringCertificates(userID.(int), days)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Return the certificates, soonest expiry first
+	c.JSON(http.StatusOK, gin.H{
+		"certificates": certificates,
+		"days":         days,
+	})
+}
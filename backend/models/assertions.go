@@ -0,0 +1,190 @@
+package models
+
+import (
+	"database/sql"
+
+	"github.com/sykell/website-analyzer/database"
+)
+
+// Assertion is a check every analysis of a website evaluates, e.g. "the H1
+// count must equal 1" or "the response time must be under 500 ms"
+type Assertion struct {
+	ID           int    `json:"id"`
+	WebsiteID    int    `json:"-"`
+	Type         string `json:"type" binding:"required,oneof=title_contains heading_count broken_links selector_count response_time status_code"`
+	Target       string `json:"target" binding:"max=1024"`                              // Heading level for heading_count, selector for selector_count
+	SelectorType string `json:"selector_type" binding:"omitempty,oneof=css xpath"`      // Defaults to css
+	Operator     string `json:"operator" binding:"omitempty,oneof=eq ne lt lte gt gte"` // Defaults depend on the type
+	Expected     string `json:"expected" binding:"max=1024"`
+}
+
+// Assertion types
+const (
+	AssertTitleContains = "title_contains"
+	AssertHeadingCount  = "heading_count"
+	AssertBrokenLinks   = "broken_links"
+	AssertSelectorCount = "selector_count"
+	AssertResponseTime  = "response_time"
+	AssertStatusCode    = "status_code"
+)
+
+// Health of a website according to its assertions
+const (
+	HealthPassing = "passing"
+	HealthFailing = "failing"
+)
+
+// AssertionResult represents the outcome of an assertion in the latest analysis
+type AssertionResult struct {
+	ID          int    `json:"-"`
+	WebsiteID   int    `json:"-"`
+	AssertionID int    `json:"assertion_id"`
+	Type        string `json:"type"`
+	Description string `json:"description"` // e.g. "h1 count must equal 1"
+	Passed      bool   `json:"passed"`
+	Actual      string `json:"actual"`
+	Message     string `json:"message,omitempty"`
+}
+
+// GetAssertions retrieves the assertions of a website
+func GetAssertions(websiteID int) ([]Assertion, error) {
+	rows, err := database.DB.Query(
+		"SELECT id, type, target, selector_type, operator, expected FROM assertions WHERE website_id = ? ORDER BY id",
+		websiteID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	assertions := []Assertion{}
+	for rows.Next() {
+		var assertion Assertion
+		assertion.WebsiteID = websiteID
+		err := rows.Scan(
+			&assertion.ID, &assertion.Type, &assertion.Target, &assertion.SelectorType, &assertion.Operator,
+			&assertion.Expected,
+		)
+		if err != nil {
+			return nil, err
+		}
+		assertions = append(assertions, assertion)
+	}
+
+	return assertions, nil
+}
+
+// SaveAssertions replaces the assertions of a website
+func SaveAssertions(websiteID int, assertions []Assertion) error {
+	// Start a transaction
+	tx, err := database.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec("DELETE FROM assertions WHERE website_id = ?", websiteID)
+	if err != nil {
+		return err
+	}
+
+	for i := range assertions {
+		assertion := &assertions[i]
+		result, err := tx.Exec(
+			"INSERT INTO assertions (website_id, type, target, selector_type, operator, expected) VALUES (?, ?, ?, ?, ?, ?)",
+			websiteID, assertion.Type, assertion.Target, assertion.SelectorType, assertion.Operator, assertion.Expected,
+		)
+		if err != nil {
+			return err
+		}
+		id, err := result.LastInsertId()
+		if err != nil {
+			return err
+		}
+		assertion.ID = int(id)
+		assertion.WebsiteID = websiteID
+	}
+
+	// Commit the transaction
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// saveAssertionResults replaces the assertion results and health of a website
+func saveAssertionResults(tx *sql.Tx, websiteID int, results []AssertionResult, health string) error {
+	_, err := tx.Exec("DELETE FROM assertion_results WHERE website_id = ?", websiteID)
+	if err != nil {
+		return err
+	}
+
+	for _, result := range results {
+		_, err = tx.Exec(
+			"INSERT INTO assertion_results (website_id, assertion_id, type, description, passed, actual, message) "+
+				"VALUES (?, ?, ?, ?, ?, ?, ?)",
+			websiteID, result.AssertionID, result.Type, result.Description, result.Passed, result.Actual, result.Message,
+		)
+		if err != nil {
+			return err
+		}
+	}
+
+	// Websites without assertions have no health
+	_, err = tx.Exec(
+		"UPDATE websites SET health = ? WHERE id = ?",
+		sql.NullString{String: health, Valid: health != ""}, websiteID,
+	)
+	return err
+}
+
+// SaveAssertionResults stores the assertion results and health of an
+// analysis that failed before its data could be saved
+func SaveAssertionResults(websiteID int, results []AssertionResult, health string) error {
+	// Start a transaction
+	tx, err := database.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := saveAssertionResults(tx, websiteID, results, health); err != nil {
+		return err
+	}
+
+	// Commit the transaction
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// GetAssertionResults retrieves the assertion results of a website
+func GetAssertionResults(websiteID int) ([]AssertionResult, error) {
+	rows, err := database.DB.Query(
+		"SELECT id, assertion_id, type, description, passed, actual, message FROM assertion_results WHERE website_id = ? ORDER BY id",
+		websiteID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	results := []AssertionResult{}
+	for rows.Next() {
+		var result AssertionResult
+		result.WebsiteID = websiteID
+		err := rows.Scan(
+			&result.ID, &result.AssertionID, &result.Type, &result.Description, &result.Passed, &result.Actual,
+			&result.Message,
+		)
+		if err != nil {
+			return nil, err
+		}
+		results = append(results, result)
+	}
+
+	return results, nil
+}
@@ -0,0 +1,84 @@
+package models
+
+import (
+	"database/sql"
+	"encoding/json"
+
+	"github.com/sykell/website-analyzer/database"
+)
+
+// CacheEntry represents the caching and compression headers of the page or one of its assets
+type CacheEntry struct {
+	ID                int      `json:"-"`
+	WebsiteID         int      `json:"-"`
+	URL               string   `json:"url"`
+	ResourceType      string   `json:"resource_type"`
+	CacheControl      string   `json:"cache_control,omitempty"`
+	Expires           string   `json:"expires,omitempty"`
+	ETag              string   `json:"etag,omitempty"`
+	LastModified      string   `json:"last_modified,omitempty"`
+	Vary              string   `json:"vary,omitempty"`
+	ContentEncoding   string   `json:"content_encoding,omitempty"`
+	MaxAge            int      `json:"max_age"`            // Seconds, -1 if the response has no freshness lifetime
+	ConditionalStatus int      `json:"conditional_status"` // Status of the revalidation request, 0 if not attempted
+	Issues            []string `json:"issues"`
+}
+
+// saveCacheEntries replaces the cache audit entries of a website
+func saveCacheEntries(tx *sql.Tx, websiteID int, entries []CacheEntry) error {
+	_, err := tx.Exec("DELETE FROM cache_entries WHERE website_id = ?", websiteID)
+	if err != nil {
+		return err
+	}
+
+	for _, entry := range entries {
+		issues, err := json.Marshal(entry.Issues)
+		if err != nil {
+			return err
+		}
+		_, err = tx.Exec(
+			"INSERT INTO cache_entries (website_id, url, resource_type, cache_control, expires, etag, last_modified, vary, "+
+				"content_encoding, max_age, conditional_status, issues) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)",
+			websiteID, entry.URL, entry.ResourceType, entry.CacheControl, entry.Expires, entry.ETag, entry.LastModified,
+			entry.Vary, entry.ContentEncoding, entry.MaxAge, entry.ConditionalStatus, issues,
+		)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// GetCacheEntries retrieves the cache audit entries of a website
+func GetCacheEntries(websiteID int) ([]CacheEntry, error) {
+	rows, err := database.DB.Query(
+		"SELECT id, url, resource_type, cache_control, expires, etag, last_modified, vary, content_encoding, max_age, "+
+			"conditional_status, issues FROM cache_entries WHERE website_id = ?",
+		websiteID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	entries := []CacheEntry{}
+	for rows.Next() {
+		var entry CacheEntry
+		var issues []byte
+		entry.WebsiteID = websiteID
+		err := rows.Scan(
+			&entry.ID, &entry.URL, &entry.ResourceType, &entry.CacheControl, &entry.Expires, &entry.ETag,
+			&entry.LastModified, &entry.Vary, &entry.ContentEncoding, &entry.MaxAge, &entry.ConditionalStatus, &issues,
+		)
+		if err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal(issues, &entry.Issues); err != nil {
+			return nil, err
+		}
+		entries = append(entries, entry)
+	}
+
+	return entries, nil
+}
@@ -0,0 +1,118 @@
+package models
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+
+	"github.com/sykell/website-analyzer/database"
+)
+
+// ContentReport represents the text metrics of the analyzed page
+type ContentReport struct {
+	ID               int            `json:"-"`
+	WebsiteID        int            `json:"-"`
+	MainContentFrom  string         `json:"main_content_from"` // Element the main content was taken from, e.g. "main", "article" or "body"
+	WordCount        int            `json:"word_count"`        // Words of the whole page
+	MainWordCount    int            `json:"main_word_count"`   // Words of the main content without navigation and footers
+	SentenceCount    int            `json:"sentence_count"`
+	TextHTMLRatio    float64        `json:"text_html_ratio"`   // Visible text bytes as a percentage of the HTML bytes
+	DeclaredLanguage string         `json:"declared_language"` // From the lang attribute of the html element
+	Language         string         `json:"language"`          // Detected from the text, empty if unknown
+	LanguageMismatch bool           `json:"language_mismatch"`
+	Readability      *Readability   `json:"readability,omitempty"` // Only for languages with a readability formula
+	Keywords         []KeywordCount `json:"keywords"`
+	Bigrams          []KeywordCount `json:"bigrams"`
+	Trigrams         []KeywordCount `json:"trigrams"`
+	ThinContent      bool           `json:"thin_content"`
+}
+
+// Readability represents a Flesch-style reading ease score of the main content
+type Readability struct {
+	Formula                 string  `json:"formula"` // e.g. "flesch", "amstad" or "kandel_moles"
+	Score                   float64 `json:"score"`   // Higher is easier, usually between 0 and 100
+	Level                   string  `json:"level"`   // e.g. "standard" or "difficult"
+	AverageSentenceLength   float64 `json:"average_sentence_length"`
+	AverageSyllablesPerWord float64 `json:"average_syllables_per_word"`
+}
+
+// KeywordCount represents a word or phrase of the main content with its frequency
+type KeywordCount struct {
+	Term    string  `json:"term"`
+	Count   int     `json:"count"`
+	Density float64 `json:"density"` // Percentage of the main content's words
+}
+
+// saveContentReport creates or replaces the content report of a website
+func saveContentReport(tx *sql.Tx, report *ContentReport) error {
+	readability, err := json.Marshal(report.Readability)
+	if err != nil {
+		return err
+	}
+	keywords, err := json.Marshal(report.Keywords)
+	if err != nil {
+		return err
+	}
+	bigrams, err := json.Marshal(report.Bigrams)
+	if err != nil {
+		return err
+	}
+	trigrams, err := json.Marshal(report.Trigrams)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(
+		"INSERT INTO content_reports (website_id, main_content_from, word_count, main_word_count, sentence_count, text_html_ratio, "+
+			"declared_language, language, language_mismatch, readability, keywords, bigrams, trigrams, thin_content) "+
+			"VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?) "+
+			"ON DUPLICATE KEY UPDATE main_content_from = VALUES(main_content_from), word_count = VALUES(word_count), "+
+			"main_word_count = VALUES(main_word_count), sentence_count = VALUES(sentence_count), "+
+			"text_html_ratio = VALUES(text_html_ratio), declared_language = VALUES(declared_language), "+
+			"language = VALUES(language), language_mismatch = VALUES(language_mismatch), readability = VALUES(readability), "+
+			"keywords = VALUES(keywords), bigrams = VALUES(bigrams), trigrams = VALUES(trigrams), thin_content = VALUES(thin_content)",
+		report.WebsiteID, report.MainContentFrom, report.WordCount, report.MainWordCount, report.SentenceCount,
+		report.TextHTMLRatio, report.DeclaredLanguage, report.Language, report.LanguageMismatch, readability, keywords,
+		bigrams, trigrams, report.ThinContent,
+	)
+	return err
+}
+
+// GetContentReport retrieves the content report of a website
+func GetContentReport(websiteID int) (*ContentReport, error) {
+	report := &ContentReport{WebsiteID: websiteID}
+	var readability, keywords, bigrams, trigrams []byte
+	err := database.DB.QueryRow(
+		"SELECT id, main_content_from, word_count, main_word_count, sentence_count, text_html_ratio, declared_language, "+
+			"language, language_mismatch, readability, keywords, bigrams, trigrams, thin_content "+
+			"FROM content_reports WHERE website_id = ?",
+		websiteID,
+	).Scan(
+		&report.ID, &report.MainContentFrom, &report.WordCount, &report.MainWordCount, &report.SentenceCount,
+		&report.TextHTMLRatio, &report.DeclaredLanguage, &report.Language, &report.LanguageMismatch, &readability,
+		&keywords, &bigrams, &trigrams, &report.ThinContent,
+	)
+
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			// No report until the website has been analyzed
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	if err := json.Unmarshal(readability, &report.Readability); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(keywords, &report.Keywords); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(bigrams, &report.Bigrams); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(trigrams, &report.Trigrams); err != nil {
+		return nil, err
+	}
+
+	return report, nil
+}
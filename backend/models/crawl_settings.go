@@ -0,0 +1,206 @@
+package models
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/url"
+
+	"github.com/sykell/website-analyzer/database"
+	"github.com/sykell/website-analyzer/utils"
+)
+
+// SecretMask replaces secret values in API responses
+const SecretMask = "********"
+
+// CrawlSettings represents the HTTP client profile used when crawling a website
+type CrawlSettings struct {
+	WebsiteID          int               `json:"-"`
+	UserAgent          string            `json:"user_agent" binding:"max=512"`
+	AcceptLanguage     string            `json:"accept_language" binding:"max=255"`
+	Headers            map[string]string `json:"headers"`
+	Cookies            []CrawlCookie     `json:"cookies" binding:"dive"`
+	AuthType           string            `json:"auth_type" binding:"omitempty,oneof=basic bearer"`
+	AuthUsername       string            `json:"auth_username"`
+	AuthPassword       string            `json:"auth_password"`
+	BearerToken        string            `json:"bearer_token"`
+	TimeoutSeconds     int               `json:"timeout_seconds" binding:"min=0,max=300"`
+	LinkTimeoutSeconds int               `json:"link_timeout_seconds" binding:"min=0,max=120"`
+	ProxyURL           string            `json:"proxy_url" binding:"omitempty,url"`
+	ProxyUsername      string            `json:"proxy_username"`
+	ProxyPassword      string            `json:"proxy_password"`
+	Login              *LoginRecipe      `json:"login,omitempty"`
+	Scope              string            `json:"scope" binding:"omitempty,oneof=host domain list"`
+	ScopeRules         []string          `json:"scope_rules" binding:"dive,required"`
+	CheckMX            bool              `json:"check_mx"`                            // Look up the mail servers of mailto link domains
+	MaxPages           int               `json:"max_pages" binding:"min=0,max=1000"`  // Pages followed for the link graph
+	GraphExclude       string            `json:"graph_exclude" binding:"max=1024"`    // Pattern of links the link graph doesn't follow, logout and signout links by default
+	MinWords           int               `json:"min_words" binding:"min=0,max=10000"` // Main content words below which a page is thin
+}
+
+// LoginRecipe describes how to log into a website before it is analyzed
+type LoginRecipe struct {
+	LoginURL        string            `json:"login_url" binding:"required,url"`
+	Fields          map[string]string `json:"fields" binding:"required"`
+	SuccessCookie   string            `json:"success_cookie"`
+	SuccessSelector string            `json:"success_selector"`
+}
+
+// Crawl scopes deciding which links belong to the website. "host" covers the
+// website's host, "domain" its registrable domain with all subdomains, and
+// "list" the hosts or host/path prefixes in ScopeRules (e.g. "example.com/blog").
+// Credentials and cookies are sent to every URL in the scope, so with "domain"
+// they go to all subdomains of the website.
+const (
+	ScopeHost   = "host"
+	ScopeDomain = "domain"
+	ScopeList   = "list"
+)
+
+// CrawlCookie represents a cookie sent with every request to the website
+type CrawlCookie struct {
+	Name  string `json:"name" binding:"required"`
+	Value string `json:"value"`
+}
+
+// Redacted returns a copy of the settings with secrets masked for API responses
+func (s *CrawlSettings) Redacted() *CrawlSettings {
+	redacted := *s
+	if redacted.AuthPassword != "" {
+		redacted.AuthPassword = SecretMask
+	}
+	if redacted.BearerToken != "" {
+		redacted.BearerToken = SecretMask
+	}
+	if redacted.ProxyPassword != "" {
+		redacted.ProxyPassword = SecretMask
+	}
+	redacted.ProxyURL = redactURL(s.ProxyURL)
+
+	// Custom headers and cookies often carry API keys and session tokens
+	if s.Headers != nil {
+		redacted.Headers = make(map[string]string, len(s.Headers))
+		for name := range s.Headers {
+			redacted.Headers[name] = SecretMask
+		}
+	}
+	if s.Cookies != nil {
+		redacted.Cookies = make([]CrawlCookie, len(s.Cookies))
+		for i, cookie := range s.Cookies {
+			redacted.Cookies[i] = CrawlCookie{Name: cookie.Name, Value: SecretMask}
+		}
+	}
+	if s.Login != nil {
+		// Login form values usually contain a password, so mask all of them
+		login := *s.Login
+		login.Fields = make(map[string]string, len(s.Login.Fields))
+		for name := range s.Login.Fields {
+			login.Fields[name] = SecretMask
+		}
+		redacted.Login = &login
+	}
+	return &redacted
+}
+
+// KeepSecrets restores secrets that were sent back masked from the existing settings
+func (s *CrawlSettings) KeepSecrets(existing *CrawlSettings) {
+	if s.AuthPassword == SecretMask {
+		s.AuthPassword = existing.AuthPassword
+	}
+	if s.BearerToken == SecretMask {
+		s.BearerToken = existing.BearerToken
+	}
+	if s.ProxyPassword == SecretMask {
+		s.ProxyPassword = existing.ProxyPassword
+	}
+	if s.ProxyURL != "" && s.ProxyURL == redactURL(existing.ProxyURL) {
+		s.ProxyURL = existing.ProxyURL
+	}
+	for name, value := range s.Headers {
+		if value == SecretMask {
+			s.Headers[name] = existing.Headers[name]
+		}
+	}
+	for i, cookie := range s.Cookies {
+		if cookie.Value == SecretMask {
+			s.Cookies[i].Value = existing.cookieValue(cookie.Name)
+		}
+	}
+	if s.Login != nil && existing.Login != nil {
+		for name, value := range s.Login.Fields {
+			if value == SecretMask {
+				s.Login.Fields[name] = existing.Login.Fields[name]
+			}
+		}
+	}
+}
+
+// cookieValue returns the value of the configured cookie with the given name
+func (s *CrawlSettings) cookieValue(name string) string {
+	for _, cookie := range s.Cookies {
+		if cookie.Name == name {
+			return cookie.Value
+		}
+	}
+	return ""
+}
+
+// redactURL masks the password in the user info of a URL
+func redactURL(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return rawURL
+	}
+	return u.Redacted()
+}
+
+// GetCrawlSettings retrieves the crawl settings for a website
+func GetCrawlSettings(websiteID int) (*CrawlSettings, error) {
+	var encrypted string
+	err := database.DB.QueryRow(
+		"SELECT settings FROM crawl_settings WHERE website_id = ?",
+		websiteID,
+	).Scan(&encrypted)
+
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			// Return empty settings if none exist
+			return &CrawlSettings{WebsiteID: websiteID}, nil
+		}
+		return nil, err
+	}
+
+	// The settings are stored encrypted since they may contain credentials
+	plaintext, err := utils.Decrypt(encrypted)
+	if err != nil {
+		return nil, err
+	}
+
+	settings := &CrawlSettings{}
+	if err := json.Unmarshal([]byte(plaintext), settings); err != nil {
+		return nil, err
+	}
+	settings.WebsiteID = websiteID
+
+	return settings, nil
+}
+
+// SaveCrawlSettings creates or replaces the crawl settings for a website
+func SaveCrawlSettings(settings *CrawlSettings) error {
+	plaintext, err := json.Marshal(settings)
+	if err != nil {
+		return err
+	}
+
+	encrypted, err := utils.Encrypt(string(plaintext))
+	if err != nil {
+		return err
+	}
+
+	_, err = database.DB.Exec(
+		"INSERT INTO crawl_settings (website_id, settings) VALUES (?, ?) "+
+			"ON DUPLICATE KEY UPDATE settings = VALUES(settings)",
+		settings.WebsiteID, encrypted,
+	)
+	return err
+}
@@ -0,0 +1,71 @@
+package models
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+
+	"github.com/sykell/website-analyzer/database"
+)
+
+// Document represents a URL that returned something other than HTML
+type Document struct {
+	ID          int               `json:"-"`
+	WebsiteID   int               `json:"-"`
+	ContentType string            `json:"content_type"`
+	Kind        string            `json:"kind"` // "pdf", "markdown", "text" or "other"
+	Size        int64             `json:"size"` // Bytes
+	Analyzable  bool              `json:"analyzable"`
+	Version     string            `json:"version,omitempty"`    // PDF version, e.g. "1.7"
+	PageCount   int               `json:"page_count,omitempty"` // PDF pages
+	Encrypted   bool              `json:"encrypted,omitempty"`  // Encrypted PDFs hide their metadata and links
+	Metadata    map[string]string `json:"metadata"`             // PDF document information, e.g. "Author", "Producer" or "CreationDate"
+	LinkCount   int               `json:"link_count"`
+}
+
+// saveDocument creates or replaces the document details of a website
+func saveDocument(tx *sql.Tx, document *Document) error {
+	metadata, err := json.Marshal(document.Metadata)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(
+		"INSERT INTO documents (website_id, content_type, kind, size, analyzable, version, page_count, encrypted, metadata, link_count) "+
+			"VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?) "+
+			"ON DUPLICATE KEY UPDATE content_type = VALUES(content_type), kind = VALUES(kind), size = VALUES(size), "+
+			"analyzable = VALUES(analyzable), version = VALUES(version), page_count = VALUES(page_count), "+
+			"encrypted = VALUES(encrypted), metadata = VALUES(metadata), link_count = VALUES(link_count)",
+		document.WebsiteID, document.ContentType, document.Kind, document.Size, document.Analyzable, document.Version,
+		document.PageCount, document.Encrypted, metadata, document.LinkCount,
+	)
+	return err
+}
+
+// GetDocument retrieves the document details of a website
+func GetDocument(websiteID int) (*Document, error) {
+	document := &Document{WebsiteID: websiteID}
+	var metadata []byte
+	err := database.DB.QueryRow(
+		"SELECT id, content_type, kind, size, analyzable, version, page_count, encrypted, metadata, link_count "+
+			"FROM documents WHERE website_id = ?",
+		websiteID,
+	).Scan(
+		&document.ID, &document.ContentType, &document.Kind, &document.Size, &document.Analyzable, &document.Version,
+		&document.PageCount, &document.Encrypted, &metadata, &document.LinkCount,
+	)
+
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			// Only URLs that returned something other than HTML have document details
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	if err := json.Unmarshal(metadata, &document.Metadata); err != nil {
+		return nil, err
+	}
+
+	return document, nil
+}
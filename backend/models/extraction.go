@@ -0,0 +1,298 @@
+package models
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/sykell/website-analyzer/database"
+)
+
+// ExtractionRule describes a data point to extract from analyzed pages. Rules
+// without a website apply to every website of the account, and website rules
+// override account rules with the same name.
+type ExtractionRule struct {
+	ID           int       `json:"id"`
+	UserID       int       `json:"-"`
+	WebsiteID    *int      `json:"website_id"` // Nil for account rules
+	Name         string    `json:"name" binding:"required,max=100"`
+	SelectorType string    `json:"selector_type" binding:"required,oneof=css xpath"`
+	Selector     string    `json:"selector" binding:"required,max=1024"`
+	Extract      string    `json:"extract" binding:"omitempty,oneof=text html attribute count"` // Defaults to text
+	Attribute    string    `json:"attribute" binding:"required_if=Extract attribute,max=100"`
+	Multiple     bool      `json:"multiple"`                                                          // Keep every match instead of the first
+	Regex        string    `json:"regex" binding:"max=512"`                                           // Keeps the first group, or the whole match, of each value
+	DataType     string    `json:"data_type" binding:"omitempty,oneof=string number integer boolean"` // Defaults to string
+	CreatedAt    time.Time `json:"created_at"`
+	UpdatedAt    time.Time `json:"updated_at"`
+}
+
+// What extraction rules extract from the matched elements
+const (
+	ExtractText      = "text"
+	ExtractHTML      = "html"
+	ExtractAttribute = "attribute"
+	ExtractCount     = "count"
+)
+
+// Types of extracted values
+const (
+	DataTypeString  = "string"
+	DataTypeNumber  = "number"
+	DataTypeInteger = "integer"
+	DataTypeBoolean = "boolean"
+)
+
+// ErrDuplicateRuleName is returned when a rule name is already used in the same scope
+var ErrDuplicateRuleName = errors.New("an extraction rule with this name already exists")
+
+// ExtractionResult represents the typed value an extraction rule produced for a website
+type ExtractionResult struct {
+	ID        int         `json:"-"`
+	WebsiteID int         `json:"-"`
+	RuleName  string      `json:"rule_name"`
+	DataType  string      `json:"data_type"`
+	Value     interface{} `json:"value"` // A value, a list of values for rules with multiple matches, or nil
+	Error     string      `json:"error,omitempty"`
+}
+
+const extractionRuleColumns = "id, user_id, website_id, name, selector_type, selector, extract, attribute, multiple, regex, data_type, created_at, updated_at"
+
+// scanExtractionRules reads extraction rules from query rows
+func scanExtractionRules(rows *sql.Rows) ([]ExtractionRule, error) {
+	rules := []ExtractionRule{}
+	for rows.Next() {
+		var rule ExtractionRule
+		var websiteID sql.NullInt64
+		err := rows.Scan(
+			&rule.ID, &rule.UserID, &websiteID, &rule.Name, &rule.SelectorType, &rule.Selector, &rule.Extract,
+			&rule.Attribute, &rule.Multiple, &rule.Regex, &rule.DataType, &rule.CreatedAt, &rule.UpdatedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		if websiteID.Valid {
+			id := int(websiteID.Int64)
+			rule.WebsiteID = &id
+		}
+		rules = append(rules, rule)
+	}
+	return rules, rows.Err()
+}
+
+// GetExtractionRules retrieves the rules of an account, or of one of its
+// websites when websiteID is not nil
+func GetExtractionRules(userID int, websiteID *int) ([]ExtractionRule, error) {
+	query := "SELECT " + extractionRuleColumns + " FROM extraction_rules WHERE user_id = ? AND website_id IS NULL ORDER BY name"
+	args := []interface{}{userID}
+	if websiteID != nil {
+		query = "SELECT " + extractionRuleColumns + " FROM extraction_rules WHERE user_id = ? AND website_id = ? ORDER BY name"
+		args = append(args, *websiteID)
+	}
+
+	rows, err := database.DB.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	return scanExtractionRules(rows)
+}
+
+// GetEffectiveExtractionRules retrieves the rules applied when analyzing a
+// website: its own rules and the account rules they don't override
+func GetEffectiveExtractionRules(userID, websiteID int) ([]ExtractionRule, error) {
+	rows, err := database.DB.Query(
+		"SELECT "+extractionRuleColumns+" FROM extraction_rules "+
+			"WHERE user_id = ? AND (website_id IS NULL OR website_id = ?) ORDER BY website_id IS NULL, name",
+		userID, websiteID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	rules, err := scanExtractionRules(rows)
+	if err != nil {
+		return nil, err
+	}
+
+	// Website rules come first, so later rules with the same name are account rules
+	effective := []ExtractionRule{}
+	seen := map[string]bool{}
+	for _, rule := range rules {
+		if !seen[rule.Name] {
+			seen[rule.Name] = true
+			effective = append(effective, rule)
+		}
+	}
+	return effective, nil
+}
+
+// GetExtractionRuleByID retrieves an extraction rule by ID
+func GetExtractionRuleByID(id int) (*ExtractionRule, error) {
+	rows, err := database.DB.Query("SELECT "+extractionRuleColumns+" FROM extraction_rules WHERE id = ?", id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	rules, err := scanExtractionRules(rows)
+	if err != nil {
+		return nil, err
+	}
+	if len(rules) == 0 {
+		return nil, errors.New("extraction rule not found")
+	}
+	return &rules[0], nil
+}
+
+// ruleNameTaken checks if another rule in the same scope already has the name
+func ruleNameTaken(rule *ExtractionRule) (bool, error) {
+	var count int
+	var err error
+	if rule.WebsiteID == nil {
+		err = database.DB.QueryRow(
+			"SELECT COUNT(*) FROM extraction_rules WHERE user_id = ? AND website_id IS NULL AND name = ? AND id != ?",
+			rule.UserID, rule.Name, rule.ID,
+		).Scan(&count)
+	} else {
+		err = database.DB.QueryRow(
+			"SELECT COUNT(*) FROM extraction_rules WHERE user_id = ? AND website_id = ? AND name = ? AND id != ?",
+			rule.UserID, *rule.WebsiteID, rule.Name, rule.ID,
+		).Scan(&count)
+	}
+	return count > 0, err
+}
+
+// applyRuleDefaults fills in the defaults of optional rule fields
+func applyRuleDefaults(rule *ExtractionRule) {
+	if rule.Extract == "" {
+		rule.Extract = ExtractText
+	}
+	if rule.DataType == "" {
+		rule.DataType = DataTypeString
+	}
+	if rule.Extract == ExtractCount {
+		rule.DataType = DataTypeInteger
+	}
+	if rule.Extract != ExtractAttribute {
+		rule.Attribute = ""
+	}
+}
+
+// CreateExtractionRule creates a new extraction rule
+func CreateExtractionRule(rule *ExtractionRule) error {
+	applyRuleDefaults(rule)
+	taken, err := ruleNameTaken(rule)
+	if err != nil {
+		return err
+	}
+	if taken {
+		return ErrDuplicateRuleName
+	}
+
+	result, err := database.DB.Exec(
+		"INSERT INTO extraction_rules (user_id, website_id, name, selector_type, selector, extract, attribute, multiple, regex, data_type) "+
+			"VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)",
+		rule.UserID, rule.WebsiteID, rule.Name, rule.SelectorType, rule.Selector, rule.Extract, rule.Attribute,
+		rule.Multiple, rule.Regex, rule.DataType,
+	)
+	if err != nil {
+		return err
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return err
+	}
+	rule.ID = int(id)
+	rule.CreatedAt = time.Now()
+	rule.UpdatedAt = time.Now()
+
+	return nil
+}
+
+// UpdateExtractionRule replaces the definition of an extraction rule
+func UpdateExtractionRule(rule *ExtractionRule) error {
+	applyRuleDefaults(rule)
+	taken, err := ruleNameTaken(rule)
+	if err != nil {
+		return err
+	}
+	if taken {
+		return ErrDuplicateRuleName
+	}
+
+	_, err = database.DB.Exec(
+		"UPDATE extraction_rules SET name = ?, selector_type = ?, selector = ?, extract = ?, attribute = ?, multiple = ?, "+
+			"regex = ?, data_type = ? WHERE id = ?",
+		rule.Name, rule.SelectorType, rule.Selector, rule.Extract, rule.Attribute, rule.Multiple, rule.Regex,
+		rule.DataType, rule.ID,
+	)
+	if err != nil {
+		return err
+	}
+	rule.UpdatedAt = time.Now()
+
+	return nil
+}
+
+// DeleteExtractionRule deletes an extraction rule
+func DeleteExtractionRule(id int) error {
+	_, err := database.DB.Exec("DELETE FROM extraction_rules WHERE id = ?", id)
+	return err
+}
+
+// saveExtractionResults replaces the extraction results of a website
+func saveExtractionResults(tx *sql.Tx, websiteID int, results []ExtractionResult) error {
+	_, err := tx.Exec("DELETE FROM extraction_results WHERE website_id = ?", websiteID)
+	if err != nil {
+		return err
+	}
+
+	for _, result := range results {
+		value, err := json.Marshal(result.Value)
+		if err != nil {
+			return err
+		}
+		_, err = tx.Exec(
+			"INSERT INTO extraction_results (website_id, rule_name, data_type, value, error) VALUES (?, ?, ?, ?, ?)",
+			websiteID, result.RuleName, result.DataType, value, result.Error,
+		)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// GetExtractionResults retrieves the extraction results of a website
+func GetExtractionResults(websiteID int) ([]ExtractionResult, error) {
+	rows, err := database.DB.Query(
+		"SELECT id, rule_name, data_type, value, error FROM extraction_results WHERE website_id = ? ORDER BY rule_name",
+		websiteID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	results := []ExtractionResult{}
+	for rows.Next() {
+		var result ExtractionResult
+		var value []byte
+		result.WebsiteID = websiteID
+		if err := rows.Scan(&result.ID, &result.RuleName, &result.DataType, &value, &result.Error); err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal(value, &result.Value); err != nil {
+			return nil, err
+		}
+		results = append(results, result)
+	}
+
+	return results, nil
+}
@@ -0,0 +1,98 @@
+package models
+
+import (
+	"database/sql"
+	"encoding/json"
+	"time"
+
+	"github.com/sykell/website-analyzer/database"
+)
+
+// Feed represents an RSS or Atom feed of the analyzed website
+type Feed struct {
+	ID               int          `json:"-"`
+	WebsiteID        int          `json:"-"`
+	URL              string       `json:"url"`
+	Source           string       `json:"source"` // "link" when declared by the page, "path" when found at a common path
+	Format           string       `json:"format"` // "rss", "atom" or "rdf", empty if the response isn't a feed
+	Version          string       `json:"version,omitempty"`
+	Title            string       `json:"title"`
+	StatusCode       int          `json:"status_code"` // 0 if the feed could not be fetched
+	Valid            bool         `json:"valid"`
+	Errors           []string     `json:"errors"`
+	ItemCount        int          `json:"item_count"`
+	LastUpdated      *time.Time   `json:"last_updated,omitempty"`
+	SelfLink         string       `json:"self_link,omitempty"`
+	SelfLinkMismatch bool         `json:"self_link_mismatch"` // The self link doesn't point to the URL the feed was fetched from
+	BrokenItems      []BrokenLink `json:"broken_items"`       // Items whose links are broken
+}
+
+// saveFeeds replaces the feeds of a website
+func saveFeeds(tx *sql.Tx, websiteID int, feeds []Feed) error {
+	_, err := tx.Exec("DELETE FROM feeds WHERE website_id = ?", websiteID)
+	if err != nil {
+		return err
+	}
+
+	for _, feed := range feeds {
+		errs, err := json.Marshal(feed.Errors)
+		if err != nil {
+			return err
+		}
+		brokenItems, err := json.Marshal(feed.BrokenItems)
+		if err != nil {
+			return err
+		}
+		_, err = tx.Exec(
+			"INSERT INTO feeds (website_id, url, source, format, version, title, status_code, valid, errors, item_count, "+
+				"last_updated, self_link, self_link_mismatch, broken_items) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)",
+			websiteID, feed.URL, feed.Source, feed.Format, feed.Version, feed.Title, feed.StatusCode, feed.Valid, errs,
+			feed.ItemCount, feed.LastUpdated, feed.SelfLink, feed.SelfLinkMismatch, brokenItems,
+		)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// GetFeeds retrieves the feeds of a website
+func GetFeeds(websiteID int) ([]Feed, error) {
+	rows, err := database.DB.Query(
+		"SELECT id, url, source, format, version, title, status_code, valid, errors, item_count, last_updated, self_link, "+
+			"self_link_mismatch, broken_items FROM feeds WHERE website_id = ? ORDER BY id",
+		websiteID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	feeds := []Feed{}
+	for rows.Next() {
+		var feed Feed
+		var errs, brokenItems []byte
+		var lastUpdated sql.NullTime
+		feed.WebsiteID = websiteID
+		err := rows.Scan(
+			&feed.ID, &feed.URL, &feed.Source, &feed.Format, &feed.Version, &feed.Title, &feed.StatusCode, &feed.Valid,
+			&errs, &feed.ItemCount, &lastUpdated, &feed.SelfLink, &feed.SelfLinkMismatch, &brokenItems,
+		)
+		if err != nil {
+			return nil, err
+		}
+		if lastUpdated.Valid {
+			feed.LastUpdated = &lastUpdated.Time
+		}
+		if err := json.Unmarshal(errs, &feed.Errors); err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal(brokenItems, &feed.BrokenItems); err != nil {
+			return nil, err
+		}
+		feeds = append(feeds, feed)
+	}
+
+	return feeds, nil
+}
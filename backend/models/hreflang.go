@@ -0,0 +1,63 @@
+package models
+
+import (
+	"database/sql"
+
+	"github.com/sykell/website-analyzer/database"
+)
+
+// HreflangFinding represents a problem with the hreflang alternates of the analyzed page
+type HreflangFinding struct {
+	ID        int    `json:"-"`
+	WebsiteID int    `json:"-"`
+	Code      string `json:"code"`     // e.g. "missing_return_link", "invalid_hreflang" or "missing_x_default"
+	Severity  string `json:"severity"` // "error" or "warning"
+	Hreflang  string `json:"hreflang,omitempty"`
+	URL       string `json:"url,omitempty"`
+	Message   string `json:"message"`
+}
+
+// saveHreflangFindings replaces the hreflang findings of a website
+func saveHreflangFindings(tx *sql.Tx, websiteID int, findings []HreflangFinding) error {
+	_, err := tx.Exec("DELETE FROM hreflang_findings WHERE website_id = ?", websiteID)
+	if err != nil {
+		return err
+	}
+
+	for _, finding := range findings {
+		_, err = tx.Exec(
+			"INSERT INTO hreflang_findings (website_id, code, severity, hreflang, url, message) VALUES (?, ?, ?, ?, ?, ?)",
+			websiteID, finding.Code, finding.Severity, finding.Hreflang, finding.URL, finding.Message,
+		)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// GetHreflangFindings retrieves the hreflang findings of a website
+func GetHreflangFindings(websiteID int) ([]HreflangFinding, error) {
+	rows, err := database.DB.Query(
+		"SELECT id, code, severity, hreflang, url, message FROM hreflang_findings WHERE website_id = ? ORDER BY id",
+		websiteID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	findings := []HreflangFinding{}
+	for rows.Next() {
+		var finding HreflangFinding
+		finding.WebsiteID = websiteID
+		err := rows.Scan(&finding.ID, &finding.Code, &finding.Severity, &finding.Hreflang, &finding.URL, &finding.Message)
+		if err != nil {
+			return nil, err
+		}
+		findings = append(findings, finding)
+	}
+
+	return findings, nil
+}
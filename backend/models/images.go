@@ -0,0 +1,86 @@
+package models
+
+import (
+	"database/sql"
+	"encoding/json"
+
+	"github.com/sykell/website-analyzer/database"
+)
+
+// PageImage represents an image of the analyzed page with its intrinsic size, format and weight
+type PageImage struct {
+	ID            int      `json:"-"`
+	WebsiteID     int      `json:"-"`
+	URL           string   `json:"url"`
+	Alt           string   `json:"alt"`
+	HasAlt        bool     `json:"has_alt"`        // False if the alt attribute is missing, an empty alt marks a decorative image
+	Width         int      `json:"width"`          // From the width attribute, 0 if missing
+	Height        int      `json:"height"`         // From the height attribute, 0 if missing
+	NaturalWidth  int      `json:"natural_width"`  // Decoded from the image, 0 if unknown
+	NaturalHeight int      `json:"natural_height"` // Decoded from the image, 0 if unknown
+	Format        string   `json:"format"`         // e.g. "jpeg", "png", "gif", "webp", "avif" or "svg", empty if unknown
+	Size          int64    `json:"size"`           // Bytes
+	StatusCode    int      `json:"status_code"`    // 0 if the image could not be fetched or is a data URL
+	Loading       string   `json:"loading,omitempty"`
+	InPicture     bool     `json:"in_picture"`
+	Issues        []string `json:"issues"` // e.g. "missing_alt", "missing_dimensions", "oversized", "legacy_format", "missing_lazy_loading" or "broken"
+}
+
+// savePageImages replaces the image inventory of a website
+func savePageImages(tx *sql.Tx, websiteID int, images []PageImage) error {
+	_, err := tx.Exec("DELETE FROM page_images WHERE website_id = ?", websiteID)
+	if err != nil {
+		return err
+	}
+
+	for _, image := range images {
+		issues, err := json.Marshal(image.Issues)
+		if err != nil {
+			return err
+		}
+		_, err = tx.Exec(
+			"INSERT INTO page_images (website_id, url, alt, has_alt, width, height, natural_width, natural_height, format, "+
+				"size, status_code, loading, in_picture, issues) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)",
+			websiteID, image.URL, image.Alt, image.HasAlt, image.Width, image.Height, image.NaturalWidth, image.NaturalHeight,
+			image.Format, image.Size, image.StatusCode, image.Loading, image.InPicture, issues,
+		)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// GetPageImages retrieves the image inventory of a website
+func GetPageImages(websiteID int) ([]PageImage, error) {
+	rows, err := database.DB.Query(
+		"SELECT id, url, alt, has_alt, width, height, natural_width, natural_height, format, size, status_code, loading, "+
+			"in_picture, issues FROM page_images WHERE website_id = ? ORDER BY id",
+		websiteID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	images := []PageImage{}
+	for rows.Next() {
+		var image PageImage
+		var issues []byte
+		image.WebsiteID = websiteID
+		err := rows.Scan(
+			&image.ID, &image.URL, &image.Alt, &image.HasAlt, &image.Width, &image.Height, &image.NaturalWidth,
+			&image.NaturalHeight, &image.Format, &image.Size, &image.StatusCode, &image.Loading, &image.InPicture, &issues,
+		)
+		if err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal(issues, &image.Issues); err != nil {
+			return nil, err
+		}
+		images = append(images, image)
+	}
+
+	return images, nil
+}
@@ -0,0 +1,216 @@
+package models
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/xml"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/sykell/website-analyzer/database"
+)
+
+// LinkGraph represents the internal link graph of a website
+type LinkGraph struct {
+	Nodes []GraphNode `json:"nodes"`
+	Edges []GraphEdge `json:"edges"`
+}
+
+// GraphNode represents a page of the internal link graph
+type GraphNode struct {
+	ID         int     `json:"-"`
+	WebsiteID  int     `json:"-"`
+	URL        string  `json:"url"`
+	StatusCode int     `json:"status_code"`
+	Depth      int     `json:"depth"` // Clicks from the start URL, -1 for orphan candidates
+	InDegree   int     `json:"in_degree"`
+	OutDegree  int     `json:"out_degree"`
+	PageRank   float64 `json:"pagerank"`
+	Crawled    bool    `json:"crawled"` // False for pages beyond the page limit
+	Orphan     bool    `json:"orphan"`  // Listed in the sitemap but not linked from any crawled page
+}
+
+// GraphEdge represents an internal link between two pages
+type GraphEdge struct {
+	ID         int    `json:"-"`
+	WebsiteID  int    `json:"-"`
+	Source     string `json:"source"`
+	Target     string `json:"target"`
+	AnchorText string `json:"anchor_text"`
+	Rel        string `json:"rel,omitempty"`
+}
+
+// saveLinkGraph replaces the link graph of a website
+func saveLinkGraph(tx *sql.Tx, websiteID int, graph *LinkGraph) error {
+	_, err := tx.Exec("DELETE FROM graph_nodes WHERE website_id = ?", websiteID)
+	if err != nil {
+		return err
+	}
+	_, err = tx.Exec("DELETE FROM graph_edges WHERE website_id = ?", websiteID)
+	if err != nil {
+		return err
+	}
+
+	for _, node := range graph.Nodes {
+		_, err = tx.Exec(
+			"INSERT INTO graph_nodes (website_id, url, status_code, depth, in_degree, out_degree, pagerank, crawled, orphan) "+
+				"VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)",
+			websiteID, node.URL, node.StatusCode, node.Depth, node.InDegree, node.OutDegree, node.PageRank,
+			node.Crawled, node.Orphan,
+		)
+		if err != nil {
+			return err
+		}
+	}
+	for _, edge := range graph.Edges {
+		_, err = tx.Exec(
+			"INSERT INTO graph_edges (website_id, source_url, target_url, anchor_text, rel) VALUES (?, ?, ?, ?, ?)",
+			websiteID, edge.Source, edge.Target, edge.AnchorText, edge.Rel,
+		)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// GetLinkGraph retrieves the link graph of a website
+func GetLinkGraph(websiteID int) (*LinkGraph, error) {
+	graph := &LinkGraph{Nodes: []GraphNode{}, Edges: []GraphEdge{}}
+
+	rows, err := database.DB.Query(
+		"SELECT id, url, status_code, depth, in_degree, out_degree, pagerank, crawled, orphan "+
+			"FROM graph_nodes WHERE website_id = ? ORDER BY pagerank DESC, url",
+		websiteID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var node GraphNode
+		node.WebsiteID = websiteID
+		err := rows.Scan(
+			&node.ID, &node.URL, &node.StatusCode, &node.Depth, &node.InDegree, &node.OutDegree, &node.PageRank,
+			&node.Crawled, &node.Orphan,
+		)
+		if err != nil {
+			return nil, err
+		}
+		graph.Nodes = append(graph.Nodes, node)
+	}
+
+	edgeRows, err := database.DB.Query(
+		"SELECT id, source_url, target_url, anchor_text, rel FROM graph_edges WHERE website_id = ? ORDER BY id",
+		websiteID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer edgeRows.Close()
+
+	for edgeRows.Next() {
+		var edge GraphEdge
+		edge.WebsiteID = websiteID
+		err := edgeRows.Scan(&edge.ID, &edge.Source, &edge.Target, &edge.AnchorText, &edge.Rel)
+		if err != nil {
+			return nil, err
+		}
+		graph.Edges = append(graph.Edges, edge)
+	}
+
+	return graph, nil
+}
+
+// GraphML renders the graph in the GraphML format
+func (g *LinkGraph) GraphML() []byte {
+	var buf bytes.Buffer
+	buf.WriteString(xml.Header)
+	buf.WriteString(`<graphml xmlns="http://graphml.graphdrawing.org/xmlns">` + "\n")
+
+	// Declare the node and edge attributes
+	keys := []struct{ id, domain, name, kind string }{
+		{"url", "node", "url", "string"},
+		{"status", "node", "status_code", "int"},
+		{"depth", "node", "depth", "int"},
+		{"in", "node", "in_degree", "int"},
+		{"out", "node", "out_degree", "int"},
+		{"pr", "node", "pagerank", "double"},
+		{"crawled", "node", "crawled", "boolean"},
+		{"orphan", "node", "orphan", "boolean"},
+		{"anchor", "edge", "anchor_text", "string"},
+		{"rel", "edge", "rel", "string"},
+	}
+	for _, key := range keys {
+		fmt.Fprintf(&buf, `  <key id="%s" for="%s" attr.name="%s" attr.type="%s"/>`+"\n", key.id, key.domain, key.name, key.kind)
+	}
+
+	buf.WriteString(`  <graph id="links" edgedefault="directed">` + "\n")
+	ids := map[string]string{}
+	for i, node := range g.Nodes {
+		id := "n" + strconv.Itoa(i)
+		ids[node.URL] = id
+		fmt.Fprintf(&buf, `    <node id="%s">`+"\n", id)
+		writeGraphMLData(&buf, "url", node.URL)
+		writeGraphMLData(&buf, "status", strconv.Itoa(node.StatusCode))
+		writeGraphMLData(&buf, "depth", strconv.Itoa(node.Depth))
+		writeGraphMLData(&buf, "in", strconv.Itoa(node.InDegree))
+		writeGraphMLData(&buf, "out", strconv.Itoa(node.OutDegree))
+		writeGraphMLData(&buf, "pr", strconv.FormatFloat(node.PageRank, 'g', -1, 64))
+		writeGraphMLData(&buf, "crawled", strconv.FormatBool(node.Crawled))
+		writeGraphMLData(&buf, "orphan", strconv.FormatBool(node.Orphan))
+		buf.WriteString("    </node>\n")
+	}
+	for i, edge := range g.Edges {
+		source, ok := ids[edge.Source]
+		target, ok2 := ids[edge.Target]
+		if !ok || !ok2 {
+			continue
+		}
+		fmt.Fprintf(&buf, `    <edge id="e%d" source="%s" target="%s">`+"\n", i, source, target)
+		writeGraphMLData(&buf, "anchor", edge.AnchorText)
+		if edge.Rel != "" {
+			writeGraphMLData(&buf, "rel", edge.Rel)
+		}
+		buf.WriteString("    </edge>\n")
+	}
+	buf.WriteString("  </graph>\n</graphml>\n")
+
+	return buf.Bytes()
+}
+
+// writeGraphMLData writes an escaped GraphML data element
+func writeGraphMLData(buf *bytes.Buffer, key, value string) {
+	fmt.Fprintf(buf, `      <data key="%s">`, key)
+	xml.EscapeText(buf, []byte(value))
+	buf.WriteString("</data>\n")
+}
+
+// DOT renders the graph in the Graphviz DOT format
+func (g *LinkGraph) DOT() string {
+	var sb strings.Builder
+	sb.WriteString("digraph links {\n")
+	for _, node := range g.Nodes {
+		fmt.Fprintf(&sb, "  %s [depth=%d, pagerank=%.6f", dotQuote(node.URL), node.Depth, node.PageRank)
+		if node.Orphan {
+			sb.WriteString(", style=dashed")
+		}
+		sb.WriteString("];\n")
+	}
+	for _, edge := range g.Edges {
+		fmt.Fprintf(&sb, "  %s -> %s [label=%s];\n", dotQuote(edge.Source), dotQuote(edge.Target), dotQuote(edge.AnchorText))
+	}
+	sb.WriteString("}\n")
+	return sb.String()
+}
+
+// dotQuote quotes a string as a DOT identifier
+func dotQuote(value string) string {
+	value = strings.ReplaceAll(value, `\`, `\\`)
+	value = strings.ReplaceAll(value, `"`, `\"`)
+	value = strings.ReplaceAll(value, "\n", `\n`)
+	return `"` + value + `"`
+}
@@ -0,0 +1,63 @@
+package models
+
+import (
+	"database/sql"
+
+	"github.com/sykell/website-analyzer/database"
+)
+
+// MixedContentItem represents an http:// subresource referenced by an HTTPS page
+type MixedContentItem struct {
+	ID             int    `json:"-"`
+	WebsiteID      int    `json:"-"`
+	URL            string `json:"url"`
+	Tag            string `json:"tag"`
+	Attribute      string `json:"attribute"`
+	ContentType    string `json:"content_type"` // "active" or "passive"
+	HTTPSAvailable bool   `json:"https_available"`
+}
+
+// saveMixedContent replaces the mixed content findings of a website
+func saveMixedContent(tx *sql.Tx, websiteID int, items []MixedContentItem) error {
+	_, err := tx.Exec("DELETE FROM mixed_content WHERE website_id = ?", websiteID)
+	if err != nil {
+		return err
+	}
+
+	for _, item := range items {
+		_, err = tx.Exec(
+			"INSERT INTO mixed_content (website_id, url, tag, attribute, content_type, https_available) VALUES (?, ?, ?, ?, ?, ?)",
+			websiteID, item.URL, item.Tag, item.Attribute, item.ContentType, item.HTTPSAvailable,
+		)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// GetMixedContent retrieves the mixed content findings of a website
+func GetMixedContent(websiteID int) ([]MixedContentItem, error) {
+	rows, err := database.DB.Query(
+		"SELECT id, url, tag, attribute, content_type, https_available FROM mixed_content WHERE website_id = ?",
+		websiteID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	items := []MixedContentItem{}
+	for rows.Next() {
+		var item MixedContentItem
+		item.WebsiteID = websiteID
+		err := rows.Scan(&item.ID, &item.URL, &item.Tag, &item.Attribute, &item.ContentType, &item.HTTPSAvailable)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+	}
+
+	return items, nil
+}
@@ -0,0 +1,75 @@
+package models
+
+import (
+	"database/sql"
+	"encoding/json"
+
+	"github.com/sykell/website-analyzer/database"
+)
+
+// PageLink represents a link of the analyzed page with its attributes
+type PageLink struct {
+	ID         int      `json:"-"`
+	WebsiteID  int      `json:"-"`
+	URL        string   `json:"url"`
+	AnchorText string   `json:"anchor_text"`
+	Rel        string   `json:"rel,omitempty"`
+	Target     string   `json:"target,omitempty"`
+	Hreflang   string   `json:"hreflang,omitempty"`
+	Bucket     string   `json:"bucket"` // "internal", "subdomain", "external" or the scheme of non-HTTP links
+	Issues     []string `json:"issues"` // e.g. "unsafe_target_blank", "internal_nofollow", "paid_without_sponsored", "generic_anchor"
+}
+
+// savePageLinks replaces the links of a website
+func savePageLinks(tx *sql.Tx, websiteID int, links []PageLink) error {
+	_, err := tx.Exec("DELETE FROM page_links WHERE website_id = ?", websiteID)
+	if err != nil {
+		return err
+	}
+
+	for _, link := range links {
+		issues, err := json.Marshal(link.Issues)
+		if err != nil {
+			return err
+		}
+		_, err = tx.Exec(
+			"INSERT INTO page_links (website_id, url, anchor_text, rel, target, hreflang, bucket, issues) "+
+				"VALUES (?, ?, ?, ?, ?, ?, ?, ?)",
+			websiteID, link.URL, link.AnchorText, link.Rel, link.Target, link.Hreflang, link.Bucket, issues,
+		)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// GetPageLinks retrieves the links of a website
+func GetPageLinks(websiteID int) ([]PageLink, error) {
+	rows, err := database.DB.Query(
+		"SELECT id, url, anchor_text, rel, target, hreflang, bucket, issues FROM page_links WHERE website_id = ? ORDER BY id",
+		websiteID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	links := []PageLink{}
+	for rows.Next() {
+		var link PageLink
+		var issues []byte
+		link.WebsiteID = websiteID
+		err := rows.Scan(&link.ID, &link.URL, &link.AnchorText, &link.Rel, &link.Target, &link.Hreflang, &link.Bucket, &issues)
+		if err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal(issues, &link.Issues); err != nil {
+			return nil, err
+		}
+		links = append(links, link)
+	}
+
+	return links, nil
+}
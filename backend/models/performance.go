@@ -0,0 +1,106 @@
+package models
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+
+	"github.com/sykell/website-analyzer/database"
+)
+
+// PerformanceReport represents the timings and weight of the analyzed page
+type PerformanceReport struct {
+	ID                  int                    `json:"-"`
+	WebsiteID           int                    `json:"-"`
+	DNSMs               int64                  `json:"dns_ms"`
+	ConnectMs           int64                  `json:"connect_ms"`
+	TLSHandshakeMs      int64                  `json:"tls_handshake_ms"`
+	TTFBMs              int64                  `json:"ttfb_ms"`
+	DownloadMs          int64                  `json:"download_ms"`
+	TotalMs             int64                  `json:"total_ms"`
+	CompressedSize      int64                  `json:"compressed_size"`
+	UncompressedSize    int64                  `json:"uncompressed_size"`
+	ContentEncoding     string                 `json:"content_encoding"`
+	UnsupportedEncoding bool                   `json:"unsupported_encoding"` // The page was analyzed without decoding its content encoding
+	GzipSupported       bool                   `json:"gzip_supported"`
+	BrotliSupported     bool                   `json:"brotli_supported"`
+	TotalWeight         int64                  `json:"total_weight"`
+	Weight              map[string]WeightEntry `json:"weight"`
+	OversizedAssets     []OversizedAsset       `json:"oversized_assets"`
+}
+
+// WeightEntry represents the number and transfer size of resources of one type
+type WeightEntry struct {
+	Count      int   `json:"count"`
+	Bytes      int64 `json:"bytes"`
+	LowerBound bool  `json:"lower_bound,omitempty"` // Some of the resources were only downloaded up to a limit
+}
+
+// OversizedAsset represents an asset larger than the budget for its type
+type OversizedAsset struct {
+	URL    string `json:"url"`
+	Type   string `json:"type"`
+	Bytes  int64  `json:"bytes"`
+	Budget int64  `json:"budget"`
+}
+
+// savePerformanceReport creates or replaces the performance report of a website
+func savePerformanceReport(tx *sql.Tx, report *PerformanceReport) error {
+	weight, err := json.Marshal(report.Weight)
+	if err != nil {
+		return err
+	}
+	oversized, err := json.Marshal(report.OversizedAssets)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(
+		"INSERT INTO performance_reports (website_id, dns_ms, connect_ms, tls_handshake_ms, ttfb_ms, download_ms, total_ms, "+
+			"compressed_size, uncompressed_size, content_encoding, unsupported_encoding, gzip_supported, brotli_supported, total_weight, "+
+			"weight, oversized_assets) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?) "+
+			"ON DUPLICATE KEY UPDATE dns_ms = VALUES(dns_ms), connect_ms = VALUES(connect_ms), tls_handshake_ms = VALUES(tls_handshake_ms), "+
+			"ttfb_ms = VALUES(ttfb_ms), download_ms = VALUES(download_ms), total_ms = VALUES(total_ms), "+
+			"compressed_size = VALUES(compressed_size), uncompressed_size = VALUES(uncompressed_size), "+
+			"content_encoding = VALUES(content_encoding), unsupported_encoding = VALUES(unsupported_encoding), gzip_supported = VALUES(gzip_supported), "+
+			"brotli_supported = VALUES(brotli_supported), total_weight = VALUES(total_weight), weight = VALUES(weight), "+
+			"oversized_assets = VALUES(oversized_assets)",
+		report.WebsiteID, report.DNSMs, report.ConnectMs, report.TLSHandshakeMs, report.TTFBMs, report.DownloadMs, report.TotalMs,
+		report.CompressedSize, report.UncompressedSize, report.ContentEncoding, report.UnsupportedEncoding, report.GzipSupported,
+		report.BrotliSupported, report.TotalWeight, weight, oversized,
+	)
+	return err
+}
+
+// GetPerformanceReport retrieves the performance report of a website
+func GetPerformanceReport(websiteID int) (*PerformanceReport, error) {
+	report := &PerformanceReport{WebsiteID: websiteID}
+	var weight, oversized []byte
+	err := database.DB.QueryRow(
+		"SELECT id, dns_ms, connect_ms, tls_handshake_ms, ttfb_ms, download_ms, total_ms, compressed_size, uncompressed_size, "+
+			"content_encoding, unsupported_encoding, gzip_supported, brotli_supported, total_weight, weight, oversized_assets "+
+			"FROM performance_reports WHERE website_id = ?",
+		websiteID,
+	).Scan(
+		&report.ID, &report.DNSMs, &report.ConnectMs, &report.TLSHandshakeMs, &report.TTFBMs, &report.DownloadMs, &report.TotalMs,
+		&report.CompressedSize, &report.UncompressedSize, &report.ContentEncoding, &report.UnsupportedEncoding, &report.GzipSupported,
+		&report.BrotliSupported, &report.TotalWeight, &weight, &oversized,
+	)
+
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			// No report until the website has been analyzed
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	if err := json.Unmarshal(weight, &report.Weight); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(oversized, &report.OversizedAssets); err != nil {
+		return nil, err
+	}
+
+	return report, nil
+}
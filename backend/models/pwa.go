@@ -0,0 +1,114 @@
+package models
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+
+	"github.com/sykell/website-analyzer/database"
+)
+
+// PWAReport represents the favicons, web app manifest and service worker of the analyzed page
+type PWAReport struct {
+	ID               int             `json:"-"`
+	WebsiteID        int             `json:"-"`
+	Favicons         []PWAIcon       `json:"favicons"`
+	ManifestURL      string          `json:"manifest_url,omitempty"`
+	ManifestStatus   int             `json:"manifest_status"` // 0 if there is no manifest or it could not be fetched
+	Manifest         *WebAppManifest `json:"manifest,omitempty"`
+	ServiceWorker    bool            `json:"service_worker"`
+	ServiceWorkerURL string          `json:"service_worker_url,omitempty"`
+	Checks           []PWACheck      `json:"checks"`
+	Installable      bool            `json:"installable"` // The checks browsers require to offer installing the app pass
+}
+
+// WebAppManifest represents the members of a web app manifest that are validated
+type WebAppManifest struct {
+	Name            string    `json:"name,omitempty"`
+	ShortName       string    `json:"short_name,omitempty"`
+	StartURL        string    `json:"start_url,omitempty"`
+	Display         string    `json:"display,omitempty"`
+	ThemeColor      string    `json:"theme_color,omitempty"`
+	BackgroundColor string    `json:"background_color,omitempty"`
+	Icons           []PWAIcon `json:"icons"`
+}
+
+// PWAIcon represents a favicon or manifest icon and whether it exists
+type PWAIcon struct {
+	URL        string `json:"url"`
+	Rel        string `json:"rel"` // e.g. "icon", "apple-touch-icon", "default" for /favicon.ico or "manifest"
+	Sizes      string `json:"sizes,omitempty"`
+	Type       string `json:"type,omitempty"`
+	StatusCode int    `json:"status_code"`
+	Exists     bool   `json:"exists"`
+	Width      int    `json:"width,omitempty"` // Decoded from the image when the format is known
+	Height     int    `json:"height,omitempty"`
+}
+
+// PWACheck represents an item of the PWA checklist
+type PWACheck struct {
+	Name    string `json:"name"` // e.g. "https", "favicon", "manifest_icons" or "service_worker"
+	Passed  bool   `json:"passed"`
+	Message string `json:"message"`
+}
+
+// savePWAReport creates or replaces the PWA report of a website
+func savePWAReport(tx *sql.Tx, report *PWAReport) error {
+	favicons, err := json.Marshal(report.Favicons)
+	if err != nil {
+		return err
+	}
+	manifest, err := json.Marshal(report.Manifest)
+	if err != nil {
+		return err
+	}
+	checks, err := json.Marshal(report.Checks)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(
+		"INSERT INTO pwa_reports (website_id, favicons, manifest_url, manifest_status, manifest, service_worker, "+
+			"service_worker_url, checks, installable) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?) "+
+			"ON DUPLICATE KEY UPDATE favicons = VALUES(favicons), manifest_url = VALUES(manifest_url), "+
+			"manifest_status = VALUES(manifest_status), manifest = VALUES(manifest), service_worker = VALUES(service_worker), "+
+			"service_worker_url = VALUES(service_worker_url), checks = VALUES(checks), installable = VALUES(installable)",
+		report.WebsiteID, favicons, report.ManifestURL, report.ManifestStatus, manifest, report.ServiceWorker,
+		report.ServiceWorkerURL, checks, report.Installable,
+	)
+	return err
+}
+
+// GetPWAReport retrieves the PWA report of a website
+func GetPWAReport(websiteID int) (*PWAReport, error) {
+	report := &PWAReport{WebsiteID: websiteID}
+	var favicons, manifest, checks []byte
+	err := database.DB.QueryRow(
+		"SELECT id, favicons, manifest_url, manifest_status, manifest, service_worker, service_worker_url, checks, installable "+
+			"FROM pwa_reports WHERE website_id = ?",
+		websiteID,
+	).Scan(
+		&report.ID, &favicons, &report.ManifestURL, &report.ManifestStatus, &manifest, &report.ServiceWorker,
+		&report.ServiceWorkerURL, &checks, &report.Installable,
+	)
+
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			// No report until the website has been analyzed
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	if err := json.Unmarshal(favicons, &report.Favicons); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(manifest, &report.Manifest); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(checks, &report.Checks); err != nil {
+		return nil, err
+	}
+
+	return report, nil
+}
@@ -0,0 +1,97 @@
+package models
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+
+	"github.com/sykell/website-analyzer/database"
+)
+
+// SecurityHeaderReport represents the security header audit of the analyzed page
+type SecurityHeaderReport struct {
+	ID        int               `json:"-"`
+	WebsiteID int               `json:"-"`
+	Grade     string            `json:"grade"`
+	Score     int               `json:"score"`
+	Headers   []SecurityHeader  `json:"headers"`
+	Cookies   []CookieAudit     `json:"cookies"`
+	Findings  []SecurityFinding `json:"findings"`
+}
+
+// SecurityHeader represents the presence and quality of a single security header
+type SecurityHeader struct {
+	Name    string `json:"name"`
+	Present bool   `json:"present"`
+	Value   string `json:"value,omitempty"`
+	Status  string `json:"status"` // "good", "weak" or "missing"
+}
+
+// CookieAudit represents the security flags of a cookie set by the page
+type CookieAudit struct {
+	Name     string `json:"name"`
+	Secure   bool   `json:"secure"`
+	HttpOnly bool   `json:"http_only"`
+	SameSite string `json:"same_site"`
+}
+
+// SecurityFinding represents a problem found by the security header audit
+type SecurityFinding struct {
+	Header   string `json:"header"`
+	Severity string `json:"severity"` // "high", "medium" or "low"
+	Message  string `json:"message"`
+}
+
+// saveSecurityHeaderReport creates or replaces the security header report of a website
+func saveSecurityHeaderReport(tx *sql.Tx, report *SecurityHeaderReport) error {
+	headers, err := json.Marshal(report.Headers)
+	if err != nil {
+		return err
+	}
+	cookies, err := json.Marshal(report.Cookies)
+	if err != nil {
+		return err
+	}
+	findings, err := json.Marshal(report.Findings)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(
+		"INSERT INTO security_header_reports (website_id, grade, score, headers, cookies, findings) VALUES (?, ?, ?, ?, ?, ?) "+
+			"ON DUPLICATE KEY UPDATE grade = VALUES(grade), score = VALUES(score), headers = VALUES(headers), "+
+			"cookies = VALUES(cookies), findings = VALUES(findings)",
+		report.WebsiteID, report.Grade, report.Score, headers, cookies, findings,
+	)
+	return err
+}
+
+// GetSecurityHeaderReport retrieves the security header report of a website
+func GetSecurityHeaderReport(websiteID int) (*SecurityHeaderReport, error) {
+	report := &SecurityHeaderReport{WebsiteID: websiteID}
+	var headers, cookies, findings []byte
+	err := database.DB.QueryRow(
+		"SELECT id, grade, score, headers, cookies, findings FROM security_header_reports WHERE website_id = ?",
+		websiteID,
+	).Scan(&report.ID, &report.Grade, &report.Score, &headers, &cookies, &findings)
+
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			// No report until the website has been analyzed
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	if err := json.Unmarshal(headers, &report.Headers); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(cookies, &report.Cookies); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(findings, &report.Findings); err != nil {
+		return nil, err
+	}
+
+	return report, nil
+}
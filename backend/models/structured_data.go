@@ -0,0 +1,107 @@
+package models
+
+import (
+	"database/sql"
+	"encoding/json"
+
+	"github.com/sykell/website-analyzer/database"
+)
+
+// StructuredDataItem represents a top-level structured data item found on a page.
+// Items from JSON-LD, Microdata and RDFa share the same graph shape: property
+// values are strings, nested items or lists of both, and nested items carry
+// their types under "@type".
+type StructuredDataItem struct {
+	ID                 int                    `json:"-"`
+	WebsiteID          int                    `json:"-"`
+	Format             string                 `json:"format"` // "json-ld", "microdata" or "rdfa"
+	Types              []string               `json:"types"`
+	ItemID             string                 `json:"item_id,omitempty"`
+	Properties         map[string]interface{} `json:"properties"`
+	Errors             []StructuredDataIssue  `json:"errors"`
+	Warnings           []StructuredDataIssue  `json:"warnings"`
+	RichResultEligible bool                   `json:"rich_result_eligible"`
+}
+
+// StructuredDataIssue represents a validation problem of a structured data item
+type StructuredDataIssue struct {
+	Type     string `json:"type,omitempty"` // The schema.org type the rule belongs to
+	Property string `json:"property,omitempty"`
+	Message  string `json:"message"`
+}
+
+// saveStructuredData replaces the structured data items of a website
+func saveStructuredData(tx *sql.Tx, websiteID int, items []StructuredDataItem) error {
+	_, err := tx.Exec("DELETE FROM structured_data WHERE website_id = ?", websiteID)
+	if err != nil {
+		return err
+	}
+
+	for _, item := range items {
+		types, err := json.Marshal(item.Types)
+		if err != nil {
+			return err
+		}
+		properties, err := json.Marshal(item.Properties)
+		if err != nil {
+			return err
+		}
+		errs, err := json.Marshal(item.Errors)
+		if err != nil {
+			return err
+		}
+		warnings, err := json.Marshal(item.Warnings)
+		if err != nil {
+			return err
+		}
+		_, err = tx.Exec(
+			"INSERT INTO structured_data (website_id, format, types, item_id, properties, errors, warnings, rich_result_eligible) "+
+				"VALUES (?, ?, ?, ?, ?, ?, ?, ?)",
+			websiteID, item.Format, types, item.ItemID, properties, errs, warnings, item.RichResultEligible,
+		)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// GetStructuredData retrieves the structured data items of a website
+func GetStructuredData(websiteID int) ([]StructuredDataItem, error) {
+	rows, err := database.DB.Query(
+		"SELECT id, format, types, item_id, properties, errors, warnings, rich_result_eligible "+
+			"FROM structured_data WHERE website_id = ? ORDER BY id",
+		websiteID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	items := []StructuredDataItem{}
+	for rows.Next() {
+		var item StructuredDataItem
+		var types, properties, errs, warnings []byte
+		item.WebsiteID = websiteID
+		err := rows.Scan(&item.ID, &item.Format, &types, &item.ItemID, &properties, &errs, &warnings, &item.RichResultEligible)
+		if err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal(types, &item.Types); err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal(properties, &item.Properties); err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal(errs, &item.Errors); err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal(warnings, &item.Warnings); err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+	}
+
+	return items, nil
+}
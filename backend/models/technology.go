@@ -0,0 +1,61 @@
+package models
+
+import (
+	"database/sql"
+
+	"github.com/sykell/website-analyzer/database"
+)
+
+// Technology represents a technology detected on a website
+type Technology struct {
+	ID        int    `json:"-"`
+	WebsiteID int    `json:"-"`
+	Name      string `json:"name"`
+	Category  string `json:"category"`
+	Version   string `json:"version,omitempty"`
+}
+
+// saveTechnologies replaces the detected technologies of a website
+func saveTechnologies(tx *sql.Tx, websiteID int, technologies []Technology) error {
+	_, err := tx.Exec("DELETE FROM technologies WHERE website_id = ?", websiteID)
+	if err != nil {
+		return err
+	}
+
+	for _, technology := range technologies {
+		_, err = tx.Exec(
+			"INSERT INTO technologies (website_id, name, category, version) VALUES (?, ?, ?, ?)",
+			websiteID, technology.Name, technology.Category, technology.Version,
+		)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// GetTechnologies retrieves the detected technologies of a website
+func GetTechnologies(websiteID int) ([]Technology, error) {
+	rows, err := database.DB.Query(
+		"SELECT id, name, category, version FROM technologies WHERE website_id = ? ORDER BY category, name",
+		websiteID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	technologies := []Technology{}
+	for rows.Next() {
+		var technology Technology
+		technology.WebsiteID = websiteID
+		err := rows.Scan(&technology.ID, &technology.Name, &technology.Category, &technology.Version)
+		if err != nil {
+			return nil, err
+		}
+		technologies = append(technologies, technology)
+	}
+
+	return technologies, nil
+}
@@ -0,0 +1,151 @@
+package models
+
+import (
+	"database/sql"
+	"encoding/json"
+	"time"
+
+	"github.com/sykell/website-analyzer/database"
+)
+
+// ThirdPartyDomain represents a third-party registrable domain referenced by the page
+type ThirdPartyDomain struct {
+	ID              int      `json:"-"`
+	WebsiteID       int      `json:"-"`
+	Domain          string   `json:"domain"`
+	Hosts           []string `json:"hosts"`
+	RequestCount    int      `json:"request_count"`
+	ResourceTypes   []string `json:"resource_types"`
+	IsTracker       bool     `json:"is_tracker"`
+	TrackerName     string   `json:"tracker_name,omitempty"`
+	TrackerCategory string   `json:"tracker_category,omitempty"`
+}
+
+// PageCookie represents a cookie set while loading the page or its resources
+type PageCookie struct {
+	ID         int        `json:"-"`
+	WebsiteID  int        `json:"-"`
+	Name       string     `json:"name"`
+	Domain     string     `json:"domain"`
+	Path       string     `json:"path"`
+	Secure     bool       `json:"secure"`
+	HttpOnly   bool       `json:"http_only"`
+	SameSite   string     `json:"same_site,omitempty"`
+	Expires    *time.Time `json:"expires,omitempty"` // Nil for session cookies
+	ThirdParty bool       `json:"third_party"`
+	SetBy      string     `json:"set_by"`
+}
+
+// saveThirdParties replaces the third-party domains and cookies of a website
+func saveThirdParties(tx *sql.Tx, websiteID int, domains []ThirdPartyDomain, cookies []PageCookie) error {
+	_, err := tx.Exec("DELETE FROM third_party_domains WHERE website_id = ?", websiteID)
+	if err != nil {
+		return err
+	}
+	for _, domain := range domains {
+		hosts, err := json.Marshal(domain.Hosts)
+		if err != nil {
+			return err
+		}
+		resourceTypes, err := json.Marshal(domain.ResourceTypes)
+		if err != nil {
+			return err
+		}
+		_, err = tx.Exec(
+			"INSERT INTO third_party_domains (website_id, domain, hosts, request_count, resource_types, is_tracker, "+
+				"tracker_name, tracker_category) VALUES (?, ?, ?, ?, ?, ?, ?, ?)",
+			websiteID, domain.Domain, hosts, domain.RequestCount, resourceTypes, domain.IsTracker,
+			domain.TrackerName, domain.TrackerCategory,
+		)
+		if err != nil {
+			return err
+		}
+	}
+
+	_, err = tx.Exec("DELETE FROM page_cookies WHERE website_id = ?", websiteID)
+	if err != nil {
+		return err
+	}
+	for _, cookie := range cookies {
+		_, err = tx.Exec(
+			"INSERT INTO page_cookies (website_id, name, domain, path, secure, http_only, same_site, expires, third_party, set_by) "+
+				"VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)",
+			websiteID, cookie.Name, cookie.Domain, cookie.Path, cookie.Secure, cookie.HttpOnly, cookie.SameSite,
+			cookie.Expires, cookie.ThirdParty, cookie.SetBy,
+		)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// GetThirdPartyDomains retrieves the third-party domains of a website
+func GetThirdPartyDomains(websiteID int) ([]ThirdPartyDomain, error) {
+	rows, err := database.DB.Query(
+		"SELECT id, domain, hosts, request_count, resource_types, is_tracker, tracker_name, tracker_category "+
+			"FROM third_party_domains WHERE website_id = ? ORDER BY request_count DESC, domain",
+		websiteID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	domains := []ThirdPartyDomain{}
+	for rows.Next() {
+		var domain ThirdPartyDomain
+		var hosts, resourceTypes []byte
+		domain.WebsiteID = websiteID
+		err := rows.Scan(
+			&domain.ID, &domain.Domain, &hosts, &domain.RequestCount, &resourceTypes, &domain.IsTracker,
+			&domain.TrackerName, &domain.TrackerCategory,
+		)
+		if err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal(hosts, &domain.Hosts); err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal(resourceTypes, &domain.ResourceTypes); err != nil {
+			return nil, err
+		}
+		domains = append(domains, domain)
+	}
+
+	return domains, nil
+}
+
+// GetPageCookies retrieves the cookies set by a website's page
+func GetPageCookies(websiteID int) ([]PageCookie, error) {
+	rows, err := database.DB.Query(
+		"SELECT id, name, domain, path, secure, http_only, same_site, expires, third_party, set_by "+
+			"FROM page_cookies WHERE website_id = ?",
+		websiteID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	cookies := []PageCookie{}
+	for rows.Next() {
+		var cookie PageCookie
+		var expires sql.NullTime
+		cookie.WebsiteID = websiteID
+		err := rows.Scan(
+			&cookie.ID, &cookie.Name, &cookie.Domain, &cookie.Path, &cookie.Secure, &cookie.HttpOnly,
+			&cookie.SameSite, &expires, &cookie.ThirdParty, &cookie.SetBy,
+		)
+		if err != nil {
+			return nil, err
+		}
+		if expires.Valid {
+			cookie.Expires = &expires.Time
+		}
+		cookies = append(cookies, cookie)
+	}
+
+	return cookies, nil
+}
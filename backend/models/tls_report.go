@@ -0,0 +1,152 @@
+package models
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"math"
+	"time"
+
+	"github.com/sykell/website-analyzer/database"
+)
+
+// TLSReport represents the HTTPS configuration of a website's host
+type TLSReport struct {
+	ID                int               `json:"-"`
+	WebsiteID         int               `json:"-"`
+	Host              string            `json:"host"`
+	TLSVersion        string            `json:"tls_version"`
+	CipherSuite       string            `json:"cipher_suite"`
+	OCSPStapled       bool              `json:"ocsp_stapled"`
+	NotAfter          *time.Time        `json:"not_after,omitempty"`
+	DaysUntilExpiry   int               `json:"days_until_expiry"`
+	Expired           bool              `json:"expired"`
+	SelfSigned        bool              `json:"self_signed"`
+	HostnameMismatch  bool              `json:"hostname_mismatch"`
+	Untrusted         bool              `json:"untrusted"`
+	VerificationError string            `json:"verification_error,omitempty"`
+	HTTPSRedirect     bool              `json:"https_redirect"`
+	ErrorMessage      string            `json:"error_message,omitempty"`
+	Certificates      []CertificateInfo `json:"certificates"`
+	InspectedAt       time.Time         `json:"inspected_at"`
+}
+
+// CertificateInfo represents a certificate in the chain presented by the server
+type CertificateInfo struct {
+	Subject            string    `json:"subject"`
+	Issuer             string    `json:"issuer"`
+	SANs               []string  `json:"sans,omitempty"`
+	SerialNumber       string    `json:"serial_number"`
+	SignatureAlgorithm string    `json:"signature_algorithm"`
+	KeyType            string    `json:"key_type"`
+	KeySize            int       `json:"key_size"`
+	IsCA               bool      `json:"is_ca"`
+	NotBefore          time.Time `json:"not_before"`
+	NotAfter           time.Time `json:"not_after"`
+	DaysUntilExpiry    int       `json:"days_until_expiry"`
+}
+
+// ExpiringCertificate represents a website whose certificate expires soon
+type ExpiringCertificate struct {
+	WebsiteID       int       `json:"website_id"`
+	URL             string    `json:"url"`
+	Host            string    `json:"host"`
+	NotAfter        time.Time `json:"not_after"`
+	DaysUntilExpiry int       `json:"days_until_expiry"`
+}
+
+// DaysUntil returns the number of whole days left until t, negative once it has passed
+func DaysUntil(t time.Time) int {
+	return int(math.Floor(time.Until(t).Hours() / 24))
+}
+
+// SaveTLSReport creates or replaces the TLS report of a website
+func SaveTLSReport(report *TLSReport) error {
+	certificates, err := json.Marshal(report.Certificates)
+	if err != nil {
+		return err
+	}
+
+	_, err = database.DB.Exec(
+		"INSERT INTO tls_reports (website_id, host, tls_version, cipher_suite, ocsp_stapled, not_after, expired, self_signed, "+
+			"hostname_mismatch, untrusted, verification_error, https_redirect, error_message, certificates, inspected_at) "+
+			"VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?) "+
+			"ON DUPLICATE KEY UPDATE host = VALUES(host), tls_version = VALUES(tls_version), cipher_suite = VALUES(cipher_suite), "+
+			"ocsp_stapled = VALUES(ocsp_stapled), not_after = VALUES(not_after), expired = VALUES(expired), "+
+			"self_signed = VALUES(self_signed), hostname_mismatch = VALUES(hostname_mismatch), untrusted = VALUES(untrusted), "+
+			"verification_error = VALUES(verification_error), https_redirect = VALUES(https_redirect), "+
+			"error_message = VALUES(error_message), certificates = VALUES(certificates), inspected_at = VALUES(inspected_at)",
+		report.WebsiteID, report.Host, report.TLSVersion, report.CipherSuite, report.OCSPStapled, report.NotAfter,
+		report.Expired, report.SelfSigned, report.HostnameMismatch, report.Untrusted, report.VerificationError,
+		report.HTTPSRedirect, report.ErrorMessage, certificates, report.InspectedAt,
+	)
+	return err
+}
+
+// GetTLSReport retrieves the TLS report of a website
+func GetTLSReport(websiteID int) (*TLSReport, error) {
+	report := &TLSReport{WebsiteID: websiteID}
+	var notAfter sql.NullTime
+	var certificates []byte
+	err := database.DB.QueryRow(
+		"SELECT id, host, tls_version, cipher_suite, ocsp_stapled, not_after, expired, self_signed, hostname_mismatch, "+
+			"untrusted, verification_error, https_redirect, error_message, certificates, inspected_at "+
+			"FROM tls_reports WHERE website_id = ?",
+		websiteID,
+	).Scan(
+		&report.ID, &report.Host, &report.TLSVersion, &report.CipherSuite, &report.OCSPStapled, &notAfter,
+		&report.Expired, &report.SelfSigned, &report.HostnameMismatch, &report.Untrusted, &report.VerificationError,
+		&report.HTTPSRedirect, &report.ErrorMessage, &certificates, &report.InspectedAt,
+	)
+
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			// No report until the website has been analyzed
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	if err := json.Unmarshal(certificates, &report.Certificates); err != nil {
+		return nil, err
+	}
+
+	// Expiry countdowns are computed on read so they never go stale
+	if notAfter.Valid {
+		report.NotAfter = &notAfter.Time
+		report.DaysUntilExpiry = DaysUntil(notAfter.Time)
+		report.Expired = report.DaysUntilExpiry < 0 || report.Expired
+	}
+	for i := range report.Certificates {
+		report.Certificates[i].DaysUntilExpiry = DaysUntil(report.Certificates[i].NotAfter)
+	}
+
+	return report, nil
+}
+
+// GetExpiringCertificates retrieves a user's websites whose certificate expires within the given days
+func GetExpiringCertificates(userID int, days int) ([]ExpiringCertificate, error) {
+	rows, err := database.DB.Query(
+		"SELECT w.id, w.url, t.host, t.not_after FROM tls_reports t JOIN websites w ON w.id = t.website_id "+
+			"WHERE w.user_id = ? AND t.not_after IS NOT NULL AND t.not_after < DATE_ADD(NOW(), INTERVAL ? DAY) "+
+			"ORDER BY t.not_after ASC",
+		userID, days,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	certificates := []ExpiringCertificate{}
+	for rows.Next() {
+		var certificate ExpiringCertificate
+		err := rows.Scan(&certificate.WebsiteID, &certificate.URL, &certificate.Host, &certificate.NotAfter)
+		if err != nil {
+			return nil, err
+		}
+		certificate.DaysUntilExpiry = DaysUntil(certificate.NotAfter)
+		certificates = append(certificates, certificate)
+	}
+
+	return certificates, nil
+}
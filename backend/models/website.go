@@ -2,6 +2,7 @@ package models
 
 import (
 	"database/sql"
+	"encoding/json"
 	"errors"
 	"time"
 
@@ -10,23 +11,48 @@ import (
 
 // Website represents a website that has been analyzed
 type Website struct {
-	ID           int            `json:"id"`
-	URL          string         `json:"url" binding:"required,url"`
-	Title        sql.NullString `json:"-"` // Use NullString to handle NULL values
-	TitleStr     string         `json:"title"` // For JSON marshalling
-	HTMLVersion  sql.NullString `json:"-"` // Use NullString to handle NULL values
-	HTMLVersionStr string        `json:"html_version"` // For JSON marshalling
-	CreatedAt    time.Time      `json:"created_at"`
-	UpdatedAt    time.Time      `json:"updated_at"`
-	UserID       int            `json:"user_id"`
-	Status       string         `json:"status"`
-	ErrorMessage sql.NullString `json:"-"` // Use NullString to handle NULL values
-	ErrorMessageStr string      `json:"error_message,omitempty"` // For JSON marshalling
-	
+	ID               int            `json:"id"`
+	URL              string         `json:"url" binding:"required,url"`
+	Title            sql.NullString `json:"-"`            // Use NullString to handle NULL values
+	TitleStr         string         `json:"title"`        // For JSON marshalling
+	HTMLVersion      sql.NullString `json:"-"`            // Use NullString to handle NULL values
+	HTMLVersionStr   string         `json:"html_version"` // For JSON marshalling
+	CreatedAt        time.Time      `json:"created_at"`
+	UpdatedAt        time.Time      `json:"updated_at"`
+	UserID           int            `json:"user_id"`
+	Status           string         `json:"status"`
+	ErrorMessage     sql.NullString `json:"-"`                        // Use NullString to handle NULL values
+	ErrorMessageStr  string         `json:"error_message,omitempty"`  // For JSON marshalling
+	SecurityGrade    sql.NullString `json:"-"`                        // Use NullString to handle NULL values
+	SecurityGradeStr string         `json:"security_grade,omitempty"` // For JSON marshalling
+	Health           sql.NullString `json:"-"`                        // Use NullString to handle NULL values
+	HealthStr        string         `json:"health,omitempty"`         // For JSON marshalling, "passing" or "failing" when the website has assertions
+
 	// Relations
-	HeadingCounts *HeadingCounts `json:"heading_counts,omitempty"`
-	LinkCounts    *LinkCounts    `json:"link_counts,omitempty"`
-	BrokenLinks   []BrokenLink   `json:"broken_links,omitempty"`
+	HeadingCounts    *HeadingCounts        `json:"heading_counts,omitempty"`
+	LinkCounts       *LinkCounts           `json:"link_counts,omitempty"`
+	BrokenLinks      []BrokenLink          `json:"broken_links,omitempty"`
+	SkippedLinks     []SkippedLink         `json:"skipped_links,omitempty"`
+	PageLinks        []PageLink            `json:"page_links,omitempty"`
+	TLSReport        *TLSReport            `json:"tls_report,omitempty"`
+	SecurityHeaders  *SecurityHeaderReport `json:"security_headers,omitempty"`
+	MixedContent     []MixedContentItem    `json:"mixed_content,omitempty"`
+	Performance      *PerformanceReport    `json:"performance,omitempty"`
+	CacheEntries     []CacheEntry          `json:"cache_entries,omitempty"`
+	Technologies     []Technology          `json:"technologies,omitempty"`
+	ThirdParties     []ThirdPartyDomain    `json:"third_parties,omitempty"`
+	Cookies          []PageCookie          `json:"cookies,omitempty"`
+	StructuredData   []StructuredDataItem  `json:"structured_data,omitempty"`
+	Extractions      []ExtractionResult    `json:"extractions,omitempty"`
+	AssertionResults []AssertionResult     `json:"assertion_results,omitempty"`
+	Content          *ContentReport        `json:"content,omitempty"`
+	HreflangFindings []HreflangFinding     `json:"hreflang_findings,omitempty"`
+	Images           []PageImage           `json:"images,omitempty"`
+	Document         *Document             `json:"document,omitempty"` // Only for URLs that returned something other than HTML
+	Feeds            []Feed                `json:"feeds,omitempty"`
+	PWA              *PWAReport            `json:"pwa,omitempty"`
+	WellKnownFiles   []WellKnownFile       `json:"well_known_files,omitempty"`
+	LinkGraph        *LinkGraph            `json:"-"` // Served by the graph endpoint since it can be large
 }
 
 // HeadingCounts represents the counts of heading tags in a website
@@ -43,11 +69,21 @@ type HeadingCounts struct {
 
 // LinkCounts represents the counts of links in a website
 type LinkCounts struct {
-	ID           int  `json:"-"`
-	WebsiteID    int  `json:"-"`
-	InternalLinks int  `json:"internal_links"`
-	ExternalLinks int  `json:"external_links"`
-	HasLoginForm bool `json:"has_login_form"`
+	ID                 int            `json:"-"`
+	WebsiteID          int            `json:"-"`
+	InternalLinks      int            `json:"internal_links"`
+	SubdomainLinks     int            `json:"subdomain_links"` // Other hosts of the same registrable domain outside the scope
+	ExternalLinks      int            `json:"external_links"`
+	SchemeCounts       map[string]int `json:"scheme_counts"` // Links per URL scheme, e.g. "https", "mailto", "tel"
+	NofollowLinks      int            `json:"nofollow_links"`
+	SponsoredLinks     int            `json:"sponsored_links"`
+	UGCLinks           int            `json:"ugc_links"`
+	UnsafeBlankTargets int            `json:"unsafe_blank_targets"` // External target=_blank links without noopener
+	InternalNofollow   int            `json:"internal_nofollow"`
+	UnmarkedPaidLinks  int            `json:"unmarked_paid_links"` // Affiliate and ad links without rel=sponsored
+	EmptyAnchors       int            `json:"empty_anchors"`
+	GenericAnchors     int            `json:"generic_anchors"`
+	HasLoginForm       bool           `json:"has_login_form"`
 }
 
 // BrokenLink represents a broken link found in a website
@@ -56,6 +92,16 @@ type BrokenLink struct {
 	WebsiteID  int    `json:"-"`
 	URL        string `json:"url"`
 	StatusCode int    `json:"status_code"`
+	Reason     string `json:"reason,omitempty"` // e.g. "http_error", "unreachable", "invalid_email", "no_mx_record"
+}
+
+// SkippedLink represents a link with a scheme the crawler does not check
+type SkippedLink struct {
+	ID             int    `json:"-"`
+	WebsiteID      int    `json:"-"`
+	URL            string `json:"url"`
+	Scheme         string `json:"scheme"`
+	Classification string `json:"classification"` // "script", "data", "file_transfer", "local_file" or "app"
 }
 
 // CreateWebsite creates a new website record in the database
@@ -98,11 +144,12 @@ func CreateWebsite(website *Website) (*Website, error) {
 func GetWebsiteByID(id int) (*Website, error) {
 	website := &Website{}
 	err := database.DB.QueryRow(
-		"SELECT id, url, title, html_version, created_at, updated_at, user_id, status, error_message FROM websites WHERE id = ?",
+		"SELECT id, url, title, html_version, created_at, updated_at, user_id, status, error_message, security_grade, health FROM websites WHERE id = ?",
 		id,
 	).Scan(
 		&website.ID, &website.URL, &website.Title, &website.HTMLVersion,
 		&website.CreatedAt, &website.UpdatedAt, &website.UserID, &website.Status, &website.ErrorMessage,
+		&website.SecurityGrade, &website.Health,
 	)
 
 	if err != nil {
@@ -122,6 +169,12 @@ func GetWebsiteByID(id int) (*Website, error) {
 	if website.ErrorMessage.Valid {
 		website.ErrorMessageStr = website.ErrorMessage.String
 	}
+	if website.SecurityGrade.Valid {
+		website.SecurityGradeStr = website.SecurityGrade.String
+	}
+	if website.Health.Valid {
+		website.HealthStr = website.Health.String
+	}
 
 	// Get the heading counts
 	website.HeadingCounts, _ = GetHeadingCounts(website.ID)
@@ -132,25 +185,116 @@ func GetWebsiteByID(id int) (*Website, error) {
 	// Get the broken links
 	website.BrokenLinks, _ = GetBrokenLinks(website.ID)
 
+	// Get the links skipped because of their scheme
+	website.SkippedLinks, _ = GetSkippedLinks(website.ID)
+
+	// Get the links with their attributes
+	website.PageLinks, _ = GetPageLinks(website.ID)
+
+	// Get the TLS report
+	website.TLSReport, _ = GetTLSReport(website.ID)
+
+	// Get the security header report
+	website.SecurityHeaders, _ = GetSecurityHeaderReport(website.ID)
+
+	// Get the mixed content findings
+	website.MixedContent, _ = GetMixedContent(website.ID)
+
+	// Get the performance report
+	website.Performance, _ = GetPerformanceReport(website.ID)
+
+	// Get the cache audit entries
+	website.CacheEntries, _ = GetCacheEntries(website.ID)
+
+	// Get the image inventory
+	website.Images, _ = GetPageImages(website.ID)
+
+	// Get the detected technologies
+	website.Technologies, _ = GetTechnologies(website.ID)
+
+	// Get the third-party inventory and cookies
+	website.ThirdParties, _ = GetThirdPartyDomains(website.ID)
+	website.Cookies, _ = GetPageCookies(website.ID)
+
+	// Get the structured data items
+	website.StructuredData, _ = GetStructuredData(website.ID)
+
+	// Get the results of the extraction rules
+	website.Extractions, _ = GetExtractionResults(website.ID)
+
+	// Get the hreflang findings
+	website.HreflangFindings, _ = GetHreflangFindings(website.ID)
+
+	// Get the RSS and Atom feeds
+	website.Feeds, _ = GetFeeds(website.ID)
+
+	// Get the PWA report
+	website.PWA, _ = GetPWAReport(website.ID)
+
+	// Get the well-known files
+	website.WellKnownFiles, _ = GetWellKnownFiles(website.ID)
+
+	// Get the document details
+	website.Document, _ = GetDocument(website.ID)
+
+	// Get the content report
+	website.Content, _ = GetContentReport(website.ID)
+
+	// Get the assertion results
+	website.AssertionResults, _ = GetAssertionResults(website.ID)
+
 	return website, nil
 }
 
-// GetWebsitesByUserID retrieves all websites for a user
-func GetWebsitesByUserID(userID int, page, pageSize int) ([]Website, int, error) {
+// WebsiteFilter narrows down the websites listed for a user
+type WebsiteFilter struct {
+	Technology string // Detected technology name, e.g. "WordPress"
+	Category   string // Detected technology category, e.g. "cms"
+	Health     string // Assertion health, "passing" or "failing"
+}
+
+// where builds the WHERE clause and arguments for the filter
+func (f WebsiteFilter) where(userID int) (string, []interface{}) {
+	clause := "WHERE user_id = ?"
+	args := []interface{}{userID}
+
+	if f.Technology != "" || f.Category != "" {
+		clause += " AND EXISTS (SELECT 1 FROM technologies t WHERE t.website_id = websites.id"
+		if f.Technology != "" {
+			clause += " AND t.name = ?"
+			args = append(args, f.Technology)
+		}
+		if f.Category != "" {
+			clause += " AND t.category = ?"
+			args = append(args, f.Category)
+		}
+		clause += ")"
+	}
+	if f.Health != "" {
+		clause += " AND health = ?"
+		args = append(args, f.Health)
+	}
+
+	return clause, args
+}
+
+// GetWebsitesByUserID retrieves all websites for a user matching the filter
+func GetWebsitesByUserID(userID int, filter WebsiteFilter, page, pageSize int) ([]Website, int, error) {
 	// Calculate the offset
 	offset := (page - 1) * pageSize
+	where, args := filter.where(userID)
 
 	// Get the total count
 	var totalCount int
-	err := database.DB.QueryRow("SELECT COUNT(*) FROM websites WHERE user_id = ?", userID).Scan(&totalCount)
+	err := database.DB.QueryRow("SELECT COUNT(*) FROM websites "+where, args...).Scan(&totalCount)
 	if err != nil {
 		return nil, 0, err
 	}
 
 	// Get the websites
 	rows, err := database.DB.Query(
-		"SELECT id, url, title, html_version, created_at, updated_at, user_id, status, error_message FROM websites WHERE user_id = ? ORDER BY created_at DESC LIMIT ? OFFSET ?",
-		userID, pageSize, offset,
+		"SELECT id, url, title, html_version, created_at, updated_at, user_id, status, error_message, security_grade, health FROM websites "+where+" ORDER BY created_at DESC LIMIT ? OFFSET ?",
+		append(args, pageSize, offset)...,
 	)
 	if err != nil {
 		return nil, 0, err
@@ -164,6 +308,7 @@ func GetWebsitesByUserID(userID int, page, pageSize int) ([]Website, int, error)
 		err := rows.Scan(
 			&website.ID, &website.URL, &website.Title, &website.HTMLVersion,
 			&website.CreatedAt, &website.UpdatedAt, &website.UserID, &website.Status, &website.ErrorMessage,
+			&website.SecurityGrade, &website.Health,
 		)
 		if err != nil {
 			return nil, 0, err
@@ -179,10 +324,17 @@ func GetWebsitesByUserID(userID int, page, pageSize int) ([]Website, int, error)
 		if website.ErrorMessage.Valid {
 			website.ErrorMessageStr = website.ErrorMessage.String
 		}
+		if website.SecurityGrade.Valid {
+			website.SecurityGradeStr = website.SecurityGrade.String
+		}
+		if website.Health.Valid {
+			website.HealthStr = website.Health.String
+		}
 
 		// Get the heading counts and link counts (can be done in a batch for better performance)
 		website.HeadingCounts, _ = GetHeadingCounts(website.ID)
 		website.LinkCounts, _ = GetLinkCounts(website.ID)
+		website.Technologies, _ = GetTechnologies(website.ID)
 
 		websites = append(websites, website)
 	}
@@ -210,8 +362,8 @@ func UpdateWebsiteData(website *Website) error {
 
 	// Update the website
 	_, err = tx.Exec(
-		"UPDATE websites SET title = ?, html_version = ?, status = ?, updated_at = NOW() WHERE id = ?",
-		website.TitleStr, website.HTMLVersionStr, website.Status, website.ID,
+		"UPDATE websites SET title = ?, html_version = ?, security_grade = ?, status = ?, updated_at = NOW() WHERE id = ?",
+		website.TitleStr, website.HTMLVersionStr, website.SecurityGradeStr, website.Status, website.ID,
 	)
 	if err != nil {
 		return err
@@ -233,32 +385,172 @@ func UpdateWebsiteData(website *Website) error {
 
 	// Update or insert the link counts
 	if website.LinkCounts != nil {
+		schemeCounts, err := json.Marshal(website.LinkCounts.SchemeCounts)
+		if err != nil {
+			return err
+		}
+		counts := website.LinkCounts
 		_, err = tx.Exec(
-			"INSERT INTO link_counts (website_id, internal_links, external_links, has_login_form) VALUES (?, ?, ?, ?) "+
-				"ON DUPLICATE KEY UPDATE internal_links = VALUES(internal_links), external_links = VALUES(external_links), has_login_form = VALUES(has_login_form)",
-			website.ID, website.LinkCounts.InternalLinks, website.LinkCounts.ExternalLinks, website.LinkCounts.HasLoginForm,
+			"INSERT INTO link_counts (website_id, internal_links, subdomain_links, external_links, scheme_counts, "+
+				"nofollow_links, sponsored_links, ugc_links, unsafe_blank_targets, internal_nofollow, unmarked_paid_links, "+
+				"empty_anchors, generic_anchors, has_login_form) "+
+				"VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?) "+
+				"ON DUPLICATE KEY UPDATE internal_links = VALUES(internal_links), subdomain_links = VALUES(subdomain_links), "+
+				"external_links = VALUES(external_links), scheme_counts = VALUES(scheme_counts), "+
+				"nofollow_links = VALUES(nofollow_links), sponsored_links = VALUES(sponsored_links), ugc_links = VALUES(ugc_links), "+
+				"unsafe_blank_targets = VALUES(unsafe_blank_targets), internal_nofollow = VALUES(internal_nofollow), "+
+				"unmarked_paid_links = VALUES(unmarked_paid_links), empty_anchors = VALUES(empty_anchors), "+
+				"generic_anchors = VALUES(generic_anchors), has_login_form = VALUES(has_login_form)",
+			website.ID, counts.InternalLinks, counts.SubdomainLinks, counts.ExternalLinks, schemeCounts,
+			counts.NofollowLinks, counts.SponsoredLinks, counts.UGCLinks, counts.UnsafeBlankTargets, counts.InternalNofollow,
+			counts.UnmarkedPaidLinks, counts.EmptyAnchors, counts.GenericAnchors, counts.HasLoginForm,
 		)
 		if err != nil {
 			return err
 		}
 	}
 
-	// Delete existing broken links and insert new ones
-	if len(website.BrokenLinks) > 0 {
-		_, err = tx.Exec("DELETE FROM broken_links WHERE website_id = ?", website.ID)
+	// Delete existing broken links and insert new ones, so an analysis
+	// without broken links clears those of the previous one
+	_, err = tx.Exec("DELETE FROM broken_links WHERE website_id = ?", website.ID)
+	if err != nil {
+		return err
+	}
+	for _, link := range website.BrokenLinks {
+		_, err = tx.Exec(
+			"INSERT INTO broken_links (website_id, url, status_code, reason) VALUES (?, ?, ?, ?)",
+			website.ID, link.URL, link.StatusCode, link.Reason,
+		)
 		if err != nil {
 			return err
 		}
+	}
+
+	// Replace the links with their attributes
+	if err := savePageLinks(tx, website.ID, website.PageLinks); err != nil {
+		return err
+	}
+
+	// Delete existing skipped links and insert new ones
+	_, err = tx.Exec("DELETE FROM skipped_links WHERE website_id = ?", website.ID)
+	if err != nil {
+		return err
+	}
+	for _, link := range website.SkippedLinks {
+		_, err = tx.Exec(
+			"INSERT INTO skipped_links (website_id, url, scheme, classification) VALUES (?, ?, ?, ?)",
+			website.ID, link.URL, link.Scheme, link.Classification,
+		)
+		if err != nil {
+			return err
+		}
+	}
+
+	// Update or insert the security header report
+	if website.SecurityHeaders != nil {
+		if err := saveSecurityHeaderReport(tx, website.SecurityHeaders); err != nil {
+			return err
+		}
+	}
+
+	// Replace the mixed content findings
+	if err := saveMixedContent(tx, website.ID, website.MixedContent); err != nil {
+		return err
+	}
+
+	// Update or insert the performance report
+	if website.Performance != nil {
+		if err := savePerformanceReport(tx, website.Performance); err != nil {
+			return err
+		}
+	} else if err := deleteWebsiteRows(tx, "performance_reports", website.ID); err != nil {
+		return err
+	}
+
+	// Replace the cache audit entries
+	if err := saveCacheEntries(tx, website.ID, website.CacheEntries); err != nil {
+		return err
+	}
+
+	// Replace the image inventory
+	if err := savePageImages(tx, website.ID, website.Images); err != nil {
+		return err
+	}
+
+	// Replace the detected technologies
+	if err := saveTechnologies(tx, website.ID, website.Technologies); err != nil {
+		return err
+	}
+
+	// Replace the third-party inventory and cookies
+	if err := saveThirdParties(tx, website.ID, website.ThirdParties, website.Cookies); err != nil {
+		return err
+	}
+
+	// Replace the structured data items
+	if err := saveStructuredData(tx, website.ID, website.StructuredData); err != nil {
+		return err
+	}
+
+	// Replace the results of the extraction rules
+	if err := saveExtractionResults(tx, website.ID, website.Extractions); err != nil {
+		return err
+	}
+
+	// Replace the hreflang findings
+	if err := saveHreflangFindings(tx, website.ID, website.HreflangFindings); err != nil {
+		return err
+	}
+
+	// Replace the RSS and Atom feeds
+	if err := saveFeeds(tx, website.ID, website.Feeds); err != nil {
+		return err
+	}
+
+	// Update or insert the PWA report
+	if website.PWA != nil {
+		if err := savePWAReport(tx, website.PWA); err != nil {
+			return err
+		}
+	} else if err := deleteWebsiteRows(tx, "pwa_reports", website.ID); err != nil {
+		return err
+	}
 
-		for _, link := range website.BrokenLinks {
-			_, err = tx.Exec(
-				"INSERT INTO broken_links (website_id, url, status_code) VALUES (?, ?, ?)",
-				website.ID, link.URL, link.StatusCode,
-			)
-			if err != nil {
-				return err
-			}
+	// Replace the well-known files
+	if err := saveWellKnownFiles(tx, website.ID, website.WellKnownFiles); err != nil {
+		return err
+	}
+
+	// Update or insert the document details
+	if website.Document != nil {
+		if err := saveDocument(tx, website.Document); err != nil {
+			return err
 		}
+	} else if err := deleteWebsiteRows(tx, "documents", website.ID); err != nil {
+		return err
+	}
+
+	// Update or insert the content report
+	if website.Content != nil {
+		if err := saveContentReport(tx, website.Content); err != nil {
+			return err
+		}
+	} else if err := deleteWebsiteRows(tx, "content_reports", website.ID); err != nil {
+		return err
+	}
+
+	// Replace the assertion results and health
+	if err := saveAssertionResults(tx, website.ID, website.AssertionResults, website.HealthStr); err != nil {
+		return err
+	}
+
+	// Replace the internal link graph
+	if website.LinkGraph != nil {
+		if err := saveLinkGraph(tx, website.ID, website.LinkGraph); err != nil {
+			return err
+		}
+	} else if err := saveLinkGraph(tx, website.ID, &LinkGraph{}); err != nil {
+		return err
 	}
 
 	// Commit the transaction
@@ -269,6 +561,13 @@ func UpdateWebsiteData(website *Website) error {
 	return nil
 }
 
+// deleteWebsiteRows removes the rows of a website from a table, for reports
+// that the latest analysis no longer produced
+func deleteWebsiteRows(tx *sql.Tx, table string, websiteID int) error {
+	_, err := tx.Exec("DELETE FROM "+table+" WHERE website_id = ?", websiteID)
+	return err
+}
+
 // DeleteWebsite deletes a website and all related data
 func DeleteWebsite(id int) error {
 	_, err := database.DB.Exec("DELETE FROM websites WHERE id = ?", id)
@@ -300,11 +599,17 @@ func GetHeadingCounts(websiteID int) (*HeadingCounts, error) {
 // GetLinkCounts retrieves the link counts for a website
 func GetLinkCounts(websiteID int) (*LinkCounts, error) {
 	linkCounts := &LinkCounts{WebsiteID: websiteID}
+	var schemeCounts []byte
 	err := database.DB.QueryRow(
-		"SELECT id, internal_links, external_links, has_login_form FROM link_counts WHERE website_id = ?",
+		"SELECT id, internal_links, subdomain_links, external_links, scheme_counts, nofollow_links, sponsored_links, ugc_links, "+
+			"unsafe_blank_targets, internal_nofollow, unmarked_paid_links, empty_anchors, generic_anchors, has_login_form "+
+			"FROM link_counts WHERE website_id = ?",
 		websiteID,
 	).Scan(
-		&linkCounts.ID, &linkCounts.InternalLinks, &linkCounts.ExternalLinks, &linkCounts.HasLoginForm,
+		&linkCounts.ID, &linkCounts.InternalLinks, &linkCounts.SubdomainLinks, &linkCounts.ExternalLinks, &schemeCounts,
+		&linkCounts.NofollowLinks, &linkCounts.SponsoredLinks, &linkCounts.UGCLinks, &linkCounts.UnsafeBlankTargets,
+		&linkCounts.InternalNofollow, &linkCounts.UnmarkedPaidLinks, &linkCounts.EmptyAnchors, &linkCounts.GenericAnchors,
+		&linkCounts.HasLoginForm,
 	)
 
 	if err != nil {
@@ -315,13 +620,20 @@ func GetLinkCounts(websiteID int) (*LinkCounts, error) {
 		return nil, err
 	}
 
+	// Counts saved before per-scheme counting was added have no scheme counts
+	if len(schemeCounts) > 0 {
+		if err := json.Unmarshal(schemeCounts, &linkCounts.SchemeCounts); err != nil {
+			return nil, err
+		}
+	}
+
 	return linkCounts, nil
 }
 
 // GetBrokenLinks retrieves all broken links for a website
 func GetBrokenLinks(websiteID int) ([]BrokenLink, error) {
 	rows, err := database.DB.Query(
-		"SELECT id, url, status_code FROM broken_links WHERE website_id = ?",
+		"SELECT id, url, status_code, reason FROM broken_links WHERE website_id = ?",
 		websiteID,
 	)
 	if err != nil {
@@ -333,7 +645,7 @@ func GetBrokenLinks(websiteID int) ([]BrokenLink, error) {
 	for rows.Next() {
 		var link BrokenLink
 		link.WebsiteID = websiteID
-		err := rows.Scan(&link.ID, &link.URL, &link.StatusCode)
+		err := rows.Scan(&link.ID, &link.URL, &link.StatusCode, &link.Reason)
 		if err != nil {
 			return nil, err
 		}
@@ -341,4 +653,29 @@ func GetBrokenLinks(websiteID int) ([]BrokenLink, error) {
 	}
 
 	return brokenLinks, nil
-} 
\ No newline at end of file
+}
+
+// GetSkippedLinks retrieves the links of a website that were skipped because of their scheme
+func GetSkippedLinks(websiteID int) ([]SkippedLink, error) {
+	rows, err := database.DB.Query(
+		"SELECT id, url, scheme, classification FROM skipped_links WHERE website_id = ?",
+		websiteID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	skippedLinks := []SkippedLink{}
+	for rows.Next() {
+		var link SkippedLink
+		link.WebsiteID = websiteID
+		err := rows.Scan(&link.ID, &link.URL, &link.Scheme, &link.Classification)
+		if err != nil {
+			return nil, err
+		}
+		skippedLinks = append(skippedLinks, link)
+	}
+
+	return skippedLinks, nil
+}
@@ -0,0 +1,111 @@
+package models
+
+import (
+	"database/sql"
+	"encoding/json"
+	"time"
+
+	"github.com/sykell/website-analyzer/database"
+)
+
+// WellKnownFile represents a well-known file of the analyzed site, e.g. security.txt or ads.txt
+type WellKnownFile struct {
+	ID          int                 `json:"-"`
+	WebsiteID   int                 `json:"-"`
+	Name        string              `json:"name"`        // "security.txt", "ads.txt", "app-ads.txt", "change-password" or "humans.txt"
+	URL         string              `json:"url"`         // Where the file was found, or first looked for
+	StatusCode  int                 `json:"status_code"` // 0 if the file could not be fetched
+	Found       bool                `json:"found"`
+	Fields      map[string][]string `json:"fields"`  // security.txt fields, ads.txt variables or humans.txt sections
+	Records     []AdsRecord         `json:"records"` // ads.txt and app-ads.txt seller records
+	RecordCount int                 `json:"record_count"`
+	Expires     *time.Time          `json:"expires,omitempty"`      // security.txt expiry
+	Signed      bool                `json:"signed"`                 // security.txt is OpenPGP cleartext signed
+	RedirectURL string              `json:"redirect_url,omitempty"` // Where change-password leads
+	Errors      []string            `json:"errors"`
+}
+
+// AdsRecord represents an authorized seller record of ads.txt or app-ads.txt
+type AdsRecord struct {
+	Domain                   string `json:"domain"`
+	AccountID                string `json:"account_id"`
+	Relationship             string `json:"relationship"` // "DIRECT" or "RESELLER"
+	CertificationAuthorityID string `json:"certification_authority_id,omitempty"`
+}
+
+// saveWellKnownFiles replaces the well-known files of a website
+func saveWellKnownFiles(tx *sql.Tx, websiteID int, files []WellKnownFile) error {
+	_, err := tx.Exec("DELETE FROM well_known_files WHERE website_id = ?", websiteID)
+	if err != nil {
+		return err
+	}
+
+	for _, file := range files {
+		fields, err := json.Marshal(file.Fields)
+		if err != nil {
+			return err
+		}
+		records, err := json.Marshal(file.Records)
+		if err != nil {
+			return err
+		}
+		errs, err := json.Marshal(file.Errors)
+		if err != nil {
+			return err
+		}
+		_, err = tx.Exec(
+			"INSERT INTO well_known_files (website_id, name, url, status_code, found, fields, records, record_count, "+
+				"expires, signed, redirect_url, errors) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)",
+			websiteID, file.Name, file.URL, file.StatusCode, file.Found, fields, records, file.RecordCount,
+			file.Expires, file.Signed, file.RedirectURL, errs,
+		)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// GetWellKnownFiles retrieves the well-known files of a website
+func GetWellKnownFiles(websiteID int) ([]WellKnownFile, error) {
+	rows, err := database.DB.Query(
+		"SELECT id, name, url, status_code, found, fields, records, record_count, expires, signed, redirect_url, errors "+
+			"FROM well_known_files WHERE website_id = ? ORDER BY id",
+		websiteID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	files := []WellKnownFile{}
+	for rows.Next() {
+		var file WellKnownFile
+		var fields, records, errs []byte
+		var expires sql.NullTime
+		file.WebsiteID = websiteID
+		err := rows.Scan(
+			&file.ID, &file.Name, &file.URL, &file.StatusCode, &file.Found, &fields, &records, &file.RecordCount,
+			&expires, &file.Signed, &file.RedirectURL, &errs,
+		)
+		if err != nil {
+			return nil, err
+		}
+		if expires.Valid {
+			file.Expires = &expires.Time
+		}
+		if err := json.Unmarshal(fields, &file.Fields); err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal(records, &file.Records); err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal(errs, &file.Errors); err != nil {
+			return nil, err
+		}
+		files = append(files, file)
+	}
+
+	return files, nil
+}
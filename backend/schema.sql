@@ -21,11 +21,14 @@ CREATE TABLE IF NOT EXISTS websites (
     created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
     updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
     user_id INT,
-    status ENUM('queued', 'running', 'done', 'error') DEFAULT 'queued',
+    status ENUM('queued', 'running', 'done', 'error', 'not_analyzable') DEFAULT 'queued',
     error_message TEXT,
+    security_grade VARCHAR(2),
+    health ENUM('passing', 'failing') NULL,
     FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE,
     INDEX idx_url (url(255)),
-    INDEX idx_status (status)
+    INDEX idx_status (status),
+    INDEX idx_health (health)
 );
 
 -- Create HeadingCounts table
@@ -46,7 +49,17 @@ CREATE TABLE IF NOT EXISTS link_counts (
     id INT AUTO_INCREMENT PRIMARY KEY,
     website_id INT NOT NULL,
     internal_links INT DEFAULT 0,
+    subdomain_links INT DEFAULT 0,
     external_links INT DEFAULT 0,
+    scheme_counts JSON NULL,
+    nofollow_links INT DEFAULT 0,
+    sponsored_links INT DEFAULT 0,
+    ugc_links INT DEFAULT 0,
+    unsafe_blank_targets INT DEFAULT 0,
+    internal_nofollow INT DEFAULT 0,
+    unmarked_paid_links INT DEFAULT 0,
+    empty_anchors INT DEFAULT 0,
+    generic_anchors INT DEFAULT 0,
     has_login_form BOOLEAN DEFAULT FALSE,
     FOREIGN KEY (website_id) REFERENCES websites(id) ON DELETE CASCADE
 );
@@ -57,10 +70,466 @@ CREATE TABLE IF NOT EXISTS broken_links (
     website_id INT NOT NULL,
     url VARCHAR(2048) NOT NULL,
     status_code INT NOT NULL,
+    reason VARCHAR(50) NOT NULL DEFAULT '',
     FOREIGN KEY (website_id) REFERENCES websites(id) ON DELETE CASCADE,
     INDEX idx_website_id (website_id)
 );
 
+-- Create PageLinks table
+CREATE TABLE IF NOT EXISTS page_links (
+    id INT AUTO_INCREMENT PRIMARY KEY,
+    website_id INT NOT NULL,
+    url VARCHAR(2048) NOT NULL,
+    anchor_text VARCHAR(1024) NOT NULL DEFAULT '',
+    rel VARCHAR(255) NOT NULL DEFAULT '',
+    target VARCHAR(50) NOT NULL DEFAULT '',
+    hreflang VARCHAR(35) NOT NULL DEFAULT '',
+    bucket VARCHAR(50) NOT NULL,
+    issues JSON NOT NULL,
+    FOREIGN KEY (website_id) REFERENCES websites(id) ON DELETE CASCADE,
+    INDEX idx_website_id (website_id)
+);
+
+-- Create SkippedLinks table
+CREATE TABLE IF NOT EXISTS skipped_links (
+    id INT AUTO_INCREMENT PRIMARY KEY,
+    website_id INT NOT NULL,
+    url VARCHAR(2048) NOT NULL,
+    scheme VARCHAR(50) NOT NULL,
+    classification VARCHAR(50) NOT NULL,
+    FOREIGN KEY (website_id) REFERENCES websites(id) ON DELETE CASCADE,
+    INDEX idx_website_id (website_id)
+);
+
+-- Create CrawlSettings table (settings are stored encrypted)
+CREATE TABLE IF NOT EXISTS crawl_settings (
+    id INT AUTO_INCREMENT PRIMARY KEY,
+    website_id INT NOT NULL UNIQUE,
+    settings TEXT NOT NULL,
+    FOREIGN KEY (website_id) REFERENCES websites(id) ON DELETE CASCADE
+);
+
+-- Create TLSReports table
+CREATE TABLE IF NOT EXISTS tls_reports (
+    id INT AUTO_INCREMENT PRIMARY KEY,
+    website_id INT NOT NULL UNIQUE,
+    host VARCHAR(255) NOT NULL,
+    tls_version VARCHAR(20) NOT NULL DEFAULT '',
+    cipher_suite VARCHAR(100) NOT NULL DEFAULT '',
+    ocsp_stapled BOOLEAN DEFAULT FALSE,
+    not_after DATETIME NULL,
+    expired BOOLEAN DEFAULT FALSE,
+    self_signed BOOLEAN DEFAULT FALSE,
+    hostname_mismatch BOOLEAN DEFAULT FALSE,
+    untrusted BOOLEAN DEFAULT FALSE,
+    verification_error TEXT NOT NULL,
+    https_redirect BOOLEAN DEFAULT FALSE,
+    error_message TEXT NOT NULL,
+    certificates JSON NOT NULL,
+    inspected_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (website_id) REFERENCES websites(id) ON DELETE CASCADE,
+    INDEX idx_not_after (not_after)
+);
+
+-- Create SecurityHeaderReports table
+CREATE TABLE IF NOT EXISTS security_header_reports (
+    id INT AUTO_INCREMENT PRIMARY KEY,
+    website_id INT NOT NULL UNIQUE,
+    grade VARCHAR(2) NOT NULL,
+    score INT DEFAULT 0,
+    headers JSON NOT NULL,
+    cookies JSON NOT NULL,
+    findings JSON NOT NULL,
+    FOREIGN KEY (website_id) REFERENCES websites(id) ON DELETE CASCADE
+);
+
+-- Create MixedContent table
+CREATE TABLE IF NOT EXISTS mixed_content (
+    id INT AUTO_INCREMENT PRIMARY KEY,
+    website_id INT NOT NULL,
+    url VARCHAR(2048) NOT NULL,
+    tag VARCHAR(50) NOT NULL,
+    attribute VARCHAR(50) NOT NULL,
+    content_type ENUM('active', 'passive') NOT NULL,
+    https_available BOOLEAN DEFAULT FALSE,
+    FOREIGN KEY (website_id) REFERENCES websites(id) ON DELETE CASCADE,
+    INDEX idx_website_id (website_id)
+);
+
+-- Create PerformanceReports table
+CREATE TABLE IF NOT EXISTS performance_reports (
+    id INT AUTO_INCREMENT PRIMARY KEY,
+    website_id INT NOT NULL UNIQUE,
+    dns_ms INT DEFAULT 0,
+    connect_ms INT DEFAULT 0,
+    tls_handshake_ms INT DEFAULT 0,
+    ttfb_ms INT DEFAULT 0,
+    download_ms INT DEFAULT 0,
+    total_ms INT DEFAULT 0,
+    compressed_size BIGINT DEFAULT 0,
+    uncompressed_size BIGINT DEFAULT 0,
+    content_encoding VARCHAR(50) NOT NULL DEFAULT '',
+    unsupported_encoding BOOLEAN DEFAULT FALSE,
+    gzip_supported BOOLEAN DEFAULT FALSE,
+    brotli_supported BOOLEAN DEFAULT FALSE,
+    total_weight BIGINT DEFAULT 0,
+    weight JSON NOT NULL,
+    oversized_assets JSON NOT NULL,
+    FOREIGN KEY (website_id) REFERENCES websites(id) ON DELETE CASCADE
+);
+
+-- Create CacheEntries table
+CREATE TABLE IF NOT EXISTS cache_entries (
+    id INT AUTO_INCREMENT PRIMARY KEY,
+    website_id INT NOT NULL,
+    url VARCHAR(2048) NOT NULL,
+    resource_type VARCHAR(20) NOT NULL,
+    cache_control VARCHAR(512) NOT NULL DEFAULT '',
+    expires VARCHAR(100) NOT NULL DEFAULT '',
+    etag VARCHAR(255) NOT NULL DEFAULT '',
+    last_modified VARCHAR(100) NOT NULL DEFAULT '',
+    vary VARCHAR(255) NOT NULL DEFAULT '',
+    content_encoding VARCHAR(50) NOT NULL DEFAULT '',
+    max_age INT DEFAULT -1,
+    conditional_status INT DEFAULT 0,
+    issues JSON NOT NULL,
+    FOREIGN KEY (website_id) REFERENCES websites(id) ON DELETE CASCADE,
+    INDEX idx_website_id (website_id)
+);
+
+-- Create Technologies table
+CREATE TABLE IF NOT EXISTS technologies (
+    id INT AUTO_INCREMENT PRIMARY KEY,
+    website_id INT NOT NULL,
+    name VARCHAR(100) NOT NULL,
+    category VARCHAR(50) NOT NULL,
+    version VARCHAR(50) NOT NULL DEFAULT '',
+    FOREIGN KEY (website_id) REFERENCES websites(id) ON DELETE CASCADE,
+    INDEX idx_website_id (website_id),
+    INDEX idx_name (name),
+    INDEX idx_category (category)
+);
+
+-- Create ThirdPartyDomains table
+CREATE TABLE IF NOT EXISTS third_party_domains (
+    id INT AUTO_INCREMENT PRIMARY KEY,
+    website_id INT NOT NULL,
+    domain VARCHAR(255) NOT NULL,
+    hosts JSON NOT NULL,
+    request_count INT DEFAULT 0,
+    resource_types JSON NOT NULL,
+    is_tracker BOOLEAN DEFAULT FALSE,
+    tracker_name VARCHAR(100) NOT NULL DEFAULT '',
+    tracker_category VARCHAR(50) NOT NULL DEFAULT '',
+    FOREIGN KEY (website_id) REFERENCES websites(id) ON DELETE CASCADE,
+    INDEX idx_website_id (website_id)
+);
+
+-- Create PageCookies table
+CREATE TABLE IF NOT EXISTS page_cookies (
+    id INT AUTO_INCREMENT PRIMARY KEY,
+    website_id INT NOT NULL,
+    name VARCHAR(255) NOT NULL,
+    domain VARCHAR(255) NOT NULL,
+    path VARCHAR(255) NOT NULL DEFAULT '/',
+    secure BOOLEAN DEFAULT FALSE,
+    http_only BOOLEAN DEFAULT FALSE,
+    same_site VARCHAR(10) NOT NULL DEFAULT '',
+    expires DATETIME NULL,
+    third_party BOOLEAN DEFAULT FALSE,
+    set_by VARCHAR(2048) NOT NULL,
+    FOREIGN KEY (website_id) REFERENCES websites(id) ON DELETE CASCADE,
+    INDEX idx_website_id (website_id)
+);
+
+-- Create StructuredData table
+CREATE TABLE IF NOT EXISTS structured_data (
+    id INT AUTO_INCREMENT PRIMARY KEY,
+    website_id INT NOT NULL,
+    format VARCHAR(20) NOT NULL,
+    types JSON NOT NULL,
+    item_id VARCHAR(2048) NOT NULL DEFAULT '',
+    properties JSON NOT NULL,
+    errors JSON NOT NULL,
+    warnings JSON NOT NULL,
+    rich_result_eligible BOOLEAN DEFAULT FALSE,
+    FOREIGN KEY (website_id) REFERENCES websites(id) ON DELETE CASCADE,
+    INDEX idx_website_id (website_id)
+);
+
+-- Create GraphNodes table
+CREATE TABLE IF NOT EXISTS graph_nodes (
+    id INT AUTO_INCREMENT PRIMARY KEY,
+    website_id INT NOT NULL,
+    url VARCHAR(2048) NOT NULL,
+    status_code INT DEFAULT 0,
+    depth INT DEFAULT 0,
+    in_degree INT DEFAULT 0,
+    out_degree INT DEFAULT 0,
+    pagerank DOUBLE DEFAULT 0,
+    crawled BOOLEAN DEFAULT FALSE,
+    orphan BOOLEAN DEFAULT FALSE,
+    FOREIGN KEY (website_id) REFERENCES websites(id) ON DELETE CASCADE,
+    INDEX idx_website_id (website_id)
+);
+
+-- Create GraphEdges table
+CREATE TABLE IF NOT EXISTS graph_edges (
+    id INT AUTO_INCREMENT PRIMARY KEY,
+    website_id INT NOT NULL,
+    source_url VARCHAR(2048) NOT NULL,
+    target_url VARCHAR(2048) NOT NULL,
+    anchor_text VARCHAR(1024) NOT NULL DEFAULT '',
+    rel VARCHAR(255) NOT NULL DEFAULT '',
+    FOREIGN KEY (website_id) REFERENCES websites(id) ON DELETE CASCADE,
+    INDEX idx_website_id (website_id)
+);
+
+-- Create ExtractionRules table
+CREATE TABLE IF NOT EXISTS extraction_rules (
+    id INT AUTO_INCREMENT PRIMARY KEY,
+    user_id INT NOT NULL,
+    website_id INT NULL,
+    name VARCHAR(100) NOT NULL,
+    selector_type VARCHAR(10) NOT NULL,
+    selector VARCHAR(1024) NOT NULL,
+    extract VARCHAR(20) NOT NULL DEFAULT 'text',
+    attribute VARCHAR(100) NOT NULL DEFAULT '',
+    multiple BOOLEAN DEFAULT FALSE,
+    regex VARCHAR(512) NOT NULL DEFAULT '',
+    data_type VARCHAR(20) NOT NULL DEFAULT 'string',
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE,
+    FOREIGN KEY (website_id) REFERENCES websites(id) ON DELETE CASCADE,
+    INDEX idx_user_website (user_id, website_id)
+);
+
+-- Create ExtractionResults table
+CREATE TABLE IF NOT EXISTS extraction_results (
+    id INT AUTO_INCREMENT PRIMARY KEY,
+    website_id INT NOT NULL,
+    rule_name VARCHAR(100) NOT NULL,
+    data_type VARCHAR(20) NOT NULL,
+    value JSON NULL,
+    error VARCHAR(512) NOT NULL DEFAULT '',
+    FOREIGN KEY (website_id) REFERENCES websites(id) ON DELETE CASCADE,
+    INDEX idx_website_id (website_id)
+);
+
+-- Create Assertions table
+CREATE TABLE IF NOT EXISTS assertions (
+    id INT AUTO_INCREMENT PRIMARY KEY,
+    website_id INT NOT NULL,
+    type VARCHAR(30) NOT NULL,
+    target VARCHAR(1024) NOT NULL DEFAULT '',
+    selector_type VARCHAR(10) NOT NULL DEFAULT '',
+    operator VARCHAR(5) NOT NULL DEFAULT '',
+    expected VARCHAR(1024) NOT NULL DEFAULT '',
+    FOREIGN KEY (website_id) REFERENCES websites(id) ON DELETE CASCADE,
+    INDEX idx_website_id (website_id)
+);
+
+-- Create AssertionResults table
+CREATE TABLE IF NOT EXISTS assertion_results (
+    id INT AUTO_INCREMENT PRIMARY KEY,
+    website_id INT NOT NULL,
+    assertion_id INT NOT NULL,
+    type VARCHAR(30) NOT NULL,
+    description VARCHAR(1024) NOT NULL,
+    passed BOOLEAN DEFAULT FALSE,
+    actual VARCHAR(1024) NOT NULL DEFAULT '',
+    message VARCHAR(512) NOT NULL DEFAULT '',
+    FOREIGN KEY (website_id) REFERENCES websites(id) ON DELETE CASCADE,
+    INDEX idx_website_id (website_id)
+);
+
+-- Create ContentReports table
+CREATE TABLE IF NOT EXISTS content_reports (
+    id INT AUTO_INCREMENT PRIMARY KEY,
+    website_id INT NOT NULL UNIQUE,
+    main_content_from VARCHAR(20) NOT NULL DEFAULT '',
+    word_count INT DEFAULT 0,
+    main_word_count INT DEFAULT 0,
+    sentence_count INT DEFAULT 0,
+    text_html_ratio DOUBLE DEFAULT 0,
+    declared_language VARCHAR(35) NOT NULL DEFAULT '',
+    language VARCHAR(10) NOT NULL DEFAULT '',
+    language_mismatch BOOLEAN DEFAULT FALSE,
+    readability JSON NULL,
+    keywords JSON NOT NULL,
+    bigrams JSON NOT NULL,
+    trigrams JSON NOT NULL,
+    thin_content BOOLEAN DEFAULT FALSE,
+    FOREIGN KEY (website_id) REFERENCES websites(id) ON DELETE CASCADE
+);
+
+-- Create HreflangFindings table
+CREATE TABLE IF NOT EXISTS hreflang_findings (
+    id INT AUTO_INCREMENT PRIMARY KEY,
+    website_id INT NOT NULL,
+    code VARCHAR(50) NOT NULL,
+    severity VARCHAR(10) NOT NULL,
+    hreflang VARCHAR(35) NOT NULL DEFAULT '',
+    url VARCHAR(2048) NOT NULL DEFAULT '',
+    message VARCHAR(512) NOT NULL,
+    FOREIGN KEY (website_id) REFERENCES websites(id) ON DELETE CASCADE,
+    INDEX idx_website_id (website_id)
+);
+
+-- Create PageImages table
+CREATE TABLE IF NOT EXISTS page_images (
+    id INT AUTO_INCREMENT PRIMARY KEY,
+    website_id INT NOT NULL,
+    url VARCHAR(2048) NOT NULL,
+    alt VARCHAR(512) NOT NULL DEFAULT '',
+    has_alt BOOLEAN DEFAULT FALSE,
+    width INT DEFAULT 0,
+    height INT DEFAULT 0,
+    natural_width INT DEFAULT 0,
+    natural_height INT DEFAULT 0,
+    format VARCHAR(20) NOT NULL DEFAULT '',
+    size BIGINT DEFAULT 0,
+    status_code INT DEFAULT 0,
+    loading VARCHAR(20) NOT NULL DEFAULT '',
+    in_picture BOOLEAN DEFAULT FALSE,
+    issues JSON NOT NULL,
+    FOREIGN KEY (website_id) REFERENCES websites(id) ON DELETE CASCADE,
+    INDEX idx_website_id (website_id)
+);
+
+-- Create Documents table
+CREATE TABLE IF NOT EXISTS documents (
+    id INT AUTO_INCREMENT PRIMARY KEY,
+    website_id INT NOT NULL UNIQUE,
+    content_type VARCHAR(255) NOT NULL DEFAULT '',
+    kind VARCHAR(20) NOT NULL,
+    size BIGINT DEFAULT 0,
+    analyzable BOOLEAN DEFAULT FALSE,
+    version VARCHAR(10) NOT NULL DEFAULT '',
+    page_count INT DEFAULT 0,
+    encrypted BOOLEAN DEFAULT FALSE,
+    metadata JSON NOT NULL,
+    link_count INT DEFAULT 0,
+    FOREIGN KEY (website_id) REFERENCES websites(id) ON DELETE CASCADE
+);
+
+-- Create Feeds table
+CREATE TABLE IF NOT EXISTS feeds (
+    id INT AUTO_INCREMENT PRIMARY KEY,
+    website_id INT NOT NULL,
+    url VARCHAR(2048) NOT NULL,
+    source VARCHAR(10) NOT NULL,
+    format VARCHAR(10) NOT NULL DEFAULT '',
+    version VARCHAR(10) NOT NULL DEFAULT '',
+    title VARCHAR(255) NOT NULL DEFAULT '',
+    status_code INT DEFAULT 0,
+    valid BOOLEAN DEFAULT FALSE,
+    errors JSON NOT NULL,
+    item_count INT DEFAULT 0,
+    last_updated DATETIME NULL,
+    self_link VARCHAR(2048) NOT NULL DEFAULT '',
+    self_link_mismatch BOOLEAN DEFAULT FALSE,
+    broken_items JSON NOT NULL,
+    FOREIGN KEY (website_id) REFERENCES websites(id) ON DELETE CASCADE,
+    INDEX idx_website_id (website_id)
+);
+
+-- Create PWAReports table
+CREATE TABLE IF NOT EXISTS pwa_reports (
+    id INT AUTO_INCREMENT PRIMARY KEY,
+    website_id INT NOT NULL UNIQUE,
+    favicons JSON NOT NULL,
+    manifest_url VARCHAR(2048) NOT NULL DEFAULT '',
+    manifest_status INT DEFAULT 0,
+    manifest JSON NULL,
+    service_worker BOOLEAN DEFAULT FALSE,
+    service_worker_url VARCHAR(2048) NOT NULL DEFAULT '',
+    checks JSON NOT NULL,
+    installable BOOLEAN DEFAULT FALSE,
+    FOREIGN KEY (website_id) REFERENCES websites(id) ON DELETE CASCADE
+);
+
+-- Create WellKnownFiles table
+CREATE TABLE IF NOT EXISTS well_known_files (
+    id INT AUTO_INCREMENT PRIMARY KEY,
+    website_id INT NOT NULL,
+    name VARCHAR(50) NOT NULL,
+    url VARCHAR(2048) NOT NULL,
+    status_code INT DEFAULT 0,
+    found BOOLEAN DEFAULT FALSE,
+    fields JSON NOT NULL,
+    records JSON NOT NULL,
+    record_count INT DEFAULT 0,
+    expires DATETIME NULL,
+    signed BOOLEAN DEFAULT FALSE,
+    redirect_url VARCHAR(2048) NOT NULL DEFAULT '',
+    errors JSON NOT NULL,
+    FOREIGN KEY (website_id) REFERENCES websites(id) ON DELETE CASCADE
+);
+
+-- Upgrade databases created by an earlier version of this schema. The
+-- CREATE TABLE statements above skip existing tables, and MySQL has no
+-- ADD COLUMN IF NOT EXISTS, so missing columns and indexes are added by
+-- procedures that check the information schema first.
+DROP PROCEDURE IF EXISTS add_column_if_missing;
+DROP PROCEDURE IF EXISTS add_index_if_missing;
+
+DELIMITER //
+CREATE PROCEDURE add_column_if_missing(IN table_param VARCHAR(64), IN column_param VARCHAR(64), IN definition_param TEXT)
+BEGIN
+    IF NOT EXISTS (
+        SELECT 1 FROM information_schema.COLUMNS
+        WHERE TABLE_SCHEMA = DATABASE() AND TABLE_NAME = table_param AND COLUMN_NAME = column_param
+    ) THEN
+        SET @upgrade_statement = CONCAT('ALTER TABLE ', table_param, ' ADD COLUMN ', column_param, ' ', definition_param);
+        PREPARE upgrade_statement FROM @upgrade_statement;
+        EXECUTE upgrade_statement;
+        DEALLOCATE PREPARE upgrade_statement;
+    END IF;
+END //
+
+CREATE PROCEDURE add_index_if_missing(IN table_param VARCHAR(64), IN index_param VARCHAR(64), IN columns_param TEXT)
+BEGIN
+    IF NOT EXISTS (
+        SELECT 1 FROM information_schema.STATISTICS
+        WHERE TABLE_SCHEMA = DATABASE() AND TABLE_NAME = table_param AND INDEX_NAME = index_param
+    ) THEN
+        SET @upgrade_statement = CONCAT('ALTER TABLE ', table_param, ' ADD INDEX ', index_param, ' (', columns_param, ')');
+        PREPARE upgrade_statement FROM @upgrade_statement;
+        EXECUTE upgrade_statement;
+        DEALLOCATE PREPARE upgrade_statement;
+    END IF;
+END //
+DELIMITER ;
+
+-- Websites: documents that can't be analyzed, security grade and assertion health
+ALTER TABLE websites MODIFY status ENUM('queued', 'running', 'done', 'error', 'not_analyzable') DEFAULT 'queued';
+CALL add_column_if_missing('websites', 'security_grade', 'VARCHAR(2) AFTER error_message');
+CALL add_column_if_missing('websites', 'health', 'ENUM(''passing'', ''failing'') NULL AFTER security_grade');
+CALL add_index_if_missing('websites', 'idx_health', 'health');
+
+-- LinkCounts: scope buckets, schemes and link attribute audits
+CALL add_column_if_missing('link_counts', 'subdomain_links', 'INT DEFAULT 0 AFTER internal_links');
+CALL add_column_if_missing('link_counts', 'scheme_counts', 'JSON NULL AFTER external_links');
+CALL add_column_if_missing('link_counts', 'nofollow_links', 'INT DEFAULT 0 AFTER scheme_counts');
+CALL add_column_if_missing('link_counts', 'sponsored_links', 'INT DEFAULT 0 AFTER nofollow_links');
+CALL add_column_if_missing('link_counts', 'ugc_links', 'INT DEFAULT 0 AFTER sponsored_links');
+CALL add_column_if_missing('link_counts', 'unsafe_blank_targets', 'INT DEFAULT 0 AFTER ugc_links');
+CALL add_column_if_missing('link_counts', 'internal_nofollow', 'INT DEFAULT 0 AFTER unsafe_blank_targets');
+CALL add_column_if_missing('link_counts', 'unmarked_paid_links', 'INT DEFAULT 0 AFTER internal_nofollow');
+CALL add_column_if_missing('link_counts', 'empty_anchors', 'INT DEFAULT 0 AFTER unmarked_paid_links');
+CALL add_column_if_missing('link_counts', 'generic_anchors', 'INT DEFAULT 0 AFTER empty_anchors');
+
+-- BrokenLinks: why a link counts as broken, e.g. a soft 404
+CALL add_column_if_missing('broken_links', 'reason', 'VARCHAR(50) NOT NULL DEFAULT '''' AFTER status_code');
+
+-- PerformanceReports: content encodings that can't be decoded
+CALL add_column_if_missing('performance_reports', 'unsupported_encoding', 'BOOLEAN DEFAULT FALSE AFTER content_encoding');
+
+DROP PROCEDURE add_column_if_missing;
+DROP PROCEDURE add_index_if_missing;
+
 -- Insert a default admin user (password: admin123)
 INSERT INTO users (username, password, email) 
 VALUES ('admin', '$2a$10$3eJXM5jYz8zS5hT1g9jN1.CCO7NhJEG5BxCRjKVr/ethVypQWqDyW', 'admin@example.com')
@@ -0,0 +1,203 @@
+package services
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/sykell/website-analyzer/models"
+	"golang.org/x/net/html"
+)
+
+// Words describing the comparison operators of assertions
+var operatorPhrases = map[string]string{
+	"eq": "equal", "ne": "not equal", "lt": "be under", "lte": "be at most", "gt": "be over", "gte": "be at least",
+}
+
+// ValidateAssertion checks an assertion and fills in the defaults of its
+// operator and expected value
+func ValidateAssertion(assertion *models.Assertion) error {
+	assertion.Target = strings.TrimSpace(assertion.Target)
+	assertion.Expected = strings.TrimSpace(assertion.Expected)
+
+	// Numeric assertions compare against an integer, with a default per type
+	defaults := map[string][2]string{
+		models.AssertHeadingCount:  {"eq", ""},
+		models.AssertBrokenLinks:   {"eq", "0"},
+		models.AssertSelectorCount: {"gte", "1"},
+		models.AssertResponseTime:  {"lt", ""},
+		models.AssertStatusCode:    {"eq", "200"},
+	}
+	if assertion.Type == models.AssertTitleContains {
+		if assertion.Expected == "" {
+			return fmt.Errorf("title_contains needs the expected text")
+		}
+		assertion.Operator, assertion.Target, assertion.SelectorType = "", "", ""
+		return nil
+	}
+	if defaults, ok := defaults[assertion.Type]; ok {
+		if assertion.Operator == "" {
+			assertion.Operator = defaults[0]
+		}
+		if assertion.Expected == "" {
+			assertion.Expected = defaults[1]
+		}
+	}
+	if _, err := strconv.Atoi(assertion.Expected); err != nil {
+		return fmt.Errorf("%s needs an integer expected value", assertion.Type)
+	}
+
+	switch assertion.Type {
+	case models.AssertHeadingCount:
+		assertion.Target = strings.ToLower(assertion.Target)
+		if len(assertion.Target) != 2 || assertion.Target[0] != 'h' || assertion.Target[1] < '1' || assertion.Target[1] > '6' {
+			return fmt.Errorf("heading_count needs a target from h1 to h6")
+		}
+		assertion.SelectorType = ""
+	case models.AssertSelectorCount:
+		if assertion.SelectorType == "" {
+			assertion.SelectorType = "css"
+		}
+		rule := &models.ExtractionRule{SelectorType: assertion.SelectorType, Selector: assertion.Target}
+		if _, err := compileExtractionMatcher(rule); err != nil {
+			return err
+		}
+	default:
+		assertion.Target, assertion.SelectorType = "", ""
+	}
+	return nil
+}
+
+// describeAssertion writes an assertion as a sentence, e.g. "h1 count must equal 1"
+func describeAssertion(assertion *models.Assertion) string {
+	phrase := operatorPhrases[assertion.Operator]
+	switch assertion.Type {
+	case models.AssertTitleContains:
+		return fmt.Sprintf("title must contain %q", assertion.Expected)
+	case models.AssertHeadingCount:
+		return fmt.Sprintf("%s count must %s %s", assertion.Target, phrase, assertion.Expected)
+	case models.AssertBrokenLinks:
+		return fmt.Sprintf("broken links must %s %s", phrase, assertion.Expected)
+	case models.AssertSelectorCount:
+		return fmt.Sprintf("matches of %s must %s %s", assertion.Target, phrase, assertion.Expected)
+	case models.AssertResponseTime:
+		return fmt.Sprintf("response time must %s %s ms", phrase, assertion.Expected)
+	}
+	return fmt.Sprintf("status code must %s %s", phrase, assertion.Expected)
+}
+
+// compareInt applies an assertion operator
+func compareInt(actual int, operator string, expected int) bool {
+	switch operator {
+	case "ne":
+		return actual != expected
+	case "lt":
+		return actual < expected
+	case "lte":
+		return actual <= expected
+	case "gt":
+		return actual > expected
+	case "gte":
+		return actual >= expected
+	}
+	return actual == expected
+}
+
+// evaluateAssertions checks the website's assertions against the analysis
+// and returns the results with the resulting health. Assertions about the
+// document fail when the page could not be analyzed, i.e. doc is nil, and
+// the status code is 0 when no response was received.
+func (c *Crawler) evaluateAssertions(statusCode int, doc *html.Node) ([]models.AssertionResult, string) {
+	results := []models.AssertionResult{}
+	if len(c.assertions) == 0 {
+		return results, ""
+	}
+
+	health := models.HealthPassing
+	for i := range c.assertions {
+		assertion := &c.assertions[i]
+		result := models.AssertionResult{
+			WebsiteID:   c.website.ID,
+			AssertionID: assertion.ID,
+			Type:        assertion.Type,
+			Description: truncateString(describeAssertion(assertion), 1024),
+		}
+		expected, _ := strconv.Atoi(assertion.Expected)
+
+		actual := -1
+		switch {
+		case assertion.Type == models.AssertStatusCode:
+			if statusCode == 0 {
+				result.Message = "No response was received"
+			} else {
+				actual = statusCode
+			}
+		case assertion.Type == models.AssertResponseTime:
+			if elapsed, ok := c.responseTimeMs(); ok {
+				actual = int(elapsed)
+			} else {
+				result.Message = "No response was received"
+			}
+		case doc == nil:
+			result.Message = "The page could not be analyzed"
+		case assertion.Type == models.AssertTitleContains:
+			title := strings.Join(strings.Fields(c.website.TitleStr), " ")
+			result.Actual = truncateString(title, 1024)
+			result.Passed = strings.Contains(strings.ToLower(title), strings.ToLower(assertion.Expected))
+		case assertion.Type == models.AssertHeadingCount:
+			counts := c.website.HeadingCounts
+			actual = []int{counts.H1Count, counts.H2Count, counts.H3Count, counts.H4Count, counts.H5Count, counts.H6Count}[assertion.Target[1]-'1']
+		case assertion.Type == models.AssertBrokenLinks:
+			actual = len(c.website.BrokenLinks)
+		case assertion.Type == models.AssertSelectorCount:
+			rule := &models.ExtractionRule{SelectorType: assertion.SelectorType, Selector: assertion.Target}
+			matcher, err := compileExtractionMatcher(rule)
+			if err != nil {
+				result.Message = truncateString(err.Error(), 512)
+				break
+			}
+			actual = len(matcher(doc))
+		}
+
+		if actual >= 0 {
+			result.Actual = strconv.Itoa(actual)
+			result.Passed = compareInt(actual, assertion.Operator, expected)
+		}
+		if !result.Passed {
+			health = models.HealthFailing
+		}
+		results = append(results, result)
+	}
+
+	return results, health
+}
+
+// responseTimeMs returns the time from sending the page request until the
+// page was downloaded, or until its first byte if the download didn't finish
+func (c *Crawler) responseTimeMs() (int64, bool) {
+	trace := c.pageTrace
+	if trace == nil {
+		return 0, false
+	}
+
+	trace.mutex.Lock()
+	defer trace.mutex.Unlock()
+	end := trace.done
+	if end.IsZero() {
+		end = trace.firstByte
+	}
+	if end.IsZero() {
+		return 0, false
+	}
+	return end.Sub(trace.start).Milliseconds(), true
+}
+
+// recordFailedAnalysis evaluates the assertions of an analysis that failed,
+// so monitored websites turn unhealthy when they can't be analyzed
+func (c *Crawler) recordFailedAnalysis(statusCode int) {
+	if len(c.assertions) == 0 {
+		return
+	}
+	results, health := c.evaluateAssertions(statusCode, nil)
+	models.SaveAssertionResults(c.website.ID, results, health)
+}
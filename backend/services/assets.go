@@ -0,0 +1,225 @@
+package services
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"io"
+	"net/http"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+const (
+	// maxAssets limits how many subresources are fetched per analysis
+	maxAssets = 200
+
+	// maxStylesheetSize limits how much of a stylesheet is kept for parsing
+	maxStylesheetSize = 2 << 20
+
+	// maxAssetSize limits how much of an asset is downloaded, larger assets
+	// are counted with the bytes read until then as a lower bound
+	maxAssetSize = 10 << 20
+
+	// maxMediaSize limits the download of audio and video without a declared
+	// length, which are often large and only fetched for their size
+	maxMediaSize = 1 << 20
+)
+
+var (
+	cssURLRegex  = regexp.MustCompile(`url\(\s*['"]?([^'")]+)['"]?\s*\)`)
+	fontURLRegex = regexp.MustCompile(`(?i)\.(woff2?|ttf|otf|eot)([?#].*)?$`)
+)
+
+// assetResponse represents a fetched subresource of the page
+type assetResponse struct {
+	URL          string
+	Kind         string
+	StatusCode   int
+	Header       http.Header
+	TransferSize int64 // Bytes on the wire, compressed if the server compressed them
+	Size         int64 // Bytes after decoding the content encoding
+	Partial      bool  // The download stopped at the size limit, so the sizes are lower bounds
+	Err          error
+	body         []byte // Only kept for stylesheets
+}
+
+// fetchAssets fetches the page's subresources, and the fonts referenced from
+// its stylesheets, once per analysis. Results are cached for all analyzers.
+func (c *Crawler) fetchAssets(resources []pageResource) []*assetResponse {
+	c.assetsOnce.Do(func() {
+		c.assets = c.fetchResourceList(resources)
+
+		// Fonts are mostly referenced from stylesheets rather than the document
+		var fonts []pageResource
+		seen := map[string]bool{}
+		for _, asset := range c.assets {
+			if asset.Kind != "stylesheet" || asset.body == nil {
+				continue
+			}
+			for _, match := range cssURLRegex.FindAllStringSubmatch(string(asset.body), -1) {
+				if !fontURLRegex.MatchString(match[1]) {
+					continue
+				}
+				fontURL, err := c.pageURL.Parse(asset.URL)
+				if err == nil {
+					fontURL, err = fontURL.Parse(strings.TrimSpace(match[1]))
+				}
+				if err != nil || seen[fontURL.String()] {
+					continue
+				}
+				seen[fontURL.String()] = true
+				fonts = append(fonts, pageResource{URL: fontURL, Tag: "style", Attr: "url", Kind: "font"})
+			}
+		}
+		c.assets = append(c.assets, c.fetchResourceList(fonts)...)
+	})
+	return c.assets
+}
+
+// fetchResourceList fetches unique resources concurrently
+func (c *Crawler) fetchResourceList(resources []pageResource) []*assetResponse {
+	var assets []*assetResponse
+	seen := map[string]bool{}
+	for _, resource := range resources {
+		// Form actions are navigation targets, not part of the page weight
+		if resource.Kind == "form" || seen[resource.URL.String()] || len(assets) >= maxAssets {
+			continue
+		}
+		seen[resource.URL.String()] = true
+		assets = append(assets, &assetResponse{URL: resource.URL.String(), Kind: resource.Kind})
+	}
+
+	var wg sync.WaitGroup
+	semaphore := make(chan struct{}, 10) // Limit concurrency
+	for _, asset := range assets {
+		wg.Add(1)
+		go func(asset *assetResponse) {
+			defer wg.Done()
+			semaphore <- struct{}{}        // Acquire token
+			defer func() { <-semaphore }() // Release token
+
+			c.fetchAsset(asset)
+		}(asset)
+	}
+	wg.Wait()
+
+	return assets
+}
+
+// fetchAsset downloads an asset, counting its compressed and decoded size
+func (c *Crawler) fetchAsset(asset *assetResponse) {
+	req, err := c.newRequest("GET", asset.URL, nil)
+	if err != nil {
+		asset.Err = err
+		return
+	}
+	// Asking for compression explicitly stops the transport from decoding it
+	req.Header.Set("Accept-Encoding", "gzip, deflate")
+
+	resp, err := c.sendAuthenticated(c.linkClient, req)
+	if err != nil {
+		asset.Err = err
+		return
+	}
+	defer resp.Body.Close()
+
+	asset.StatusCode = resp.StatusCode
+	asset.Header = resp.Header
+
+	// Media is only fetched for its size, so a declared length of an
+	// unencoded body is used without downloading it
+	limit := int64(maxAssetSize)
+	if asset.Kind == "media" {
+		if resp.ContentLength >= 0 && resp.Header.Get("Content-Encoding") == "" {
+			asset.TransferSize = resp.ContentLength
+			asset.Size = resp.ContentLength
+			return
+		}
+		limit = maxMediaSize
+	}
+
+	wire := &countingReader{reader: io.LimitReader(resp.Body, limit)}
+	decoded, _ := decodeContent(wire, resp.Header.Get("Content-Encoding"))
+
+	var sink io.Writer = io.Discard
+	var body bytes.Buffer
+	if asset.Kind == "stylesheet" {
+		sink = &limitedWriter{writer: &body, remaining: maxStylesheetSize}
+	}
+	asset.Size, err = io.Copy(sink, decoded)
+	asset.TransferSize = wire.count
+	if asset.Kind == "stylesheet" {
+		asset.body = body.Bytes()
+	}
+
+	// A body cut off at the limit ends mid-stream, which isn't an error
+	if wire.count >= limit && resp.ContentLength != wire.count {
+		asset.Partial = true
+		return
+	}
+	asset.Err = err
+}
+
+// decodeContent wraps a reader to undo the given content encoding. Encodings
+// that can't be decoded, like br or a misconfigured value, and corrupt
+// compressed bodies fall back to the raw body and report false.
+func decodeContent(reader io.Reader, encoding string) (io.Reader, bool) {
+	// Keep the bytes the decoder reads so the raw body can be restored
+	var header bytes.Buffer
+	tee := io.TeeReader(reader, &header)
+	var decoded io.Reader
+	var err error
+	switch strings.ToLower(strings.TrimSpace(encoding)) {
+	case "", "identity":
+		return reader, true
+	case "gzip", "x-gzip":
+		decoded, err = gzip.NewReader(tee)
+	case "deflate":
+		decoded, err = zlib.NewReader(tee)
+	default:
+		return reader, false
+	}
+	if err == io.EOF {
+		// Empty bodies carry no compression header
+		return bytes.NewReader(nil), true
+	}
+	if err != nil {
+		return io.MultiReader(&header, reader), false
+	}
+	return decoded, true
+}
+
+// countingReader counts the bytes read through it
+type countingReader struct {
+	reader io.Reader
+	count  int64
+}
+
+func (r *countingReader) Read(p []byte) (int, error) {
+	n, err := r.reader.Read(p)
+	r.count += int64(n)
+	return n, err
+}
+
+// limitedWriter keeps the first bytes written to it and discards the rest
+type limitedWriter struct {
+	writer    io.Writer
+	remaining int64
+}
+
+func (w *limitedWriter) Write(p []byte) (int, error) {
+	if w.remaining > 0 {
+		keep := p
+		if int64(len(keep)) > w.remaining {
+			keep = keep[:w.remaining]
+		}
+		n, err := w.writer.Write(keep)
+		w.remaining -= int64(n)
+		if err != nil {
+			return n, err
+		}
+	}
+	return len(p), nil
+}
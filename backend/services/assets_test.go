@@ -0,0 +1,116 @@
+package services
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/sykell/website-analyzer/models"
+)
+
+func TestFetchAssetSizes(t *testing.T) {
+	script := strings.Repeat("console.log(1);", 1000)
+	var gzipped bytes.Buffer
+	writer := gzip.NewWriter(&gzipped)
+	writer.Write([]byte(script))
+	writer.Close()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/app.js":
+			w.Header().Set("Content-Encoding", "gzip")
+			w.Write(gzipped.Bytes())
+		case "/video.mp4":
+			// A declared length is used without reading the body
+			w.Header().Set("Content-Length", "50000000")
+			w.Write(make([]byte, 1024))
+		case "/stream.mp4":
+			// Without a declared length only the start is downloaded
+			chunk := make([]byte, 64<<10)
+			for i := 0; i < 32; i++ {
+				if _, err := w.Write(chunk); err != nil {
+					return
+				}
+				w.(http.Flusher).Flush()
+			}
+		case "/clip.mp4":
+			w.Write(make([]byte, 1000))
+		}
+	}))
+	defer server.Close()
+
+	crawler := newTestCrawler(t, server.URL+"/", &models.CrawlSettings{})
+	var resources []pageResource
+	for _, resource := range []struct{ path, kind string }{
+		{"/app.js", "script"},
+		{"/video.mp4", "media"},
+		{"/stream.mp4", "media"},
+		{"/clip.mp4", "media"},
+	} {
+		resourceURL, _ := url.Parse(server.URL + resource.path)
+		resources = append(resources, pageResource{URL: resourceURL, Kind: resource.kind})
+	}
+	assets := crawler.fetchResourceList(resources)
+
+	tests := []struct {
+		path               string
+		transferSize, size int64
+		partial            bool
+	}{
+		{"/app.js", int64(gzipped.Len()), int64(len(script)), false},
+		{"/video.mp4", 50000000, 50000000, false},
+		{"/stream.mp4", maxMediaSize, maxMediaSize, true},
+		{"/clip.mp4", 1000, 1000, false},
+	}
+	for i, tt := range tests {
+		asset := assets[i]
+		if asset.Err != nil {
+			t.Errorf("%s failed: %v", tt.path, asset.Err)
+			continue
+		}
+		if asset.TransferSize != tt.transferSize || asset.Size != tt.size || asset.Partial != tt.partial {
+			t.Errorf("%s: transfer size %d, size %d, partial %v, want %d, %d, %v", tt.path,
+				asset.TransferSize, asset.Size, asset.Partial, tt.transferSize, tt.size, tt.partial)
+		}
+	}
+}
+
+func TestDecodeContent(t *testing.T) {
+	var gzipped bytes.Buffer
+	writer := gzip.NewWriter(&gzipped)
+	writer.Write([]byte("compressed"))
+	writer.Close()
+
+	tests := []struct {
+		name     string
+		body     []byte
+		encoding string
+		want     string
+		decoded  bool
+	}{
+		{"identity", []byte("plain"), "", "plain", true},
+		{"gzip", gzipped.Bytes(), "GZIP", "compressed", true},
+		{"empty gzip body", nil, "gzip", "", true},
+		{"unsupported encoding", []byte("brotli bytes"), "br", "brotli bytes", false},
+		{"corrupt gzip body", []byte("not gzip at all"), "gzip", "not gzip at all", false},
+		{"corrupt deflate body", []byte("not zlib"), "deflate", "not zlib", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reader, decoded := decodeContent(bytes.NewReader(tt.body), tt.encoding)
+			body, err := io.ReadAll(reader)
+			if err != nil {
+				t.Fatalf("reading failed: %v", err)
+			}
+			if string(body) != tt.want || decoded != tt.decoded {
+				t.Errorf("got %q decoded %v, want %q decoded %v", body, decoded, tt.want, tt.decoded)
+			}
+		})
+	}
+}
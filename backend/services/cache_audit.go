@@ -0,0 +1,204 @@
+package services
+
+import (
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sykell/website-analyzer/models"
+	"golang.org/x/net/html"
+)
+
+const (
+	// minStaticMaxAge is the shortest lifetime considered long-lived for static assets (7 days)
+	minStaticMaxAge = 7 * 24 * 60 * 60
+
+	// minCompressibleSize is the size above which text responses should be compressed
+	minCompressibleSize = 1024
+)
+
+// Resource types treated as static assets that should be cached for long
+var staticResourceTypes = map[string]bool{
+	"css":   true,
+	"js":    true,
+	"image": true,
+	"font":  true,
+	"media": true,
+}
+
+// auditCaching evaluates the caching and compression headers of the page and
+// its assets, and revalidates each response to confirm conditional requests work
+func (c *Crawler) auditCaching(resp *http.Response, doc *html.Node, bodySize int) []models.CacheEntry {
+	entries := []models.CacheEntry{
+		c.cacheEntry(c.pageURL.String(), "html", resp.Header, int64(bodySize)),
+	}
+	for _, asset := range c.fetchAssets(c.collectResources(doc)) {
+		if asset.Err != nil || asset.StatusCode >= 400 {
+			continue
+		}
+		entries = append(entries, c.cacheEntry(asset.URL, weightType(asset.Kind), asset.Header, asset.Size))
+	}
+
+	// Revalidate every response that carries a validator
+	var wg sync.WaitGroup
+	semaphore := make(chan struct{}, 10) // Limit concurrency
+	for i := range entries {
+		if entries[i].ETag == "" && entries[i].LastModified == "" {
+			continue
+		}
+		wg.Add(1)
+		go func(entry *models.CacheEntry) {
+			defer wg.Done()
+			semaphore <- struct{}{}        // Acquire token
+			defer func() { <-semaphore }() // Release token
+
+			entry.ConditionalStatus = c.revalidate(entry)
+			if entry.ConditionalStatus != http.StatusNotModified {
+				entry.Issues = append(entry.Issues, "conditional_request_not_honored")
+			}
+		}(&entries[i])
+	}
+	wg.Wait()
+
+	// Revalidation needs the full URL and validators, so truncate to the
+	// column sizes only afterwards
+	for i := range entries {
+		entry := &entries[i]
+		entry.URL = truncateString(entry.URL, 2048)
+		entry.CacheControl = truncateString(entry.CacheControl, 512)
+		entry.Expires = truncateString(entry.Expires, 100)
+		entry.ETag = truncateString(entry.ETag, 255)
+		entry.LastModified = truncateString(entry.LastModified, 100)
+		entry.Vary = truncateString(entry.Vary, 255)
+		entry.ContentEncoding = truncateString(entry.ContentEncoding, 50)
+	}
+
+	return entries
+}
+
+// cacheEntry captures the caching headers of a response and flags problems
+func (c *Crawler) cacheEntry(rawURL, resourceType string, header http.Header, size int64) models.CacheEntry {
+	entry := models.CacheEntry{
+		WebsiteID:       c.website.ID,
+		URL:             rawURL,
+		ResourceType:    resourceType,
+		CacheControl:    header.Get("Cache-Control"),
+		Expires:         header.Get("Expires"),
+		ETag:            header.Get("ETag"),
+		LastModified:    header.Get("Last-Modified"),
+		Vary:            header.Get("Vary"),
+		ContentEncoding: header.Get("Content-Encoding"),
+		Issues:          []string{},
+	}
+	directives := parseCacheControl(entry.CacheControl)
+	entry.MaxAge = freshnessLifetime(directives, header)
+
+	if staticResourceTypes[resourceType] && entry.MaxAge < minStaticMaxAge {
+		entry.Issues = append(entry.Issues, "short_cache_lifetime")
+	}
+	if resourceType == "html" {
+		if _, ok := directives["immutable"]; ok {
+			entry.Issues = append(entry.Issues, "html_immutable")
+		}
+	}
+	if entry.ContentEncoding == "" && size > minCompressibleSize && isCompressible(header.Get("Content-Type")) {
+		entry.Issues = append(entry.Issues, "missing_compression")
+	}
+	if entry.ETag == "" && entry.LastModified == "" {
+		entry.Issues = append(entry.Issues, "missing_validator")
+	}
+	if strings.TrimSpace(entry.Vary) == "*" {
+		entry.Issues = append(entry.Issues, "vary_star")
+	}
+
+	return entry
+}
+
+// revalidate issues a conditional request and returns its status code
+func (c *Crawler) revalidate(entry *models.CacheEntry) int {
+	req, err := c.newRequest("GET", entry.URL, nil)
+	if err != nil {
+		return 0
+	}
+	if entry.ETag != "" {
+		req.Header.Set("If-None-Match", entry.ETag)
+	} else {
+		req.Header.Set("If-Modified-Since", entry.LastModified)
+	}
+
+	resp, err := c.sendAuthenticated(c.linkClient, req)
+	if err != nil {
+		return 0
+	}
+	resp.Body.Close()
+
+	return resp.StatusCode
+}
+
+// parseCacheControl splits a Cache-Control header into lowercase directives
+func parseCacheControl(value string) map[string]string {
+	directives := map[string]string{}
+	for _, directive := range strings.Split(value, ",") {
+		directive = strings.TrimSpace(directive)
+		if directive == "" {
+			continue
+		}
+		name, argument, _ := strings.Cut(directive, "=")
+		directives[strings.ToLower(strings.TrimSpace(name))] = strings.Trim(strings.TrimSpace(argument), `"`)
+	}
+	return directives
+}
+
+// freshnessLifetime returns how long a response may be cached, in seconds,
+// or -1 if it has no explicit lifetime
+func freshnessLifetime(directives map[string]string, header http.Header) int {
+	if _, ok := directives["no-store"]; ok {
+		return 0
+	}
+	if _, ok := directives["no-cache"]; ok {
+		return 0
+	}
+	if value, ok := directives["max-age"]; ok {
+		if maxAge, err := strconv.Atoi(value); err == nil {
+			return maxAge
+		}
+	}
+
+	expires, err := http.ParseTime(header.Get("Expires"))
+	if err != nil {
+		// Invalid dates such as "0" mean already expired
+		if header.Get("Expires") != "" {
+			return 0
+		}
+		return -1
+	}
+	date, err := http.ParseTime(header.Get("Date"))
+	if err != nil {
+		date = time.Now()
+	}
+	if lifetime := int(expires.Sub(date).Seconds()); lifetime > 0 {
+		return lifetime
+	}
+	return 0
+}
+
+// isCompressible checks if a content type is text that benefits from compression
+func isCompressible(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	if strings.HasPrefix(mediaType, "text/") {
+		return true
+	}
+	switch mediaType {
+	case "application/javascript", "application/x-javascript", "application/json", "application/ld+json",
+		"application/manifest+json", "application/xml", "application/rss+xml", "application/atom+xml",
+		"image/svg+xml", "application/vnd.ms-fontobject", "font/ttf", "font/otf":
+		return true
+	}
+	return strings.HasSuffix(mediaType, "+json") || strings.HasSuffix(mediaType, "+xml")
+}
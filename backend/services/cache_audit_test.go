@@ -0,0 +1,67 @@
+package services
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/sykell/website-analyzer/models"
+	"golang.org/x/net/html"
+)
+
+func TestAuditCachingTruncatesLongHeaders(t *testing.T) {
+	etag := `"` + strings.Repeat("e", 400) + `"`
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Only the full validator is honored
+		if r.Header.Get("If-None-Match") == etag {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	longPath := "/" + strings.Repeat("p", 3000)
+	crawler := newTestCrawler(t, server.URL+longPath, &models.CrawlSettings{})
+	resp := &http.Response{StatusCode: http.StatusOK, Header: http.Header{
+		"Cache-Control":    {"max-age=60, " + strings.Repeat("x", 600)},
+		"Expires":          {strings.Repeat("1", 150)},
+		"Etag":             {etag},
+		"Last-Modified":    {strings.Repeat("2", 150)},
+		"Vary":             {strings.Repeat("Accept, ", 50)},
+		"Content-Encoding": {strings.Repeat("gzip, ", 20)},
+	}}
+	doc, _ := html.Parse(strings.NewReader("<html></html>"))
+
+	entries := crawler.auditCaching(resp, doc, 0)
+	if len(entries) != 1 {
+		t.Fatalf("got %d entries, want the page only", len(entries))
+	}
+	entry := entries[0]
+
+	if entry.ConditionalStatus != http.StatusNotModified {
+		t.Errorf("conditional status = %d, want 304 for a revalidation with the full ETag", entry.ConditionalStatus)
+	}
+	if entry.MaxAge != 60 {
+		t.Errorf("max age = %d, want 60", entry.MaxAge)
+	}
+	columns := []struct {
+		name  string
+		value string
+		size  int
+	}{
+		{"url", entry.URL, 2048},
+		{"cache_control", entry.CacheControl, 512},
+		{"expires", entry.Expires, 100},
+		{"etag", entry.ETag, 255},
+		{"last_modified", entry.LastModified, 100},
+		{"vary", entry.Vary, 255},
+		{"content_encoding", entry.ContentEncoding, 50},
+	}
+	for _, column := range columns {
+		if len(column.value) != column.size {
+			t.Errorf("%s has %d characters, want it truncated to %d", column.name, len(column.value), column.size)
+		}
+	}
+}
@@ -0,0 +1,285 @@
+package services
+
+import (
+	"math"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/sykell/website-analyzer/models"
+	"golang.org/x/net/html"
+)
+
+const (
+	// DefaultMinWords is the main content word count below which a page is thin unless configured
+	DefaultMinWords = 300
+
+	// Number of keywords and n-grams kept per report
+	maxKeywords = 20
+	maxNgrams   = 10
+)
+
+// Class and id names of boilerplate inside the main content, e.g. "site-footer" or "cookie_banner"
+var boilerplateRegex = regexp.MustCompile(`(?i)(?:^|[-_ ])(?:nav|navbar|navigation|menu|sidebar|footer|cookies?|consent|breadcrumbs?|share|sharing|social|newsletter|related|advert|ads|skip-link)(?:[-_ ]|$)`)
+
+// Elements never part of the main content
+var boilerplateElements = map[string]bool{
+	"nav": true, "header": true, "footer": true, "aside": true, "form": true, "script": true, "style": true,
+	"noscript": true, "template": true, "svg": true, "button": true, "select": true, "iframe": true, "head": true,
+}
+
+// Roles of landmark elements that aren't main content
+var boilerplateRoles = map[string]bool{
+	"navigation": true, "banner": true, "contentinfo": true, "complementary": true, "search": true, "dialog": true,
+}
+
+// Elements starting a new block of text
+var blockElements = map[string]bool{
+	"p": true, "div": true, "li": true, "h1": true, "h2": true, "h3": true, "h4": true, "h5": true, "h6": true,
+	"td": true, "th": true, "tr": true, "section": true, "article": true, "main": true, "blockquote": true,
+	"pre": true, "dd": true, "dt": true, "figcaption": true, "br": true, "ul": true, "ol": true, "table": true,
+	"body": true, "hr": true, "address": true, "details": true, "summary": true,
+}
+
+// Characters ending a sentence, including the full-width ones of CJK text
+var sentenceEndRegex = regexp.MustCompile(`[.!?。！？]+(?:\s|$)|[。！？]`)
+
+// analyzeContent extracts the main content of the page and measures its
+// words, language, readability and keywords
+func (c *Crawler) analyzeContent(doc *html.Node, htmlSize int) *models.ContentReport {
+	report := &models.ContentReport{
+		WebsiteID: c.website.ID,
+		Keywords:  []models.KeywordCount{},
+		Bigrams:   []models.KeywordCount{},
+		Trigrams:  []models.KeywordCount{},
+	}
+
+	// Measure the whole page
+	pageText := strings.Join(strings.Fields(visibleText(doc)), " ")
+	report.WordCount = len(splitWords(pageText))
+	if htmlSize > 0 {
+		report.TextHTMLRatio = math.Round(float64(len(pageText))/float64(htmlSize)*10000) / 100
+	}
+	htmlSelector, _ := compileSelector("html")
+	if root := htmlSelector.MatchFirst(doc); root != nil {
+		report.DeclaredLanguage = truncateString(strings.TrimSpace(getAttr(root, "lang")), 35)
+	}
+
+	// Split the main content into sentences of words
+	root, from := mainContentRoot(doc)
+	report.MainContentFrom = from
+	var sentences [][]string
+	var words []string
+	for _, block := range textBlocks(root) {
+		for _, sentence := range sentenceEndRegex.Split(block, -1) {
+			if sentenceWords := splitWords(sentence); len(sentenceWords) > 0 {
+				sentences = append(sentences, sentenceWords)
+				words = append(words, sentenceWords...)
+			}
+		}
+	}
+	report.MainWordCount = len(words)
+	report.SentenceCount = len(sentences)
+
+	// Compare the detected language to the declared one by primary subtag
+	report.Language = detectLanguage(words)
+	if report.Language != "" && report.DeclaredLanguage != "" {
+		declared := strings.ToLower(strings.SplitN(strings.ReplaceAll(report.DeclaredLanguage, "_", "-"), "-", 2)[0])
+		report.LanguageMismatch = declared != report.Language
+	}
+
+	if formula, ok := readabilityFormulas[report.Language]; ok && len(words) > 0 {
+		syllables := 0
+		for _, word := range words {
+			syllables += countSyllables(word, report.Language)
+		}
+		asl := float64(len(words)) / float64(len(sentences))
+		asw := float64(syllables) / float64(len(words))
+		score := formula.score(asl, asw)
+		report.Readability = &models.Readability{
+			Formula:                 formula.name,
+			Score:                   math.Round(score*10) / 10,
+			Level:                   readabilityLevel(score),
+			AverageSentenceLength:   math.Round(asl*10) / 10,
+			AverageSyllablesPerWord: math.Round(asw*100) / 100,
+		}
+	}
+
+	report.Keywords = topNgrams(sentences, 1, report.Language, maxKeywords, len(words))
+	report.Bigrams = topNgrams(sentences, 2, report.Language, maxNgrams, len(words))
+	report.Trigrams = topNgrams(sentences, 3, report.Language, maxNgrams, len(words))
+
+	minWords := c.settings.MinWords
+	if minWords <= 0 {
+		minWords = DefaultMinWords
+	}
+	report.ThinContent = report.MainWordCount < minWords
+
+	return report
+}
+
+// mainContentRoot picks the element holding the main content: the main
+// element or landmark, the longest article, or the body as a fallback
+func mainContentRoot(doc *html.Node) (*html.Node, string) {
+	candidates := []struct{ selector, name string }{
+		{"main", "main"},
+		{"[role=main]", "role_main"},
+		{"article", "article"},
+	}
+	for _, candidate := range candidates {
+		selector, _ := compileSelector(candidate.selector)
+		var best *html.Node
+		bestWords := 0
+		for _, n := range selector.MatchAll(doc) {
+			if words := len(splitWords(strings.Join(textBlocks(n), " "))); words > bestWords {
+				best, bestWords = n, words
+			}
+		}
+		if best != nil {
+			return best, candidate.name
+		}
+	}
+
+	bodySelector, _ := compileSelector("body")
+	if body := bodySelector.MatchFirst(doc); body != nil {
+		return body, "body"
+	}
+	return doc, "document"
+}
+
+// isBoilerplate checks if an element is navigation, a footer, a form or similar
+func isBoilerplate(n *html.Node) bool {
+	if boilerplateElements[n.Data] || boilerplateRoles[strings.ToLower(getAttr(n, "role"))] {
+		return true
+	}
+	if _, hidden := lookupAttr(n, "hidden"); hidden || strings.EqualFold(getAttr(n, "aria-hidden"), "true") {
+		return true
+	}
+	return boilerplateRegex.MatchString(getAttr(n, "class")) || boilerplateRegex.MatchString(getAttr(n, "id"))
+}
+
+// textBlocks returns the normalized text of each block of an element, leaving out boilerplate
+func textBlocks(root *html.Node) []string {
+	var blocks []string
+	var sb strings.Builder
+	flush := func() {
+		if text := strings.Join(strings.Fields(sb.String()), " "); text != "" {
+			blocks = append(blocks, text)
+		}
+		sb.Reset()
+	}
+
+	var collectFunc func(*html.Node)
+	collectFunc = func(n *html.Node) {
+		if n.Type == html.ElementNode {
+			if n != root && isBoilerplate(n) {
+				return
+			}
+			if blockElements[n.Data] {
+				flush()
+				defer flush()
+			}
+		}
+		if n.Type == html.TextNode {
+			sb.WriteString(n.Data)
+		}
+		for child := n.FirstChild; child != nil; child = child.NextSibling {
+			collectFunc(child)
+		}
+	}
+	collectFunc(root)
+	flush()
+
+	return blocks
+}
+
+// splitWords splits text into words of letters and digits, keeping inner
+// apostrophes and hyphens. Each character of scripts written without spaces
+// counts as a word.
+func splitWords(text string) []string {
+	var words []string
+	var word []rune
+	runes := []rune(text)
+	for i, r := range runes {
+		switch {
+		case unicode.Is(unicode.Han, r) || unicode.Is(unicode.Hiragana, r) || unicode.Is(unicode.Katakana, r):
+			if len(word) > 0 {
+				words = append(words, string(word))
+				word = nil
+			}
+			words = append(words, string(r))
+		case unicode.IsLetter(r) || unicode.IsDigit(r) || unicode.Is(unicode.Mn, r):
+			word = append(word, r)
+		case (r == '\'' || r == '’' || r == '-') && len(word) > 0 && i+1 < len(runes) && unicode.IsLetter(runes[i+1]):
+			word = append(word, r)
+		default:
+			if len(word) > 0 {
+				words = append(words, string(word))
+				word = nil
+			}
+		}
+	}
+	if len(word) > 0 {
+		words = append(words, string(word))
+	}
+	return words
+}
+
+// topNgrams counts the phrases of n words within sentences and returns the
+// most frequent ones. Phrases starting or ending with a stopword, numbers
+// and, for phrases of several words, those seen only once are left out.
+func topNgrams(sentences [][]string, n int, language string, limit, totalWords int) []models.KeywordCount {
+	skip := stopwords[language]
+	if skip == nil {
+		skip = stopwords["en"]
+	}
+	isTerm := func(word string) bool {
+		if skip[word] || len([]rune(word)) < 2 {
+			return false
+		}
+		for _, r := range word {
+			if unicode.IsLetter(r) {
+				return true
+			}
+		}
+		return false
+	}
+
+	counts := map[string]int{}
+	for _, sentence := range sentences {
+		for i := 0; i+n <= len(sentence); i++ {
+			first, last := strings.ToLower(sentence[i]), strings.ToLower(sentence[i+n-1])
+			if !isTerm(first) || !isTerm(last) {
+				continue
+			}
+			terms := make([]string, n)
+			for j := range terms {
+				terms[j] = strings.ToLower(sentence[i+j])
+			}
+			counts[strings.Join(terms, " ")]++
+		}
+	}
+
+	keywords := []models.KeywordCount{}
+	for term, count := range counts {
+		if n > 1 && count < 2 {
+			continue
+		}
+		keywords = append(keywords, models.KeywordCount{
+			Term:    term,
+			Count:   count,
+			Density: math.Round(float64(count*n)/float64(totalWords)*10000) / 100,
+		})
+	}
+	sort.Slice(keywords, func(i, j int) bool {
+		if keywords[i].Count != keywords[j].Count {
+			return keywords[i].Count > keywords[j].Count
+		}
+		return keywords[i].Term < keywords[j].Term
+	})
+	if len(keywords) > limit {
+		keywords = keywords[:limit]
+	}
+	return keywords
+}
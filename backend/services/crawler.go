@@ -1,14 +1,16 @@
 package services
 
 import (
+	"errors"
 	"fmt"
 	"io"
+	"log"
 	"net/http"
 	"net/url"
 	"regexp"
+	"runtime/debug"
 	"strings"
 	"sync"
-	"time"
 
 	"github.com/sykell/website-analyzer/models"
 	"golang.org/x/net/html"
@@ -21,10 +23,27 @@ var (
 
 // Crawler represents a website crawler
 type Crawler struct {
-	website    *models.Website
-	baseURL    *url.URL
-	httpClient *http.Client
-	mutex      sync.Mutex
+	website         *models.Website
+	baseURL         *url.URL
+	pageURL         *url.URL
+	settings        *models.CrawlSettings
+	transport       *http.Transport
+	httpClient      *http.Client
+	linkClient      *http.Client
+	mutex           sync.Mutex
+	loginMutex      sync.Mutex
+	loginCount      int
+	pageTrace       *pageTrace
+	assets          []*assetResponse
+	assetsOnce      sync.Once
+	mxCache         map[string]bool
+	sitemap         []sitemapEntry
+	sitemapOnce     sync.Once
+	robots          *robotsRules
+	robotsOnce      sync.Once
+	notFound        map[string]*notFoundFingerprint
+	extractionRules []models.ExtractionRule
+	assertions      []models.Assertion
 }
 
 // NewCrawler creates a new crawler for a website
@@ -34,95 +53,231 @@ func NewCrawler(website *models.Website) (*Crawler, error) {
 		return nil, fmt.Errorf("invalid URL: %w", err)
 	}
 
-	httpClient := &http.Client{
-		Timeout: 30 * time.Second,
-		CheckRedirect: func(req *http.Request, via []*http.Request) error {
-			if len(via) >= 10 {
-				return fmt.Errorf("too many redirects")
-			}
-			return nil
-		},
+	// Load the HTTP client profile for the website
+	settings, err := models.GetCrawlSettings(website.ID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load crawl settings: %w", err)
+	}
+
+	// Load the extraction rules of the website and its account
+	extractionRules, err := models.GetEffectiveExtractionRules(website.UserID, website.ID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load extraction rules: %w", err)
+	}
+
+	// Load the assertions checked by every analysis
+	assertions, err := models.GetAssertions(website.ID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load assertions: %w", err)
+	}
+
+	crawler := &Crawler{
+		website:         website,
+		baseURL:         baseURL,
+		pageURL:         baseURL,
+		settings:        settings,
+		mutex:           sync.Mutex{},
+		extractionRules: extractionRules,
+		assertions:      assertions,
+	}
+	if err := crawler.newHTTPClients(); err != nil {
+		return nil, err
 	}
 
-	return &Crawler{
-		website:    website,
-		baseURL:    baseURL,
-		httpClient: httpClient,
-		mutex:      sync.Mutex{},
-	}, nil
+	return crawler, nil
 }
 
 // Crawl crawls the website and collects data
-func (c *Crawler) Crawl() error {
+func (c *Crawler) Crawl() (err error) {
+	// The status code of the page, 0 until a response is received
+	statusCode := 0
+
+	// Crawls run in the background, so a panic on malformed content must
+	// fail the analysis instead of taking down the server
+	defer func() {
+		if r := recover(); r != nil {
+			log.Printf("Analysis of website %d panicked: %v\n%s", c.website.ID, r, debug.Stack())
+			err = c.failAnalysis(statusCode, fmt.Sprintf("Analysis failed: %v", r), fmt.Errorf("analysis failed: %v", r))
+		}
+	}()
+
 	// Update status to running
-	err := models.UpdateWebsiteStatus(c.website.ID, "running", "")
+	err = models.UpdateWebsiteStatus(c.website.ID, "running", "")
 	if err != nil {
 		return err
 	}
 
+	// Log in first if the website has a login recipe
+	if c.settings.Login != nil {
+		c.loginCount++
+		if err := c.login(); err != nil {
+			return c.failAnalysis(statusCode, fmt.Sprintf("Failed to log in: %v", err), err)
+		}
+	}
+
+	// Inspect the HTTPS configuration first so certificate problems are
+	// recorded even when they make the page fetch fail
+	c.website.TLSReport = c.inspectTLS()
+	if err := models.SaveTLSReport(c.website.TLSReport); err != nil {
+		return c.failAnalysis(statusCode, fmt.Sprintf("Failed to save TLS report: %v", err), err)
+	}
+
 	// Get the HTML content
-	resp, err := c.httpClient.Get(c.website.URL)
+	resp, err := c.fetchPage()
 	if err != nil {
-		errMsg := fmt.Sprintf("Failed to fetch URL: %v", err)
-		models.UpdateWebsiteStatus(c.website.ID, "error", errMsg)
-		return err
+		return c.failAnalysis(statusCode, fmt.Sprintf("Failed to fetch URL: %v", err), err)
 	}
 	defer resp.Body.Close()
+	c.pageURL = resp.Request.URL
+	statusCode = resp.StatusCode
 
 	// Check if the response is successful
 	if resp.StatusCode != http.StatusOK {
 		errMsg := fmt.Sprintf("HTTP status code: %d", resp.StatusCode)
-		models.UpdateWebsiteStatus(c.website.ID, "error", errMsg)
-		return fmt.Errorf(errMsg)
+		return c.failAnalysis(statusCode, errMsg, errors.New(errMsg))
+	}
+
+	// Drop the results of the previous analysis so none of them are saved again
+	c.resetAnalysis()
+
+	// Analyze PDFs, text and other content types separately from HTML
+	if kind, mediaType := detectDocumentKind(resp); kind != documentHTML {
+		return c.analyzeDocument(resp, kind, mediaType)
 	}
 
 	// Read the HTML content
 	body, err := io.ReadAll(resp.Body)
 	if err != nil {
-		errMsg := fmt.Sprintf("Failed to read response body: %v", err)
-		models.UpdateWebsiteStatus(c.website.ID, "error", errMsg)
-		return err
+		return c.failAnalysis(statusCode, fmt.Sprintf("Failed to read response body: %v", err), err)
 	}
+	c.pageTrace.finish()
 	htmlContent := string(body)
 
 	// Parse the HTML
 	doc, err := html.Parse(strings.NewReader(htmlContent))
 	if err != nil {
-		errMsg := fmt.Sprintf("Failed to parse HTML: %v", err)
-		models.UpdateWebsiteStatus(c.website.ID, "error", errMsg)
-		return err
+		return c.failAnalysis(statusCode, fmt.Sprintf("Failed to parse HTML: %v", err), err)
 	}
 
-	// Initialize data structures
-	c.website.HeadingCounts = &models.HeadingCounts{WebsiteID: c.website.ID}
-	c.website.LinkCounts = &models.LinkCounts{WebsiteID: c.website.ID}
-	c.website.BrokenLinks = []models.BrokenLink{}
-
 	// Extract information
 	htmlVersion := c.detectHTMLVersion(htmlContent)
 	c.website.HTMLVersionStr = htmlVersion
-	
+
 	title := c.extractTitle(doc)
 	c.website.TitleStr = title
-	
+
 	c.extractHeadingCounts(doc)
 	c.extractLinks(doc)
-	
+
 	// Check for login form
 	c.website.LinkCounts.HasLoginForm = c.detectLoginForm(doc, htmlContent)
 
+	// Audit the security headers of the response
+	c.website.SecurityHeaders = c.analyzeSecurityHeaders(resp)
+	c.website.SecurityGradeStr = c.website.SecurityHeaders.Grade
+
+	// Find insecure subresources on HTTPS pages
+	c.website.MixedContent = c.detectMixedContent(doc)
+
+	// Report page timings and weight
+	c.website.Performance = c.analyzePerformance(resp, doc, len(body))
+
+	// Evaluate caching and compression of the page and its assets
+	c.website.CacheEntries = c.auditCaching(resp, doc, len(body))
+
+	// Audit the dimensions, format, weight and loading of the images
+	c.website.Images = c.auditImages(doc)
+
+	// Identify the site's technology stack
+	c.website.Technologies = c.detectTechnologies(resp, doc, htmlContent)
+
+	// Extract and validate structured data
+	c.website.StructuredData = c.extractStructuredData(doc)
+
+	// Measure the words, language, readability and keywords of the content
+	c.website.Content = c.analyzeContent(doc, len(body))
+
+	// Check the hreflang alternates and their return links
+	c.website.HreflangFindings = c.checkHreflang(resp, doc)
+
+	// Discover and validate the RSS and Atom feeds
+	c.website.Feeds = c.checkFeeds(doc)
+
+	// Check the favicons, web app manifest and service worker
+	c.website.PWA = c.checkPWA(doc)
+
+	// Fetch and validate security.txt, ads.txt, change-password and humans.txt
+	c.website.WellKnownFiles = c.checkWellKnownFiles()
+
+	// Evaluate the custom extraction rules
+	c.website.Extractions = c.applyExtractionRules(doc)
+
+	// Follow internal links to build the link graph
+	c.website.LinkGraph = c.buildLinkGraph(doc, resp.StatusCode)
+
+	// Inventory third-party domains, trackers and cookies
+	c.website.ThirdParties, c.website.Cookies, err = c.analyzeThirdParties(resp, c.fetchAssets(c.collectResources(doc)))
+	if err != nil {
+		return c.failAnalysis(statusCode, fmt.Sprintf("Failed to analyze third parties: %v", err), err)
+	}
+
+	// Check the assertions of the website
+	c.website.AssertionResults, c.website.HealthStr = c.evaluateAssertions(resp.StatusCode, doc)
+
 	// Update status to done
 	c.website.Status = "done"
 	err = models.UpdateWebsiteData(c.website)
 	if err != nil {
-		errMsg := fmt.Sprintf("Failed to update website data: %v", err)
-		models.UpdateWebsiteStatus(c.website.ID, "error", errMsg)
-		return err
+		return c.failAnalysis(statusCode, fmt.Sprintf("Failed to update website data: %v", err), err)
 	}
 
 	return nil
 }
 
+// failAnalysis marks the analysis as failed with a message and evaluates the
+// assertions against the failure. Every failure goes through here so that
+// monitored websites don't keep the health of their previous analysis.
+func (c *Crawler) failAnalysis(statusCode int, errMsg string, err error) error {
+	c.recordFailedAnalysis(statusCode)
+	models.UpdateWebsiteStatus(c.website.ID, "error", errMsg)
+	return err
+}
+
+// resetAnalysis clears every field of the website that an analysis fills in.
+// Empty lists replace the saved rows and nil reports delete them, so results
+// of the previous analysis that the HTML or document path doesn't produce
+// again are removed.
+func (c *Crawler) resetAnalysis() {
+	c.website.TitleStr = ""
+	c.website.HTMLVersionStr = ""
+	c.website.SecurityGradeStr = ""
+	c.website.HealthStr = ""
+
+	c.website.HeadingCounts = &models.HeadingCounts{WebsiteID: c.website.ID}
+	c.website.LinkCounts = &models.LinkCounts{WebsiteID: c.website.ID}
+	c.website.BrokenLinks = []models.BrokenLink{}
+	c.website.SkippedLinks = []models.SkippedLink{}
+	c.website.PageLinks = []models.PageLink{}
+	c.website.SecurityHeaders = nil
+	c.website.MixedContent = []models.MixedContentItem{}
+	c.website.Performance = nil
+	c.website.CacheEntries = []models.CacheEntry{}
+	c.website.Technologies = []models.Technology{}
+	c.website.ThirdParties = []models.ThirdPartyDomain{}
+	c.website.Cookies = []models.PageCookie{}
+	c.website.StructuredData = []models.StructuredDataItem{}
+	c.website.Extractions = []models.ExtractionResult{}
+	c.website.AssertionResults = []models.AssertionResult{}
+	c.website.Content = nil
+	c.website.HreflangFindings = []models.HreflangFinding{}
+	c.website.Images = []models.PageImage{}
+	c.website.Document = nil
+	c.website.Feeds = []models.Feed{}
+	c.website.PWA = nil
+	c.website.WellKnownFiles = []models.WellKnownFile{}
+	c.website.LinkGraph = nil
+}
+
 // detectHTMLVersion detects the HTML version of the document
 func (c *Crawler) detectHTMLVersion(htmlContent string) string {
 	// Check for HTML5
@@ -193,13 +348,13 @@ func (c *Crawler) extractHeadingCounts(doc *html.Node) {
 
 // extractLinks extracts and categorizes links in the document
 func (c *Crawler) extractLinks(doc *html.Node) {
-	var links []string
+	var links []*html.Node
 	var extractLinksFunc func(*html.Node)
 	extractLinksFunc = func(n *html.Node) {
 		if n.Type == html.ElementNode && n.Data == "a" {
 			for _, attr := range n.Attr {
 				if attr.Key == "href" {
-					links = append(links, attr.Val)
+					links = append(links, n)
 					break
 				}
 			}
@@ -210,42 +365,111 @@ func (c *Crawler) extractLinks(doc *html.Node) {
 	}
 	extractLinksFunc(doc)
 
+	hrefs := make([]string, len(links))
+	for i, anchor := range links {
+		hrefs[i] = getAttr(anchor, "href")
+	}
+	c.checkLinks(hrefs, links)
+}
+
+// checkLinks categorizes links and checks them. The anchors are optional
+// and used to audit the link attributes when the links come from HTML.
+func (c *Crawler) checkLinks(hrefs []string, anchors []*html.Node) {
 	// Process the links in batches to check accessibility
 	var wg sync.WaitGroup
 	semaphore := make(chan struct{}, 10) // Limit concurrency
 
-	for _, link := range links {
+	c.website.LinkCounts.SchemeCounts = map[string]int{}
+	for i, href := range hrefs {
 		// Parse the link
-		parsedLink, err := c.resolveURL(link)
+		parsedLink, err := c.resolveURL(href)
 		if err != nil {
 			continue
 		}
 
-		// Check if the link is internal or external
-		if c.isInternalLink(parsedLink) {
+		// Keep the link attributes and audit them
+		if anchors != nil {
+			pageLink := c.auditLinkAttributes(anchors[i], parsedLink)
+			c.website.PageLinks = append(c.website.PageLinks, pageLink)
+		}
+
+		// Count the link by scheme, then handle it according to its scheme
+		c.mutex.Lock()
+		c.website.LinkCounts.SchemeCounts[parsedLink.Scheme]++
+		c.mutex.Unlock()
+
+		switch parsedLink.Scheme {
+		case "http", "https":
+			// Handled below
+		case "mailto", "tel", "sms":
+			// Validate contact links without requesting them
+			wg.Add(1)
+			go func(link *url.URL) {
+				defer wg.Done()
+				semaphore <- struct{}{}        // Acquire token
+				defer func() { <-semaphore }() // Release token
+
+				if reason := c.checkContactLink(link); reason != "" {
+					c.mutex.Lock()
+					c.website.BrokenLinks = append(c.website.BrokenLinks, models.BrokenLink{
+						WebsiteID: c.website.ID,
+						URL:       truncateString(link.String(), 2048),
+						Reason:    reason,
+					})
+					c.mutex.Unlock()
+				}
+			}(parsedLink)
+			continue
+		default:
+			// Skip schemes that cannot be checked
 			c.mutex.Lock()
-			c.website.LinkCounts.InternalLinks++
+			c.website.SkippedLinks = append(c.website.SkippedLinks, models.SkippedLink{
+				WebsiteID:      c.website.ID,
+				URL:            truncateString(parsedLink.String(), 2048), // Inline data: URLs can be very long
+				Scheme:         truncateString(parsedLink.Scheme, 50),
+				Classification: schemeClassification(parsedLink.Scheme),
+			})
 			c.mutex.Unlock()
-		} else {
-			c.mutex.Lock()
+			continue
+		}
+
+		// Check if the link is internal, on a subdomain or external
+		c.mutex.Lock()
+		switch c.linkBucket(parsedLink) {
+		case linkInternal:
+			c.website.LinkCounts.InternalLinks++
+		case linkSubdomain:
+			c.website.LinkCounts.SubdomainLinks++
+		default:
 			c.website.LinkCounts.ExternalLinks++
-			c.mutex.Unlock()
 		}
+		c.mutex.Unlock()
 
 		// Check if the link is accessible
 		wg.Add(1)
 		go func(url string) {
 			defer wg.Done()
-			semaphore <- struct{}{} // Acquire token
+			semaphore <- struct{}{}        // Acquire token
 			defer func() { <-semaphore }() // Release token
 
 			statusCode, err := c.checkLinkAccessibility(url)
-			if err != nil || statusCode >= 400 {
+			reason := ""
+			switch {
+			case err != nil:
+				reason = "unreachable"
+			case statusCode >= 400:
+				reason = "http_error"
+			case statusCode < 300 && c.isSoft404(url):
+				// Not-found pages served with a success status
+				reason = "soft_404"
+			}
+			if reason != "" {
 				c.mutex.Lock()
 				c.website.BrokenLinks = append(c.website.BrokenLinks, models.BrokenLink{
 					WebsiteID:  c.website.ID,
 					URL:        url,
 					StatusCode: statusCode,
+					Reason:     reason,
 				})
 				c.mutex.Unlock()
 			}
@@ -257,8 +481,8 @@ func (c *Crawler) extractLinks(doc *html.Node) {
 
 // resolveURL resolves a relative URL to an absolute URL
 func (c *Crawler) resolveURL(href string) (*url.URL, error) {
-	// Handle empty hrefs
-	if href == "" || href == "#" || strings.HasPrefix(href, "javascript:") {
+	// Handle empty hrefs. Other schemes, like javascript:, are classified by the caller.
+	if href == "" || href == "#" {
 		return nil, fmt.Errorf("invalid URL")
 	}
 
@@ -277,34 +501,10 @@ func (c *Crawler) resolveURL(href string) (*url.URL, error) {
 	return c.baseURL.ResolveReference(parsedURL), nil
 }
 
-// isInternalLink checks if a URL is internal to the website
-func (c *Crawler) isInternalLink(parsedURL *url.URL) bool {
-	return parsedURL.Host == "" || parsedURL.Host == c.baseURL.Host
-}
-
 // checkLinkAccessibility checks if a link is accessible
 func (c *Crawler) checkLinkAccessibility(link string) (int, error) {
-	// Create a new request
-	req, err := http.NewRequest("HEAD", link, nil)
-	if err != nil {
-		return 0, err
-	}
-
-	// Set a user agent
-	req.Header.Set("User-Agent", "WebsiteAnalyzer/1.0")
-
-	// Send the request
-	client := &http.Client{
-		Timeout: 5 * time.Second,
-		CheckRedirect: func(req *http.Request, via []*http.Request) error {
-			if len(via) >= 5 {
-				return fmt.Errorf("too many redirects")
-			}
-			return nil
-		},
-	}
-
-	resp, err := client.Do(req)
+	// Send the request with the website's HTTP client profile
+	resp, err := c.doAuthenticated(c.linkClient, "HEAD", link)
 	if err != nil {
 		return 0, err
 	}
@@ -344,4 +544,4 @@ func (c *Crawler) detectLoginForm(doc *html.Node, htmlContent string) bool {
 	checkFormsFunc(doc)
 
 	return hasPasswordField
-} 
\ No newline at end of file
+}
@@ -0,0 +1,194 @@
+package services
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"path"
+	"regexp"
+	"strings"
+
+	"github.com/sykell/website-analyzer/models"
+	"golang.org/x/net/html"
+)
+
+// maxDocumentSize limits how much of a PDF or text document is read
+const maxDocumentSize = 50 << 20
+
+// Kinds of documents the URL can return
+const (
+	documentHTML     = "html"
+	documentPDF      = "pdf"
+	documentMarkdown = "markdown"
+	documentText     = "text"
+	documentOther    = "other"
+)
+
+var (
+	// Inline links and images, e.g. [text](url "title")
+	markdownLinkRegex = regexp.MustCompile(`\]\(\s*<?([^)\s>]+)>?(?:\s+(?:"[^"]*"|'[^']*'))?\s*\)`)
+	// Reference definitions, e.g. [id]: url
+	markdownReferenceRegex = regexp.MustCompile(`(?m)^ {0,3}\[[^\]]+\]:\s*<?([^\s>]+)>?`)
+	// Autolinks, e.g. <https://example.com> or <mailto:someone@example.com>
+	autolinkRegex = regexp.MustCompile(`<((?:https?|mailto):[^>\s]+)>`)
+	// Bare URLs in text
+	bareURLRegex = regexp.MustCompile(`https?://[^\s<>"'()\[\]{}]+`)
+	// The first level heading of a markdown document
+	markdownTitleRegex = regexp.MustCompile(`(?m)^ {0,3}#[ \t]+(.+?)(?:[ \t]+#+)?[ \t]*$`)
+)
+
+// detectDocumentKind tells HTML apart from PDFs, text and other content from
+// the Content-Type header, sniffing the start of the body when the header is
+// missing or generic
+func detectDocumentKind(resp *http.Response) (string, string) {
+	reader := bufio.NewReader(resp.Body)
+	resp.Body = struct {
+		io.Reader
+		io.Closer
+	}{reader, resp.Body}
+	peek, _ := reader.Peek(512)
+
+	mediaType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	mediaType = strings.ToLower(mediaType)
+	if mediaType == "" || mediaType == "application/octet-stream" {
+		mediaType, _, _ = mime.ParseMediaType(http.DetectContentType(peek))
+	}
+
+	switch {
+	case mediaType == "application/pdf" || bytes.HasPrefix(bytes.TrimLeft(peek, " \t\r\n"), []byte("%PDF-")):
+		return documentPDF, "application/pdf"
+	case mediaType == "text/html" || mediaType == "application/xhtml+xml":
+		return documentHTML, mediaType
+	case mediaType == "text/markdown" || mediaType == "text/x-markdown":
+		return documentMarkdown, mediaType
+	case mediaType == "text/plain":
+		// Markdown files are often served as plain text
+		if extension := strings.ToLower(path.Ext(resp.Request.URL.Path)); extension == ".md" || extension == ".markdown" {
+			return documentMarkdown, mediaType
+		}
+		return documentText, mediaType
+	}
+	return documentOther, mediaType
+}
+
+// analyzeDocument records the type and size of a response that isn't HTML.
+// PDFs get their title, page count, metadata and links, text and markdown
+// get their links, and the links are checked like those of HTML pages.
+// Other content types are marked as not analyzable.
+func (c *Crawler) analyzeDocument(resp *http.Response, kind, mediaType string) error {
+	document := &models.Document{
+		WebsiteID:   c.website.ID,
+		ContentType: truncateString(mediaType, 255),
+		Kind:        kind,
+		Metadata:    map[string]string{},
+	}
+	c.website.Document = document
+
+	// Audit the security headers of the response
+	c.website.SecurityHeaders = c.analyzeSecurityHeaders(resp)
+	c.website.SecurityGradeStr = c.website.SecurityHeaders.Grade
+
+	if kind == documentOther {
+		// Measure the size without downloading the content when it's declared
+		document.Size = resp.ContentLength
+		if document.Size < 0 {
+			size, err := io.Copy(io.Discard, io.LimitReader(resp.Body, maxDocumentSize))
+			if err != nil {
+				return c.failAnalysis(resp.StatusCode, fmt.Sprintf("Failed to read response body: %v", err), err)
+			}
+			document.Size = size
+		}
+		c.pageTrace.finish()
+
+		// Check the assertions of the website
+		c.website.AssertionResults, c.website.HealthStr = c.evaluateAssertions(resp.StatusCode, nil)
+
+		// Update status to not analyzable
+		c.website.Status = "not_analyzable"
+		if err := models.UpdateWebsiteData(c.website); err != nil {
+			return c.failAnalysis(resp.StatusCode, fmt.Sprintf("Failed to update website data: %v", err), err)
+		}
+		errMsg := fmt.Sprintf("Content type %q cannot be analyzed", mediaType)
+		return models.UpdateWebsiteStatus(c.website.ID, "not_analyzable", errMsg)
+	}
+
+	// Read the document
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxDocumentSize))
+	if err != nil {
+		return c.failAnalysis(resp.StatusCode, fmt.Sprintf("Failed to read response body: %v", err), err)
+	}
+	c.pageTrace.finish()
+	document.Size = int64(len(body))
+	document.Analyzable = true
+
+	var links []string
+	switch kind {
+	case documentPDF:
+		pdf, err := parsePDF(body)
+		if err != nil {
+			return c.failAnalysis(resp.StatusCode, fmt.Sprintf("Failed to parse PDF: %v", err), err)
+		}
+		document.Version = pdf.version
+		document.PageCount = pdf.pageCount
+		document.Encrypted = pdf.encrypted
+		document.Metadata = pdf.info
+		c.website.TitleStr = truncateString(pdf.info["Title"], 255)
+		links = pdf.links
+	default:
+		c.website.TitleStr, links = extractTextLinks(string(body), kind == documentMarkdown)
+	}
+	document.LinkCount = len(links)
+
+	// Check the links of the document
+	c.checkLinks(links, nil)
+
+	// Check the assertions of the website against an empty page, so title and
+	// broken link assertions apply to the document
+	empty, _ := html.Parse(strings.NewReader(""))
+	c.website.AssertionResults, c.website.HealthStr = c.evaluateAssertions(resp.StatusCode, empty)
+
+	// Update status to done
+	c.website.Status = "done"
+	if err := models.UpdateWebsiteData(c.website); err != nil {
+		return c.failAnalysis(resp.StatusCode, fmt.Sprintf("Failed to update website data: %v", err), err)
+	}
+
+	return nil
+}
+
+// extractTextLinks returns the title and links of a text document. Plain
+// text only has bare URLs, markdown also has inline links, reference
+// definitions, autolinks and a first level heading as its title.
+func extractTextLinks(text string, markdown bool) (string, []string) {
+	var links []string
+	seen := map[string]bool{}
+	add := func(link string) {
+		if link != "" && !seen[link] {
+			seen[link] = true
+			links = append(links, link)
+		}
+	}
+
+	title := ""
+	if markdown {
+		if match := markdownTitleRegex.FindStringSubmatch(text); match != nil {
+			title = truncateString(strings.TrimSpace(match[1]), 255)
+		}
+		for _, regex := range []*regexp.Regexp{markdownLinkRegex, markdownReferenceRegex, autolinkRegex} {
+			for _, match := range regex.FindAllStringSubmatch(text, -1) {
+				// Fragments point within the document
+				if !strings.HasPrefix(match[1], "#") {
+					add(match[1])
+				}
+			}
+		}
+	}
+	for _, match := range bareURLRegex.FindAllString(text, -1) {
+		add(strings.TrimRight(match, ".,;:!?*_~`"))
+	}
+
+	return title, links
+}
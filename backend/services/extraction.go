@@ -0,0 +1,219 @@
+package services
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/sykell/website-analyzer/models"
+	"golang.org/x/net/html"
+)
+
+// maxExtractedValues limits how many values a rule with multiple matches keeps
+const maxExtractedValues = 100
+
+// maxExtractedLength limits the length of each extracted value, in characters
+const maxExtractedLength = 10000
+
+// Numbers with optional thousands separators and decimals, e.g. "1,299.00" or "1 299,00"
+var numberRegex = regexp.MustCompile(`-?\d+(?:[,. \x{00a0}]\d{3})*(?:[,.]\d+)?`)
+
+// ValidateExtractionRule checks that the selector and regular expression of a rule compile
+func ValidateExtractionRule(rule *models.ExtractionRule) error {
+	if _, err := compileExtractionMatcher(rule); err != nil {
+		return err
+	}
+	if rule.Regex != "" {
+		if _, err := regexp.Compile(rule.Regex); err != nil {
+			return fmt.Errorf("invalid regex: %w", err)
+		}
+	}
+	return nil
+}
+
+// extractionMatcher selects the items a rule extracts from
+type extractionMatcher func(doc *html.Node) []xpathItem
+
+// compileExtractionMatcher compiles the CSS selector or XPath of a rule
+func compileExtractionMatcher(rule *models.ExtractionRule) (extractionMatcher, error) {
+	if rule.SelectorType == "xpath" {
+		expr, err := compileXPath(rule.Selector)
+		if err != nil {
+			return nil, err
+		}
+		return expr.Select, nil
+	}
+
+	selector, err := compileSelector(rule.Selector)
+	if err != nil {
+		return nil, err
+	}
+	return func(doc *html.Node) []xpathItem {
+		var items []xpathItem
+		for _, n := range selector.MatchAll(doc) {
+			items = append(items, xpathItem{node: n})
+		}
+		return items
+	}, nil
+}
+
+// applyExtractionRules evaluates the website's extraction rules on the page.
+// Rules that fail, e.g. because a value doesn't have the rule's type, report
+// the error in their result instead of failing the analysis.
+func (c *Crawler) applyExtractionRules(doc *html.Node) []models.ExtractionResult {
+	results := []models.ExtractionResult{}
+	for i := range c.extractionRules {
+		rule := &c.extractionRules[i]
+		result := models.ExtractionResult{WebsiteID: c.website.ID, RuleName: rule.Name, DataType: rule.DataType}
+		value, err := extractValue(rule, doc)
+		if err != nil {
+			result.Error = truncateString(err.Error(), 512)
+		} else {
+			result.Value = value
+		}
+		results = append(results, result)
+	}
+	return results
+}
+
+// extractValue evaluates one rule and converts the extracted strings to the rule's type
+func extractValue(rule *models.ExtractionRule, doc *html.Node) (interface{}, error) {
+	matcher, err := compileExtractionMatcher(rule)
+	if err != nil {
+		return nil, err
+	}
+	var pattern *regexp.Regexp
+	if rule.Regex != "" {
+		if pattern, err = regexp.Compile(rule.Regex); err != nil {
+			return nil, fmt.Errorf("invalid regex: %w", err)
+		}
+	}
+
+	items := matcher(doc)
+	if rule.Extract == models.ExtractCount {
+		return len(items), nil
+	}
+
+	var values []interface{}
+	for _, item := range items {
+		raw, ok := itemValue(rule, item)
+		if !ok {
+			continue
+		}
+
+		// Keep the first group of the regex, or the whole match without groups
+		if pattern != nil {
+			match := pattern.FindStringSubmatch(raw)
+			if match == nil {
+				continue
+			}
+			raw = match[0]
+			if len(match) > 1 {
+				raw = match[1]
+			}
+		}
+
+		value, err := convertValue(raw, rule.DataType)
+		if err != nil {
+			return nil, err
+		}
+		values = append(values, value)
+		if !rule.Multiple || len(values) >= maxExtractedValues {
+			break
+		}
+	}
+
+	if rule.Multiple {
+		if values == nil {
+			values = []interface{}{}
+		}
+		return values, nil
+	}
+	if len(values) == 0 {
+		return nil, nil
+	}
+	return values[0], nil
+}
+
+// itemValue returns the text, HTML or attribute of a matched item. Attributes
+// selected by XPath are used as they are.
+func itemValue(rule *models.ExtractionRule, item xpathItem) (string, bool) {
+	var value string
+	switch {
+	case item.attr != nil:
+		value = item.attr.Val
+	case rule.Extract == models.ExtractAttribute:
+		attr, ok := lookupAttr(item.node, strings.ToLower(rule.Attribute))
+		if !ok {
+			return "", false
+		}
+		value = attr
+	case rule.Extract == models.ExtractHTML:
+		var sb strings.Builder
+		if err := html.Render(&sb, item.node); err != nil {
+			return "", false
+		}
+		value = sb.String()
+	default:
+		value = strings.Join(strings.Fields(textContent(item.node)), " ")
+	}
+	return truncateString(strings.TrimSpace(value), maxExtractedLength), true
+}
+
+// convertValue converts an extracted string to the rule's data type
+func convertValue(raw, dataType string) (interface{}, error) {
+	switch dataType {
+	case models.DataTypeNumber, models.DataTypeInteger:
+		number, err := parseNumber(raw)
+		if err != nil {
+			return nil, err
+		}
+		if dataType == models.DataTypeInteger {
+			if number != float64(int64(number)) {
+				return nil, fmt.Errorf("%q is not an integer", raw)
+			}
+			return int64(number), nil
+		}
+		return number, nil
+	case models.DataTypeBoolean:
+		switch strings.ToLower(raw) {
+		case "true", "yes", "on", "1":
+			return true, nil
+		case "false", "no", "off", "0", "":
+			return false, nil
+		}
+		return nil, fmt.Errorf("%q is not a boolean", raw)
+	}
+	return raw, nil
+}
+
+// parseNumber reads a number from text like "$1,299.00" or "1.299,00 €". The
+// last comma or dot is a thousands separator when exactly three digits follow
+// it, unless the other separator comes before it or the integer part is zero.
+func parseNumber(raw string) (float64, error) {
+	match := strings.NewReplacer(" ", "", "\u00a0", "").Replace(numberRegex.FindString(raw))
+	if match == "" {
+		return 0, fmt.Errorf("%q is not a number", raw)
+	}
+
+	if separator := strings.LastIndexAny(match, ",."); separator >= 0 {
+		integer, fraction := match[:separator], match[separator+1:]
+		other := "."
+		if match[separator] == '.' {
+			other = ","
+		}
+		digits := strings.NewReplacer(",", "", ".", "").Replace(integer)
+		if len(fraction) != 3 || strings.Contains(integer, other) || strings.TrimPrefix(integer, "-") == "0" {
+			match = digits + "." + fraction
+		} else {
+			match = digits + fraction
+		}
+	}
+
+	number, err := strconv.ParseFloat(match, 64)
+	if err != nil {
+		return 0, fmt.Errorf("%q is not a number", raw)
+	}
+	return number, nil
+}
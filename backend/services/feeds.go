@@ -0,0 +1,482 @@
+package services
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sykell/website-analyzer/models"
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/charset"
+)
+
+const (
+	// maxFeeds limits how many feeds declared by the page are checked
+	maxFeeds = 10
+
+	// maxFeedSize limits how much of a feed is parsed
+	maxFeedSize = 10 << 20
+
+	// maxFeedItemChecks limits how many item links are checked per feed
+	maxFeedItemChecks = 50
+
+	atomNamespace = "http://www.w3.org/2005/Atom"
+)
+
+// Paths where feeds are commonly published without being declared
+var commonFeedPaths = []string{"/feed", "/rss", "/rss.xml", "/feed.xml", "/atom.xml", "/index.xml"}
+
+// Media types of feed link elements
+var feedMediaTypes = map[string]bool{"application/rss+xml": true, "application/atom+xml": true, "application/rdf+xml": true}
+
+// Layouts of RSS dates, which should be RFC 822 but often aren't
+var feedDateLayouts = []string{
+	time.RFC1123Z, time.RFC1123, "Mon, 2 Jan 2006 15:04:05 -0700", "Mon, 2 Jan 2006 15:04:05 MST",
+	"2 Jan 2006 15:04:05 -0700", "2 Jan 2006 15:04:05 MST", time.RFC822Z, time.RFC822, "Mon, 2 Jan 06 15:04:05 -0700",
+	"Mon, 2 Jan 2006 15:04 -0700", "Mon, 2 Jan 2006 15:04:05",
+}
+
+// feedLink is a link element of an RSS or Atom document
+type feedLink struct {
+	XMLName xml.Name
+	Rel     string `xml:"rel,attr"`
+	Href    string `xml:"href,attr"`
+	Value   string `xml:",chardata"`
+}
+
+// rssDocument is an RSS 0.9x or 2.0 document
+type rssDocument struct {
+	Version string `xml:"version,attr"`
+	Channel struct {
+		Title         string     `xml:"title"`
+		Links         []feedLink `xml:"link"`
+		Description   *string    `xml:"description"`
+		LastBuildDate string     `xml:"lastBuildDate"`
+		PubDate       string     `xml:"pubDate"`
+		Items         []struct {
+			Title       string `xml:"title"`
+			Link        string `xml:"link"`
+			Description string `xml:"description"`
+			PubDate     string `xml:"pubDate"`
+			GUID        struct {
+				Value       string `xml:",chardata"`
+				IsPermaLink string `xml:"isPermaLink,attr"`
+			} `xml:"guid"`
+		} `xml:"item"`
+	} `xml:"channel"`
+}
+
+// rdfDocument is an RSS 1.0 document
+type rdfDocument struct {
+	Channel struct {
+		Title string `xml:"title"`
+		Link  string `xml:"link"`
+		Date  string `xml:"http://purl.org/dc/elements/1.1/ date"`
+	} `xml:"channel"`
+	Items []struct {
+		Title string `xml:"title"`
+		Link  string `xml:"link"`
+		Date  string `xml:"http://purl.org/dc/elements/1.1/ date"`
+	} `xml:"item"`
+}
+
+// atomDocument is an Atom 1.0 document
+type atomDocument struct {
+	XMLName xml.Name
+	ID      string     `xml:"id"`
+	Title   string     `xml:"title"`
+	Updated string     `xml:"updated"`
+	Links   []feedLink `xml:"link"`
+	Entries []struct {
+		ID        string     `xml:"id"`
+		Title     string     `xml:"title"`
+		Updated   string     `xml:"updated"`
+		Published string     `xml:"published"`
+		Links     []feedLink `xml:"link"`
+	} `xml:"entry"`
+}
+
+// parsedFeed is the common view of a feed, whatever its format
+type parsedFeed struct {
+	format      string
+	version     string
+	title       string
+	errors      []string
+	itemCount   int
+	lastUpdated *time.Time
+	selfLink    string
+	itemLinks   []string
+}
+
+// checkFeeds discovers the RSS and Atom feeds declared by the page and
+// published at common paths, parses and validates them, and checks the
+// links of their items
+func (c *Crawler) checkFeeds(doc *html.Node) []models.Feed {
+	feeds := []models.Feed{}
+
+	// Feeds declared by the page
+	var declared []string
+	seen := map[string]bool{}
+	selector, _ := compileSelector("link[rel][type][href]")
+	for _, link := range selector.MatchAll(doc) {
+		mediaType := strings.ToLower(strings.TrimSpace(strings.SplitN(getAttr(link, "type"), ";", 2)[0]))
+		if !feedMediaTypes[mediaType] || !containsString(strings.Fields(strings.ToLower(getAttr(link, "rel"))), "alternate") {
+			continue
+		}
+		feedURL, err := c.pageURL.Parse(strings.TrimSpace(getAttr(link, "href")))
+		if err != nil || (feedURL.Scheme != "http" && feedURL.Scheme != "https") || seen[feedURL.String()] || len(declared) >= maxFeeds {
+			continue
+		}
+		seen[feedURL.String()] = true
+		declared = append(declared, feedURL.String())
+	}
+
+	// Feeds at common paths of the site
+	var probed []string
+	for _, feedPath := range commonFeedPaths {
+		feedURL := &url.URL{Scheme: c.pageURL.Scheme, Host: c.pageURL.Host, Path: feedPath}
+		if !seen[feedURL.String()] {
+			seen[feedURL.String()] = true
+			probed = append(probed, feedURL.String())
+		}
+	}
+
+	type fetchedFeed struct {
+		feed     models.Feed
+		finalURL string
+	}
+	results := make([]fetchedFeed, len(declared)+len(probed))
+	var wg sync.WaitGroup
+	semaphore := make(chan struct{}, 10) // Limit concurrency
+	for i, feedURL := range append(declared, probed...) {
+		wg.Add(1)
+		go func(i int, feedURL string) {
+			defer wg.Done()
+			semaphore <- struct{}{}        // Acquire token
+			defer func() { <-semaphore }() // Release token
+
+			feed, finalURL := c.checkFeed(feedURL)
+			results[i] = fetchedFeed{feed, finalURL}
+		}(i, feedURL)
+	}
+	wg.Wait()
+
+	// Keep every declared feed, and probed paths only when they are feeds not declared already
+	known := map[string]bool{}
+	for i, result := range results {
+		if i >= len(declared) && (result.feed.Format == "" || known[result.finalURL]) {
+			continue
+		}
+		result.feed.Source = "link"
+		if i >= len(declared) {
+			result.feed.Source = "path"
+		}
+		known[result.finalURL] = true
+		feeds = append(feeds, result.feed)
+	}
+
+	return feeds
+}
+
+// checkFeed fetches, parses and validates a feed and checks the links of its
+// items. It also returns the URL the feed was fetched from after redirects.
+func (c *Crawler) checkFeed(feedURL string) (models.Feed, string) {
+	feed := models.Feed{
+		WebsiteID:   c.website.ID,
+		URL:         truncateString(feedURL, 2048),
+		Errors:      []string{},
+		BrokenItems: []models.BrokenLink{},
+	}
+
+	resp, err := c.doAuthenticated(c.httpClient, "GET", feedURL)
+	if err != nil {
+		feed.Errors = append(feed.Errors, fmt.Sprintf("The feed could not be fetched: %v", err))
+		return feed, feedURL
+	}
+	defer resp.Body.Close()
+	feed.StatusCode = resp.StatusCode
+	finalURL := normalizePageURL(resp.Request.URL)
+	if resp.StatusCode != http.StatusOK {
+		feed.Errors = append(feed.Errors, fmt.Sprintf("The feed returned status %d", resp.StatusCode))
+		return feed, finalURL
+	}
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxFeedSize))
+	if err != nil {
+		feed.Errors = append(feed.Errors, fmt.Sprintf("The feed could not be read: %v", err))
+		return feed, finalURL
+	}
+	parsed, err := parseFeed(body)
+	if err != nil {
+		feed.Errors = append(feed.Errors, truncateString(fmt.Sprintf("The feed could not be parsed: %v", err), 512))
+		return feed, finalURL
+	}
+
+	feed.Format = parsed.format
+	feed.Version = truncateString(parsed.version, 10)
+	feed.Title = truncateString(strings.Join(strings.Fields(parsed.title), " "), 255)
+	feed.ItemCount = parsed.itemCount
+	feed.LastUpdated = parsed.lastUpdated
+	feed.Errors = append(feed.Errors, parsed.errors...)
+	feed.Valid = len(feed.Errors) == 0
+
+	// The self link should be where the feed is fetched from, or subscribers end up elsewhere
+	if parsed.selfLink != "" {
+		feed.SelfLink = truncateString(parsed.selfLink, 2048)
+		selfURL, err := resp.Request.URL.Parse(parsed.selfLink)
+		feed.SelfLinkMismatch = err != nil || normalizePageURL(selfURL) != finalURL
+	}
+
+	// Check the links of the items
+	var links []string
+	checked := map[string]bool{}
+	for _, link := range parsed.itemLinks {
+		itemURL, err := resp.Request.URL.Parse(link)
+		if err != nil || (itemURL.Scheme != "http" && itemURL.Scheme != "https") || checked[itemURL.String()] {
+			continue
+		}
+		checked[itemURL.String()] = true
+		links = append(links, itemURL.String())
+		if len(links) >= maxFeedItemChecks {
+			break
+		}
+	}
+	var wg sync.WaitGroup
+	var mutex sync.Mutex
+	semaphore := make(chan struct{}, 10) // Limit concurrency
+	for _, link := range links {
+		wg.Add(1)
+		go func(link string) {
+			defer wg.Done()
+			semaphore <- struct{}{}        // Acquire token
+			defer func() { <-semaphore }() // Release token
+
+			statusCode, err := c.checkLinkAccessibility(link)
+			reason := ""
+			switch {
+			case err != nil:
+				reason = "unreachable"
+			case statusCode >= 400:
+				reason = "http_error"
+			}
+			if reason != "" {
+				mutex.Lock()
+				feed.BrokenItems = append(feed.BrokenItems, models.BrokenLink{
+					WebsiteID:  c.website.ID,
+					URL:        link,
+					StatusCode: statusCode,
+					Reason:     reason,
+				})
+				mutex.Unlock()
+			}
+		}(link)
+	}
+	wg.Wait()
+
+	return feed, finalURL
+}
+
+// parseFeed parses an RSS 2.0, RSS 1.0 or Atom document and validates the
+// elements the formats require
+func parseFeed(body []byte) (*parsedFeed, error) {
+	decoder := xml.NewDecoder(bytes.NewReader(body))
+	decoder.CharsetReader = charset.NewReaderLabel
+
+	// Find the root element to tell the formats apart
+	var root xml.StartElement
+	for {
+		token, err := decoder.Token()
+		if err != nil {
+			return nil, fmt.Errorf("not an RSS or Atom feed: %v", err)
+		}
+		if start, ok := token.(xml.StartElement); ok {
+			root = start
+			break
+		}
+	}
+
+	feed := &parsedFeed{}
+	var lastUpdated time.Time
+	updated := func(value string, parse func(string) (time.Time, bool), element string) {
+		value = strings.TrimSpace(value)
+		if value == "" {
+			return
+		}
+		parsed, ok := parse(value)
+		if !ok {
+			feed.errors = append(feed.errors, fmt.Sprintf("%s %q is not a valid date", element, truncateString(value, 100)))
+			return
+		}
+		if parsed.After(lastUpdated) {
+			lastUpdated = parsed
+		}
+	}
+
+	switch {
+	case root.Name.Local == "rss":
+		var rss rssDocument
+		if err := decoder.DecodeElement(&rss, &root); err != nil {
+			return nil, fmt.Errorf("not well-formed XML: %v", err)
+		}
+		feed.format, feed.version, feed.title = "rss", rss.Version, rss.Channel.Title
+		channelLink := ""
+		for _, link := range rss.Channel.Links {
+			switch {
+			case link.XMLName.Space == atomNamespace && link.Rel == "self":
+				feed.selfLink = strings.TrimSpace(link.Href)
+			case link.XMLName.Space == "":
+				channelLink = strings.TrimSpace(link.Value)
+			}
+		}
+		if strings.TrimSpace(rss.Channel.Title) == "" {
+			feed.errors = append(feed.errors, "The channel has no title")
+		}
+		if channelLink == "" {
+			feed.errors = append(feed.errors, "The channel has no link")
+		}
+		if rss.Channel.Description == nil {
+			feed.errors = append(feed.errors, "The channel has no description")
+		}
+
+		// The channel dates take precedence over those of the items
+		updated(rss.Channel.LastBuildDate, parseRSSDate, "lastBuildDate")
+		updated(rss.Channel.PubDate, parseRSSDate, "pubDate")
+		channelUpdated := lastUpdated
+		for i, item := range rss.Channel.Items {
+			if strings.TrimSpace(item.Title) == "" && strings.TrimSpace(item.Description) == "" {
+				feed.errors = append(feed.errors, fmt.Sprintf("Item %d has neither a title nor a description", i+1))
+			}
+			updated(item.PubDate, parseRSSDate, fmt.Sprintf("Item %d pubDate", i+1))
+			link := strings.TrimSpace(item.Link)
+			if link == "" && item.GUID.IsPermaLink != "false" {
+				link = strings.TrimSpace(item.GUID.Value)
+			}
+			if link != "" {
+				feed.itemLinks = append(feed.itemLinks, link)
+			}
+		}
+		if !channelUpdated.IsZero() {
+			lastUpdated = channelUpdated
+		}
+		feed.itemCount = len(rss.Channel.Items)
+
+	case root.Name.Local == "RDF":
+		var rdf rdfDocument
+		if err := decoder.DecodeElement(&rdf, &root); err != nil {
+			return nil, fmt.Errorf("not well-formed XML: %v", err)
+		}
+		feed.format, feed.version, feed.title = "rdf", "1.0", rdf.Channel.Title
+		if strings.TrimSpace(rdf.Channel.Title) == "" {
+			feed.errors = append(feed.errors, "The channel has no title")
+		}
+		if strings.TrimSpace(rdf.Channel.Link) == "" {
+			feed.errors = append(feed.errors, "The channel has no link")
+		}
+		updated(rdf.Channel.Date, parseW3CDate, "dc:date")
+		for i, item := range rdf.Items {
+			if strings.TrimSpace(item.Link) == "" {
+				feed.errors = append(feed.errors, fmt.Sprintf("Item %d has no link", i+1))
+			} else {
+				feed.itemLinks = append(feed.itemLinks, strings.TrimSpace(item.Link))
+			}
+			updated(item.Date, parseW3CDate, fmt.Sprintf("Item %d dc:date", i+1))
+		}
+		feed.itemCount = len(rdf.Items)
+
+	case root.Name.Local == "feed":
+		var atom atomDocument
+		if err := decoder.DecodeElement(&atom, &root); err != nil {
+			return nil, fmt.Errorf("not well-formed XML: %v", err)
+		}
+		feed.format, feed.version, feed.title = "atom", "1.0", atom.Title
+		if root.Name.Space != atomNamespace {
+			feed.errors = append(feed.errors, "The feed element is not in the Atom namespace")
+		}
+		if strings.TrimSpace(atom.ID) == "" {
+			feed.errors = append(feed.errors, "The feed has no id")
+		}
+		if strings.TrimSpace(atom.Title) == "" {
+			feed.errors = append(feed.errors, "The feed has no title")
+		}
+		if strings.TrimSpace(atom.Updated) == "" {
+			feed.errors = append(feed.errors, "The feed has no updated date")
+		}
+		for _, link := range atom.Links {
+			if link.Rel == "self" {
+				feed.selfLink = strings.TrimSpace(link.Href)
+			}
+		}
+
+		updated(atom.Updated, parseAtomDate, "updated")
+		feedUpdated := lastUpdated
+		for i, entry := range atom.Entries {
+			if strings.TrimSpace(entry.ID) == "" {
+				feed.errors = append(feed.errors, fmt.Sprintf("Entry %d has no id", i+1))
+			}
+			if strings.TrimSpace(entry.Title) == "" {
+				feed.errors = append(feed.errors, fmt.Sprintf("Entry %d has no title", i+1))
+			}
+			if strings.TrimSpace(entry.Updated) == "" {
+				feed.errors = append(feed.errors, fmt.Sprintf("Entry %d has no updated date", i+1))
+			}
+			updated(entry.Updated, parseAtomDate, fmt.Sprintf("Entry %d updated", i+1))
+			for _, link := range entry.Links {
+				if link.Rel == "" || link.Rel == "alternate" {
+					feed.itemLinks = append(feed.itemLinks, strings.TrimSpace(link.Href))
+					break
+				}
+			}
+		}
+		if !feedUpdated.IsZero() {
+			lastUpdated = feedUpdated
+		}
+		feed.itemCount = len(atom.Entries)
+
+	default:
+		return nil, fmt.Errorf("not an RSS or Atom feed, the root element is <%s>", root.Name.Local)
+	}
+
+	if !lastUpdated.IsZero() {
+		lastUpdated = lastUpdated.UTC()
+		feed.lastUpdated = &lastUpdated
+	}
+	// Keep the report readable for feeds with many invalid items
+	if len(feed.errors) > 20 {
+		feed.errors = append(feed.errors[:20], fmt.Sprintf("%d more errors", len(feed.errors)-20))
+	}
+	return feed, nil
+}
+
+// parseRSSDate parses an RFC 822 date, accepting common variations
+func parseRSSDate(value string) (time.Time, bool) {
+	value = strings.Join(strings.Fields(value), " ")
+	for _, layout := range feedDateLayouts {
+		if parsed, err := time.Parse(layout, value); err == nil {
+			return parsed, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// parseAtomDate parses an RFC 3339 date
+func parseAtomDate(value string) (time.Time, bool) {
+	parsed, err := time.Parse(time.RFC3339, strings.TrimSpace(value))
+	return parsed, err == nil
+}
+
+// parseW3CDate parses a W3C date and time, which may leave out the time or the seconds
+func parseW3CDate(value string) (time.Time, bool) {
+	value = strings.TrimSpace(value)
+	for _, layout := range []string{time.RFC3339, "2006-01-02T15:04Z07:00", "2006-01-02"} {
+		if parsed, err := time.Parse(layout, value); err == nil {
+			return parsed, true
+		}
+	}
+	return time.Time{}, false
+}
@@ -0,0 +1,196 @@
+package services
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseFeed(t *testing.T) {
+	tests := []struct {
+		name        string
+		body        string
+		format      string
+		version     string
+		title       string
+		itemCount   int
+		lastUpdated string
+		selfLink    string
+		itemLinks   []string
+		errors      []string
+	}{
+		{
+			name: "rss 2.0",
+			body: `<?xml version="1.0"?>
+				<rss version="2.0" xmlns:atom="http://www.w3.org/2005/Atom">
+				<channel>
+					<title>News</title>
+					<link>https://site.test/</link>
+					<atom:link rel="self" href=" https://site.test/feed.xml " type="application/rss+xml"/>
+					<description></description>
+					<lastBuildDate>Tue, 02 Jan 2024 10:00:00 +0000</lastBuildDate>
+					<item><title>First</title><link>https://site.test/1</link><pubDate>Wed, 03 Jan 2024 10:00:00 GMT</pubDate></item>
+					<item><description>Second</description><guid>https://site.test/2</guid></item>
+					<item><title>Third</title><guid isPermaLink="false">id-3</guid></item>
+				</channel>
+				</rss>`,
+			format: "rss", version: "2.0", title: "News", itemCount: 3,
+			// The channel date takes precedence over the newer item date
+			lastUpdated: "2024-01-02T10:00:00Z",
+			selfLink:    "https://site.test/feed.xml",
+			itemLinks:   []string{"https://site.test/1", "https://site.test/2"},
+		},
+		{
+			name: "rss with missing elements",
+			body: `<rss version="2.0"><channel>
+					<pubDate>yesterday</pubDate>
+					<item><pubDate>Wed, 3 Jan 2024 10:00 +0100</pubDate></item>
+					<item><title>Two</title><pubDate>3 Jan 2024 12:00:00 +0000</pubDate></item>
+				</channel></rss>`,
+			format: "rss", version: "2.0", itemCount: 2,
+			// Without a valid channel date the newest item is used
+			lastUpdated: "2024-01-03T12:00:00Z",
+			errors: []string{
+				"The channel has no title",
+				"The channel has no link",
+				"The channel has no description",
+				`pubDate "yesterday" is not a valid date`,
+				"Item 1 has neither a title nor a description",
+			},
+		},
+		{
+			name: "rss 1.0",
+			body: `<rdf:RDF xmlns:rdf="http://www.w3.org/1999/02/22-rdf-syntax-ns#" xmlns="http://purl.org/rss/1.0/" xmlns:dc="http://purl.org/dc/elements/1.1/">
+					<channel><title>Journal</title><link>https://site.test/</link><dc:date>2024-01-02</dc:date></channel>
+					<item><title>One</title><link>https://site.test/1</link><dc:date>2024-01-05T08:30+01:00</dc:date></item>
+					<item><title>Two</title><dc:date>Jan 6</dc:date></item>
+				</rdf:RDF>`,
+			format: "rdf", version: "1.0", title: "Journal", itemCount: 2,
+			lastUpdated: "2024-01-05T07:30:00Z",
+			itemLinks:   []string{"https://site.test/1"},
+			errors:      []string{"Item 2 has no link", `Item 2 dc:date "Jan 6" is not a valid date`},
+		},
+		{
+			name: "atom",
+			body: `<feed xmlns="http://www.w3.org/2005/Atom">
+					<id>urn:feed</id><title>Blog</title><updated>2024-01-02T10:00:00+02:00</updated>
+					<link rel="alternate" href="https://site.test/"/>
+					<link rel="self" href="https://site.test/atom.xml"/>
+					<entry><id>urn:1</id><title>One</title><updated>2024-01-03T00:00:00Z</updated>
+						<link rel="enclosure" href="https://site.test/1.mp3"/><link href="https://site.test/1"/></entry>
+					<entry><title>Two</title><link rel="alternate" href="https://site.test/2"/></entry>
+				</feed>`,
+			format: "atom", version: "1.0", title: "Blog", itemCount: 2,
+			lastUpdated: "2024-01-02T08:00:00Z",
+			selfLink:    "https://site.test/atom.xml",
+			itemLinks:   []string{"https://site.test/1", "https://site.test/2"},
+			errors:      []string{"Entry 2 has no id", "Entry 2 has no updated date"},
+		},
+		{
+			name:   "atom without namespace",
+			body:   `<feed><title>Blog</title><updated>soon</updated></feed>`,
+			format: "atom", version: "1.0", title: "Blog",
+			errors: []string{
+				"The feed element is not in the Atom namespace",
+				"The feed has no id",
+				`updated "soon" is not a valid date`,
+			},
+		},
+		{
+			name:   "latin-1 encoding",
+			body:   "<?xml version=\"1.0\" encoding=\"ISO-8859-1\"?><rss version=\"0.92\"><channel><title>Caf\xe9</title><link>https://site.test/</link><description/></channel></rss>",
+			format: "rss", version: "0.92", title: "Café",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			feed, err := parseFeed([]byte(tt.body))
+			if err != nil {
+				t.Fatalf("parseFeed failed: %v", err)
+			}
+			if feed.format != tt.format || feed.version != tt.version || feed.title != tt.title || feed.itemCount != tt.itemCount {
+				t.Errorf("feed is %s %s %q with %d items, want %s %s %q with %d items",
+					feed.format, feed.version, feed.title, feed.itemCount, tt.format, tt.version, tt.title, tt.itemCount)
+			}
+			lastUpdated := ""
+			if feed.lastUpdated != nil {
+				lastUpdated = feed.lastUpdated.Format(time.RFC3339)
+			}
+			if lastUpdated != tt.lastUpdated {
+				t.Errorf("last updated = %q, want %q", lastUpdated, tt.lastUpdated)
+			}
+			if feed.selfLink != tt.selfLink {
+				t.Errorf("self link = %q, want %q", feed.selfLink, tt.selfLink)
+			}
+			if !reflect.DeepEqual(feed.itemLinks, tt.itemLinks) {
+				t.Errorf("item links = %q, want %q", feed.itemLinks, tt.itemLinks)
+			}
+			if !reflect.DeepEqual(feed.errors, tt.errors) {
+				t.Errorf("errors = %q, want %q", feed.errors, tt.errors)
+			}
+		})
+	}
+}
+
+func TestParseFeedRejected(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+		want string
+	}{
+		{"empty body", "", "not an RSS or Atom feed"},
+		{"html page", "<html><body>Not found</body></html>", "the root element is <html>"},
+		{"malformed rss", "<rss version=\"2.0\"><channel><title>News</channel></rss>", "not well-formed XML"},
+		{"truncated atom", "<feed xmlns=\"http://www.w3.org/2005/Atom\"><entry>", "not well-formed XML"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := parseFeed([]byte(tt.body))
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("parseFeed error = %v, want %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseFeedLimitsErrors(t *testing.T) {
+	body := "<rss version=\"2.0\"><channel><title>News</title><link>https://site.test/</link><description/>" +
+		strings.Repeat("<item></item>", 25) + "</channel></rss>"
+	feed, err := parseFeed([]byte(body))
+	if err != nil {
+		t.Fatalf("parseFeed failed: %v", err)
+	}
+	if len(feed.errors) != 21 || feed.errors[20] != "5 more errors" {
+		t.Errorf("got %d errors ending with %q, want 20 and a summary of the other 5", len(feed.errors), feed.errors[len(feed.errors)-1])
+	}
+}
+
+func TestParseRSSDate(t *testing.T) {
+	tests := []struct {
+		value string
+		want  string
+	}{
+		{"Mon, 02 Jan 2006 15:04:05 -0700", "2006-01-02T22:04:05Z"},
+		{"Mon, 2 Jan 2006 15:04:05 GMT", "2006-01-02T15:04:05Z"},
+		{"  Mon,  2 Jan 2006\n15:04:05 +0000 ", "2006-01-02T15:04:05Z"},
+		{"2 Jan 2006 15:04:05 +0100", "2006-01-02T14:04:05Z"},
+		{"02 Jan 06 15:04 +0000", "2006-01-02T15:04:00Z"},
+		{"Mon, 2 Jan 2006 15:04:05", "2006-01-02T15:04:05Z"},
+		{"2006-01-02T15:04:05Z", ""},
+		{"", ""},
+	}
+
+	for _, tt := range tests {
+		parsed, ok := parseRSSDate(tt.value)
+		got := ""
+		if ok {
+			got = parsed.UTC().Format(time.RFC3339)
+		}
+		if got != tt.want {
+			t.Errorf("parseRSSDate(%q) = %q, want %q", tt.value, got, tt.want)
+		}
+	}
+}
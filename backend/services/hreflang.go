@@ -0,0 +1,445 @@
+package services
+
+import (
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/sykell/website-analyzer/models"
+	"golang.org/x/net/html"
+	"golang.org/x/text/language"
+)
+
+const (
+	// maxHreflangAlternates limits how many alternate pages are fetched
+	maxHreflangAlternates = 50
+
+	// maxAlternatePageSize limits how much of each alternate page is parsed
+	maxAlternatePageSize = 5 << 20
+)
+
+// Severity of each hreflang finding
+var hreflangSeverities = map[string]string{
+	"invalid_hreflang":        "error",
+	"relative_href":           "warning",
+	"conflicting_hreflang":    "error",
+	"missing_self_reference":  "error",
+	"missing_x_default":       "warning",
+	"page_not_canonical":      "warning",
+	"alternate_unreachable":   "error",
+	"alternate_error_status":  "error",
+	"alternate_redirects":     "error",
+	"alternate_noindex":       "error",
+	"alternate_not_canonical": "error",
+	"missing_return_link":     "error",
+}
+
+// hreflangAnnotation is an hreflang alternate declared for a page
+type hreflangAnnotation struct {
+	hreflang string
+	href     string
+	url      *url.URL // Resolved href, nil if invalid
+	source   string   // "html", "header" or "sitemap"
+}
+
+// alternatePage is what was learned by fetching an alternate
+type alternatePage struct {
+	err        error
+	statusCode int
+	finalURL   string
+	noindex    bool
+	canonical  string
+	alternates map[string]bool // Normalized URLs of the alternate's own hreflang annotations
+}
+
+// linkHeaderValue is a link of an HTTP Link header, e.g. `<https://example.com/de>; rel="alternate"; hreflang="de"`
+type linkHeaderValue struct {
+	target string
+	params map[string]string
+}
+
+// checkHreflang collects the hreflang alternates of the page from its link
+// tags, Link headers and sitemap entry, and checks the codes, the
+// self-reference, x-default, and that each alternate is reachable,
+// indexable, canonical to itself and links back
+func (c *Crawler) checkHreflang(resp *http.Response, doc *html.Node) []models.HreflangFinding {
+	findings := []models.HreflangFinding{}
+	addFinding := func(code, hreflang, link, message string) {
+		findings = append(findings, models.HreflangFinding{
+			WebsiteID: c.website.ID,
+			Code:      code,
+			Severity:  hreflangSeverities[code],
+			Hreflang:  truncateString(hreflang, 35),
+			URL:       truncateString(link, 2048),
+			Message:   truncateString(message, 512),
+		})
+	}
+
+	pageURL := normalizePageURL(c.pageURL)
+	annotations := c.hreflangAnnotations(resp.Header, doc, c.pageURL, true)
+	if len(annotations) == 0 {
+		return findings
+	}
+
+	// Check the codes and URLs, and group the URLs by code
+	urlsByCode := map[string]map[string]bool{}
+	codesByURL := map[string]string{}
+	var codes []string
+	hasSelf, hasDefault := false, false
+	for _, annotation := range annotations {
+		if message := validateHreflang(annotation.hreflang); message != "" {
+			addFinding("invalid_hreflang", annotation.hreflang, annotation.href, message)
+		}
+		if annotation.url == nil {
+			continue
+		}
+		if hrefURL, err := url.Parse(annotation.href); err == nil && !hrefURL.IsAbs() {
+			addFinding("relative_href", annotation.hreflang, annotation.href,
+				fmt.Sprintf("The %s annotation uses a relative URL; hreflang URLs must be fully qualified", annotation.source))
+		}
+
+		code := strings.ToLower(annotation.hreflang)
+		target := normalizePageURL(annotation.url)
+		if urlsByCode[code] == nil {
+			urlsByCode[code] = map[string]bool{}
+			codes = append(codes, code)
+		}
+		urlsByCode[code][target] = true
+		if _, ok := codesByURL[target]; !ok {
+			codesByURL[target] = annotation.hreflang
+		}
+		hasSelf = hasSelf || target == pageURL
+		hasDefault = hasDefault || code == "x-default"
+	}
+
+	for _, code := range codes {
+		if len(urlsByCode[code]) > 1 {
+			addFinding("conflicting_hreflang", code, "",
+				fmt.Sprintf("%s points to %d different URLs: %s", code, len(urlsByCode[code]), strings.Join(sortedKeys(urlsByCode[code]), ", ")))
+		}
+	}
+	if !hasSelf {
+		addFinding("missing_self_reference", "", pageURL, "The page is not listed among its own hreflang alternates")
+	}
+	if !hasDefault {
+		addFinding("missing_x_default", "x-default", "", "No x-default alternate is declared for users matching no other language")
+	}
+	if canonical := canonicalURL(doc, c.pageURL); canonical != "" && canonical != pageURL {
+		addFinding("page_not_canonical", "", canonical,
+			"The page is canonicalized to another URL, so search engines ignore its hreflang annotations")
+	}
+
+	// Fetch every alternate other than the page itself
+	var targets []string
+	for target := range codesByURL {
+		if target != pageURL {
+			targets = append(targets, target)
+		}
+	}
+	sort.Strings(targets)
+	if len(targets) > maxHreflangAlternates {
+		targets = targets[:maxHreflangAlternates]
+	}
+	pages := c.fetchAlternatePages(targets)
+
+	// Return links may also be declared in the sitemap entries of the alternates
+	sitemapAlternates := map[string]map[string]bool{}
+	for _, entry := range c.sitemapEntries() {
+		if entryURL, err := url.Parse(entry.Loc); err == nil && len(entry.Alternates) > 0 {
+			alternates := map[string]bool{}
+			for _, alternate := range entry.Alternates {
+				if alternateURL, err := entryURL.Parse(strings.TrimSpace(alternate.Href)); err == nil {
+					alternates[normalizePageURL(alternateURL)] = true
+				}
+			}
+			sitemapAlternates[normalizePageURL(entryURL)] = alternates
+		}
+	}
+
+	for _, target := range targets {
+		page := pages[target]
+		code := codesByURL[target]
+		switch {
+		case page.err != nil:
+			addFinding("alternate_unreachable", code, target, fmt.Sprintf("The alternate could not be fetched: %v", page.err))
+			continue
+		case page.finalURL != target:
+			addFinding("alternate_redirects", code, target, fmt.Sprintf("The alternate redirects to %s", page.finalURL))
+		}
+		if page.statusCode != http.StatusOK {
+			addFinding("alternate_error_status", code, target, fmt.Sprintf("The alternate returned status %d", page.statusCode))
+			continue
+		}
+		if page.noindex {
+			addFinding("alternate_noindex", code, target, "The alternate is marked noindex")
+		}
+		if page.canonical != "" && page.canonical != page.finalURL {
+			addFinding("alternate_not_canonical", code, target, fmt.Sprintf("The alternate is canonicalized to %s", page.canonical))
+		}
+		if !page.alternates[pageURL] && !sitemapAlternates[target][pageURL] && !sitemapAlternates[page.finalURL][pageURL] {
+			addFinding("missing_return_link", code, target, "The alternate does not link back to the page with hreflang")
+		}
+	}
+
+	return findings
+}
+
+// hreflangAnnotations collects the hreflang alternates declared in the link
+// tags and Link headers of a page, and optionally its sitemap entry
+func (c *Crawler) hreflangAnnotations(header http.Header, doc *html.Node, base *url.URL, withSitemap bool) []hreflangAnnotation {
+	var annotations []hreflangAnnotation
+	add := func(hreflang, href, source string) {
+		annotation := hreflangAnnotation{hreflang: strings.TrimSpace(hreflang), href: strings.TrimSpace(href), source: source}
+		if resolved, err := base.Parse(annotation.href); err == nil && annotation.href != "" {
+			annotation.url = resolved
+		}
+		annotations = append(annotations, annotation)
+	}
+
+	selector, _ := compileSelector("link[rel][hreflang]")
+	for _, link := range selector.MatchAll(doc) {
+		if containsString(strings.Fields(strings.ToLower(getAttr(link, "rel"))), "alternate") {
+			add(getAttr(link, "hreflang"), getAttr(link, "href"), "html")
+		}
+	}
+	for _, link := range parseLinkHeader(header.Values("Link")) {
+		if containsString(strings.Fields(strings.ToLower(link.params["rel"])), "alternate") && link.params["hreflang"] != "" {
+			add(link.params["hreflang"], link.target, "header")
+		}
+	}
+
+	if withSitemap {
+		page := normalizePageURL(base)
+		for _, entry := range c.sitemapEntries() {
+			entryURL, err := url.Parse(entry.Loc)
+			if err != nil || normalizePageURL(entryURL) != page {
+				continue
+			}
+			for _, alternate := range entry.Alternates {
+				add(alternate.Hreflang, alternate.Href, "sitemap")
+			}
+		}
+	}
+
+	return annotations
+}
+
+// fetchAlternatePages fetches alternates concurrently
+func (c *Crawler) fetchAlternatePages(targets []string) map[string]*alternatePage {
+	pages := map[string]*alternatePage{}
+	var wg sync.WaitGroup
+	semaphore := make(chan struct{}, 10) // Limit concurrency
+	for _, target := range targets {
+		wg.Add(1)
+		go func(target string) {
+			defer wg.Done()
+			semaphore <- struct{}{}        // Acquire token
+			defer func() { <-semaphore }() // Release token
+
+			page := c.fetchAlternatePage(target)
+			c.mutex.Lock()
+			pages[target] = page
+			c.mutex.Unlock()
+		}(target)
+	}
+	wg.Wait()
+
+	return pages
+}
+
+// fetchAlternatePage fetches an alternate and reads its robots directives,
+// canonical URL and hreflang annotations
+func (c *Crawler) fetchAlternatePage(target string) *alternatePage {
+	page := &alternatePage{alternates: map[string]bool{}}
+	resp, err := c.doAuthenticated(c.httpClient, "GET", target)
+	if err != nil {
+		page.err = err
+		return page
+	}
+	defer resp.Body.Close()
+	page.statusCode = resp.StatusCode
+	page.finalURL = normalizePageURL(resp.Request.URL)
+	page.noindex = hasNoindex(resp.Header.Values("X-Robots-Tag"))
+
+	mediaType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	if resp.StatusCode != http.StatusOK || (mediaType != "text/html" && mediaType != "application/xhtml+xml") {
+		return page
+	}
+	doc, err := html.Parse(io.LimitReader(resp.Body, maxAlternatePageSize))
+	if err != nil {
+		return page
+	}
+
+	robotsSelector, _ := compileSelector("meta[name]")
+	for _, meta := range robotsSelector.MatchAll(doc) {
+		name := strings.ToLower(getAttr(meta, "name"))
+		if (name == "robots" || name == "googlebot") && hasNoindex([]string{getAttr(meta, "content")}) {
+			page.noindex = true
+		}
+	}
+	page.canonical = canonicalURL(doc, resp.Request.URL)
+	for _, annotation := range c.hreflangAnnotations(resp.Header, doc, resp.Request.URL, false) {
+		if annotation.url != nil {
+			page.alternates[normalizePageURL(annotation.url)] = true
+		}
+	}
+
+	return page
+}
+
+// hasNoindex checks robots directives, e.g. "noindex, nofollow" or "googlebot: none"
+func hasNoindex(values []string) bool {
+	for _, value := range values {
+		for _, directive := range strings.Split(strings.ToLower(value), ",") {
+			directive = strings.TrimSpace(directive)
+			// X-Robots-Tag directives may be prefixed by a user agent
+			if _, rest, found := strings.Cut(directive, ":"); found {
+				directive = strings.TrimSpace(rest)
+			}
+			if directive == "noindex" || directive == "none" {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// canonicalURL returns the normalized canonical URL declared by a document
+func canonicalURL(doc *html.Node, base *url.URL) string {
+	selector, _ := compileSelector("link[rel][href]")
+	for _, link := range selector.MatchAll(doc) {
+		if containsString(strings.Fields(strings.ToLower(getAttr(link, "rel"))), "canonical") {
+			if canonical, err := base.Parse(strings.TrimSpace(getAttr(link, "href"))); err == nil {
+				return normalizePageURL(canonical)
+			}
+		}
+	}
+	return ""
+}
+
+// validateHreflang checks an hreflang value: x-default, or an ISO 639-1
+// language optionally followed by an ISO 15924 script and an ISO 3166-1
+// alpha-2 region. It returns a message describing the problem, or an empty string.
+func validateHreflang(value string) string {
+	if strings.EqualFold(value, "x-default") {
+		return ""
+	}
+	if value == "" {
+		return "The hreflang value is empty"
+	}
+	if strings.Contains(value, "_") {
+		return fmt.Sprintf("%q must separate its parts with a hyphen, not an underscore", value)
+	}
+
+	parts := strings.Split(value, "-")
+	if len(parts) > 3 {
+		return fmt.Sprintf("%q has too many parts for a language and region", value)
+	}
+	// Parsing a tag replaces deprecated and three-letter codes, e.g. "iw" by "he"
+	tag, err := language.Parse(parts[0])
+	base, _ := tag.Base()
+	if err != nil || base.String() == "und" {
+		return fmt.Sprintf("%q is not an ISO 639-1 language code", parts[0])
+	}
+	if base.String() != strings.ToLower(parts[0]) {
+		return fmt.Sprintf("%q is not a current ISO 639-1 language code, use %q", parts[0], base.String())
+	}
+	if len(parts[0]) != 2 {
+		return fmt.Sprintf("%q is not an ISO 639-1 language code", parts[0])
+	}
+
+	rest := parts[1:]
+	if len(rest) > 0 && len(rest[0]) == 4 {
+		if _, err := language.ParseScript(rest[0]); err != nil {
+			return fmt.Sprintf("%q is not an ISO 15924 script code", rest[0])
+		}
+		rest = rest[1:]
+	}
+	if len(rest) == 0 {
+		return ""
+	}
+	if len(rest) > 1 || len(rest[0]) != 2 {
+		return fmt.Sprintf("%q is not an ISO 3166-1 alpha-2 region code", strings.Join(rest, "-"))
+	}
+	region, err := language.ParseRegion(rest[0])
+	if err != nil || !region.IsCountry() {
+		return fmt.Sprintf("%q is not an ISO 3166-1 alpha-2 region code", rest[0])
+	}
+	if canonical := region.Canonicalize().String(); canonical != strings.ToUpper(rest[0]) {
+		return fmt.Sprintf("%q is not an ISO 3166-1 alpha-2 region code, use %q", rest[0], canonical)
+	}
+	return ""
+}
+
+// parseLinkHeader parses the links of HTTP Link headers. Parameter names are lowercased.
+func parseLinkHeader(values []string) []linkHeaderValue {
+	var links []linkHeaderValue
+	for _, value := range values {
+		for value != "" {
+			value = strings.TrimLeft(value, " \t,")
+			if !strings.HasPrefix(value, "<") {
+				break
+			}
+			end := strings.IndexByte(value, '>')
+			if end < 0 {
+				break
+			}
+			link := linkHeaderValue{target: value[1:end], params: map[string]string{}}
+			value = value[end+1:]
+
+			// Read the parameters up to the comma starting the next link
+			for {
+				value = strings.TrimLeft(value, " \t")
+				if !strings.HasPrefix(value, ";") {
+					break
+				}
+				value = strings.TrimLeft(value[1:], " \t")
+				nameEnd := strings.IndexAny(value, "=;,")
+				if nameEnd < 0 {
+					nameEnd = len(value)
+				}
+				name := strings.ToLower(strings.TrimSpace(value[:nameEnd]))
+				value = value[nameEnd:]
+				paramValue := ""
+				if strings.HasPrefix(value, "=") {
+					value = strings.TrimLeft(value[1:], " \t")
+					if strings.HasPrefix(value, `"`) {
+						closing := strings.IndexByte(value[1:], '"')
+						if closing < 0 {
+							paramValue, value = value[1:], ""
+						} else {
+							paramValue, value = value[1:closing+1], value[closing+2:]
+						}
+					} else {
+						valueEnd := strings.IndexAny(value, ";,")
+						if valueEnd < 0 {
+							valueEnd = len(value)
+						}
+						paramValue = strings.TrimSpace(value[:valueEnd])
+						value = value[valueEnd:]
+					}
+				}
+				if _, ok := link.params[name]; !ok && name != "" {
+					link.params[name] = paramValue
+				}
+			}
+			links = append(links, link)
+		}
+	}
+	return links
+}
+
+// sortedKeys returns the keys of a set in order
+func sortedKeys(set map[string]bool) []string {
+	keys := make([]string, 0, len(set))
+	for key := range set {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
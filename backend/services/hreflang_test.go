@@ -0,0 +1,122 @@
+package services
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestValidateHreflang(t *testing.T) {
+	tests := []struct {
+		value string
+		want  string
+	}{
+		{"x-default", ""},
+		{"X-Default", ""},
+		{"en", ""},
+		{"DE", ""},
+		{"en-GB", ""},
+		{"en-gb", ""},
+		{"zh-Hant", ""},
+		{"zh-Hant-TW", ""},
+		{"", "The hreflang value is empty"},
+		{"en_GB", `"en_GB" must separate its parts with a hyphen, not an underscore`},
+		{"en-Latn-GB-x", `"en-Latn-GB-x" has too many parts for a language and region`},
+		{"xx", `"xx" is not an ISO 639-1 language code`},
+		{"english", `"english" is not an ISO 639-1 language code`},
+		{"iw", `"iw" is not a current ISO 639-1 language code, use "he"`},
+		{"deu", `"deu" is not a current ISO 639-1 language code, use "de"`},
+		{"haw", `"haw" is not an ISO 639-1 language code`},
+		{"zh-Abcd", `"Abcd" is not an ISO 15924 script code`},
+		{"en-UK", `"UK" is not an ISO 3166-1 alpha-2 region code, use "GB"`},
+		{"en-XY", `"XY" is not an ISO 3166-1 alpha-2 region code`},
+		{"es-419", `"419" is not an ISO 3166-1 alpha-2 region code`},
+		{"en-EU", `"EU" is not an ISO 3166-1 alpha-2 region code`},
+		{"en-GB-scotland", `"GB-scotland" is not an ISO 3166-1 alpha-2 region code`},
+	}
+
+	for _, tt := range tests {
+		if got := validateHreflang(tt.value); got != tt.want {
+			t.Errorf("validateHreflang(%q) = %q, want %q", tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestParseLinkHeader(t *testing.T) {
+	tests := []struct {
+		name   string
+		values []string
+		want   []linkHeaderValue
+	}{
+		{
+			"several links",
+			[]string{`<https://site.test/de>; rel="alternate"; hreflang="de", <https://site.test/fr>; REL=alternate; hreflang=fr`},
+			[]linkHeaderValue{
+				{"https://site.test/de", map[string]string{"rel": "alternate", "hreflang": "de"}},
+				{"https://site.test/fr", map[string]string{"rel": "alternate", "hreflang": "fr"}},
+			},
+		},
+		{
+			"several headers",
+			[]string{`</style.css>; rel=preload; as=style`, `<https://site.test/en>;rel="alternate";hreflang="en"`},
+			[]linkHeaderValue{
+				{"/style.css", map[string]string{"rel": "preload", "as": "style"}},
+				{"https://site.test/en", map[string]string{"rel": "alternate", "hreflang": "en"}},
+			},
+		},
+		{
+			"quoted commas and semicolons",
+			[]string{`<https://site.test/a>; title="a, b; c"; rel=alternate, <https://site.test/b>`},
+			[]linkHeaderValue{
+				{"https://site.test/a", map[string]string{"title": "a, b; c", "rel": "alternate"}},
+				{"https://site.test/b", map[string]string{}},
+			},
+		},
+		{
+			"parameters without values and repeated parameters",
+			[]string{`<https://site.test/a>; crossorigin; rel=alternate; rel=next`},
+			[]linkHeaderValue{
+				{"https://site.test/a", map[string]string{"crossorigin": "", "rel": "alternate"}},
+			},
+		},
+		{
+			"unterminated quote",
+			[]string{`<https://site.test/a>; hreflang="de`},
+			[]linkHeaderValue{
+				{"https://site.test/a", map[string]string{"hreflang": "de"}},
+			},
+		},
+		{
+			"malformed values",
+			[]string{`https://site.test/a; rel=alternate`, `<https://site.test/b`, ``},
+			nil,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := parseLinkHeader(tt.values); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseLinkHeader(%q) = %v, want %v", tt.values, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestHasNoindex(t *testing.T) {
+	tests := []struct {
+		values []string
+		want   bool
+	}{
+		{[]string{"noindex, nofollow"}, true},
+		{[]string{"index", "NONE"}, true},
+		{[]string{"googlebot: noindex"}, true},
+		{[]string{"nofollow", "noarchive"}, false},
+		{[]string{"unavailable_after: 25 Jun 2010 15:00:00 PST"}, false},
+		{nil, false},
+	}
+
+	for _, tt := range tests {
+		if got := hasNoindex(tt.values); got != tt.want {
+			t.Errorf("hasNoindex(%q) = %v, want %v", tt.values, got, tt.want)
+		}
+	}
+}
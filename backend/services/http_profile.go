@@ -0,0 +1,176 @@
+package services
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/cookiejar"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"golang.org/x/net/publicsuffix"
+)
+
+const (
+	// DefaultUserAgent is sent when a website has no user agent configured
+	DefaultUserAgent = "WebsiteAnalyzer/1.0"
+
+	defaultPageTimeout = 30 * time.Second
+	defaultLinkTimeout = 5 * time.Second
+)
+
+// newHTTPClients builds the page and link clients from the website's crawl settings
+func (c *Crawler) newHTTPClients() error {
+	pageTimeout := defaultPageTimeout
+	if c.settings.TimeoutSeconds > 0 {
+		pageTimeout = time.Duration(c.settings.TimeoutSeconds) * time.Second
+	}
+	linkTimeout := defaultLinkTimeout
+	if c.settings.LinkTimeoutSeconds > 0 {
+		linkTimeout = time.Duration(c.settings.LinkTimeoutSeconds) * time.Second
+	}
+
+	// Both clients share one transport so connections are reused, and one
+	// cookie jar so a login session is kept for the whole analysis
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	proxyURL, err := c.proxyURL()
+	if err != nil {
+		return err
+	}
+	if proxyURL != nil {
+		transport.Proxy = http.ProxyURL(proxyURL)
+	}
+	c.transport = transport
+	jar, _ := cookiejar.New(&cookiejar.Options{PublicSuffixList: publicsuffix.List})
+
+	c.httpClient = &http.Client{
+		Transport:     transport,
+		Jar:           jar,
+		Timeout:       pageTimeout,
+		CheckRedirect: c.redirectPolicy(10),
+	}
+	c.linkClient = &http.Client{
+		Transport:     transport,
+		Jar:           jar,
+		Timeout:       linkTimeout,
+		CheckRedirect: c.redirectPolicy(5),
+	}
+
+	return nil
+}
+
+// proxyURL returns the proxy analysis traffic is routed through. The website's
+// proxy overrides the global CRAWLER_PROXY_URL default; nil means no proxy.
+func (c *Crawler) proxyURL() (*url.URL, error) {
+	rawURL := c.settings.ProxyURL
+	username, password := c.settings.ProxyUsername, c.settings.ProxyPassword
+	if rawURL == "" {
+		rawURL = os.Getenv("CRAWLER_PROXY_URL")
+		username, password = os.Getenv("CRAWLER_PROXY_USERNAME"), os.Getenv("CRAWLER_PROXY_PASSWORD")
+	}
+	if rawURL == "" {
+		return nil, nil
+	}
+
+	proxyURL, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid proxy URL: %w", err)
+	}
+
+	// The transport speaks HTTP CONNECT to http(s) proxies and SOCKS5 natively
+	switch strings.ToLower(proxyURL.Scheme) {
+	case "http", "https", "socks5", "socks5h":
+	default:
+		return nil, fmt.Errorf("unsupported proxy scheme: %q", proxyURL.Scheme)
+	}
+
+	// Proxy credentials are passed as URL user info for both proxy types
+	if username != "" {
+		proxyURL.User = url.UserPassword(username, password)
+	}
+
+	return proxyURL, nil
+}
+
+// redirectPolicy limits redirects and re-applies the profile to every hop
+func (c *Crawler) redirectPolicy(maxRedirects int) func(*http.Request, []*http.Request) error {
+	return func(req *http.Request, via []*http.Request) error {
+		if len(via) >= maxRedirects {
+			return fmt.Errorf("too many redirects")
+		}
+		c.applyProfile(req)
+		return nil
+	}
+}
+
+// newRequest creates a request carrying the website's HTTP client profile
+func (c *Crawler) newRequest(method, rawURL string, body io.Reader) (*http.Request, error) {
+	req, err := http.NewRequest(method, rawURL, body)
+	if err != nil {
+		return nil, err
+	}
+	c.applyProfile(req)
+	return req, nil
+}
+
+// applyProfile sets the configured headers on a request. Custom headers are
+// only sent to hosts in the website's scope, and credentials and cookies only
+// to those hosts over HTTPS, never to third parties or in cleartext.
+func (c *Crawler) applyProfile(req *http.Request) {
+	userAgent := c.settings.UserAgent
+	if userAgent == "" {
+		userAgent = DefaultUserAgent
+	}
+	req.Header.Set("User-Agent", userAgent)
+
+	if c.settings.AcceptLanguage != "" {
+		req.Header.Set("Accept-Language", c.settings.AcceptLanguage)
+	}
+
+	// Redirects copy the headers of the previous request, so remove the
+	// profile's headers again when a redirect leaves the scope
+	inScope := req.URL.Host != "" && c.inScope(req.URL)
+	for name, value := range c.settings.Headers {
+		if inScope {
+			req.Header.Set(name, value)
+		} else {
+			req.Header.Del(name)
+		}
+	}
+
+	if !c.sendsCredentials(req.URL) {
+		req.Header.Del("Authorization")
+		req.Header.Del("Cookie")
+		return
+	}
+
+	switch c.settings.AuthType {
+	case "basic":
+		req.SetBasicAuth(c.settings.AuthUsername, c.settings.AuthPassword)
+	case "bearer":
+		req.Header.Set("Authorization", "Bearer "+c.settings.BearerToken)
+	}
+
+	if req.Header.Get("Cookie") == "" {
+		for _, cookie := range c.settings.Cookies {
+			req.AddCookie(&http.Cookie{Name: cookie.Name, Value: cookie.Value})
+		}
+	}
+}
+
+// sendsCredentials checks if credentials and cookies may be sent to a URL.
+// Only hosts within the website's crawl scope receive them, and only over
+// HTTPS when the profile has credentials.
+func (c *Crawler) sendsCredentials(u *url.URL) bool {
+	if u.Host == "" || !c.inScope(u) {
+		return false
+	}
+	return u.Scheme == "https" || !c.hasCredentials()
+}
+
+// hasCredentials checks if the profile sends authentication or cookies
+func (c *Crawler) hasCredentials() bool {
+	return c.settings.AuthType != "" || len(c.settings.Cookies) > 0
+}
@@ -0,0 +1,246 @@
+package services
+
+import (
+	"bufio"
+	"encoding/base64"
+	"encoding/binary"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"testing"
+
+	"github.com/sykell/website-analyzer/models"
+)
+
+// newTestCrawler creates a crawler for a website without loading anything from the database
+func newTestCrawler(t *testing.T, websiteURL string, settings *models.CrawlSettings) *Crawler {
+	t.Helper()
+	baseURL, err := url.Parse(websiteURL)
+	if err != nil {
+		t.Fatalf("invalid website URL: %v", err)
+	}
+	crawler := &Crawler{
+		website:  &models.Website{ID: 1, URL: websiteURL},
+		baseURL:  baseURL,
+		pageURL:  baseURL,
+		settings: settings,
+	}
+	if err := crawler.newHTTPClients(); err != nil {
+		t.Fatalf("newHTTPClients failed: %v", err)
+	}
+	return crawler
+}
+
+// fetchThroughCrawler requests a URL with the crawler's page client and returns the body
+func fetchThroughCrawler(t *testing.T, crawler *Crawler, rawURL string) string {
+	t.Helper()
+	req, err := crawler.newRequest("GET", rawURL, nil)
+	if err != nil {
+		t.Fatalf("newRequest failed: %v", err)
+	}
+	resp, err := crawler.httpClient.Do(req)
+	if err != nil {
+		t.Fatalf("request through the proxy failed: %v", err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatalf("failed to read the response: %v", err)
+	}
+	return string(body)
+}
+
+func TestHTTPProxy(t *testing.T) {
+	var requested, proxyAuth string
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Requests to an HTTP proxy carry the absolute target URL
+		requested = r.URL.String()
+		proxyAuth = r.Header.Get("Proxy-Authorization")
+		io.WriteString(w, "via http proxy")
+	}))
+	defer proxy.Close()
+
+	crawler := newTestCrawler(t, "http://site.test/", &models.CrawlSettings{
+		ProxyURL:      proxy.URL,
+		ProxyUsername: "user",
+		ProxyPassword: "secret",
+	})
+	body := fetchThroughCrawler(t, crawler, "http://site.test/page")
+
+	if body != "via http proxy" {
+		t.Errorf("body = %q, want the proxy's response", body)
+	}
+	if requested != "http://site.test/page" {
+		t.Errorf("proxy received %q, want http://site.test/page", requested)
+	}
+	wantAuth := "Basic " + base64.StdEncoding.EncodeToString([]byte("user:secret"))
+	if proxyAuth != wantAuth {
+		t.Errorf("Proxy-Authorization = %q, want %q", proxyAuth, wantAuth)
+	}
+}
+
+func TestHTTPProxyFromEnvironment(t *testing.T) {
+	var requested string
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requested = r.URL.String()
+	}))
+	defer proxy.Close()
+	t.Setenv("CRAWLER_PROXY_URL", proxy.URL)
+
+	crawler := newTestCrawler(t, "http://site.test/", &models.CrawlSettings{})
+	fetchThroughCrawler(t, crawler, "http://site.test/")
+
+	if requested != "http://site.test/" {
+		t.Errorf("proxy received %q, want http://site.test/", requested)
+	}
+}
+
+func TestSOCKS5Proxy(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	defer listener.Close()
+
+	type socksRequest struct {
+		target, username, password string
+		err                        error
+	}
+	requests := make(chan socksRequest, 1)
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			requests <- socksRequest{err: err}
+			return
+		}
+		defer conn.Close()
+		target, username, password, err := serveSOCKS5(conn)
+		requests <- socksRequest{target, username, password, err}
+	}()
+
+	crawler := newTestCrawler(t, "http://site.test/", &models.CrawlSettings{
+		ProxyURL:      "socks5://" + listener.Addr().String(),
+		ProxyUsername: "user",
+		ProxyPassword: "secret",
+	})
+	body := fetchThroughCrawler(t, crawler, "http://site.test/page")
+
+	request := <-requests
+	if request.err != nil {
+		t.Fatalf("SOCKS5 proxy failed: %v", request.err)
+	}
+	if body != "via socks5 proxy" {
+		t.Errorf("body = %q, want the proxy's response", body)
+	}
+	if request.target != "site.test:80" {
+		t.Errorf("proxy connected to %q, want site.test:80", request.target)
+	}
+	if request.username != "user" || request.password != "secret" {
+		t.Errorf("proxy credentials = %q/%q, want user/secret", request.username, request.password)
+	}
+}
+
+func TestUnsupportedProxyScheme(t *testing.T) {
+	baseURL, _ := url.Parse("http://site.test/")
+	crawler := &Crawler{baseURL: baseURL, settings: &models.CrawlSettings{ProxyURL: "ftp://proxy.test"}}
+	if err := crawler.newHTTPClients(); err == nil {
+		t.Error("newHTTPClients succeeded for an ftp proxy, want an error")
+	}
+}
+
+func TestApplyProfile(t *testing.T) {
+	crawler := newTestCrawler(t, "https://site.test/", &models.CrawlSettings{
+		Headers:     map[string]string{"X-Api-Key": "key"},
+		Cookies:     []models.CrawlCookie{{Name: "session", Value: "abc"}},
+		AuthType:    "bearer",
+		BearerToken: "token",
+	})
+
+	tests := []struct {
+		name        string
+		url         string
+		header      bool
+		credentials bool
+	}{
+		{"in scope over https", "https://site.test/page", true, true},
+		{"in scope over http", "http://site.test/page", true, false},
+		{"third party", "https://cdn.other.test/app.js", false, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := crawler.newRequest("GET", tt.url, nil)
+			if err != nil {
+				t.Fatalf("newRequest failed: %v", err)
+			}
+			if got := req.Header.Get("X-Api-Key") != ""; got != tt.header {
+				t.Errorf("custom header sent = %v, want %v", got, tt.header)
+			}
+			if got := req.Header.Get("Authorization") != ""; got != tt.credentials {
+				t.Errorf("Authorization sent = %v, want %v", got, tt.credentials)
+			}
+			if got := req.Header.Get("Cookie") != ""; got != tt.credentials {
+				t.Errorf("Cookie sent = %v, want %v", got, tt.credentials)
+			}
+		})
+	}
+}
+
+// serveSOCKS5 answers one SOCKS5 CONNECT with username/password authentication
+// and then acts as the target, answering the HTTP request sent through it
+func serveSOCKS5(conn net.Conn) (target, username, password string, err error) {
+	reader := bufio.NewReader(conn)
+	readBytes := func(n int) []byte {
+		if err != nil {
+			return make([]byte, n)
+		}
+		buf := make([]byte, n)
+		_, err = io.ReadFull(reader, buf)
+		return buf
+	}
+
+	// Greeting: version, methods; choose username/password
+	greeting := readBytes(2)
+	readBytes(int(greeting[1]))
+	if err == nil {
+		_, err = conn.Write([]byte{0x05, 0x02})
+	}
+
+	// Username/password subnegotiation
+	readBytes(1)
+	username = string(readBytes(int(readBytes(1)[0])))
+	password = string(readBytes(int(readBytes(1)[0])))
+	if err == nil {
+		_, err = conn.Write([]byte{0x01, 0x00})
+	}
+
+	// CONNECT request with the target address
+	header := readBytes(4)
+	var host string
+	switch header[3] {
+	case 0x01:
+		host = net.IP(readBytes(4)).String()
+	case 0x04:
+		host = net.IP(readBytes(16)).String()
+	default:
+		host = string(readBytes(int(readBytes(1)[0])))
+	}
+	port := binary.BigEndian.Uint16(readBytes(2))
+	target = net.JoinHostPort(host, strconv.Itoa(int(port)))
+	if err != nil {
+		return
+	}
+	if _, err = conn.Write([]byte{0x05, 0x00, 0x00, 0x01, 0, 0, 0, 0, 0, 0}); err != nil {
+		return
+	}
+
+	// Answer the tunneled HTTP request
+	if _, err = http.ReadRequest(reader); err != nil {
+		return
+	}
+	_, err = io.WriteString(conn, "HTTP/1.1 200 OK\r\nContent-Length: 16\r\nConnection: close\r\n\r\nvia socks5 proxy")
+	return
+}
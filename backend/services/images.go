@@ -0,0 +1,351 @@
+package services
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"image"
+	_ "image/gif"  // Register the GIF decoder
+	_ "image/jpeg" // Register the JPEG decoder
+	_ "image/png"  // Register the PNG decoder
+	"io"
+	"mime"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/sykell/website-analyzer/models"
+	"golang.org/x/net/html"
+)
+
+const (
+	// maxImages limits how many images of the page are audited
+	maxImages = 100
+
+	// maxImageHeaderSize limits how much of each image is kept to read its dimensions
+	maxImageHeaderSize = 1 << 20
+
+	// aboveFoldImages is how many images at the start of the document are treated as above the fold
+	aboveFoldImages = 3
+
+	// oversizedImageFactor is how much larger than its declared size an image may be, allowing for high-density displays
+	oversizedImageFactor = 2
+)
+
+// Formats with a WebP or AVIF successor
+var legacyImageFormats = map[string]bool{"jpeg": true, "png": true, "gif": true}
+
+// imageFetch is the result of fetching an image
+type imageFetch struct {
+	statusCode int
+	format     string
+	width      int
+	height     int
+	size       int64
+	err        error
+}
+
+// auditImages inventories the img elements of the page, fetches each image
+// to read its format, dimensions and weight, and flags missing alt text and
+// dimensions, oversized and legacy format images and images below the fold
+// that aren't lazy-loaded
+func (c *Crawler) auditImages(doc *html.Node) []models.PageImage {
+	images := []models.PageImage{}
+	var elements []*html.Node
+	selector, _ := compileSelector("img")
+	for _, n := range selector.MatchAll(doc) {
+		if len(elements) >= maxImages {
+			break
+		}
+		elements = append(elements, n)
+	}
+
+	// Fetch each image once
+	sources := make([]string, len(elements))
+	var targets []string
+	seen := map[string]bool{}
+	for i, n := range elements {
+		src := strings.TrimSpace(getAttr(n, "src"))
+		if src == "" {
+			if candidates := parseSrcset(getAttr(n, "srcset")); len(candidates) > 0 {
+				src = candidates[0]
+			}
+		}
+		if src == "" || strings.HasPrefix(src, "data:") {
+			sources[i] = src
+			continue
+		}
+		imageURL, err := c.pageURL.Parse(src)
+		if err != nil || (imageURL.Scheme != "http" && imageURL.Scheme != "https") {
+			sources[i] = src
+			continue
+		}
+		sources[i] = imageURL.String()
+		if !seen[sources[i]] {
+			seen[sources[i]] = true
+			targets = append(targets, sources[i])
+		}
+	}
+	fetched := c.fetchImages(targets)
+
+	for i, n := range elements {
+		alt, hasAlt := lookupAttr(n, "alt")
+		image := models.PageImage{
+			WebsiteID: c.website.ID,
+			URL:       truncateString(sources[i], 2048),
+			Alt:       truncateString(strings.TrimSpace(alt), 512),
+			HasAlt:    hasAlt,
+			Width:     dimensionAttr(n, "width"),
+			Height:    dimensionAttr(n, "height"),
+			Loading:   truncateString(strings.ToLower(strings.TrimSpace(getAttr(n, "loading"))), 20),
+			InPicture: n.Parent != nil && n.Parent.Type == html.ElementNode && n.Parent.Data == "picture",
+			Issues:    []string{},
+		}
+
+		result := fetched[sources[i]]
+		if strings.HasPrefix(sources[i], "data:") {
+			result = decodeDataImage(sources[i])
+		}
+		if result != nil {
+			image.StatusCode = result.statusCode
+			image.Format = result.format
+			image.NaturalWidth, image.NaturalHeight = result.width, result.height
+			image.Size = result.size
+		}
+
+		if !hasAlt {
+			image.Issues = append(image.Issues, "missing_alt")
+		}
+		if image.Width == 0 || image.Height == 0 {
+			image.Issues = append(image.Issues, "missing_dimensions")
+		}
+		// With a srcset the browser picks a candidate matching the rendered size
+		if getAttr(n, "srcset") == "" && ((image.Width > 0 && image.NaturalWidth > image.Width*oversizedImageFactor) ||
+			(image.Height > 0 && image.NaturalHeight > image.Height*oversizedImageFactor)) {
+			image.Issues = append(image.Issues, "oversized")
+		}
+		if legacyImageFormats[image.Format] && !hasModernAlternative(n) {
+			image.Issues = append(image.Issues, "legacy_format")
+		}
+		if i >= aboveFoldImages && image.Loading != "lazy" {
+			image.Issues = append(image.Issues, "missing_lazy_loading")
+		}
+		if result != nil && (result.err != nil || result.statusCode >= 400) {
+			image.Issues = append(image.Issues, "broken")
+		}
+
+		images = append(images, image)
+	}
+
+	return images
+}
+
+// fetchImages fetches images concurrently
+func (c *Crawler) fetchImages(targets []string) map[string]*imageFetch {
+	results := map[string]*imageFetch{}
+	var wg sync.WaitGroup
+	semaphore := make(chan struct{}, 10) // Limit concurrency
+	for _, target := range targets {
+		wg.Add(1)
+		go func(target string) {
+			defer wg.Done()
+			semaphore <- struct{}{}        // Acquire token
+			defer func() { <-semaphore }() // Release token
+
+			result := c.fetchImage(target)
+			c.mutex.Lock()
+			results[target] = result
+			c.mutex.Unlock()
+		}(target)
+	}
+	wg.Wait()
+
+	return results
+}
+
+// fetchImage fetches an image, keeping the start of it to read its format and dimensions
+func (c *Crawler) fetchImage(target string) *imageFetch {
+	result := &imageFetch{}
+	resp, err := c.doAuthenticated(c.linkClient, "GET", target)
+	if err != nil {
+		result.err = err
+		return result
+	}
+	defer resp.Body.Close()
+	result.statusCode = resp.StatusCode
+	if resp.StatusCode >= 400 {
+		return result
+	}
+
+	var header bytes.Buffer
+	headerSize, err := io.Copy(&header, io.LimitReader(resp.Body, maxImageHeaderSize))
+	if err != nil {
+		result.err = err
+		return result
+	}
+	restSize, err := io.Copy(io.Discard, resp.Body)
+	if err != nil {
+		result.err = err
+		return result
+	}
+	result.size = headerSize + restSize
+
+	mediaType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	result.format, result.width, result.height = decodeImageConfig(header.Bytes(), mediaType)
+	return result
+}
+
+// decodeDataImage reads the format, dimensions and size of an image embedded in a data URL
+func decodeDataImage(dataURL string) *imageFetch {
+	meta, payload, found := strings.Cut(strings.TrimPrefix(dataURL, "data:"), ",")
+	if !found {
+		return nil
+	}
+	var data []byte
+	var err error
+	if strings.HasSuffix(strings.ToLower(meta), ";base64") {
+		data, err = base64.StdEncoding.DecodeString(strings.Join(strings.Fields(payload), ""))
+		meta = meta[:len(meta)-len(";base64")]
+	} else {
+		var unescaped string
+		unescaped, err = url.PathUnescape(payload)
+		data = []byte(unescaped)
+	}
+	if err != nil {
+		return nil
+	}
+
+	mediaType, _, _ := mime.ParseMediaType(meta)
+	result := &imageFetch{size: int64(len(data))}
+	result.format, result.width, result.height = decodeImageConfig(data, mediaType)
+	return result
+}
+
+// decodeImageConfig detects the format of an image and reads its dimensions.
+// GIF, JPEG and PNG are decoded with the standard library, WebP and AVIF
+// dimensions are read from their headers, and SVG has none.
+func decodeImageConfig(data []byte, mediaType string) (string, int, int) {
+	if config, format, err := image.DecodeConfig(bytes.NewReader(data)); err == nil {
+		return format, config.Width, config.Height
+	}
+
+	switch {
+	case len(data) >= 16 && string(data[0:4]) == "RIFF" && string(data[8:12]) == "WEBP":
+		width, height := webpDimensions(data)
+		return "webp", width, height
+	case len(data) >= 12 && string(data[4:8]) == "ftyp" && (string(data[8:12]) == "avif" || string(data[8:12]) == "avis"):
+		// The image spatial extents property holds the dimensions
+		if i := bytes.Index(data, []byte("ispe")); i >= 0 && len(data) >= i+16 {
+			return "avif", int(binary.BigEndian.Uint32(data[i+8:])), int(binary.BigEndian.Uint32(data[i+12:]))
+		}
+		return "avif", 0, 0
+	case len(data) >= 22 && bytes.HasPrefix(data, []byte{0, 0, 1, 0}):
+		width, height := icoDimensions(data)
+		return "ico", width, height
+	case mediaType == "image/svg+xml" || bytes.Contains(data[:minInt(len(data), 1024)], []byte("<svg")):
+		return "svg", 0, 0
+	}
+
+	// Fall back to the declared type, e.g. "image/x-icon"
+	if strings.HasPrefix(mediaType, "image/") {
+		return truncateString(strings.TrimPrefix(mediaType, "image/"), 20), 0, 0
+	}
+	return "", 0, 0
+}
+
+// icoDimensions reads the size of the largest image in an ICO directory,
+// where a zero width or height stands for 256 pixels
+func icoDimensions(data []byte) (int, int) {
+	largestWidth, largestHeight := 0, 0
+	count := int(binary.LittleEndian.Uint16(data[4:]))
+	for i := 0; i < count && len(data) >= 6+(i+1)*16; i++ {
+		width, height := int(data[6+i*16]), int(data[7+i*16])
+		if width == 0 {
+			width = 256
+		}
+		if height == 0 {
+			height = 256
+		}
+		if width*height > largestWidth*largestHeight {
+			largestWidth, largestHeight = width, height
+		}
+	}
+	return largestWidth, largestHeight
+}
+
+// webpDimensions reads the canvas size from a lossy, lossless or extended WebP header
+func webpDimensions(data []byte) (int, int) {
+	switch string(data[12:16]) {
+	case "VP8 ":
+		if len(data) >= 30 {
+			return int(binary.LittleEndian.Uint16(data[26:]) & 0x3fff), int(binary.LittleEndian.Uint16(data[28:]) & 0x3fff)
+		}
+	case "VP8L":
+		if len(data) >= 25 {
+			b := data[21:25]
+			width := 1 + (int(b[0]) | int(b[1]&0x3f)<<8)
+			height := 1 + (int(b[1])>>6 | int(b[2])<<2 | int(b[3]&0x0f)<<10)
+			return width, height
+		}
+	case "VP8X":
+		if len(data) >= 30 {
+			width := 1 + (int(data[24]) | int(data[25])<<8 | int(data[26])<<16)
+			height := 1 + (int(data[27]) | int(data[28])<<8 | int(data[29])<<16)
+			return width, height
+		}
+	}
+	return 0, 0
+}
+
+// hasModernAlternative checks if an img offers a WebP or AVIF version, through
+// the sources of its picture element or its own srcset
+func hasModernAlternative(n *html.Node) bool {
+	isModern := func(mediaType, srcset string) bool {
+		mediaType = strings.ToLower(strings.TrimSpace(mediaType))
+		if mediaType == "image/webp" || mediaType == "image/avif" {
+			return true
+		}
+		for _, candidate := range parseSrcset(srcset) {
+			path := strings.ToLower(strings.SplitN(candidate, "?", 2)[0])
+			if strings.HasSuffix(path, ".webp") || strings.HasSuffix(path, ".avif") {
+				return true
+			}
+		}
+		return false
+	}
+
+	if isModern("", getAttr(n, "srcset")) {
+		return true
+	}
+	if n.Parent == nil || n.Parent.Data != "picture" {
+		return false
+	}
+	for sibling := n.Parent.FirstChild; sibling != nil; sibling = sibling.NextSibling {
+		if sibling.Type == html.ElementNode && sibling.Data == "source" && isModern(getAttr(sibling, "type"), getAttr(sibling, "srcset")) {
+			return true
+		}
+	}
+	return false
+}
+
+// dimensionAttr parses a width or height attribute in pixels, e.g. "640" or "640px"
+func dimensionAttr(n *html.Node, name string) int {
+	value := strings.TrimSuffix(strings.TrimSpace(getAttr(n, name)), "px")
+	if dot := strings.IndexByte(value, '.'); dot >= 0 {
+		value = value[:dot]
+	}
+	size, err := strconv.Atoi(value)
+	if err != nil || size < 0 {
+		return 0
+	}
+	return size
+}
+
+// minInt returns the smaller of two ints
+func minInt(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
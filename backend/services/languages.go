@@ -0,0 +1,208 @@
+package services
+
+import (
+	"strings"
+	"unicode"
+)
+
+// Frequent words of the languages detected from Latin-script text, also
+// skipped when counting keywords
+var stopwords = map[string]map[string]bool{
+	"en": wordSet("the and of to a in is that it for on with as was are be this by at from or an have not but you your we our they their can will has all more about which when there what one"),
+	"de": wordSet("der die das und ist in zu den von mit sich des auf für nicht eine ein im dem als auch es an werden aus er hat dass sie nach wird bei einer um am sind noch wie einem über einen so zum war haben nur oder aber vor zur bis unter"),
+	"fr": wordSet("le la les de des et est un une du en que qui dans pour pas sur au avec ce il par plus ne se sont aux ou son sa mais nous vous leur cette comme été ont elle tout lui être"),
+	"es": wordSet("el la los las de del y que en un una es por con no para se su al lo como más pero sus le ya o este sí porque esta entre cuando muy sin sobre también me hasta hay donde"),
+	"it": wordSet("il lo la i gli le di del della e che è un una per in con non si da al alla dei delle sono come anche più ma ha nel nella questo questa se gli essere tra"),
+	"pt": wordSet("o a os as de do da dos das e que em um uma é para com não no na por se mais como mas ao ele foi ser também seu sua ou quando muito nos já está"),
+	"nl": wordSet("de het een en van in is dat op te zijn met voor niet aan er die om ook als bij of door maar naar dan nog wel uit tot wordt hij ze we ons je"),
+	"sv": wordSet("och att det som en på är av för med den till inte har de om ett var men jag så kan vi från eller när sig under hade efter också ska"),
+	"pl": wordSet("i w na z się nie do to jest że o jak ale co przez po od za tak dla już jego czy może być są lub oraz tylko przy ten ta"),
+}
+
+// wordSet splits a list of words into a set
+func wordSet(words string) map[string]bool {
+	set := map[string]bool{}
+	for _, word := range strings.Fields(words) {
+		set[word] = true
+	}
+	return set
+}
+
+// Scripts that identify a language on their own, checked in order
+var scriptLanguages = []struct {
+	language string
+	table    *unicode.RangeTable
+}{
+	{"ko", unicode.Hangul},
+	{"ar", unicode.Arabic},
+	{"he", unicode.Hebrew},
+	{"el", unicode.Greek},
+	{"th", unicode.Thai},
+	{"hi", unicode.Devanagari},
+}
+
+// detectLanguage guesses the language of a text from its script or, for
+// Latin-script text, from its most frequent words. It returns an empty
+// string when the text is too short or ambiguous.
+func detectLanguage(words []string) string {
+	letters := map[string]int{}
+	total := 0
+	for _, word := range words {
+		for _, r := range word {
+			if !unicode.IsLetter(r) {
+				continue
+			}
+			total++
+			switch {
+			case unicode.Is(unicode.Hiragana, r) || unicode.Is(unicode.Katakana, r):
+				letters["kana"]++
+			case unicode.Is(unicode.Han, r):
+				letters["han"]++
+			case unicode.Is(unicode.Cyrillic, r):
+				letters["cyrillic"]++
+				if strings.ContainsRune("іїєґІЇЄҐ", r) {
+					letters["ukrainian"]++
+				}
+			case unicode.Is(unicode.Latin, r):
+				letters["latin"]++
+			default:
+				for _, script := range scriptLanguages {
+					if unicode.Is(script.table, r) {
+						letters[script.language]++
+						break
+					}
+				}
+			}
+		}
+	}
+	if total < 20 {
+		return ""
+	}
+
+	// Japanese mixes kana with Han characters
+	switch {
+	case letters["kana"]*10 >= total:
+		return "ja"
+	case (letters["han"]+letters["kana"])*2 >= total:
+		return "zh"
+	case letters["cyrillic"]*2 >= total:
+		if letters["ukrainian"] > 0 {
+			return "uk"
+		}
+		return "ru"
+	}
+	for _, script := range scriptLanguages {
+		if letters[script.language]*2 >= total {
+			return script.language
+		}
+	}
+	if letters["latin"]*2 < total {
+		return ""
+	}
+
+	// Latin-script languages are told apart by their stopwords
+	hits := map[string]int{}
+	for _, word := range words {
+		lower := strings.ToLower(word)
+		for language, set := range stopwords {
+			if set[lower] {
+				hits[language]++
+			}
+		}
+	}
+	best, bestHits, secondHits := "", 0, 0
+	for language, count := range hits {
+		if count > bestHits || count == bestHits && language < best {
+			best, bestHits, secondHits = language, count, bestHits
+		} else if count > secondHits {
+			secondHits = count
+		}
+	}
+
+	// Require the stopwords to be frequent and clearly ahead of other languages
+	if bestHits < 5 || bestHits*20 < len(words) || bestHits*4 < secondHits*5 {
+		return ""
+	}
+	return best
+}
+
+// Vowels used to count syllables per language
+var syllableVowels = map[string]string{
+	"en": "aeiouy",
+	"de": "aeiouyäöü",
+	"fr": "aeiouyàâéèêëîïôûùüÿœæ",
+	"es": "aeiouáéíóúü",
+	"it": "aeiouàèéìíòóùú",
+	"pt": "aeiouáàâãéêíóôõú",
+	"nl": "aeiouyëïé",
+}
+
+// countSyllables approximates the syllables of a word by counting groups of
+// vowels, ignoring the silent final "e" of English and French words
+func countSyllables(word, language string) int {
+	vowels := syllableVowels[language]
+	word = strings.ToLower(word)
+	runes := []rune(word)
+
+	count := 0
+	previousVowel := false
+	for _, r := range runes {
+		vowel := strings.ContainsRune(vowels, r)
+		if vowel && !previousVowel {
+			count++
+		}
+		previousVowel = vowel
+	}
+
+	switch language {
+	case "en":
+		if count > 1 && strings.HasSuffix(word, "e") && !strings.HasSuffix(word, "le") && !strings.HasSuffix(word, "ee") {
+			count--
+		}
+	case "fr":
+		if count > 1 && (strings.HasSuffix(word, "e") || strings.HasSuffix(word, "es")) {
+			count--
+		}
+	}
+	if count == 0 {
+		count = 1
+	}
+	return count
+}
+
+// readabilityFormula computes a reading ease score from the average sentence
+// length and syllables per word
+type readabilityFormula struct {
+	name  string
+	score func(wordsPerSentence, syllablesPerWord float64) float64
+}
+
+// Reading ease formulas adapted to each language
+var readabilityFormulas = map[string]readabilityFormula{
+	"en": {"flesch", func(asl, asw float64) float64 { return 206.835 - 1.015*asl - 84.6*asw }},
+	"de": {"amstad", func(asl, asw float64) float64 { return 180 - asl - 58.5*asw }},
+	"fr": {"kandel_moles", func(asl, asw float64) float64 { return 207 - 1.015*asl - 73.6*asw }},
+	"es": {"fernandez_huerta", func(asl, asw float64) float64 { return 206.84 - 60*asw - 102/asl }},
+	"it": {"flesch_vacca", func(asl, asw float64) float64 { return 217 - 1.3*asl - 60*asw }},
+	"pt": {"flesch_martins", func(asl, asw float64) float64 { return 248.835 - 1.015*asl - 84.6*asw }},
+	"nl": {"douma", func(asl, asw float64) float64 { return 206.835 - 0.93*asl - 77*asw }},
+}
+
+// readabilityLevel names the band of a reading ease score
+func readabilityLevel(score float64) string {
+	switch {
+	case score >= 90:
+		return "very_easy"
+	case score >= 80:
+		return "easy"
+	case score >= 70:
+		return "fairly_easy"
+	case score >= 60:
+		return "standard"
+	case score >= 50:
+		return "fairly_difficult"
+	case score >= 30:
+		return "difficult"
+	}
+	return "very_difficult"
+}
@@ -0,0 +1,142 @@
+package services
+
+import (
+	"net/url"
+	"strings"
+
+	"github.com/sykell/website-analyzer/models"
+	"golang.org/x/net/html"
+)
+
+// Anchor texts that say nothing about the link target, compared in lowercase
+// without surrounding punctuation
+var genericAnchorTexts = map[string]bool{
+	"click here": true, "click": true, "here": true, "this": true, "link": true, "this link": true,
+	"this page": true, "read more": true, "more": true, "learn more": true, "more info": true,
+	"more information": true, "details": true, "continue": true, "continue reading": true, "go": true,
+	"see more": true, "find out more": true, "download": true, "website": true,
+	"hier klicken": true, "hier": true, "mehr": true, "weiterlesen": true, "mehr erfahren": true,
+	"cliquez ici": true, "ici": true, "en savoir plus": true, "lire la suite": true,
+	"haga clic aquí": true, "aquí": true, "leer más": true, "más información": true,
+	"clicca qui": true, "qui": true, "leggi di più": true, "klik hier": true, "lees meer": true,
+}
+
+// Domains of affiliate networks and link shorteners used for paid links
+var affiliateDomains = map[string]bool{
+	"amzn.to": true, "awin1.com": true, "shareasale.com": true, "clickbank.net": true, "linksynergy.com": true,
+	"anrdoezrs.net": true, "dpbolvw.net": true, "jdoqocy.com": true, "kqzyfj.com": true, "tkqlhce.com": true,
+	"prf.hn": true, "skimresources.com": true, "sjv.io": true, "pntra.com": true, "avantlink.com": true,
+	"go2cloud.org": true, "impactradius.com": true, "partnerize.com": true, "tradedoubler.com": true,
+	"webgains.com": true, "zanox.com": true, "rstyle.me": true, "shopstyle.it": true,
+}
+
+// Query parameters identifying affiliate or paid traffic
+var paidQueryParams = []string{"aff_id", "affid", "affiliate", "affiliate_id", "aff", "partner_id"}
+
+// auditLinkAttributes keeps the attributes of a link, flags problems with
+// them and adds the link to the attribute counts
+func (c *Crawler) auditLinkAttributes(anchor *html.Node, link *url.URL) models.PageLink {
+	rels := strings.Fields(strings.ToLower(getAttr(anchor, "rel")))
+	target := strings.TrimSpace(getAttr(anchor, "target"))
+	pageLink := models.PageLink{
+		WebsiteID:  c.website.ID,
+		URL:        truncateString(link.String(), 2048),
+		AnchorText: anchorText(anchor),
+		Rel:        truncateString(strings.Join(rels, " "), 255),
+		Target:     truncateString(target, 50),
+		Hreflang:   truncateString(strings.TrimSpace(getAttr(anchor, "hreflang")), 35),
+		Bucket:     link.Scheme,
+		Issues:     []string{},
+	}
+	web := link.Scheme == "http" || link.Scheme == "https"
+	if web {
+		pageLink.Bucket = c.linkBucket(link)
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	counts := c.website.LinkCounts
+
+	if containsString(rels, "nofollow") {
+		counts.NofollowLinks++
+	}
+	if containsString(rels, "sponsored") {
+		counts.SponsoredLinks++
+	}
+	if containsString(rels, "ugc") {
+		counts.UGCLinks++
+	}
+
+	if web && pageLink.Bucket != linkInternal {
+		// noreferrer implies noopener
+		if strings.EqualFold(target, "_blank") && !containsString(rels, "noopener") && !containsString(rels, "noreferrer") {
+			pageLink.Issues = append(pageLink.Issues, "unsafe_target_blank")
+			counts.UnsafeBlankTargets++
+		}
+		if isPaidLink(link) && !containsString(rels, "sponsored") {
+			pageLink.Issues = append(pageLink.Issues, "paid_without_sponsored")
+			counts.UnmarkedPaidLinks++
+		}
+	}
+	if web && pageLink.Bucket == linkInternal && containsString(rels, "nofollow") {
+		pageLink.Issues = append(pageLink.Issues, "internal_nofollow")
+		counts.InternalNofollow++
+	}
+
+	// Fall back to the accessible name when the link has no visible text
+	name := pageLink.AnchorText
+	if name == "" {
+		name = strings.TrimSpace(getAttr(anchor, "aria-label"))
+	}
+	if name == "" {
+		name = strings.TrimSpace(getAttr(anchor, "title"))
+	}
+	switch {
+	case name == "":
+		pageLink.Issues = append(pageLink.Issues, "empty_anchor")
+		counts.EmptyAnchors++
+	case genericAnchorTexts[strings.ToLower(strings.Trim(name, " .,:;!?»›→…>"))]:
+		pageLink.Issues = append(pageLink.Issues, "generic_anchor")
+		counts.GenericAnchors++
+	}
+
+	return pageLink
+}
+
+// isPaidLink checks if a link goes through an affiliate network, an ad
+// network from the tracker list, or carries affiliate or paid campaign parameters
+func isPaidLink(link *url.URL) bool {
+	host := strings.ToLower(link.Hostname())
+	if affiliateDomains[registrableDomain(host)] || affiliateDomains[host] {
+		return true
+	}
+	if list, err := loadTrackers(); err == nil {
+		if tracker, ok := lookupTracker(list, host); ok && tracker.Category == "advertising" {
+			return true
+		}
+	}
+
+	query := link.Query()
+	for _, param := range paidQueryParams {
+		if query.Get(param) != "" {
+			return true
+		}
+	}
+	// Amazon associate links carry the associate tag
+	if strings.HasPrefix(registrableDomain(host), "amazon.") && query.Get("tag") != "" {
+		return true
+	}
+	switch strings.ToLower(query.Get("utm_medium")) {
+	case "affiliate", "cpc", "ppc", "paid", "sponsored":
+		return true
+	}
+	return false
+}
+
+// truncateString shortens a string to at most max characters
+func truncateString(value string, max int) string {
+	if runes := []rune(value); len(runes) > max {
+		return string(runes[:max])
+	}
+	return value
+}
@@ -0,0 +1,309 @@
+package services
+
+import (
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/sykell/website-analyzer/models"
+	"golang.org/x/net/html"
+)
+
+const (
+	// DefaultMaxPages is the number of pages followed for the link graph unless configured
+	DefaultMaxPages = 50
+
+	// maxGraphPageSize limits how much of each followed page is parsed
+	maxGraphPageSize = 5 << 20
+
+	// maxAnchorTextLength limits the stored anchor text of a link, in characters
+	maxAnchorTextLength = 1000
+
+	// maxGraphURLLength skips URLs too long to be stored
+	maxGraphURLLength = 2048
+
+	// DefaultGraphExclude matches logout and signout links, which the link graph doesn't follow
+	DefaultGraphExclude = `(?i)(?:log|sign)[-_]?(?:out|off)`
+
+	pageRankDamping    = 0.85
+	pageRankIterations = 100
+	pageRankTolerance  = 1e-9
+)
+
+// pageLink represents an internal link found on a page
+type pageLink struct {
+	URL        *url.URL
+	AnchorText string
+	Rel        string
+}
+
+// graphPage represents a page fetched while following internal links
+type graphPage struct {
+	statusCode int
+	links      []pageLink
+}
+
+// buildLinkGraph follows internal links breadth-first from the start page, up
+// to the page limit, and scores the pages of the resulting graph
+func (c *Crawler) buildLinkGraph(doc *html.Node, statusCode int) *models.LinkGraph {
+	maxPages := c.settings.MaxPages
+	if maxPages <= 0 {
+		maxPages = DefaultMaxPages
+	}
+
+	graph := &models.LinkGraph{Nodes: []models.GraphNode{}, Edges: []models.GraphEdge{}}
+	nodes := map[string]int{} // URL to index in graph.Nodes
+	addNode := func(pageURL string, depth int) {
+		nodes[pageURL] = len(graph.Nodes)
+		graph.Nodes = append(graph.Nodes, models.GraphNode{WebsiteID: c.website.ID, URL: pageURL, Depth: depth})
+	}
+
+	start := normalizePageURL(c.pageURL)
+	addNode(start, 0)
+	graph.Nodes[0].Crawled = true
+	graph.Nodes[0].StatusCode = statusCode
+	pages := map[string]*graphPage{start: {statusCode: statusCode, links: c.pageLinks(doc, c.pageURL)}}
+	queued := map[string]bool{start: true}
+	crawled := 1
+	exclude := c.graphExcludePattern()
+
+	// Pages first found at the same depth are fetched together
+	level := []string{start}
+	for len(level) > 0 {
+		var next []string
+		for _, source := range level {
+			for _, link := range pages[source].links {
+				target := normalizePageURL(link.URL)
+				if target == source || len(target) > maxGraphURLLength {
+					continue
+				}
+				graph.Edges = append(graph.Edges, models.GraphEdge{
+					WebsiteID:  c.website.ID,
+					Source:     source,
+					Target:     target,
+					AnchorText: link.AnchorText,
+					Rel:        truncateString(link.Rel, 255),
+				})
+				if _, ok := nodes[target]; !ok {
+					addNode(target, graph.Nodes[nodes[source]].Depth+1)
+				}
+				// Pages that may only be reached through links not to follow stay uncrawled
+				if !queued[target] && c.followsGraphLink(link, exclude) {
+					queued[target] = true
+					next = append(next, target)
+				}
+			}
+		}
+
+		// Pages beyond the limit stay in the graph without being followed
+		if remaining := maxPages - crawled; len(next) > remaining {
+			next = next[:remaining]
+		}
+		for pageURL, page := range c.fetchGraphPages(next) {
+			pages[pageURL] = page
+			node := &graph.Nodes[nodes[pageURL]]
+			node.Crawled = true
+			node.StatusCode = page.statusCode
+		}
+		crawled += len(next)
+		level = next
+	}
+
+	// Count the links of every page
+	for _, edge := range graph.Edges {
+		graph.Nodes[nodes[edge.Source]].OutDegree++
+		graph.Nodes[nodes[edge.Target]].InDegree++
+	}
+	computePageRank(graph, nodes)
+
+	// Pages in the sitemap that no crawled page links to may be orphans
+	for _, entry := range c.sitemapEntries() {
+		entryURL, err := url.Parse(entry.Loc)
+		if err != nil || !entryURL.IsAbs() || !c.inScope(entryURL) {
+			continue
+		}
+		pageURL := normalizePageURL(entryURL)
+		if _, ok := nodes[pageURL]; ok || len(pageURL) > maxGraphURLLength {
+			continue
+		}
+		addNode(pageURL, -1)
+		graph.Nodes[len(graph.Nodes)-1].Orphan = true
+	}
+
+	return graph
+}
+
+// graphExcludePattern compiles the pattern of links the link graph doesn't
+// follow, falling back to the default for an invalid pattern
+func (c *Crawler) graphExcludePattern() *regexp.Regexp {
+	pattern := c.settings.GraphExclude
+	if pattern == "" {
+		pattern = DefaultGraphExclude
+	}
+	exclude, err := regexp.Compile(pattern)
+	if err != nil {
+		log.Printf("Invalid graph exclude pattern %q: %v", pattern, err)
+		return regexp.MustCompile(DefaultGraphExclude)
+	}
+	return exclude
+}
+
+// followsGraphLink checks if the link graph may fetch a link. Links marked
+// nofollow, disallowed by robots.txt or matching the exclude pattern, like
+// logout links that would end the session, are not fetched.
+func (c *Crawler) followsGraphLink(link pageLink, exclude *regexp.Regexp) bool {
+	if containsString(strings.Fields(link.Rel), "nofollow") {
+		return false
+	}
+	if exclude.MatchString(link.URL.String()) {
+		return false
+	}
+	return c.robotsFile().allowed(link.URL)
+}
+
+// fetchGraphPages fetches pages concurrently and extracts their internal links
+func (c *Crawler) fetchGraphPages(pageURLs []string) map[string]*graphPage {
+	pages := map[string]*graphPage{}
+	var wg sync.WaitGroup
+	semaphore := make(chan struct{}, 10) // Limit concurrency
+	for _, pageURL := range pageURLs {
+		wg.Add(1)
+		go func(pageURL string) {
+			defer wg.Done()
+			semaphore <- struct{}{}        // Acquire token
+			defer func() { <-semaphore }() // Release token
+
+			page := c.fetchGraphPage(pageURL)
+			c.mutex.Lock()
+			pages[pageURL] = page
+			c.mutex.Unlock()
+		}(pageURL)
+	}
+	wg.Wait()
+
+	return pages
+}
+
+// fetchGraphPage fetches a page and extracts its internal links. Pages that
+// redirect out of the scope or are not HTML have no links.
+func (c *Crawler) fetchGraphPage(pageURL string) *graphPage {
+	page := &graphPage{}
+	resp, err := c.doAuthenticated(c.httpClient, "GET", pageURL)
+	if err != nil {
+		return page
+	}
+	defer resp.Body.Close()
+	page.statusCode = resp.StatusCode
+
+	mediaType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	if resp.StatusCode != http.StatusOK || mediaType != "text/html" || !c.inScope(resp.Request.URL) {
+		return page
+	}
+	doc, err := html.Parse(io.LimitReader(resp.Body, maxGraphPageSize))
+	if err != nil {
+		return page
+	}
+	page.links = c.pageLinks(doc, resp.Request.URL)
+
+	return page
+}
+
+// pageLinks returns the in-scope links of a document with their anchor text and rel
+func (c *Crawler) pageLinks(doc *html.Node, base *url.URL) []pageLink {
+	var links []pageLink
+	selector, _ := compileSelector("a[href]")
+	for _, anchor := range selector.MatchAll(doc) {
+		linkURL, err := base.Parse(strings.TrimSpace(getAttr(anchor, "href")))
+		if err != nil || (linkURL.Scheme != "http" && linkURL.Scheme != "https") || !c.inScope(linkURL) {
+			continue
+		}
+		links = append(links, pageLink{
+			URL:        linkURL,
+			AnchorText: anchorText(anchor),
+			Rel:        strings.Join(strings.Fields(strings.ToLower(getAttr(anchor, "rel"))), " "),
+		})
+	}
+	return links
+}
+
+// anchorText returns the visible text of a link, or the alt text of its images
+func anchorText(anchor *html.Node) string {
+	text := strings.Join(strings.Fields(textContent(anchor)), " ")
+	if text == "" {
+		imgSelector, _ := compileSelector("img[alt]")
+		var alts []string
+		for _, img := range imgSelector.MatchAll(anchor) {
+			alts = append(alts, strings.TrimSpace(getAttr(img, "alt")))
+		}
+		text = strings.Join(strings.Fields(strings.Join(alts, " ")), " ")
+	}
+	return truncateString(text, maxAnchorTextLength)
+}
+
+// normalizePageURL identifies a page by its URL without fragment
+func normalizePageURL(u *url.URL) string {
+	normalized := *u
+	normalized.Fragment, normalized.RawFragment = "", ""
+	normalized.Host = strings.ToLower(normalized.Host)
+	if normalized.Path == "" {
+		normalized.Path = "/"
+	}
+	return normalized.String()
+}
+
+// computePageRank scores the pages by iterating PageRank over the internal
+// links. Pages without links spread their score evenly over all pages.
+func computePageRank(graph *models.LinkGraph, nodes map[string]int) {
+	n := len(graph.Nodes)
+	if n == 0 {
+		return
+	}
+
+	outLinks := make([][]int, n)
+	for _, edge := range graph.Edges {
+		source := nodes[edge.Source]
+		outLinks[source] = append(outLinks[source], nodes[edge.Target])
+	}
+
+	ranks := make([]float64, n)
+	for i := range ranks {
+		ranks[i] = 1 / float64(n)
+	}
+	for iteration := 0; iteration < pageRankIterations; iteration++ {
+		next := make([]float64, n)
+		dangling := 0.0
+		for i, targets := range outLinks {
+			if len(targets) == 0 {
+				dangling += ranks[i]
+				continue
+			}
+			share := ranks[i] / float64(len(targets))
+			for _, target := range targets {
+				next[target] += share
+			}
+		}
+
+		delta := 0.0
+		for i := range next {
+			next[i] = (1-pageRankDamping)/float64(n) + pageRankDamping*(next[i]+dangling/float64(n))
+			if diff := next[i] - ranks[i]; diff > 0 {
+				delta += diff
+			} else {
+				delta -= diff
+			}
+		}
+		ranks = next
+		if delta < pageRankTolerance {
+			break
+		}
+	}
+
+	for i := range graph.Nodes {
+		graph.Nodes[i].PageRank = ranks[i]
+	}
+}
@@ -0,0 +1,155 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/mail"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// mxLookupTimeout limits each mail server lookup
+const mxLookupTimeout = 5 * time.Second
+
+var (
+	// Phone numbers may contain visual separators, which are removed before validation
+	phoneSeparatorRegex = regexp.MustCompile(`[\s\-.()]`)
+	globalPhoneRegex    = regexp.MustCompile(`^\+[0-9]{4,15}$`)
+	localPhoneRegex     = regexp.MustCompile(`^[0-9*#]{2,20}$`)
+)
+
+// Classifications of links with schemes the crawler does not check
+var skippedSchemeClasses = map[string]string{
+	"javascript": "script",
+	"data":       "data",
+	"blob":       "data",
+	"ftp":        "file_transfer",
+	"ftps":       "file_transfer",
+	"sftp":       "file_transfer",
+	"file":       "local_file",
+}
+
+// schemeClassification classifies a link scheme the crawler skips. Unknown
+// schemes are assumed to open an app, like whatsapp: or spotify:.
+func schemeClassification(scheme string) string {
+	if class, ok := skippedSchemeClasses[scheme]; ok {
+		return class
+	}
+	return "app"
+}
+
+// checkContactLink validates a mailto:, tel: or sms: link and returns the
+// reason it is broken, or an empty string if it is valid
+func (c *Crawler) checkContactLink(link *url.URL) string {
+	switch link.Scheme {
+	case "mailto":
+		addresses, ok := mailtoAddresses(link)
+		if !ok {
+			return "invalid_email"
+		}
+		if c.settings.CheckMX {
+			for _, address := range addresses {
+				if !c.hasMailServer(address[strings.LastIndex(address, "@")+1:]) {
+					return "no_mx_record"
+				}
+			}
+		}
+	case "tel", "sms":
+		if !validPhoneNumber(link.Opaque) {
+			return "invalid_phone"
+		}
+	}
+	return ""
+}
+
+// mailtoAddresses returns the recipients of a mailto: link, including those in
+// the to, cc and bcc fields, and whether they are all valid addresses
+func mailtoAddresses(link *url.URL) ([]string, bool) {
+	recipients, err := url.PathUnescape(link.Opaque)
+	if err != nil {
+		return nil, false
+	}
+	query := link.Query()
+	var candidates []string
+	for _, field := range []string{recipients, query.Get("to"), query.Get("cc"), query.Get("bcc")} {
+		for _, candidate := range strings.Split(field, ",") {
+			if candidate = strings.TrimSpace(candidate); candidate != "" {
+				candidates = append(candidates, candidate)
+			}
+		}
+	}
+	if len(candidates) == 0 {
+		return nil, false
+	}
+
+	var addresses []string
+	for _, candidate := range candidates {
+		address, err := mail.ParseAddress(candidate)
+		// Only bare addresses are valid in mailto links, not "Name <address>"
+		if err != nil || address.Address != candidate || !strings.Contains(address.Address, ".") {
+			return nil, false
+		}
+		addresses = append(addresses, address.Address)
+	}
+	return addresses, true
+}
+
+// validPhoneNumber checks the number of a tel: or sms: link (RFC 3966).
+// Local numbers are accepted since they are common on regional sites.
+func validPhoneNumber(opaque string) bool {
+	number, params, _ := strings.Cut(opaque, ";")
+	number, _, _ = strings.Cut(number, "?") // sms: links may carry a body
+	number, err := url.PathUnescape(number)
+	if err != nil {
+		return false
+	}
+	number = phoneSeparatorRegex.ReplaceAllString(number, "")
+	if strings.HasPrefix(number, "+") {
+		return globalPhoneRegex.MatchString(number)
+	}
+	return localPhoneRegex.MatchString(number) || (strings.Contains(params, "phone-context=") && number != "")
+}
+
+// hasMailServer checks if a domain accepts mail. Domains without MX records
+// fall back to their address records, as mail servers do (RFC 5321).
+func (c *Crawler) hasMailServer(domain string) bool {
+	domain = strings.ToLower(domain)
+
+	c.mutex.Lock()
+	if c.mxCache == nil {
+		c.mxCache = map[string]bool{}
+	}
+	result, ok := c.mxCache[domain]
+	c.mutex.Unlock()
+	if ok {
+		return result
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), mxLookupTimeout)
+	defer cancel()
+
+	records, err := net.DefaultResolver.LookupMX(ctx, domain)
+	var dnsErr *net.DNSError
+	switch {
+	case err == nil && len(records) == 1 && records[0].Host == ".":
+		// A null MX record means the domain accepts no mail (RFC 7505)
+		result = false
+	case err == nil && len(records) > 0:
+		result = true
+	case err == nil || (errors.As(err, &dnsErr) && dnsErr.IsNotFound):
+		addresses, err := net.DefaultResolver.LookupHost(ctx, domain)
+		result = err == nil && len(addresses) > 0
+	default:
+		// Resolver failures are not the link's fault
+		result = true
+	}
+
+	c.mutex.Lock()
+	c.mxCache[domain] = result
+	c.mutex.Unlock()
+
+	return result
+}
@@ -0,0 +1,297 @@
+package services
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// maxReauthentications limits how often a crawl logs in again after being bounced
+const maxReauthentications = 3
+
+// login executes the website's login recipe. The session cookies end up in
+// the crawler's cookie jar and are sent with every later request.
+func (c *Crawler) login() error {
+	recipe := c.settings.Login
+
+	// Fetch the login page to pick up the form and any CSRF tokens
+	req, err := c.newRequest("GET", recipe.LoginURL, nil)
+	if err != nil {
+		return fmt.Errorf("invalid login URL: %w", err)
+	}
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to fetch login page: %w", err)
+	}
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return fmt.Errorf("failed to read login page: %w", err)
+	}
+
+	doc, err := html.Parse(bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to parse login page: %w", err)
+	}
+
+	form := c.findLoginForm(doc)
+	if form == nil {
+		return fmt.Errorf("no login form found on %s", recipe.LoginURL)
+	}
+
+	// Submit the form with its existing values overridden by the recipe
+	values := formValues(form)
+	for name, value := range recipe.Fields {
+		values.Set(name, value)
+	}
+
+	action, err := resp.Request.URL.Parse(getAttr(form, "action"))
+	if err != nil {
+		return fmt.Errorf("invalid login form action: %w", err)
+	}
+
+	if strings.EqualFold(getAttr(form, "method"), "get") {
+		action.RawQuery = values.Encode()
+		req, err = c.newRequest("GET", action.String(), nil)
+	} else {
+		req, err = c.newRequest("POST", action.String(), strings.NewReader(values.Encode()))
+		if err == nil {
+			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		}
+	}
+	if err != nil {
+		return fmt.Errorf("failed to create login request: %w", err)
+	}
+	req.Header.Set("Referer", resp.Request.URL.String())
+
+	resp, err = c.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to submit login form: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 400 {
+		return fmt.Errorf("login failed with HTTP status code: %d", resp.StatusCode)
+	}
+
+	return c.checkLoginSuccess(resp)
+}
+
+// checkLoginSuccess verifies the login response against the recipe's success check
+func (c *Crawler) checkLoginSuccess(resp *http.Response) error {
+	recipe := c.settings.Login
+
+	if recipe.SuccessCookie != "" {
+		found := false
+		for _, cookie := range c.httpClient.Jar.Cookies(c.baseURL) {
+			if cookie.Name == recipe.SuccessCookie {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return fmt.Errorf("login failed: cookie %q was not set", recipe.SuccessCookie)
+		}
+	}
+
+	if recipe.SuccessSelector != "" {
+		selector, err := compileSelector(recipe.SuccessSelector)
+		if err != nil {
+			return fmt.Errorf("invalid login success selector: %w", err)
+		}
+		doc, err := html.Parse(resp.Body)
+		if err != nil {
+			return fmt.Errorf("failed to parse login response: %w", err)
+		}
+		if selector.MatchFirst(doc) == nil {
+			return fmt.Errorf("login failed: %q not found on the page after login", recipe.SuccessSelector)
+		}
+	}
+
+	// Without an explicit check, landing back on the login page means failure
+	if recipe.SuccessCookie == "" && recipe.SuccessSelector == "" && c.isLoginPage(resp.Request.URL) {
+		return fmt.Errorf("login failed: redirected back to the login page")
+	}
+
+	return nil
+}
+
+// findLoginForm finds the form the recipe fields belong to
+func (c *Crawler) findLoginForm(doc *html.Node) *html.Node {
+	var forms []*html.Node
+	var findFormsFunc func(*html.Node)
+	findFormsFunc = func(n *html.Node) {
+		if n.Type == html.ElementNode && n.Data == "form" {
+			forms = append(forms, n)
+		}
+		for child := n.FirstChild; child != nil; child = child.NextSibling {
+			findFormsFunc(child)
+		}
+	}
+	findFormsFunc(doc)
+
+	// Prefer the form containing the recipe's fields, then one with a password field
+	for _, form := range forms {
+		values := formValues(form)
+		for name := range c.settings.Login.Fields {
+			if _, ok := values[name]; ok {
+				return form
+			}
+		}
+	}
+	passwordSelector, _ := compileSelector("input[type=password]")
+	for _, form := range forms {
+		if passwordSelector.MatchFirst(form) != nil {
+			return form
+		}
+	}
+	if len(forms) > 0 {
+		return forms[0]
+	}
+	return nil
+}
+
+// formValues collects the default values of a form's named fields
+func formValues(form *html.Node) url.Values {
+	values := url.Values{}
+	var collectFunc func(*html.Node)
+	collectFunc = func(n *html.Node) {
+		if n.Type == html.ElementNode {
+			name := getAttr(n, "name")
+			switch {
+			case name == "":
+			case n.Data == "input":
+				switch strings.ToLower(getAttr(n, "type")) {
+				case "submit", "button", "image", "reset", "file":
+				case "checkbox", "radio":
+					if _, checked := lookupAttr(n, "checked"); checked {
+						values.Add(name, getAttr(n, "value"))
+					}
+				default:
+					values.Add(name, getAttr(n, "value"))
+				}
+			case n.Data == "textarea":
+				values.Add(name, textContent(n))
+			case n.Data == "select":
+				values.Add(name, selectedOption(n))
+			}
+		}
+		for child := n.FirstChild; child != nil; child = child.NextSibling {
+			collectFunc(child)
+		}
+	}
+	collectFunc(form)
+	return values
+}
+
+// selectedOption returns the value of a select's selected (or first) option
+func selectedOption(n *html.Node) string {
+	optionSelector, _ := compileSelector("option")
+	options := optionSelector.MatchAll(n)
+	for _, option := range options {
+		if _, selected := lookupAttr(option, "selected"); selected {
+			return optionValue(option)
+		}
+	}
+	if len(options) > 0 {
+		return optionValue(options[0])
+	}
+	return ""
+}
+
+// optionValue returns an option's value attribute, falling back to its text
+func optionValue(option *html.Node) string {
+	if value, ok := lookupAttr(option, "value"); ok {
+		return value
+	}
+	return strings.TrimSpace(textContent(option))
+}
+
+// textContent returns the concatenated text of a node and its descendants
+func textContent(n *html.Node) string {
+	var sb strings.Builder
+	var collectFunc func(*html.Node)
+	collectFunc = func(n *html.Node) {
+		if n.Type == html.TextNode {
+			sb.WriteString(n.Data)
+		}
+		for child := n.FirstChild; child != nil; child = child.NextSibling {
+			collectFunc(child)
+		}
+	}
+	collectFunc(n)
+	return sb.String()
+}
+
+// isLoginPage checks if a URL points at the recipe's login page
+func (c *Crawler) isLoginPage(u *url.URL) bool {
+	if c.settings.Login == nil {
+		return false
+	}
+	loginURL, err := url.Parse(c.settings.Login.LoginURL)
+	if err != nil {
+		return false
+	}
+	return strings.EqualFold(u.Host, loginURL.Host) &&
+		strings.TrimSuffix(u.Path, "/") == strings.TrimSuffix(loginURL.Path, "/")
+}
+
+// doAuthenticated sends a request and logs in again when the website bounces
+// it back to the login page, retrying the request once with the new session
+func (c *Crawler) doAuthenticated(client *http.Client, method, rawURL string) (*http.Response, error) {
+	req, err := c.newRequest(method, rawURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	return c.sendAuthenticated(client, req)
+}
+
+// sendAuthenticated is doAuthenticated for a prepared request without a body
+func (c *Crawler) sendAuthenticated(client *http.Client, req *http.Request) (*http.Response, error) {
+	c.loginMutex.Lock()
+	session := c.loginCount
+	c.loginMutex.Unlock()
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	// Only a redirect away from the requested page counts as a bounce
+	if c.settings.Login == nil || !c.isLoginPage(resp.Request.URL) || c.isLoginPage(req.URL) {
+		return resp, nil
+	}
+	resp.Body.Close()
+
+	if err := c.reauthenticate(session); err != nil {
+		return nil, err
+	}
+
+	// The client added the stale session cookies to the request, so rebuild them
+	retry := req.Clone(req.Context())
+	retry.Header.Del("Cookie")
+	c.applyProfile(retry)
+	return client.Do(retry)
+}
+
+// reauthenticate logs in again unless another request already renewed the
+// session it was bounced from, so concurrent link checks share one login
+func (c *Crawler) reauthenticate(session int) error {
+	c.loginMutex.Lock()
+	defer c.loginMutex.Unlock()
+
+	if c.loginCount != session {
+		return nil
+	}
+	if c.loginCount > maxReauthentications {
+		return fmt.Errorf("session lost: re-authentication limit reached")
+	}
+	c.loginCount++
+
+	return c.login()
+}
@@ -0,0 +1,86 @@
+package services
+
+import (
+	"sync"
+
+	"github.com/sykell/website-analyzer/models"
+	"golang.org/x/net/html"
+)
+
+// Resource kinds browsers block as active mixed content. Images and media
+// are passive: browsers upgrade or load them with a warning instead.
+var activeMixedContentKinds = map[string]bool{
+	"script":     true,
+	"stylesheet": true,
+	"font":       true,
+	"iframe":     true,
+	"object":     true,
+	"form":       true,
+	"manifest":   true,
+	"other":      true,
+}
+
+// detectMixedContent finds http:// subresources on a page served over HTTPS
+// and checks whether each of them is also available over HTTPS
+func (c *Crawler) detectMixedContent(doc *html.Node) []models.MixedContentItem {
+	items := []models.MixedContentItem{}
+	if c.pageURL.Scheme != "https" {
+		return items
+	}
+
+	seen := map[string]bool{}
+	for _, resource := range c.collectResources(doc) {
+		if resource.URL.Scheme != "http" {
+			continue
+		}
+		key := resource.Tag + " " + resource.Attr + " " + resource.URL.String()
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+
+		contentType := "passive"
+		if activeMixedContentKinds[resource.Kind] {
+			contentType = "active"
+		}
+		items = append(items, models.MixedContentItem{
+			WebsiteID:   c.website.ID,
+			URL:         resource.URL.String(),
+			Tag:         resource.Tag,
+			Attribute:   resource.Attr,
+			ContentType: contentType,
+		})
+	}
+
+	// Check the HTTPS variant of each resource concurrently
+	var wg sync.WaitGroup
+	semaphore := make(chan struct{}, 10) // Limit concurrency
+	for i := range items {
+		wg.Add(1)
+		go func(item *models.MixedContentItem) {
+			defer wg.Done()
+			semaphore <- struct{}{}        // Acquire token
+			defer func() { <-semaphore }() // Release token
+
+			httpsURL := *c.pageURL
+			if parsed, err := c.pageURL.Parse(item.URL); err == nil {
+				httpsURL = *parsed
+			}
+			httpsURL.Scheme = "https"
+			if httpsURL.Port() == "80" {
+				httpsURL.Host = httpsURL.Hostname()
+			}
+
+			statusCode, err := c.checkLinkAccessibility(httpsURL.String())
+			item.HTTPSAvailable = err == nil && statusCode < 400
+		}(&items[i])
+	}
+	wg.Wait()
+
+	// The HTTPS check needs the full URL, so truncate to the column size afterwards
+	for i := range items {
+		items[i].URL = truncateString(items[i].URL, 2048)
+	}
+
+	return items
+}
@@ -0,0 +1,449 @@
+package services
+
+import (
+	"bytes"
+	"compress/zlib"
+	"errors"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf16"
+)
+
+// maxPDFStreamSize limits how much of each compressed stream is inflated
+const maxPDFStreamSize = 10 << 20
+
+var (
+	pdfVersionRegex   = regexp.MustCompile(`%PDF-(\d\.\d)`)
+	pdfObjectRegex    = regexp.MustCompile(`(\d+)\s+\d+\s+obj\b`)
+	pdfLengthRegex    = regexp.MustCompile(`/Length\s+(\d+)(?:\s+\d+\s+R)?`)
+	pdfPageRegex      = regexp.MustCompile(`/Type\s*/Page\b`)
+	pdfObjStmRegex    = regexp.MustCompile(`/Type\s*/ObjStm\b`)
+	pdfXRefRegex      = regexp.MustCompile(`/Type\s*/XRef\b`)
+	pdfInfoRegex      = regexp.MustCompile(`/Info\s+(\d+)\s+\d+\s+R`)
+	pdfURIRegex       = regexp.MustCompile(`/URI\s*[(<]`)
+	pdfReferenceRegex = regexp.MustCompile(`^\s*(\d+)\s+\d+\s+R`)
+	pdfIntRegex       = regexp.MustCompile(`/(N|First)\s+(\d+)`)
+	pdfTrailerRegex   = regexp.MustCompile(`trailer\s*<<`)
+)
+
+// Keys of the document information dictionary
+var pdfInfoKeys = []string{"Title", "Author", "Subject", "Keywords", "Creator", "Producer", "CreationDate", "ModDate"}
+
+// pdfObject is an object of a PDF file, split into its dictionary and decoded stream
+type pdfObject struct {
+	dict   []byte
+	stream []byte
+}
+
+// pdfDocument is what was read from a PDF file
+type pdfDocument struct {
+	version   string
+	pageCount int
+	encrypted bool
+	info      map[string]string
+	links     []string
+}
+
+// parsePDF reads the version, page count, document information and link
+// URIs of a PDF file. Objects are found by scanning rather than through the
+// cross-reference table, so damaged files still give results. Streams are
+// inflated when they use FlateDecode, which includes compressed object streams.
+func parsePDF(data []byte) (*pdfDocument, error) {
+	header := data[:minInt(len(data), 1024)]
+	match := pdfVersionRegex.FindSubmatch(header)
+	if match == nil {
+		return nil, errors.New("not a PDF file")
+	}
+	document := &pdfDocument{version: string(match[1]), info: map[string]string{}}
+
+	objects := map[int]*pdfObject{}
+	var trailers [][]byte
+	for pos := 0; pos < len(data); {
+		loc := pdfObjectRegex.FindSubmatchIndex(data[pos:])
+		if loc == nil {
+			break
+		}
+		number, _ := strconv.Atoi(string(data[pos+loc[2] : pos+loc[3]]))
+		start := pos + loc[1]
+		object, end := readPDFObject(data, start)
+		pos = end
+
+		// Later definitions are incremental updates replacing earlier ones
+		objects[number] = object
+		if pdfXRefRegex.Match(object.dict) {
+			trailers = append(trailers, object.dict)
+		}
+	}
+
+	// Objects of object streams don't replace objects defined directly
+	for _, object := range objects {
+		if object.stream == nil || !pdfObjStmRegex.Match(object.dict) {
+			continue
+		}
+		for number, contained := range readObjectStream(object) {
+			if _, ok := objects[number]; !ok {
+				objects[number] = contained
+			}
+		}
+	}
+
+	// Classic trailers come after the cross-reference table
+	for _, index := range pdfTrailerRegex.FindAllIndex(data, -1) {
+		end := bytes.Index(data[index[0]:], []byte("startxref"))
+		if end < 0 {
+			end = len(data) - index[0]
+		}
+		trailers = append(trailers, data[index[0]:index[0]+end])
+	}
+
+	var infoNumber int
+	for _, trailer := range trailers {
+		if bytes.Contains(trailer, []byte("/Encrypt")) {
+			document.encrypted = true
+		}
+		if match := pdfInfoRegex.FindSubmatch(trailer); match != nil {
+			infoNumber, _ = strconv.Atoi(string(match[1]))
+		}
+	}
+
+	seenLinks := map[string]bool{}
+	for _, object := range objects {
+		if pdfPageRegex.Match(object.dict) {
+			document.pageCount++
+		}
+		// Strings of encrypted files can't be read without the key
+		if document.encrypted {
+			continue
+		}
+		for _, index := range pdfURIRegex.FindAllIndex(object.dict, -1) {
+			if value, ok := readPDFString(object.dict[index[1]-1:]); ok {
+				link := strings.TrimSpace(decodePDFText(value))
+				if link != "" && !seenLinks[link] {
+					seenLinks[link] = true
+					document.links = append(document.links, link)
+				}
+			}
+		}
+	}
+
+	if info := objects[infoNumber]; info != nil && !document.encrypted {
+		for _, key := range pdfInfoKeys {
+			value, ok := pdfDictString(info.dict, key, objects)
+			if !ok {
+				continue
+			}
+			if key == "CreationDate" || key == "ModDate" {
+				value = formatPDFDate(value)
+			}
+			if value = strings.TrimSpace(value); value != "" {
+				document.info[key] = truncateString(value, 1024)
+			}
+		}
+	}
+
+	return document, nil
+}
+
+// readPDFObject reads the object starting at start and returns it with the offset after it
+func readPDFObject(data []byte, start int) (*pdfObject, int) {
+	end := bytes.Index(data[start:], []byte("endobj"))
+	if end < 0 {
+		end = len(data) - start
+	}
+	body := data[start : start+end]
+	next := start + end
+
+	streamIndex := bytes.Index(body, []byte("stream"))
+	if streamIndex < 0 {
+		return &pdfObject{dict: body}, next
+	}
+	object := &pdfObject{dict: body[:streamIndex]}
+
+	// The stream data starts after the end of line following the keyword
+	streamStart := start + streamIndex + len("stream")
+	if streamStart < len(data) && data[streamStart] == '\r' {
+		streamStart++
+	}
+	if streamStart < len(data) && data[streamStart] == '\n' {
+		streamStart++
+	}
+
+	// Use the declared length when it's direct, since binary data may contain "endobj"
+	var raw []byte
+	if match := pdfLengthRegex.FindSubmatch(object.dict); match != nil && !bytes.HasSuffix(bytes.TrimSpace(match[0]), []byte("R")) {
+		// Compare without adding, a huge length would overflow
+		length, err := strconv.Atoi(string(match[1]))
+		if err == nil && length >= 0 && length <= len(data)-streamStart {
+			raw = data[streamStart : streamStart+length]
+			if after := bytes.Index(data[streamStart+length:], []byte("endobj")); after >= 0 {
+				next = streamStart + length + after
+			}
+		}
+	}
+	if raw == nil {
+		streamEnd := bytes.Index(data[streamStart:], []byte("endstream"))
+		if streamEnd < 0 {
+			streamEnd = 0
+		}
+		raw = data[streamStart : streamStart+streamEnd]
+	}
+
+	object.stream = decodePDFStream(object.dict, raw)
+	return object, next + len("endobj")
+}
+
+// decodePDFStream inflates FlateDecode streams and returns unfiltered ones as they
+// are. Streams with other filters, like images, are left out.
+func decodePDFStream(dict, raw []byte) []byte {
+	filterIndex := bytes.Index(dict, []byte("/Filter"))
+	if filterIndex < 0 {
+		return raw
+	}
+	filter := dict[filterIndex+len("/Filter"):]
+	filter = bytes.TrimLeft(filter, " \t\r\n")
+	isFlate := bytes.HasPrefix(filter, []byte("/FlateDecode")) ||
+		(bytes.HasPrefix(filter, []byte("[")) && bytes.HasPrefix(bytes.TrimLeft(filter[1:], " \t\r\n"), []byte("/FlateDecode")) &&
+			bytes.Count(filter[:bytes.IndexByte(filter, ']')+1], []byte("/")) == 1)
+	if !isFlate {
+		return nil
+	}
+
+	reader, err := zlib.NewReader(bytes.NewReader(raw))
+	if err != nil {
+		return nil
+	}
+	defer reader.Close()
+	// Keep what was inflated before any error, streams are often truncated
+	decoded, _ := io.ReadAll(io.LimitReader(reader, maxPDFStreamSize))
+	return decoded
+}
+
+// readObjectStream splits an object stream into its objects
+func readObjectStream(object *pdfObject) map[int]*pdfObject {
+	values := map[string]int{}
+	for _, match := range pdfIntRegex.FindAllSubmatch(object.dict, -1) {
+		values[string(match[1])], _ = strconv.Atoi(string(match[2]))
+	}
+	count, first := values["N"], values["First"]
+	if first <= 0 || first > len(object.stream) {
+		return nil
+	}
+
+	// The header lists the number and offset of each object
+	fields := strings.Fields(string(object.stream[:first]))
+	objects := map[int]*pdfObject{}
+	for i := 0; i+1 < len(fields) && i/2 < count; i += 2 {
+		number, err1 := strconv.Atoi(fields[i])
+		offset, err2 := strconv.Atoi(fields[i+1])
+		// Offsets are relative to first and must stay inside the stream
+		if err1 != nil || err2 != nil || offset < 0 || offset > len(object.stream)-first {
+			break
+		}
+		end := len(object.stream)
+		if i+3 < len(fields) {
+			if nextOffset, err := strconv.Atoi(fields[i+3]); err == nil && nextOffset >= offset && nextOffset <= end-first {
+				end = first + nextOffset
+			}
+		}
+		objects[number] = &pdfObject{dict: object.stream[first+offset : end]}
+	}
+	return objects
+}
+
+// pdfDictString reads the string value of a key of a dictionary, following an indirect reference
+func pdfDictString(dict []byte, key string, objects map[int]*pdfObject) (string, bool) {
+	// Skip longer keys starting with the same name, e.g. "/TitleFont"
+	name := []byte("/" + key)
+	var value []byte
+	for offset := 0; ; {
+		index := bytes.Index(dict[offset:], name)
+		if index < 0 {
+			return "", false
+		}
+		end := offset + index + len(name)
+		if end == len(dict) || !isPDFRegularChar(dict[end]) {
+			value = dict[end:]
+			break
+		}
+		offset = end
+	}
+	if match := pdfReferenceRegex.FindSubmatch(value); match != nil {
+		number, _ := strconv.Atoi(string(match[1]))
+		object := objects[number]
+		if object == nil {
+			return "", false
+		}
+		value = object.dict
+	}
+	raw, ok := readPDFString(value)
+	if !ok {
+		return "", false
+	}
+	return decodePDFText(raw), true
+}
+
+// isPDFRegularChar checks if a byte can be part of a name, i.e. isn't whitespace or a delimiter
+func isPDFRegularChar(b byte) bool {
+	return !strings.ContainsRune(" \t\r\n\f\x00()<>[]{}/%", rune(b))
+}
+
+// readPDFString reads the literal "(...)" or hexadecimal "<...>" string at the start of b
+func readPDFString(b []byte) ([]byte, bool) {
+	b = bytes.TrimLeft(b, " \t\r\n")
+	if len(b) == 0 {
+		return nil, false
+	}
+
+	if b[0] == '<' {
+		if len(b) > 1 && b[1] == '<' {
+			return nil, false
+		}
+		end := bytes.IndexByte(b, '>')
+		if end < 0 {
+			return nil, false
+		}
+		hex := make([]byte, 0, end)
+		for _, ch := range b[1:end] {
+			if ch != ' ' && ch != '\t' && ch != '\r' && ch != '\n' {
+				hex = append(hex, ch)
+			}
+		}
+		if len(hex)%2 == 1 {
+			hex = append(hex, '0')
+		}
+		value := make([]byte, 0, len(hex)/2)
+		for i := 0; i < len(hex); i += 2 {
+			n, err := strconv.ParseUint(string(hex[i:i+2]), 16, 8)
+			if err != nil {
+				return nil, false
+			}
+			value = append(value, byte(n))
+		}
+		return value, true
+	}
+
+	if b[0] != '(' {
+		return nil, false
+	}
+	var value []byte
+	depth := 0
+	for i := 1; i < len(b); i++ {
+		ch := b[i]
+		switch {
+		case ch == '\\' && i+1 < len(b):
+			i++
+			switch escaped := b[i]; escaped {
+			case 'n':
+				value = append(value, '\n')
+			case 'r':
+				value = append(value, '\r')
+			case 't':
+				value = append(value, '\t')
+			case 'b':
+				value = append(value, '\b')
+			case 'f':
+				value = append(value, '\f')
+			case '\r':
+				// A backslash at the end of a line continues the string
+				if i+1 < len(b) && b[i+1] == '\n' {
+					i++
+				}
+			case '\n':
+			default:
+				if escaped >= '0' && escaped <= '7' {
+					n := int(escaped - '0')
+					for digits := 1; digits < 3 && i+1 < len(b) && b[i+1] >= '0' && b[i+1] <= '7'; digits++ {
+						i++
+						n = n*8 + int(b[i]-'0')
+					}
+					value = append(value, byte(n))
+				} else {
+					value = append(value, escaped)
+				}
+			}
+		case ch == '(':
+			depth++
+			value = append(value, ch)
+		case ch == ')':
+			if depth == 0 {
+				return value, true
+			}
+			depth--
+			value = append(value, ch)
+		default:
+			value = append(value, ch)
+		}
+	}
+	return nil, false
+}
+
+// Characters of PDFDocEncoding from 0x80 to 0x9e, the range where it differs from Latin-1
+var pdfDocEncodingHigh = []rune("•†‡…—–ƒ⁄‹›−‰„“”‘’‚™ﬁﬂŁŒŠŸŽıłœšž")
+
+// decodePDFText decodes a PDF text string, which is UTF-16BE with a byte
+// order mark, UTF-8 with a byte order mark or otherwise PDFDocEncoding
+func decodePDFText(raw []byte) string {
+	switch {
+	case bytes.HasPrefix(raw, []byte{0xfe, 0xff}):
+		units := make([]uint16, 0, len(raw)/2)
+		for i := 2; i+1 < len(raw); i += 2 {
+			units = append(units, uint16(raw[i])<<8|uint16(raw[i+1]))
+		}
+		return string(utf16.Decode(units))
+	case bytes.HasPrefix(raw, []byte{0xef, 0xbb, 0xbf}):
+		return string(raw[3:])
+	}
+	runes := make([]rune, len(raw))
+	for i, b := range raw {
+		runes[i] = rune(b)
+		if b >= 0x80 && int(b-0x80) < len(pdfDocEncodingHigh) {
+			runes[i] = pdfDocEncodingHigh[b-0x80]
+		}
+	}
+	return string(runes)
+}
+
+// formatPDFDate converts a PDF date such as "D:20240131120000+01'00'" to
+// RFC 3339, returning the value unchanged when it doesn't parse
+func formatPDFDate(value string) string {
+	date := strings.TrimPrefix(strings.TrimSpace(value), "D:")
+	digits := 0
+	for digits < len(date) && digits < 14 && date[digits] >= '0' && date[digits] <= '9' {
+		digits++
+	}
+	if digits < 4 {
+		return value
+	}
+	// Missing parts default to the start of the period
+	full := date[:digits] + "0101000000"[digits-4:]
+	parsed, err := time.Parse("20060102150405", full)
+	if err != nil {
+		return value
+	}
+
+	zone := strings.ReplaceAll(date[digits:], "'", "")
+	switch {
+	case zone == "" || zone == "Z" || strings.HasPrefix(zone, "Z"):
+		return parsed.Format(time.RFC3339)
+	case len(zone) >= 3 && (zone[0] == '+' || zone[0] == '-'):
+		hours, err1 := strconv.Atoi(zone[1:3])
+		minutes := 0
+		var err2 error
+		if len(zone) >= 5 {
+			minutes, err2 = strconv.Atoi(zone[3:5])
+		}
+		if err1 != nil || err2 != nil {
+			return value
+		}
+		offset := hours*3600 + minutes*60
+		if zone[0] == '-' {
+			offset = -offset
+		}
+		return time.Date(parsed.Year(), parsed.Month(), parsed.Day(), parsed.Hour(), parsed.Minute(), parsed.Second(), 0,
+			time.FixedZone("", offset)).Format(time.RFC3339)
+	}
+	return value
+}
@@ -0,0 +1,215 @@
+package services
+
+import (
+	"bytes"
+	"compress/zlib"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// pdfStream builds a stream object with a direct length
+func pdfStream(number int, dict string, data []byte) string {
+	return fmt.Sprintf("%d 0 obj\n<< %s /Length %d >>\nstream\n%s\nendstream\nendobj\n", number, dict, len(data), data)
+}
+
+// deflate compresses data like a FlateDecode stream
+func deflate(t *testing.T, data string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	writer := zlib.NewWriter(&buf)
+	writer.Write([]byte(data))
+	writer.Close()
+	return buf.Bytes()
+}
+
+const testPDF = `%PDF-1.7
+1 0 obj
+<< /Type /Catalog /Pages 2 0 R >>
+endobj
+2 0 obj
+<< /Type /Pages /Kids [3 0 R 4 0 R] /Count 2 >>
+endobj
+3 0 obj
+<< /Type /Page /Parent 2 0 R /Annots [<< /A << /S /URI /URI (https://example.com/a) >> >>] >>
+endobj
+4 0 obj
+<< /Type /Page /Parent 2 0 R /Annots [<< /A << /URI <68747470733a2f2f6578616d706c652e636f6d2f62> >> >>] >>
+endobj
+5 0 obj
+<< /Title (Annual \(2024\) report) /TitleFont (ignored) /Author 6 0 R /CreationDate (D:20240131120000+01'00') >>
+endobj
+6 0 obj
+<FEFF004A00FC0072006700650072>
+endobj
+trailer
+<< /Root 1 0 R /Info 5 0 R >>
+startxref
+0
+%%EOF`
+
+func TestParsePDF(t *testing.T) {
+	document, err := parsePDF([]byte(testPDF))
+	if err != nil {
+		t.Fatalf("parsePDF failed: %v", err)
+	}
+	if document.version != "1.7" {
+		t.Errorf("version = %q, want 1.7", document.version)
+	}
+	if document.pageCount != 2 {
+		t.Errorf("pageCount = %d, want 2", document.pageCount)
+	}
+	if document.encrypted {
+		t.Error("document is reported as encrypted")
+	}
+	wantInfo := map[string]string{
+		"Title":        "Annual (2024) report",
+		"Author":       "Jürger",
+		"CreationDate": "2024-01-31T12:00:00+01:00",
+	}
+	if !reflect.DeepEqual(document.info, wantInfo) {
+		t.Errorf("info = %v, want %v", document.info, wantInfo)
+	}
+	wantLinks := []string{"https://example.com/a", "https://example.com/b"}
+	links := append([]string(nil), document.links...)
+	if len(links) == 2 && links[0] > links[1] {
+		links[0], links[1] = links[1], links[0]
+	}
+	if !reflect.DeepEqual(links, wantLinks) {
+		t.Errorf("links = %v, want %v", document.links, wantLinks)
+	}
+}
+
+func TestParsePDFStreams(t *testing.T) {
+	// The page and info dictionary are compressed inside an object stream
+	contained := "<< /Type /Page >> << /Title (Compressed) >>"
+	objStm := "3 0 4 18 " + contained
+	data := "%PDF-1.5\n" +
+		pdfStream(1, "/Type /ObjStm /N 2 /First 9 /Filter /FlateDecode", deflate(t, objStm)) +
+		pdfStream(2, "/Type /XRef /Info 4 0 R", []byte("endobj inside binary data"))
+
+	document, err := parsePDF([]byte(data))
+	if err != nil {
+		t.Fatalf("parsePDF failed: %v", err)
+	}
+	if document.pageCount != 1 {
+		t.Errorf("pageCount = %d, want 1", document.pageCount)
+	}
+	if document.info["Title"] != "Compressed" {
+		t.Errorf("Title = %q, want Compressed", document.info["Title"])
+	}
+}
+
+func TestParsePDFEncrypted(t *testing.T) {
+	data := "%PDF-1.4\n1 0 obj\n<< /Type /Page /URI (https://secret.test/) >>\nendobj\n" +
+		"trailer\n<< /Encrypt 2 0 R >>\nstartxref\n0\n%%EOF"
+	document, err := parsePDF([]byte(data))
+	if err != nil {
+		t.Fatalf("parsePDF failed: %v", err)
+	}
+	if !document.encrypted {
+		t.Error("document isn't reported as encrypted")
+	}
+	if len(document.links) != 0 {
+		t.Errorf("links = %v, want none for an encrypted document", document.links)
+	}
+}
+
+func TestParsePDFMalformed(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+	}{
+		{"truncated object", "%PDF-1.4\n1 0 obj\n<< /Type /Page"},
+		{"truncated stream", "%PDF-1.4\n1 0 obj\n<< /Length 100 >>\nstream\nabc"},
+		{"stream keyword at the end", "%PDF-1.4\n1 0 obj\n<< /Length 5 >>\nstream"},
+		{"overflowing length", "%PDF-1.4\n1 0 obj\n<< /Length 9223372036854775807 >>\nstream\nabc\nendstream\nendobj"},
+		{"length beyond int range", "%PDF-1.4\n1 0 obj\n<< /Length 99999999999999999999 >>\nstream\nabc\nendstream\nendobj"},
+		{"negative object offset", "%PDF-1.5\n" + pdfStream(1, "/Type /ObjStm /N 1 /First 8", []byte("3 -92   << /Type /Page >>"))},
+		{"overflowing object offset", "%PDF-1.5\n" + pdfStream(1, "/Type /ObjStm /N 2 /First 26", []byte("3 0 4 9223372036854775807 <<>>"))},
+		{"first beyond the stream", "%PDF-1.5\n" + pdfStream(1, "/Type /ObjStm /N 1 /First 999", []byte("3 0 <<>>"))},
+		{"overflowing first", "%PDF-1.5\n" + pdfStream(1, "/Type /ObjStm /N 1 /First 99999999999999999999", []byte("3 0 <<>>"))},
+		{"unterminated strings", "%PDF-1.4\n1 0 obj\n<< /URI (https://a.test /Title <4142 >>\nendobj\ntrailer << /Info 1 0 R"},
+		{"broken flate stream", "%PDF-1.5\n" + pdfStream(1, "/Type /ObjStm /N 1 /First 4 /Filter /FlateDecode", []byte("not zlib"))},
+		{"unclosed filter array", "%PDF-1.5\n1 0 obj\n<< /Filter [/FlateDecode /Length 3 >>\nstream\nabc\nendstream\nendobj"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := parsePDF([]byte(tt.data)); err != nil {
+				t.Errorf("parsePDF failed: %v", err)
+			}
+		})
+	}
+
+	if _, err := parsePDF([]byte("<html>not a pdf</html>")); err == nil {
+		t.Error("parsePDF succeeded for an HTML file, want an error")
+	}
+}
+
+func TestReadObjectStreamOffsets(t *testing.T) {
+	tests := []struct {
+		name   string
+		dict   string
+		stream string
+		want   map[int]string
+	}{
+		{"two objects", "/N 2 /First 8", "3 0 4 5 <<1>> <<2>>", map[int]string{3: "<<1>>", 4: "<<2>>"}},
+		{"offsets out of order", "/N 2 /First 8", "3 5 4 0 <<1>> <<2>>", map[int]string{3: "<<2>>", 4: "<<1>> <<2>>"}},
+		{"negative offset", "/N 1 /First 6", "3 -92 <<>>", map[int]string{}},
+		{"offset beyond the stream", "/N 1 /First 6", "3 999 <<>>", map[int]string{}},
+		{"negative next offset", "/N 2 /First 9", "3 0 4 -1 <<1>>", map[int]string{3: "<<1>>"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			objects := readObjectStream(&pdfObject{dict: []byte(tt.dict), stream: []byte(tt.stream)})
+			got := map[int]string{}
+			for number, object := range objects {
+				got[number] = strings.TrimSpace(string(object.dict))
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("objects = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFormatPDFDate(t *testing.T) {
+	tests := []struct {
+		value string
+		want  string
+	}{
+		{"D:20240131120000Z", "2024-01-31T12:00:00Z"},
+		{"D:20240131120000-05'30'", "2024-01-31T12:00:00-05:30"},
+		{"D:2024", "2024-01-01T00:00:00Z"},
+		{"20240131", "2024-01-31T00:00:00Z"},
+		{"D:20241399", "D:20241399"},
+		{"yesterday", "yesterday"},
+	}
+
+	for _, tt := range tests {
+		if got := formatPDFDate(tt.value); got != tt.want {
+			t.Errorf("formatPDFDate(%q) = %q, want %q", tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestDecodePDFText(t *testing.T) {
+	tests := []struct {
+		raw  []byte
+		want string
+	}{
+		{[]byte("plain"), "plain"},
+		{[]byte{0xfe, 0xff, 0x00, 'A', 0x20, 0xac}, "A€"},
+		{[]byte{0xef, 0xbb, 0xbf, 0xc3, 0xa9}, "é"},
+		{[]byte{0x84, 0xe9}, "—é"},
+	}
+
+	for _, tt := range tests {
+		if got := decodePDFText(tt.raw); got != tt.want {
+			t.Errorf("decodePDFText(%q) = %q, want %q", tt.raw, got, tt.want)
+		}
+	}
+}
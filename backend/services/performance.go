@@ -0,0 +1,212 @@
+package services
+
+import (
+	"crypto/tls"
+	"io"
+	"net/http"
+	"net/http/httptrace"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sykell/website-analyzer/models"
+	"golang.org/x/net/html"
+)
+
+// Transfer size budgets above which an asset is flagged as oversized
+var weightBudgets = map[string]int64{
+	"html":  512 << 10,
+	"css":   150 << 10,
+	"js":    300 << 10,
+	"image": 500 << 10,
+	"font":  150 << 10,
+}
+
+// pageTrace records connection timings of the page fetch
+type pageTrace struct {
+	mutex               sync.Mutex
+	start               time.Time
+	hopStart            time.Time
+	dnsStart            time.Time
+	dnsDone             time.Time
+	connectStart        time.Time
+	connectDone         time.Time
+	tlsStart            time.Time
+	tlsDone             time.Time
+	firstByte           time.Time
+	done                time.Time
+	wire                *countingReader
+	unsupportedEncoding bool // The content encoding couldn't be decoded
+}
+
+// finish marks the page body as completely downloaded
+func (t *pageTrace) finish() {
+	t.mutex.Lock()
+	t.done = time.Now()
+	t.mutex.Unlock()
+}
+
+// clientTrace returns the httptrace hooks. Timings are reset on every
+// redirect hop so they describe the connection that served the page.
+func (t *pageTrace) clientTrace() *httptrace.ClientTrace {
+	record := func(field *time.Time) {
+		t.mutex.Lock()
+		*field = time.Now()
+		t.mutex.Unlock()
+	}
+	return &httptrace.ClientTrace{
+		GetConn: func(string) {
+			t.mutex.Lock()
+			t.hopStart = time.Now()
+			t.dnsStart, t.dnsDone = time.Time{}, time.Time{}
+			t.connectStart, t.connectDone = time.Time{}, time.Time{}
+			t.tlsStart, t.tlsDone = time.Time{}, time.Time{}
+			t.mutex.Unlock()
+		},
+		DNSStart: func(httptrace.DNSStartInfo) { record(&t.dnsStart) },
+		DNSDone:  func(httptrace.DNSDoneInfo) { record(&t.dnsDone) },
+		ConnectStart: func(string, string) {
+			t.mutex.Lock()
+			if t.connectStart.IsZero() {
+				t.connectStart = time.Now()
+			}
+			t.mutex.Unlock()
+		},
+		ConnectDone:          func(string, string, error) { record(&t.connectDone) },
+		TLSHandshakeStart:    func() { record(&t.tlsStart) },
+		TLSHandshakeDone:     func(tls.ConnectionState, error) { record(&t.tlsDone) },
+		GotFirstResponseByte: func() { record(&t.firstByte) },
+	}
+}
+
+// fetchPage fetches the page with connection timings recorded. The body of
+// the returned response is decoded; its size on the wire is tracked.
+func (c *Crawler) fetchPage() (*http.Response, error) {
+	trace := &pageTrace{start: time.Now()}
+	c.pageTrace = trace
+
+	req, err := c.newRequest("GET", c.website.URL, nil)
+	if err != nil {
+		return nil, err
+	}
+	req = req.WithContext(httptrace.WithClientTrace(req.Context(), trace.clientTrace()))
+	// Asking for compression explicitly stops the transport from decoding it,
+	// so the compressed size can be measured
+	req.Header.Set("Accept-Encoding", "gzip, deflate")
+
+	resp, err := c.sendAuthenticated(c.httpClient, req)
+	if err != nil {
+		return nil, err
+	}
+
+	trace.wire = &countingReader{reader: resp.Body}
+	// Pages with an encoding that can't be decoded are analyzed as they are
+	decoded, supported := decodeContent(trace.wire, resp.Header.Get("Content-Encoding"))
+	trace.unsupportedEncoding = !supported
+	resp.Body = struct {
+		io.Reader
+		io.Closer
+	}{decoded, resp.Body}
+
+	return resp, nil
+}
+
+// analyzePerformance reports the page timings and estimates the page weight
+// from the document and its subresources
+func (c *Crawler) analyzePerformance(resp *http.Response, doc *html.Node, bodySize int) *models.PerformanceReport {
+	trace := c.pageTrace
+
+	trace.mutex.Lock()
+	report := &models.PerformanceReport{
+		WebsiteID:           c.website.ID,
+		DNSMs:               elapsedMs(trace.dnsStart, trace.dnsDone),
+		ConnectMs:           elapsedMs(trace.connectStart, trace.connectDone),
+		TLSHandshakeMs:      elapsedMs(trace.tlsStart, trace.tlsDone),
+		TTFBMs:              elapsedMs(trace.hopStart, trace.firstByte),
+		DownloadMs:          elapsedMs(trace.firstByte, trace.done),
+		TotalMs:             elapsedMs(trace.start, trace.done),
+		CompressedSize:      trace.wire.count,
+		UncompressedSize:    int64(bodySize),
+		ContentEncoding:     truncateString(resp.Header.Get("Content-Encoding"), 50),
+		Weight:              map[string]models.WeightEntry{},
+		OversizedAssets:     []models.OversizedAsset{},
+		UnsupportedEncoding: trace.unsupportedEncoding,
+	}
+	trace.mutex.Unlock()
+
+	encoding := strings.ToLower(report.ContentEncoding)
+	report.GzipSupported = strings.Contains(encoding, "gzip")
+	report.BrotliSupported = c.checkBrotliSupport()
+
+	// Add up the transfer size of the document and its subresources by type
+	c.addWeight(report, c.pageURL.String(), "html", report.CompressedSize, false)
+	for _, asset := range c.fetchAssets(c.collectResources(doc)) {
+		if asset.Err != nil || asset.StatusCode >= 400 {
+			continue
+		}
+		c.addWeight(report, asset.URL, weightType(asset.Kind), asset.TransferSize, asset.Partial)
+	}
+
+	return report
+}
+
+// addWeight adds a resource to the weight breakdown and flags it if oversized.
+// Partial resources were only downloaded up to a limit and count as lower bounds.
+func (c *Crawler) addWeight(report *models.PerformanceReport, rawURL, resourceType string, size int64, partial bool) {
+	entry := report.Weight[resourceType]
+	entry.Count++
+	entry.Bytes += size
+	entry.LowerBound = entry.LowerBound || partial
+	report.Weight[resourceType] = entry
+	report.TotalWeight += size
+
+	if budget, ok := weightBudgets[resourceType]; ok && size > budget {
+		report.OversizedAssets = append(report.OversizedAssets, models.OversizedAsset{
+			URL:    rawURL,
+			Type:   resourceType,
+			Bytes:  size,
+			Budget: budget,
+		})
+	}
+}
+
+// checkBrotliSupport checks if the server serves the page brotli compressed.
+// The body is never read since the standard library cannot decode brotli.
+func (c *Crawler) checkBrotliSupport() bool {
+	req, err := c.newRequest("GET", c.pageURL.String(), nil)
+	if err != nil {
+		return false
+	}
+	req.Header.Set("Accept-Encoding", "br")
+
+	resp, err := c.sendAuthenticated(c.linkClient, req)
+	if err != nil {
+		return false
+	}
+	resp.Body.Close()
+
+	return strings.Contains(strings.ToLower(resp.Header.Get("Content-Encoding")), "br")
+}
+
+// weightType maps a resource kind to its page weight category
+func weightType(kind string) string {
+	switch kind {
+	case "stylesheet":
+		return "css"
+	case "script":
+		return "js"
+	case "image", "icon":
+		return "image"
+	case "font", "media":
+		return kind
+	}
+	return "other"
+}
+
+// elapsedMs returns the milliseconds between two trace events, or 0 if either did not happen
+func elapsedMs(start, end time.Time) int64 {
+	if start.IsZero() || end.IsZero() || end.Before(start) {
+		return 0
+	}
+	return end.Sub(start).Milliseconds()
+}
@@ -0,0 +1,447 @@
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/sykell/website-analyzer/models"
+	"golang.org/x/net/html"
+)
+
+const (
+	// maxFavicons limits how many icons declared by the page are checked
+	maxFavicons = 20
+
+	// maxManifestIcons limits how many icons of the manifest are checked
+	maxManifestIcons = 20
+
+	// maxManifestSize limits how much of the web app manifest is parsed
+	maxManifestSize = 1 << 20
+
+	// maxPWAScripts limits how many same-origin scripts are searched for a service worker registration
+	maxPWAScripts = 10
+
+	// maxPWAScriptSize limits how much of each script is searched
+	maxPWAScriptSize = 2 << 20
+)
+
+var (
+	// Service worker registrations, directly or through Workbox, with the worker URL when it's a literal
+	serviceWorkerRegex = regexp.MustCompile("(?:serviceWorker\\s*\\.\\s*register|new\\s+Workbox)\\s*\\(\\s*(?:['\"`]([^'\"`]+)['\"`])?")
+	// Hex, functional and named CSS colors
+	cssColorRegex = regexp.MustCompile(`(?i)^(?:#(?:[0-9a-f]{3,4}|[0-9a-f]{6}|[0-9a-f]{8})|(?:rgba?|hsla?|hwb|lab|lch|oklab|oklch|color)\([^()]*\)|[a-z]+)$`)
+)
+
+// Display modes that let the app open in its own window once installed
+var installableDisplayModes = map[string]bool{"fullscreen": true, "standalone": true, "minimal-ui": true}
+
+// manifestDocument is the JSON of a web app manifest
+type manifestDocument struct {
+	Name            string `json:"name"`
+	ShortName       string `json:"short_name"`
+	StartURL        string `json:"start_url"`
+	Display         string `json:"display"`
+	ThemeColor      string `json:"theme_color"`
+	BackgroundColor string `json:"background_color"`
+	Icons           []struct {
+		Src   string `json:"src"`
+		Sizes string `json:"sizes"`
+		Type  string `json:"type"`
+	} `json:"icons"`
+}
+
+// checkPWA finds the favicons, web app manifest and service worker
+// registration of the page, validates the manifest, checks that the icons
+// exist and sums it all up in a PWA checklist
+func (c *Crawler) checkPWA(doc *html.Node) *models.PWAReport {
+	report := &models.PWAReport{
+		WebsiteID: c.website.ID,
+		Favicons:  []models.PWAIcon{},
+		Checks:    []models.PWACheck{},
+	}
+
+	// Icons declared by the page, and /favicon.ico which browsers request when none is
+	seen := map[string]bool{}
+	var manifestHref, metaThemeColor string
+	selector, _ := compileSelector("link[rel][href], meta[name]")
+	for _, n := range selector.MatchAll(doc) {
+		if n.Data == "meta" {
+			if strings.EqualFold(strings.TrimSpace(getAttr(n, "name")), "theme-color") && metaThemeColor == "" {
+				metaThemeColor = strings.TrimSpace(getAttr(n, "content"))
+			}
+			continue
+		}
+		rels := strings.Fields(strings.ToLower(getAttr(n, "rel")))
+		if containsString(rels, "manifest") {
+			if manifestHref == "" {
+				manifestHref = strings.TrimSpace(getAttr(n, "href"))
+			}
+			continue
+		}
+		rel := ""
+		for _, candidate := range []string{"apple-touch-icon", "apple-touch-icon-precomposed", "mask-icon", "icon"} {
+			if containsString(rels, candidate) {
+				rel = candidate
+				break
+			}
+		}
+		iconURL, err := c.pageURL.Parse(strings.TrimSpace(getAttr(n, "href")))
+		if rel == "" || err != nil || seen[iconURL.String()] || len(report.Favicons) >= maxFavicons {
+			continue
+		}
+		seen[iconURL.String()] = true
+		report.Favicons = append(report.Favicons, models.PWAIcon{
+			URL:   iconURL.String(),
+			Rel:   rel,
+			Sizes: truncateString(getAttr(n, "sizes"), 100),
+			Type:  truncateString(getAttr(n, "type"), 100),
+		})
+	}
+	defaultFavicon := (&url.URL{Scheme: c.pageURL.Scheme, Host: c.pageURL.Host, Path: "/favicon.ico"}).String()
+	if !seen[defaultFavicon] {
+		report.Favicons = append(report.Favicons, models.PWAIcon{URL: defaultFavicon, Rel: "default"})
+	}
+
+	// Fetch and parse the manifest
+	var manifest *manifestDocument
+	var manifestURL *url.URL
+	manifestMessage := "The page doesn't link a web app manifest"
+	if manifestHref != "" {
+		manifest, manifestURL, manifestMessage = c.fetchManifest(report, manifestHref)
+	}
+	if manifest != nil {
+		report.Manifest = &models.WebAppManifest{
+			Name:            truncateString(manifest.Name, 255),
+			ShortName:       truncateString(manifest.ShortName, 255),
+			StartURL:        truncateString(manifest.StartURL, 2048),
+			Display:         truncateString(manifest.Display, 50),
+			ThemeColor:      truncateString(manifest.ThemeColor, 100),
+			BackgroundColor: truncateString(manifest.BackgroundColor, 100),
+			Icons:           []models.PWAIcon{},
+		}
+		for _, icon := range manifest.Icons {
+			iconURL, err := manifestURL.Parse(strings.TrimSpace(icon.Src))
+			if err != nil || strings.TrimSpace(icon.Src) == "" || len(report.Manifest.Icons) >= maxManifestIcons {
+				continue
+			}
+			report.Manifest.Icons = append(report.Manifest.Icons, models.PWAIcon{
+				URL:   iconURL.String(),
+				Rel:   "manifest",
+				Sizes: truncateString(icon.Sizes, 100),
+				Type:  truncateString(icon.Type, 100),
+			})
+		}
+	}
+
+	// Check that the icons exist, reading their format and dimensions
+	icons := make([]*models.PWAIcon, 0, len(report.Favicons))
+	for i := range report.Favicons {
+		icons = append(icons, &report.Favicons[i])
+	}
+	if report.Manifest != nil {
+		for i := range report.Manifest.Icons {
+			icons = append(icons, &report.Manifest.Icons[i])
+		}
+	}
+	var targets []string
+	for _, icon := range icons {
+		if !strings.HasPrefix(icon.URL, "data:") {
+			targets = append(targets, icon.URL)
+		}
+	}
+	results := c.fetchImages(targets)
+	for _, icon := range icons {
+		var result *imageFetch
+		if strings.HasPrefix(icon.URL, "data:") {
+			result = decodeDataImage(icon.URL)
+			if result != nil {
+				result.statusCode = http.StatusOK
+			}
+		} else {
+			result = results[icon.URL]
+		}
+		if result != nil {
+			icon.StatusCode = result.statusCode
+			icon.Width, icon.Height = result.width, result.height
+			icon.Exists = result.err == nil && result.statusCode < 400 && result.format != ""
+		}
+		icon.URL = truncateString(icon.URL, 2048)
+	}
+
+	// Find the service worker registration
+	report.ServiceWorker, report.ServiceWorkerURL = c.findServiceWorker(doc)
+
+	// Build the checklist
+	addCheck := func(name string, passed bool, message string) {
+		report.Checks = append(report.Checks, models.PWACheck{Name: name, Passed: passed, Message: message})
+	}
+	https := c.pageURL.Scheme == "https"
+	if https {
+		addCheck("https", true, "The page is served over HTTPS")
+	} else {
+		addCheck("https", false, "Service workers and installation require HTTPS")
+	}
+
+	favicon, appleTouchIcon, appleTouchIconDeclared := false, false, false
+	var missing []string
+	for _, icon := range icons {
+		appleTouchIconDeclared = appleTouchIconDeclared || strings.HasPrefix(icon.Rel, "apple-touch-icon")
+		switch {
+		case icon.Exists && (icon.Rel == "icon" || icon.Rel == "default"):
+			favicon = true
+		case icon.Exists && strings.HasPrefix(icon.Rel, "apple-touch-icon"):
+			appleTouchIcon = true
+		case !icon.Exists && icon.Rel != "default":
+			missing = append(missing, icon.URL)
+		}
+	}
+	if favicon {
+		addCheck("favicon", true, "A favicon was found")
+	} else {
+		addCheck("favicon", false, "No favicon was found in link tags or at /favicon.ico")
+	}
+	if appleTouchIcon {
+		addCheck("apple_touch_icon", true, "An apple-touch-icon was found")
+	} else if appleTouchIconDeclared {
+		addCheck("apple_touch_icon", false, "The declared apple-touch-icon doesn't exist")
+	} else {
+		addCheck("apple_touch_icon", false, "No apple-touch-icon is declared for iOS home screens")
+	}
+	if len(missing) == 0 {
+		addCheck("icons_exist", true, "Every referenced icon exists")
+	} else {
+		addCheck("icons_exist", false, truncateString(fmt.Sprintf("%d referenced icons are missing or not images: %s",
+			len(missing), strings.Join(missing, ", ")), 512))
+	}
+
+	addCheck("manifest", manifest != nil, manifestMessage)
+	if manifest == nil {
+		for _, name := range []string{"manifest_name", "manifest_icons", "manifest_start_url", "manifest_display"} {
+			addCheck(name, false, "No valid web app manifest")
+		}
+	} else {
+		// Name
+		switch {
+		case strings.TrimSpace(manifest.Name) == "" && strings.TrimSpace(manifest.ShortName) == "":
+			addCheck("manifest_name", false, "The manifest has neither a name nor a short_name")
+		case strings.TrimSpace(manifest.Name) == "":
+			addCheck("manifest_name", true, "The manifest only has a short_name")
+		default:
+			addCheck("manifest_name", true, "The manifest has a name")
+		}
+
+		// Existing icons large enough for the home screen and the splash screen
+		has192, has512 := false, false
+		for _, icon := range report.Manifest.Icons {
+			if !icon.Exists {
+				continue
+			}
+			size := largestIconSize(icon.Sizes)
+			has192 = has192 || size >= 192
+			has512 = has512 || size >= 512
+		}
+		switch {
+		case has192 && has512:
+			addCheck("manifest_icons", true, "The manifest has 192px and 512px icons")
+		case has192:
+			addCheck("manifest_icons", false, "The manifest has no existing icon of at least 512px")
+		default:
+			addCheck("manifest_icons", false, "The manifest has no existing icon of at least 192px")
+		}
+
+		// Start URL, which browsers ignore when it's on another origin
+		if strings.TrimSpace(manifest.StartURL) == "" {
+			addCheck("manifest_start_url", false, "The manifest has no start_url")
+		} else if startURL, err := manifestURL.Parse(strings.TrimSpace(manifest.StartURL)); err != nil {
+			addCheck("manifest_start_url", false, "The start_url is not a valid URL")
+		} else if startURL.Scheme != c.pageURL.Scheme || startURL.Host != c.pageURL.Host {
+			addCheck("manifest_start_url", false, "The start_url is not on the origin of the page")
+		} else {
+			addCheck("manifest_start_url", true, "The manifest has a start_url on the origin of the page")
+		}
+
+		// Display mode
+		display := strings.ToLower(strings.TrimSpace(manifest.Display))
+		switch {
+		case display == "":
+			addCheck("manifest_display", false, "The manifest has no display mode")
+		case installableDisplayModes[display]:
+			addCheck("manifest_display", true, fmt.Sprintf("The app opens in %s mode", display))
+		default:
+			addCheck("manifest_display", false, truncateString(fmt.Sprintf("Display mode %q doesn't open the app in its own window", display), 512))
+		}
+	}
+
+	// Theme color from the manifest or the theme-color meta tag
+	switch {
+	case manifest != nil && cssColorRegex.MatchString(strings.TrimSpace(manifest.ThemeColor)):
+		addCheck("theme_color", true, "The manifest has a theme_color")
+	case manifest != nil && strings.TrimSpace(manifest.ThemeColor) != "":
+		addCheck("theme_color", false, truncateString(fmt.Sprintf("The theme_color %q is not a valid color", manifest.ThemeColor), 512))
+	case cssColorRegex.MatchString(metaThemeColor):
+		addCheck("theme_color", true, "The page has a theme-color meta tag")
+	default:
+		addCheck("theme_color", false, "Neither the manifest nor a meta tag sets a theme color")
+	}
+
+	if report.ServiceWorker {
+		addCheck("service_worker", true, "The page registers a service worker")
+	} else {
+		addCheck("service_worker", false, "No service worker registration was found in the page's scripts")
+	}
+
+	// Browsers offer installing the app when these pass
+	report.Installable = true
+	for _, check := range report.Checks {
+		switch check.Name {
+		case "https", "manifest", "manifest_name", "manifest_icons", "manifest_start_url", "manifest_display":
+			report.Installable = report.Installable && check.Passed
+		}
+	}
+
+	return report
+}
+
+// fetchManifest fetches and parses the web app manifest linked by the page.
+// It returns the manifest, the URL it was fetched from after redirects, and
+// a message for the checklist.
+func (c *Crawler) fetchManifest(report *models.PWAReport, href string) (*manifestDocument, *url.URL, string) {
+	manifestURL, err := c.pageURL.Parse(href)
+	if err != nil || (manifestURL.Scheme != "http" && manifestURL.Scheme != "https") {
+		return nil, nil, "The manifest link is not a valid URL"
+	}
+	report.ManifestURL = truncateString(manifestURL.String(), 2048)
+
+	resp, err := c.doAuthenticated(c.linkClient, "GET", manifestURL.String())
+	if err != nil {
+		return nil, nil, truncateString(fmt.Sprintf("The manifest could not be fetched: %v", err), 512)
+	}
+	defer resp.Body.Close()
+	report.ManifestStatus = resp.StatusCode
+	if resp.StatusCode != http.StatusOK {
+		return nil, nil, fmt.Sprintf("The manifest returned status %d", resp.StatusCode)
+	}
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxManifestSize))
+	if err != nil {
+		return nil, nil, truncateString(fmt.Sprintf("The manifest could not be read: %v", err), 512)
+	}
+	var manifest manifestDocument
+	if err := json.Unmarshal(body, &manifest); err != nil {
+		return nil, nil, truncateString(fmt.Sprintf("The manifest could not be parsed: %v", err), 512)
+	}
+
+	return &manifest, resp.Request.URL, "The web app manifest was found"
+}
+
+// findServiceWorker searches the inline scripts of the page and its
+// same-origin scripts for a service worker registration, returning the
+// worker URL when the registration names it
+func (c *Crawler) findServiceWorker(doc *html.Node) (bool, string) {
+	var inline []string
+	var scripts []string
+	seen := map[string]bool{}
+	selector, _ := compileSelector("script")
+	for _, n := range selector.MatchAll(doc) {
+		src, ok := lookupAttr(n, "src")
+		if !ok {
+			if n.FirstChild != nil {
+				inline = append(inline, n.FirstChild.Data)
+			}
+			continue
+		}
+		scriptURL, err := c.pageURL.Parse(strings.TrimSpace(src))
+		if err != nil || scriptURL.Scheme != c.pageURL.Scheme || scriptURL.Host != c.pageURL.Host ||
+			seen[scriptURL.String()] || len(scripts) >= maxPWAScripts {
+			continue
+		}
+		seen[scriptURL.String()] = true
+		scripts = append(scripts, scriptURL.String())
+	}
+
+	registration := func(source string, base *url.URL) (bool, string) {
+		match := serviceWorkerRegex.FindStringSubmatch(source)
+		if match == nil {
+			return false, ""
+		}
+		if match[1] != "" {
+			// Workers are resolved against the document, whichever script registers them
+			if workerURL, err := base.Parse(strings.TrimSpace(match[1])); err == nil {
+				return true, truncateString(workerURL.String(), 2048)
+			}
+		}
+		return true, ""
+	}
+
+	for _, source := range inline {
+		if found, workerURL := registration(source, c.pageURL); found {
+			return found, workerURL
+		}
+	}
+
+	var found bool
+	var workerURL string
+	var wg sync.WaitGroup
+	var mutex sync.Mutex
+	semaphore := make(chan struct{}, 10) // Limit concurrency
+	for _, script := range scripts {
+		wg.Add(1)
+		go func(script string) {
+			defer wg.Done()
+			semaphore <- struct{}{}        // Acquire token
+			defer func() { <-semaphore }() // Release token
+
+			resp, err := c.doAuthenticated(c.linkClient, "GET", script)
+			if err != nil {
+				return
+			}
+			defer resp.Body.Close()
+			if resp.StatusCode != http.StatusOK {
+				return
+			}
+			body, err := io.ReadAll(io.LimitReader(resp.Body, maxPWAScriptSize))
+			if err != nil {
+				return
+			}
+			if ok, scriptWorkerURL := registration(string(body), c.pageURL); ok {
+				mutex.Lock()
+				// Prefer a registration that names the worker
+				if !found || workerURL == "" {
+					found, workerURL = true, scriptWorkerURL
+				}
+				mutex.Unlock()
+			}
+		}(script)
+	}
+	wg.Wait()
+
+	return found, workerURL
+}
+
+// largestIconSize returns the largest square size an icon's sizes attribute
+// declares, e.g. 512 for "192x192 512x512". "any" is scalable, e.g. an SVG.
+func largestIconSize(sizes string) int {
+	largest := 0
+	for _, size := range strings.Fields(strings.ToLower(sizes)) {
+		if size == "any" {
+			return 1 << 16
+		}
+		width, height, found := strings.Cut(size, "x")
+		if !found {
+			continue
+		}
+		w, errWidth := strconv.Atoi(width)
+		h, errHeight := strconv.Atoi(height)
+		if errWidth == nil && errHeight == nil && minInt(w, h) > largest {
+			largest = minInt(w, h)
+		}
+	}
+	return largest
+}
@@ -0,0 +1,133 @@
+package services
+
+import (
+	"net/url"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// pageResource represents a subresource or form target referenced by the document
+type pageResource struct {
+	URL  *url.URL
+	Tag  string
+	Attr string
+	Kind string // "script", "stylesheet", "image", "font", "media", "iframe", "object", "form", "icon", "manifest" or "other"
+}
+
+// collectResources finds every subresource URL referenced by the document.
+// Relative URLs are resolved against the page URL after redirects.
+func (c *Crawler) collectResources(doc *html.Node) []pageResource {
+	var resources []pageResource
+	add := func(n *html.Node, attr, kind, rawURL string) {
+		rawURL = strings.TrimSpace(rawURL)
+		if rawURL == "" || strings.HasPrefix(rawURL, "data:") || strings.HasPrefix(rawURL, "javascript:") {
+			return
+		}
+		parsedURL, err := c.pageURL.Parse(rawURL)
+		if err != nil || (parsedURL.Scheme != "http" && parsedURL.Scheme != "https") {
+			return
+		}
+		resources = append(resources, pageResource{URL: parsedURL, Tag: n.Data, Attr: attr, Kind: kind})
+	}
+	addAttr := func(n *html.Node, attr, kind string) {
+		if value, ok := lookupAttr(n, attr); ok {
+			add(n, attr, kind, value)
+		}
+	}
+	addSrcset := func(n *html.Node, kind string) {
+		for _, candidate := range parseSrcset(getAttr(n, "srcset")) {
+			add(n, "srcset", kind, candidate)
+		}
+	}
+
+	var collectFunc func(*html.Node)
+	collectFunc = func(n *html.Node) {
+		if n.Type == html.ElementNode {
+			switch n.Data {
+			case "script":
+				addAttr(n, "src", "script")
+			case "link":
+				if kind := linkResourceKind(n); kind != "" {
+					addAttr(n, "href", kind)
+				}
+			case "img":
+				addAttr(n, "src", "image")
+				addSrcset(n, "image")
+			case "source":
+				kind := "media"
+				if n.Parent != nil && n.Parent.Data == "picture" {
+					kind = "image"
+				}
+				addAttr(n, "src", kind)
+				addSrcset(n, kind)
+			case "audio", "video", "track":
+				addAttr(n, "src", "media")
+				if n.Data == "video" {
+					addAttr(n, "poster", "image")
+				}
+			case "iframe", "frame":
+				addAttr(n, "src", "iframe")
+			case "object":
+				addAttr(n, "data", "object")
+			case "embed":
+				addAttr(n, "src", "object")
+			case "form":
+				addAttr(n, "action", "form")
+			case "input":
+				if strings.EqualFold(getAttr(n, "type"), "image") {
+					addAttr(n, "src", "image")
+				}
+			}
+		}
+		for child := n.FirstChild; child != nil; child = child.NextSibling {
+			collectFunc(child)
+		}
+	}
+	collectFunc(doc)
+
+	return resources
+}
+
+// linkResourceKind classifies a <link> element by its rel and as attributes.
+// Links that don't load a subresource, like canonical, alternate, next or
+// preconnect, return an empty kind.
+func linkResourceKind(n *html.Node) string {
+	rels := strings.Fields(strings.ToLower(getAttr(n, "rel")))
+	switch {
+	case containsString(rels, "stylesheet"):
+		return "stylesheet"
+	case containsString(rels, "icon") || containsString(rels, "apple-touch-icon"):
+		return "icon"
+	case containsString(rels, "manifest"):
+		return "manifest"
+	case containsString(rels, "modulepreload"):
+		return "script"
+	case containsString(rels, "preload") || containsString(rels, "prefetch"):
+		switch strings.ToLower(getAttr(n, "as")) {
+		case "script", "worker":
+			return "script"
+		case "style":
+			return "stylesheet"
+		case "font":
+			return "font"
+		case "image":
+			return "image"
+		case "audio", "video", "track":
+			return "media"
+		}
+		return "other"
+	}
+	return ""
+}
+
+// parseSrcset returns the URLs of a srcset attribute
+func parseSrcset(srcset string) []string {
+	var urls []string
+	for _, candidate := range strings.Split(srcset, ",") {
+		if fields := strings.Fields(candidate); len(fields) > 0 {
+			urls = append(urls, fields[0])
+		}
+	}
+	return urls
+}
@@ -0,0 +1,158 @@
+package services
+
+import (
+	"bufio"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// robotsRules represents the parts of robots.txt the crawler uses
+type robotsRules struct {
+	sitemaps []string
+	rules    []robotsRule // Rules of the group that applies to the crawler
+}
+
+// robotsRule represents an Allow or Disallow line
+type robotsRule struct {
+	allow   bool
+	pattern string
+}
+
+// robotsFile fetches and parses the website's robots.txt once per analysis.
+// A missing or unreadable file allows everything.
+func (c *Crawler) robotsFile() *robotsRules {
+	c.robotsOnce.Do(func() {
+		c.robots = &robotsRules{}
+
+		robotsURL := *c.pageURL
+		robotsURL.Path, robotsURL.RawQuery, robotsURL.Fragment = "/robots.txt", "", ""
+		resp, err := c.doAuthenticated(c.linkClient, "GET", robotsURL.String())
+		if err != nil {
+			return
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			return
+		}
+
+		c.robots = c.parseRobots(io.LimitReader(resp.Body, 1<<20))
+	})
+	return c.robots
+}
+
+// parseRobots reads robots.txt and keeps the rules of the most specific
+// group matching the crawler's user agent, falling back to the * group
+func (c *Crawler) parseRobots(reader io.Reader) *robotsRules {
+	userAgent := c.settings.UserAgent
+	if userAgent == "" {
+		userAgent = DefaultUserAgent
+	}
+	// Groups are matched by the product token, e.g. "websiteanalyzer"
+	token := strings.ToLower(strings.TrimSpace(userAgent))
+	if i := strings.IndexAny(token, "/ "); i >= 0 {
+		token = token[:i]
+	}
+
+	robots := &robotsRules{}
+	var groupAgents []string
+	var groupRules []robotsRule
+	inRules := false
+	bestMatch := -1
+	endGroup := func() {
+		for _, agent := range groupAgents {
+			match := -1
+			if agent == "*" {
+				match = 0
+			} else if agent != "" && strings.Contains(token, agent) {
+				match = len(agent)
+			}
+			if match > bestMatch {
+				bestMatch = match
+				robots.rules = groupRules
+			}
+		}
+		groupAgents, groupRules, inRules = nil, nil, false
+	}
+
+	scanner := bufio.NewScanner(reader)
+	for scanner.Scan() {
+		line, _, _ := strings.Cut(scanner.Text(), "#")
+		name, value, found := strings.Cut(line, ":")
+		if !found {
+			continue
+		}
+		name = strings.ToLower(strings.TrimSpace(name))
+		value = strings.TrimSpace(value)
+
+		switch name {
+		case "sitemap":
+			if sitemapURL, err := c.pageURL.Parse(value); err == nil {
+				robots.sitemaps = append(robots.sitemaps, sitemapURL.String())
+			}
+		case "user-agent":
+			// A user-agent line after rules starts a new group
+			if inRules {
+				endGroup()
+			}
+			groupAgents = append(groupAgents, strings.ToLower(value))
+		case "allow", "disallow":
+			inRules = true
+			// An empty Disallow allows everything
+			if value != "" {
+				groupRules = append(groupRules, robotsRule{allow: name == "allow", pattern: value})
+			}
+		}
+	}
+	endGroup()
+
+	return robots
+}
+
+// allowed checks if robots.txt allows the crawler to fetch a URL. The longest
+// matching rule wins, and Allow wins over Disallow for rules of equal length.
+func (r *robotsRules) allowed(u *url.URL) bool {
+	path := u.EscapedPath()
+	if path == "" {
+		path = "/"
+	}
+	if u.RawQuery != "" {
+		path += "?" + u.RawQuery
+	}
+
+	allowed, longest := true, -1
+	for _, rule := range r.rules {
+		if !robotsPatternMatches(rule.pattern, path) {
+			continue
+		}
+		if len(rule.pattern) > longest || (len(rule.pattern) == longest && rule.allow) {
+			allowed, longest = rule.allow, len(rule.pattern)
+		}
+	}
+	return allowed
+}
+
+// robotsPatternMatches matches a path against a robots.txt pattern, where *
+// matches any characters and a trailing $ anchors the end of the path
+func robotsPatternMatches(pattern, path string) bool {
+	anchored := strings.HasSuffix(pattern, "$")
+	pattern = strings.TrimSuffix(pattern, "$")
+
+	parts := strings.Split(pattern, "*")
+	if !strings.HasPrefix(path, parts[0]) {
+		return false
+	}
+	rest := path[len(parts[0]):]
+	for i, part := range parts[1:] {
+		if i == len(parts)-2 && anchored {
+			return strings.HasSuffix(rest, part)
+		}
+		index := strings.Index(rest, part)
+		if index < 0 {
+			return false
+		}
+		rest = rest[index+len(part):]
+	}
+	return !anchored || rest == ""
+}
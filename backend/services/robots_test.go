@@ -0,0 +1,77 @@
+package services
+
+import (
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/sykell/website-analyzer/models"
+)
+
+const robotsTestFile = `# Rules for everyone
+User-agent: *
+Disallow: /private/
+Disallow: /*.pdf$
+Allow: /private/public
+
+User-agent: OtherBot
+Disallow: /
+
+User-agent: WebsiteAnalyzer
+Disallow: /admin
+Allow: /admin/help
+Disallow: /search?
+
+Sitemap: https://example.com/sitemap.xml
+`
+
+func TestRobotsAllowed(t *testing.T) {
+	tests := []struct {
+		name      string
+		userAgent string
+		path      string
+		want      bool
+	}{
+		{"own group allows unlisted path", "", "/private/page", true},
+		{"own group disallows", "", "/admin/users", false},
+		{"longer allow wins", "", "/admin/help/page", true},
+		{"query", "", "/search?q=x", false},
+		{"path without query", "", "/search", true},
+		{"wildcard group", "Mozilla/5.0 Crawler", "/private/page", false},
+		{"wildcard group allow", "Mozilla/5.0 Crawler", "/private/public/page", true},
+		{"end anchor", "Mozilla/5.0 Crawler", "/files/report.pdf", false},
+		{"end anchor not at end", "Mozilla/5.0 Crawler", "/files/report.pdf.html", true},
+		{"other bot", "OtherBot/2.0", "/anything", false},
+	}
+
+	pageURL, _ := url.Parse("https://example.com/")
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			crawler := &Crawler{pageURL: pageURL, settings: &models.CrawlSettings{UserAgent: tt.userAgent}}
+			robots := crawler.parseRobots(strings.NewReader(robotsTestFile))
+			link, _ := url.Parse("https://example.com" + tt.path)
+			if got := robots.allowed(link); got != tt.want {
+				t.Errorf("allowed(%q) = %v, want %v", tt.path, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRobotsSitemaps(t *testing.T) {
+	pageURL, _ := url.Parse("https://example.com/")
+	crawler := &Crawler{pageURL: pageURL, settings: &models.CrawlSettings{}}
+	robots := crawler.parseRobots(strings.NewReader(robotsTestFile))
+	if len(robots.sitemaps) != 1 || robots.sitemaps[0] != "https://example.com/sitemap.xml" {
+		t.Errorf("sitemaps = %v, want [https://example.com/sitemap.xml]", robots.sitemaps)
+	}
+}
+
+func TestRobotsEmptyDisallow(t *testing.T) {
+	pageURL, _ := url.Parse("https://example.com/")
+	crawler := &Crawler{pageURL: pageURL, settings: &models.CrawlSettings{}}
+	robots := crawler.parseRobots(strings.NewReader("User-agent: *\nDisallow:\n"))
+	link, _ := url.Parse("https://example.com/page")
+	if !robots.allowed(link) {
+		t.Error("an empty Disallow should allow everything")
+	}
+}
@@ -0,0 +1,113 @@
+package services
+
+import (
+	"net"
+	"net/url"
+	"strings"
+
+	"github.com/sykell/website-analyzer/models"
+)
+
+// Link buckets reported in the link counts
+const (
+	linkInternal  = "internal"
+	linkSubdomain = "subdomain"
+	linkExternal  = "external"
+)
+
+// scopeRule is a parsed entry of a "list" scope
+type scopeRule struct {
+	host       string
+	pathPrefix string
+}
+
+// parseScopeRule parses a "host" or "host/path/prefix" scope entry. A scheme
+// is accepted and ignored so full URLs can be pasted as rules.
+func parseScopeRule(rule string) scopeRule {
+	rule = strings.TrimSpace(rule)
+	if i := strings.Index(rule, "://"); i >= 0 {
+		rule = rule[i+3:]
+	}
+	host, path, found := strings.Cut(rule, "/")
+	parsed := scopeRule{host: normalizeHost(host, "")}
+	if found {
+		parsed.pathPrefix = "/" + path
+	}
+	return parsed
+}
+
+// coversPath checks if a path is within the rule's path prefix. The prefix
+// ends at a path segment, so "/blog" covers "/blog/post" but not "/blog-old".
+func (r scopeRule) coversPath(path string) bool {
+	prefix := strings.TrimSuffix(r.pathPrefix, "/")
+	return prefix == "" || path == prefix || strings.HasPrefix(path, prefix+"/")
+}
+
+// normalizeHost lowercases a host, drops the port if it is the default one for
+// the scheme and strips a leading "www." so both variants compare equal
+func normalizeHost(host, scheme string) string {
+	host = strings.ToLower(host)
+	if hostname, port, err := net.SplitHostPort(host); err == nil {
+		if port == "" || (scheme == "http" && port == "80") || (scheme == "https" && port == "443") ||
+			(scheme == "" && (port == "80" || port == "443")) {
+			host = hostname
+		}
+	}
+	host = strings.TrimSuffix(host, ".")
+	return strings.TrimPrefix(host, "www.")
+}
+
+// scopeMode returns the configured scope, defaulting to the exact host
+func (c *Crawler) scopeMode() string {
+	if c.settings.Scope == models.ScopeList && len(c.settings.ScopeRules) > 0 {
+		return models.ScopeList
+	}
+	if c.settings.Scope == models.ScopeDomain {
+		return models.ScopeDomain
+	}
+	return models.ScopeHost
+}
+
+// inScope checks if a URL belongs to the website according to its crawl scope.
+// The same check decides which links are internal, which hosts receive
+// credentials and which pages are followed.
+func (c *Crawler) inScope(u *url.URL) bool {
+	if u.Host == "" {
+		return true
+	}
+	if u.Scheme != "" && u.Scheme != "http" && u.Scheme != "https" {
+		return false
+	}
+
+	host := normalizeHost(u.Host, u.Scheme)
+	switch c.scopeMode() {
+	case models.ScopeDomain:
+		return registrableDomain(u.Hostname()) == registrableDomain(c.baseURL.Hostname())
+	case models.ScopeList:
+		path := u.EscapedPath()
+		if path == "" {
+			path = "/"
+		}
+		for _, entry := range c.settings.ScopeRules {
+			rule := parseScopeRule(entry)
+			if host == rule.host && rule.coversPath(path) {
+				return true
+			}
+		}
+		return false
+	}
+	return host == normalizeHost(c.baseURL.Host, c.baseURL.Scheme)
+}
+
+// linkBucket classifies a link as internal, on another subdomain of the
+// website's registrable domain, or external
+func (c *Crawler) linkBucket(u *url.URL) string {
+	if c.inScope(u) {
+		return linkInternal
+	}
+	if (u.Scheme == "http" || u.Scheme == "https") &&
+		registrableDomain(u.Hostname()) == registrableDomain(c.baseURL.Hostname()) {
+		return linkSubdomain
+	}
+	return linkExternal
+}
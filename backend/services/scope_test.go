@@ -0,0 +1,51 @@
+package services
+
+import (
+	"net/url"
+	"testing"
+
+	"github.com/sykell/website-analyzer/models"
+)
+
+func TestInScope(t *testing.T) {
+	tests := []struct {
+		name     string
+		settings models.CrawlSettings
+		link     string
+		want     bool
+	}{
+		{"same host", models.CrawlSettings{}, "https://example.com/page", true},
+		{"www variant", models.CrawlSettings{}, "https://www.example.com/", true},
+		{"subdomain with host scope", models.CrawlSettings{}, "https://blog.example.com/", false},
+		{"subdomain with domain scope", models.CrawlSettings{Scope: models.ScopeDomain}, "https://blog.example.com/", true},
+		{"other domain with domain scope", models.CrawlSettings{Scope: models.ScopeDomain}, "https://example.org/", false},
+		{"list prefix itself", listScope("example.com/blog"), "https://example.com/blog", true},
+		{"list prefix below", listScope("example.com/blog"), "https://example.com/blog/post", true},
+		{"list prefix sibling", listScope("example.com/blog"), "https://example.com/blog-old", false},
+		{"list prefix longer segment", listScope("example.com/blog"), "https://example.com/blogger", false},
+		{"list prefix with slash", listScope("example.com/blog/"), "https://example.com/blog", true},
+		{"list host only", listScope("docs.example.com"), "https://docs.example.com/any", true},
+		{"list other host", listScope("example.com/blog"), "https://docs.example.com/blog", false},
+		{"non-web scheme", models.CrawlSettings{}, "ftp://example.com/file", false},
+	}
+
+	baseURL, _ := url.Parse("https://example.com/")
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			settings := tt.settings
+			crawler := &Crawler{baseURL: baseURL, settings: &settings}
+			link, err := url.Parse(tt.link)
+			if err != nil {
+				t.Fatalf("invalid link: %v", err)
+			}
+			if got := crawler.inScope(link); got != tt.want {
+				t.Errorf("inScope(%q) = %v, want %v", tt.link, got, tt.want)
+			}
+		})
+	}
+}
+
+// listScope returns settings with a list scope of the given rules
+func listScope(rules ...string) models.CrawlSettings {
+	return models.CrawlSettings{Scope: models.ScopeList, ScopeRules: rules}
+}
@@ -0,0 +1,396 @@
+package services
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/sykell/website-analyzer/models"
+)
+
+// minHSTSMaxAge is the smallest HSTS max-age considered strong (180 days)
+const minHSTSMaxAge = 15552000
+
+// Score deductions by finding severity
+var severityPenalty = map[string]int{
+	"high":   20,
+	"medium": 10,
+	"low":    5,
+}
+
+// securityHeaderAudit collects the results while auditing the page's response headers
+type securityHeaderAudit struct {
+	report *models.SecurityHeaderReport
+	https  bool
+}
+
+// analyzeSecurityHeaders audits the security headers and cookies of the page response
+func (c *Crawler) analyzeSecurityHeaders(resp *http.Response) *models.SecurityHeaderReport {
+	audit := &securityHeaderAudit{
+		report: &models.SecurityHeaderReport{
+			WebsiteID: c.website.ID,
+			Headers:   []models.SecurityHeader{},
+			Cookies:   []models.CookieAudit{},
+			Findings:  []models.SecurityFinding{},
+		},
+		https: resp.Request.URL.Scheme == "https",
+	}
+
+	audit.checkHSTS(resp.Header)
+	frameAncestors := audit.checkCSP(resp.Header)
+	audit.checkFrameOptions(resp.Header, frameAncestors)
+	audit.checkContentTypeOptions(resp.Header)
+	audit.checkReferrerPolicy(resp.Header)
+	audit.checkPermissionsPolicy(resp.Header)
+	audit.checkCookies(resp.Cookies())
+
+	// Grade the page from the accumulated findings
+	score := 100
+	for _, finding := range audit.report.Findings {
+		score -= severityPenalty[finding.Severity]
+	}
+	if score < 0 {
+		score = 0
+	}
+	audit.report.Score = score
+	audit.report.Grade = securityGrade(score)
+
+	return audit.report
+}
+
+// addHeader records the presence and status of a header
+func (a *securityHeaderAudit) addHeader(name, value, status string) {
+	a.report.Headers = append(a.report.Headers, models.SecurityHeader{
+		Name:    name,
+		Present: value != "",
+		Value:   value,
+		Status:  status,
+	})
+}
+
+// addFinding records a problem with a header or cookie
+func (a *securityHeaderAudit) addFinding(header, severity, message string) {
+	a.report.Findings = append(a.report.Findings, models.SecurityFinding{
+		Header:   header,
+		Severity: severity,
+		Message:  message,
+	})
+}
+
+// checkHSTS checks the Strict-Transport-Security header
+func (a *securityHeaderAudit) checkHSTS(header http.Header) {
+	const name = "Strict-Transport-Security"
+	value := header.Get(name)
+
+	if !a.https {
+		a.addHeader(name, value, "missing")
+		a.addFinding(name, "high", "Page is not served over HTTPS, so HSTS cannot apply")
+		return
+	}
+	if value == "" {
+		a.addHeader(name, value, "missing")
+		a.addFinding(name, "high", "Missing Strict-Transport-Security header")
+		return
+	}
+
+	status := "good"
+	maxAge := -1
+	for _, directive := range strings.Split(value, ";") {
+		directive = strings.TrimSpace(strings.ToLower(directive))
+		if strings.HasPrefix(directive, "max-age=") {
+			maxAge, _ = strconv.Atoi(strings.Trim(strings.TrimPrefix(directive, "max-age="), `"`))
+		}
+	}
+	if maxAge < minHSTSMaxAge {
+		status = "weak"
+		a.addFinding(name, "low", fmt.Sprintf("max-age should be at least %d seconds", minHSTSMaxAge))
+	}
+	a.addHeader(name, value, status)
+}
+
+// checkCSP parses the Content-Security-Policy header and reports unsafe sources.
+// It returns whether a policy restricts framing via frame-ancestors.
+func (a *securityHeaderAudit) checkCSP(header http.Header) bool {
+	const name = "Content-Security-Policy"
+	value := strings.Join(header.Values(name), ", ")
+
+	if value == "" {
+		a.addHeader(name, value, "missing")
+		if header.Get("Content-Security-Policy-Report-Only") != "" {
+			a.addFinding(name, "high", "Content-Security-Policy is only sent in report-only mode")
+		} else {
+			a.addFinding(name, "high", "Missing Content-Security-Policy header")
+		}
+		return false
+	}
+
+	// Browsers enforce every policy, so a weakness is only reported when all
+	// of them allow it
+	policies := parseCSP(value)
+	findingCount := len(a.report.Findings)
+
+	if !cspAllows(policies, "script-src", func([]string) bool { return true }) {
+		a.addFinding(name, "medium", "Policy sets neither script-src nor default-src")
+	}
+	// unsafe-inline is ignored by browsers when a nonce or hash is present
+	if cspAllows(policies, "script-src", func(sources []string) bool {
+		return containsString(sources, "'unsafe-inline'") && !hasNonceOrHash(sources)
+	}) {
+		a.addFinding(name, "medium", "script-src allows 'unsafe-inline'")
+	}
+	if cspAllows(policies, "script-src", func(sources []string) bool { return containsString(sources, "'unsafe-eval'") }) {
+		a.addFinding(name, "medium", "script-src allows 'unsafe-eval'")
+	}
+	for _, wildcard := range []string{"*", "http:", "https:", "data:"} {
+		if cspAllows(policies, "script-src", func(sources []string) bool { return containsString(sources, wildcard) }) {
+			a.addFinding(name, "medium", fmt.Sprintf("script-src allows the wildcard source %s", wildcard))
+		}
+	}
+
+	if cspAllows(policies, "style-src", func(sources []string) bool { return containsString(sources, "'unsafe-inline'") }) {
+		a.addFinding(name, "low", "style-src allows 'unsafe-inline'")
+	}
+	if cspAllows(policies, "object-src", func(sources []string) bool { return containsString(sources, "*") }) {
+		a.addFinding(name, "medium", "object-src allows the wildcard source *")
+	}
+
+	// Other fetch directives set by any of the policies
+	seen := map[string]bool{"script-src": true, "default-src": true, "object-src": true}
+	var names []string
+	for _, directives := range policies {
+		for directive := range directives {
+			if !seen[directive] && strings.HasSuffix(directive, "-src") {
+				seen[directive] = true
+				names = append(names, directive)
+			}
+		}
+	}
+	sort.Strings(names)
+	for _, directive := range names {
+		if cspAllows(policies, directive, func(sources []string) bool { return len(sources) == 1 && sources[0] == "*" }) {
+			a.addFinding(name, "low", fmt.Sprintf("%s allows the wildcard source *", directive))
+		}
+	}
+
+	status := "good"
+	if len(a.report.Findings) > findingCount {
+		status = "weak"
+	}
+	a.addHeader(name, value, status)
+
+	// One policy restricting framing is enough
+	for _, directives := range policies {
+		if sources, ok := directives["frame-ancestors"]; ok && !containsString(sources, "*") {
+			return true
+		}
+	}
+	return false
+}
+
+// cspAllows checks if every policy allows what allowed reports for the
+// sources of a fetch directive. Directives fall back to default-src, and a
+// policy setting neither allows anything, but at least one policy has to
+// restrict the directive for a weakness to be reported.
+func cspAllows(policies []map[string][]string, directive string, allowed func(sources []string) bool) bool {
+	restricted := false
+	for _, directives := range policies {
+		sources, ok := directives[directive]
+		if !ok {
+			sources, ok = directives["default-src"]
+		}
+		if !ok {
+			continue
+		}
+		restricted = true
+		if !allowed(sources) {
+			return false
+		}
+	}
+	return restricted
+}
+
+// checkFrameOptions checks clickjacking protection via X-Frame-Options or frame-ancestors
+func (a *securityHeaderAudit) checkFrameOptions(header http.Header, frameAncestors bool) {
+	const name = "X-Frame-Options"
+	value := header.Get(name)
+
+	switch strings.ToUpper(strings.TrimSpace(value)) {
+	case "DENY", "SAMEORIGIN":
+		a.addHeader(name, value, "good")
+	case "":
+		if frameAncestors {
+			// CSP frame-ancestors supersedes X-Frame-Options
+			a.addHeader(name, value, "good")
+			return
+		}
+		a.addHeader(name, value, "missing")
+		a.addFinding(name, "medium", "Missing X-Frame-Options header and CSP frame-ancestors directive")
+	default:
+		a.addHeader(name, value, "weak")
+		if !frameAncestors {
+			a.addFinding(name, "medium", fmt.Sprintf("Invalid X-Frame-Options value %q", value))
+		}
+	}
+}
+
+// checkContentTypeOptions checks the X-Content-Type-Options header
+func (a *securityHeaderAudit) checkContentTypeOptions(header http.Header) {
+	const name = "X-Content-Type-Options"
+	value := header.Get(name)
+
+	switch {
+	case strings.EqualFold(strings.TrimSpace(value), "nosniff"):
+		a.addHeader(name, value, "good")
+	case value == "":
+		a.addHeader(name, value, "missing")
+		a.addFinding(name, "medium", "Missing X-Content-Type-Options header")
+	default:
+		a.addHeader(name, value, "weak")
+		a.addFinding(name, "medium", fmt.Sprintf("X-Content-Type-Options should be nosniff, not %q", value))
+	}
+}
+
+// checkReferrerPolicy checks the Referrer-Policy header
+func (a *securityHeaderAudit) checkReferrerPolicy(header http.Header) {
+	const name = "Referrer-Policy"
+	value := header.Get(name)
+
+	if value == "" {
+		a.addHeader(name, value, "missing")
+		a.addFinding(name, "low", "Missing Referrer-Policy header")
+		return
+	}
+
+	// The last recognized policy in the list wins
+	policies := strings.Split(value, ",")
+	policy := strings.ToLower(strings.TrimSpace(policies[len(policies)-1]))
+	switch policy {
+	case "unsafe-url", "no-referrer-when-downgrade":
+		a.addHeader(name, value, "weak")
+		a.addFinding(name, "low", fmt.Sprintf("Referrer-Policy %s leaks full URLs to other origins", policy))
+	default:
+		a.addHeader(name, value, "good")
+	}
+}
+
+// checkPermissionsPolicy checks the Permissions-Policy header
+func (a *securityHeaderAudit) checkPermissionsPolicy(header http.Header) {
+	const name = "Permissions-Policy"
+	value := header.Get(name)
+
+	if value == "" {
+		a.addHeader(name, value, "missing")
+		a.addFinding(name, "low", "Missing Permissions-Policy header")
+		return
+	}
+	a.addHeader(name, value, "good")
+}
+
+// checkCookies checks the Secure, HttpOnly and SameSite flags of the page's cookies
+func (a *securityHeaderAudit) checkCookies(cookies []*http.Cookie) {
+	const name = "Set-Cookie"
+
+	for _, cookie := range cookies {
+		audit := models.CookieAudit{
+			Name:     cookie.Name,
+			Secure:   cookie.Secure,
+			HttpOnly: cookie.HttpOnly,
+			SameSite: sameSiteName(cookie.SameSite),
+		}
+		a.report.Cookies = append(a.report.Cookies, audit)
+
+		if a.https && !cookie.Secure {
+			a.addFinding(name, "low", fmt.Sprintf("Cookie %s is missing the Secure flag", cookie.Name))
+		}
+		if !cookie.HttpOnly {
+			a.addFinding(name, "low", fmt.Sprintf("Cookie %s is missing the HttpOnly flag", cookie.Name))
+		}
+		switch cookie.SameSite {
+		case http.SameSiteNoneMode:
+			if !cookie.Secure {
+				a.addFinding(name, "low", fmt.Sprintf("Cookie %s uses SameSite=None without Secure", cookie.Name))
+			}
+		case http.SameSiteLaxMode, http.SameSiteStrictMode:
+		default:
+			a.addFinding(name, "low", fmt.Sprintf("Cookie %s is missing the SameSite attribute", cookie.Name))
+		}
+	}
+}
+
+// parseCSP splits a Content-Security-Policy header into its policies, with
+// the sources of each directive. Several policies can be sent comma-separated
+// or in separate headers; within a policy, a repeated directive is ignored.
+func parseCSP(value string) []map[string][]string {
+	var policies []map[string][]string
+	for _, policy := range strings.Split(value, ",") {
+		directives := map[string][]string{}
+		for _, directive := range strings.Split(policy, ";") {
+			fields := strings.Fields(directive)
+			if len(fields) == 0 {
+				continue
+			}
+			name := strings.ToLower(fields[0])
+			if _, ok := directives[name]; ok {
+				continue
+			}
+			sources := []string{}
+			for _, source := range fields[1:] {
+				sources = append(sources, strings.ToLower(source))
+			}
+			directives[name] = sources
+		}
+		if len(directives) > 0 {
+			policies = append(policies, directives)
+		}
+	}
+
+	// A header without any directive is a policy that restricts nothing
+	if len(policies) == 0 {
+		policies = append(policies, map[string][]string{})
+	}
+	return policies
+}
+
+// hasNonceOrHash checks if a source list contains a nonce or hash source
+func hasNonceOrHash(sources []string) bool {
+	for _, source := range sources {
+		if strings.HasPrefix(source, "'nonce-") || strings.HasPrefix(source, "'sha") {
+			return true
+		}
+	}
+	return false
+}
+
+// sameSiteName returns the SameSite attribute as written in the Set-Cookie header
+func sameSiteName(mode http.SameSite) string {
+	switch mode {
+	case http.SameSiteLaxMode:
+		return "Lax"
+	case http.SameSiteStrictMode:
+		return "Strict"
+	case http.SameSiteNoneMode:
+		return "None"
+	}
+	return ""
+}
+
+// securityGrade converts a score into a letter grade
+func securityGrade(score int) string {
+	switch {
+	case score >= 100:
+		return "A+"
+	case score >= 90:
+		return "A"
+	case score >= 80:
+		return "B"
+	case score >= 70:
+		return "C"
+	case score >= 60:
+		return "D"
+	case score >= 50:
+		return "E"
+	}
+	return "F"
+}
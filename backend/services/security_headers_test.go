@@ -0,0 +1,141 @@
+package services
+
+import (
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/sykell/website-analyzer/models"
+)
+
+func TestParseCSP(t *testing.T) {
+	tests := []struct {
+		name  string
+		value string
+		want  []map[string][]string
+	}{
+		{
+			"single policy",
+			"default-src 'self'; Script-Src 'SELF' https://CDN.test; upgrade-insecure-requests",
+			[]map[string][]string{{
+				"default-src":               {"'self'"},
+				"script-src":                {"'self'", "https://cdn.test"},
+				"upgrade-insecure-requests": {},
+			}},
+		},
+		{
+			"repeated directive",
+			"script-src 'self'; script-src *",
+			[]map[string][]string{{"script-src": {"'self'"}}},
+		},
+		{
+			"several policies",
+			"script-src *, default-src 'self'",
+			[]map[string][]string{{"script-src": {"*"}}, {"default-src": {"'self'"}}},
+		},
+		{
+			"empty policies are skipped",
+			" ; , script-src 'self';;",
+			[]map[string][]string{{"script-src": {"'self'"}}},
+		},
+		{
+			"no directives",
+			";",
+			[]map[string][]string{{}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := parseCSP(tt.value); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseCSP(%q) = %v, want %v", tt.value, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCheckCSP(t *testing.T) {
+	tests := []struct {
+		name           string
+		policies       []string
+		want           []string
+		frameAncestors bool
+	}{
+		{"strict policy", []string{"default-src 'self'; frame-ancestors 'none'"}, nil, true},
+		{"missing script sources", []string{"img-src 'self'"}, []string{"Policy sets neither script-src nor default-src"}, false},
+		{"unsafe inline", []string{"script-src 'self' 'unsafe-inline'"}, []string{"script-src allows 'unsafe-inline'"}, false},
+		{"unsafe inline with nonce", []string{"script-src 'unsafe-inline' 'nonce-abc'"}, nil, false},
+		{"default-src fallback", []string{"default-src * 'unsafe-eval'"}, []string{
+			"script-src allows 'unsafe-eval'",
+			"script-src allows the wildcard source *",
+			"object-src allows the wildcard source *",
+		}, false},
+		{"script-src overrides default-src", []string{"default-src *; script-src 'self'; object-src 'none'"}, nil, false},
+		{"other wildcards", []string{"default-src 'self'; img-src *; style-src 'unsafe-inline'; object-src *"}, []string{
+			"style-src allows 'unsafe-inline'",
+			"object-src allows the wildcard source *",
+			"img-src allows the wildcard source *",
+		}, false},
+		{"wildcard frame ancestors", []string{"default-src 'self'; frame-ancestors *"}, nil, false},
+		{"second policy blocks inline scripts", []string{"script-src 'unsafe-inline'", "default-src 'self'"}, nil, false},
+		{"second policy uses a nonce", []string{"script-src 'unsafe-inline'", "script-src 'unsafe-inline' 'nonce-abc'"}, nil, false},
+		{"both policies allow eval", []string{"script-src * 'unsafe-eval'", "script-src 'self' 'unsafe-eval'"}, []string{
+			"script-src allows 'unsafe-eval'",
+		}, false},
+		{"comma-separated policies", []string{"script-src *, script-src 'self'; frame-ancestors 'self'"}, nil, true},
+		{"unrestricted in the other policy", []string{"img-src *", "script-src 'self'"}, []string{
+			"img-src allows the wildcard source *",
+		}, false},
+		{"restricted in the other policy", []string{"img-src *", "default-src 'self'"}, nil, false},
+		{"no policy restricts scripts", []string{"img-src 'self'", "style-src 'self'"}, []string{
+			"Policy sets neither script-src nor default-src",
+		}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			audit := &securityHeaderAudit{report: &models.SecurityHeaderReport{}, https: true}
+			header := http.Header{"Content-Security-Policy": tt.policies}
+			frameAncestors := audit.checkCSP(header)
+
+			var got []string
+			for _, finding := range audit.report.Findings {
+				got = append(got, finding.Message)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("findings = %q, want %q", got, tt.want)
+			}
+			if frameAncestors != tt.frameAncestors {
+				t.Errorf("frame ancestors restricted = %v, want %v", frameAncestors, tt.frameAncestors)
+			}
+			wantStatus := "good"
+			if len(tt.want) > 0 {
+				wantStatus = "weak"
+			}
+			if status := audit.report.Headers[0].Status; status != wantStatus {
+				t.Errorf("status = %s, want %s", status, wantStatus)
+			}
+		})
+	}
+}
+
+func TestCheckCSPMissing(t *testing.T) {
+	tests := []struct {
+		header http.Header
+		want   string
+	}{
+		{http.Header{}, "Missing Content-Security-Policy header"},
+		{http.Header{"Content-Security-Policy-Report-Only": {"default-src 'self'"}}, "Content-Security-Policy is only sent in report-only mode"},
+	}
+
+	for _, tt := range tests {
+		audit := &securityHeaderAudit{report: &models.SecurityHeaderReport{}}
+		if audit.checkCSP(tt.header) {
+			t.Error("a missing policy restricts framing")
+		}
+		if len(audit.report.Findings) != 1 || !strings.Contains(audit.report.Findings[0].Message, tt.want) {
+			t.Errorf("findings = %v, want %q", audit.report.Findings, tt.want)
+		}
+	}
+}
@@ -0,0 +1,559 @@
+package services
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// cssSelector is a compiled CSS selector list, e.g. "form#login input[type=password], .error"
+type cssSelector struct {
+	groups []complexSelector
+}
+
+// complexSelector is a chain of compound selectors joined by combinators
+type complexSelector struct {
+	parts       []compoundSelector
+	combinators []byte // combinators[i] joins parts[i] and parts[i+1]
+}
+
+// compoundSelector matches a single element, e.g. "input.field[type=password]:first-child"
+type compoundSelector struct {
+	tag     string
+	id      string
+	classes []string
+	attrs   []attrSelector
+	pseudos []pseudoSelector
+}
+
+// attrSelector matches an attribute, e.g. [type="password"] or [href^=https]
+type attrSelector struct {
+	key      string
+	operator string
+	value    string
+}
+
+// pseudoSelector matches a structural pseudo-class, e.g. :nth-child(2n+1) or :not(.hidden)
+type pseudoSelector struct {
+	name string
+	a, b int
+	not  *compoundSelector
+}
+
+// compileSelector parses a CSS selector list
+func compileSelector(selector string) (*cssSelector, error) {
+	p := &selectorParser{input: strings.TrimSpace(selector)}
+	if p.input == "" {
+		return nil, fmt.Errorf("empty selector")
+	}
+
+	compiled := &cssSelector{}
+	for {
+		group, err := p.parseComplex()
+		if err != nil {
+			return nil, fmt.Errorf("invalid selector %q: %w", selector, err)
+		}
+		compiled.groups = append(compiled.groups, group)
+
+		p.skipSpace()
+		if p.done() {
+			break
+		}
+		if p.peek() != ',' {
+			return nil, fmt.Errorf("invalid selector %q: unexpected %q", selector, p.peek())
+		}
+		p.pos++
+	}
+
+	return compiled, nil
+}
+
+// MatchAll returns every element below root matching the selector, in document order
+func (s *cssSelector) MatchAll(root *html.Node) []*html.Node {
+	var matches []*html.Node
+	var matchFunc func(*html.Node)
+	matchFunc = func(n *html.Node) {
+		if n.Type == html.ElementNode && s.Match(n) {
+			matches = append(matches, n)
+		}
+		for child := n.FirstChild; child != nil; child = child.NextSibling {
+			matchFunc(child)
+		}
+	}
+	matchFunc(root)
+	return matches
+}
+
+// MatchFirst returns the first element below root matching the selector
+func (s *cssSelector) MatchFirst(root *html.Node) *html.Node {
+	if matches := s.MatchAll(root); len(matches) > 0 {
+		return matches[0]
+	}
+	return nil
+}
+
+// Match checks if an element matches any selector in the list
+func (s *cssSelector) Match(n *html.Node) bool {
+	for _, group := range s.groups {
+		if group.match(n, len(group.parts)-1) {
+			return true
+		}
+	}
+	return false
+}
+
+// match checks the element against parts[index] and the combinators to its left
+func (cs complexSelector) match(n *html.Node, index int) bool {
+	if !cs.parts[index].match(n) {
+		return false
+	}
+	if index == 0 {
+		return true
+	}
+
+	switch cs.combinators[index-1] {
+	case '>':
+		parent := n.Parent
+		return parent != nil && parent.Type == html.ElementNode && cs.match(parent, index-1)
+	case '+':
+		sibling := previousElementSibling(n)
+		return sibling != nil && cs.match(sibling, index-1)
+	case '~':
+		for sibling := previousElementSibling(n); sibling != nil; sibling = previousElementSibling(sibling) {
+			if cs.match(sibling, index-1) {
+				return true
+			}
+		}
+		return false
+	default:
+		for ancestor := n.Parent; ancestor != nil && ancestor.Type == html.ElementNode; ancestor = ancestor.Parent {
+			if cs.match(ancestor, index-1) {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// match checks a single element against the compound selector
+func (cs *compoundSelector) match(n *html.Node) bool {
+	if n.Type != html.ElementNode {
+		return false
+	}
+	if cs.tag != "" && cs.tag != "*" && cs.tag != n.Data {
+		return false
+	}
+	if cs.id != "" && getAttr(n, "id") != cs.id {
+		return false
+	}
+	if len(cs.classes) > 0 {
+		classes := strings.Fields(getAttr(n, "class"))
+		for _, class := range cs.classes {
+			if !containsString(classes, class) {
+				return false
+			}
+		}
+	}
+	for _, attr := range cs.attrs {
+		if !attr.match(n) {
+			return false
+		}
+	}
+	for _, pseudo := range cs.pseudos {
+		if !pseudo.match(n) {
+			return false
+		}
+	}
+	return true
+}
+
+// match checks an element's attribute against the selector
+func (as attrSelector) match(n *html.Node) bool {
+	value, ok := lookupAttr(n, as.key)
+	if !ok {
+		return false
+	}
+
+	switch as.operator {
+	case "":
+		return true
+	case "=":
+		return value == as.value
+	case "~=":
+		return containsString(strings.Fields(value), as.value)
+	case "|=":
+		return value == as.value || strings.HasPrefix(value, as.value+"-")
+	case "^=":
+		return as.value != "" && strings.HasPrefix(value, as.value)
+	case "$=":
+		return as.value != "" && strings.HasSuffix(value, as.value)
+	case "*=":
+		return as.value != "" && strings.Contains(value, as.value)
+	}
+	return false
+}
+
+// match checks an element's position or negation against the pseudo-class
+func (ps pseudoSelector) match(n *html.Node) bool {
+	switch ps.name {
+	case "not":
+		return !ps.not.match(n)
+	case "first-child":
+		return previousElementSibling(n) == nil
+	case "last-child":
+		return nextElementSibling(n) == nil
+	case "nth-child":
+		position := 1
+		for sibling := previousElementSibling(n); sibling != nil; sibling = previousElementSibling(sibling) {
+			position++
+		}
+		if ps.a == 0 {
+			return position == ps.b
+		}
+		return (position-ps.b)%ps.a == 0 && (position-ps.b)/ps.a >= 0
+	}
+	return false
+}
+
+// selectorParser is a small recursive descent parser for CSS selectors
+type selectorParser struct {
+	input string
+	pos   int
+}
+
+func (p *selectorParser) done() bool { return p.pos >= len(p.input) }
+
+func (p *selectorParser) peek() byte { return p.input[p.pos] }
+
+func (p *selectorParser) skipSpace() bool {
+	start := p.pos
+	for !p.done() && strings.IndexByte(" \t\n\r\f", p.peek()) >= 0 {
+		p.pos++
+	}
+	return p.pos > start
+}
+
+// parseComplex parses compound selectors joined by combinators
+func (p *selectorParser) parseComplex() (complexSelector, error) {
+	var cs complexSelector
+	p.skipSpace()
+
+	for {
+		compound, err := p.parseCompound()
+		if err != nil {
+			return cs, err
+		}
+		cs.parts = append(cs.parts, compound)
+
+		hadSpace := p.skipSpace()
+		if p.done() || p.peek() == ',' || p.peek() == ')' {
+			return cs, nil
+		}
+
+		combinator := byte(' ')
+		if c := p.peek(); c == '>' || c == '+' || c == '~' {
+			combinator = c
+			p.pos++
+			p.skipSpace()
+		} else if !hadSpace {
+			return cs, fmt.Errorf("unexpected %q", c)
+		}
+		cs.combinators = append(cs.combinators, combinator)
+	}
+}
+
+// parseCompound parses a type selector followed by ids, classes, attributes and pseudo-classes
+func (p *selectorParser) parseCompound() (compoundSelector, error) {
+	var cs compoundSelector
+
+	if !p.done() && p.peek() == '*' {
+		cs.tag = "*"
+		p.pos++
+	} else if name := p.parseIdent(); name != "" {
+		cs.tag = strings.ToLower(name)
+	}
+
+	for !p.done() {
+		switch p.peek() {
+		case '#':
+			p.pos++
+			cs.id = p.parseIdent()
+			if cs.id == "" {
+				return cs, fmt.Errorf("expected id after '#'")
+			}
+		case '.':
+			p.pos++
+			class := p.parseIdent()
+			if class == "" {
+				return cs, fmt.Errorf("expected class after '.'")
+			}
+			cs.classes = append(cs.classes, class)
+		case '[':
+			attr, err := p.parseAttr()
+			if err != nil {
+				return cs, err
+			}
+			cs.attrs = append(cs.attrs, attr)
+		case ':':
+			pseudo, err := p.parsePseudo()
+			if err != nil {
+				return cs, err
+			}
+			cs.pseudos = append(cs.pseudos, pseudo)
+		default:
+			if cs.tag == "" && cs.id == "" && len(cs.classes) == 0 && len(cs.attrs) == 0 && len(cs.pseudos) == 0 {
+				return cs, fmt.Errorf("unexpected %q", p.peek())
+			}
+			return cs, nil
+		}
+	}
+
+	if cs.tag == "" && cs.id == "" && len(cs.classes) == 0 && len(cs.attrs) == 0 && len(cs.pseudos) == 0 {
+		return cs, fmt.Errorf("unexpected end of selector")
+	}
+	return cs, nil
+}
+
+// parseAttr parses an attribute selector such as [name="q" i]
+func (p *selectorParser) parseAttr() (attrSelector, error) {
+	var as attrSelector
+	p.pos++ // Skip '['
+	p.skipSpace()
+
+	as.key = strings.ToLower(p.parseIdent())
+	if as.key == "" {
+		return as, fmt.Errorf("expected attribute name")
+	}
+	p.skipSpace()
+	if p.done() {
+		return as, fmt.Errorf("unterminated attribute selector")
+	}
+
+	if p.peek() != ']' {
+		for _, operator := range []string{"~=", "|=", "^=", "$=", "*=", "="} {
+			if strings.HasPrefix(p.input[p.pos:], operator) {
+				as.operator = operator
+				p.pos += len(operator)
+				break
+			}
+		}
+		if as.operator == "" {
+			return as, fmt.Errorf("invalid attribute operator")
+		}
+
+		p.skipSpace()
+		value, err := p.parseValue()
+		if err != nil {
+			return as, err
+		}
+		as.value = value
+		p.skipSpace()
+	}
+
+	if p.done() || p.peek() != ']' {
+		return as, fmt.Errorf("unterminated attribute selector")
+	}
+	p.pos++
+	return as, nil
+}
+
+// parsePseudo parses a supported pseudo-class
+func (p *selectorParser) parsePseudo() (pseudoSelector, error) {
+	var ps pseudoSelector
+	p.pos++ // Skip ':'
+
+	ps.name = strings.ToLower(p.parseIdent())
+	switch ps.name {
+	case "first-child", "last-child":
+		return ps, nil
+	case "nth-child", "not":
+	default:
+		return ps, fmt.Errorf("unsupported pseudo-class %q", ps.name)
+	}
+
+	if p.done() || p.peek() != '(' {
+		return ps, fmt.Errorf("expected '(' after :%s", ps.name)
+	}
+	p.pos++
+	end := closingParen(p.input[p.pos:])
+	if end < 0 {
+		return ps, fmt.Errorf("unterminated :%s", ps.name)
+	}
+	argument := strings.TrimSpace(p.input[p.pos : p.pos+end])
+	p.pos += end + 1
+
+	if ps.name == "not" {
+		inner := &selectorParser{input: argument}
+		compound, err := inner.parseCompound()
+		if err != nil || !inner.done() {
+			return ps, fmt.Errorf("invalid :not argument %q", argument)
+		}
+		ps.not = &compound
+		return ps, nil
+	}
+
+	a, b, err := parseNth(argument)
+	if err != nil {
+		return ps, err
+	}
+	ps.a, ps.b = a, b
+	return ps, nil
+}
+
+// closingParen returns the index of the parenthesis closing an argument that
+// starts at the beginning of input, or -1. Nested parentheses, like those of
+// :not(:nth-child(2)), and parentheses in quoted strings are skipped.
+func closingParen(input string) int {
+	depth := 0
+	var quote byte
+	for i := 0; i < len(input); i++ {
+		c := input[i]
+		switch {
+		case c == '\\':
+			i++ // Skip the escaped character
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		case c == '(':
+			depth++
+		case c == ')':
+			if depth == 0 {
+				return i
+			}
+			depth--
+		}
+	}
+	return -1
+}
+
+// parseIdent parses a CSS identifier
+func (p *selectorParser) parseIdent() string {
+	start := p.pos
+	for !p.done() {
+		c := p.peek()
+		if c == '-' || c == '_' || c >= 0x80 ||
+			(c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (c >= '0' && c <= '9') {
+			p.pos++
+			continue
+		}
+		if c == '\\' && p.pos+1 < len(p.input) {
+			p.pos += 2
+			continue
+		}
+		break
+	}
+	return strings.ReplaceAll(p.input[start:p.pos], "\\", "")
+}
+
+// parseValue parses a quoted string or an identifier
+func (p *selectorParser) parseValue() (string, error) {
+	if p.done() {
+		return "", fmt.Errorf("expected attribute value")
+	}
+
+	quote := p.peek()
+	if quote != '"' && quote != '\'' {
+		return p.parseIdent(), nil
+	}
+
+	p.pos++
+	end := strings.IndexByte(p.input[p.pos:], quote)
+	if end < 0 {
+		return "", fmt.Errorf("unterminated string")
+	}
+	value := p.input[p.pos : p.pos+end]
+	p.pos += end + 1
+	return value, nil
+}
+
+// parseNth parses the an+b argument of :nth-child
+func parseNth(argument string) (int, int, error) {
+	argument = strings.ReplaceAll(strings.ToLower(argument), " ", "")
+	switch argument {
+	case "odd":
+		return 2, 1, nil
+	case "even":
+		return 2, 0, nil
+	}
+
+	index := strings.IndexByte(argument, 'n')
+	if index < 0 {
+		b, err := strconv.Atoi(argument)
+		if err != nil {
+			return 0, 0, fmt.Errorf("invalid :nth-child argument %q", argument)
+		}
+		return 0, b, nil
+	}
+
+	a := 1
+	switch coefficient := argument[:index]; coefficient {
+	case "", "+":
+	case "-":
+		a = -1
+	default:
+		var err error
+		if a, err = strconv.Atoi(coefficient); err != nil {
+			return 0, 0, fmt.Errorf("invalid :nth-child argument %q", argument)
+		}
+	}
+
+	b := 0
+	if offset := argument[index+1:]; offset != "" {
+		var err error
+		if b, err = strconv.Atoi(offset); err != nil {
+			return 0, 0, fmt.Errorf("invalid :nth-child argument %q", argument)
+		}
+	}
+	return a, b, nil
+}
+
+// getAttr returns the value of an attribute, or an empty string if it is missing
+func getAttr(n *html.Node, key string) string {
+	value, _ := lookupAttr(n, key)
+	return value
+}
+
+// lookupAttr returns the value of an attribute and whether it is present
+func lookupAttr(n *html.Node, key string) (string, bool) {
+	for _, attr := range n.Attr {
+		if attr.Namespace == "" && strings.EqualFold(attr.Key, key) {
+			return attr.Val, true
+		}
+	}
+	return "", false
+}
+
+// previousElementSibling returns the closest preceding element sibling
+func previousElementSibling(n *html.Node) *html.Node {
+	for sibling := n.PrevSibling; sibling != nil; sibling = sibling.PrevSibling {
+		if sibling.Type == html.ElementNode {
+			return sibling
+		}
+	}
+	return nil
+}
+
+// nextElementSibling returns the closest following element sibling
+func nextElementSibling(n *html.Node) *html.Node {
+	for sibling := n.NextSibling; sibling != nil; sibling = sibling.NextSibling {
+		if sibling.Type == html.ElementNode {
+			return sibling
+		}
+	}
+	return nil
+}
+
+// containsString checks if a slice contains a string
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,141 @@
+package services
+
+import (
+	"strings"
+	"testing"
+
+	"golang.org/x/net/html"
+)
+
+const selectorTestPage = `<!DOCTYPE html>
+<html><body>
+<div id="main" class="content wide">
+  <h1 id="title">Title</h1>
+  <form id="login" action="/login">
+    <input id="user" type="text" name="user">
+    <input id="pass" type="password" name="pass" class="field secret">
+  </form>
+  <ul id="list">
+    <li id="li1" class="item">One</li>
+    <li id="li2" class="item x">Two</li>
+    <li id="li3" class="item">Three</li>
+    <li id="li4" class="item x">Four</li>
+  </ul>
+  <p id="p1" lang="en-US" data-tags="a b c">First</p>
+  <p id="p2" lang="en">Second</p>
+  <a id="a1" href="https://example.com/page" title="a (b)">External</a>
+  <a id="a2" href="/about.html">About</a>
+</div>
+<span id="outside"></span>
+</body></html>`
+
+// matchedIDs returns the ids of the elements a selector matches in the test page
+func matchedIDs(t *testing.T, selector string) string {
+	t.Helper()
+	doc, err := html.Parse(strings.NewReader(selectorTestPage))
+	if err != nil {
+		t.Fatalf("failed to parse test page: %v", err)
+	}
+	compiled, err := compileSelector(selector)
+	if err != nil {
+		t.Fatalf("compileSelector(%q) failed: %v", selector, err)
+	}
+	var ids []string
+	for _, n := range compiled.MatchAll(doc) {
+		ids = append(ids, getAttr(n, "id"))
+	}
+	return strings.Join(ids, " ")
+}
+
+func TestSelectorMatches(t *testing.T) {
+	tests := []struct {
+		name     string
+		selector string
+		want     string
+	}{
+		{"type", "h1", "title"},
+		{"universal", "ul > *", "li1 li2 li3 li4"},
+		{"id", "#pass", "pass"},
+		{"class", ".item.x", "li2 li4"},
+		{"type and class", "input.secret", "pass"},
+		{"attribute presence", "[data-tags]", "p1"},
+		{"attribute equals", `input[type="password"]`, "pass"},
+		{"attribute unquoted", "input[type=text]", "user"},
+		{"attribute word", "[data-tags~=b]", "p1"},
+		{"attribute language", "[lang|=en]", "p1 p2"},
+		{"attribute prefix", "a[href^=https]", "a1"},
+		{"attribute suffix", "a[href$='.html']", "a2"},
+		{"attribute substring", "a[href*=example]", "a1"},
+		{"attribute quoted parenthesis", `a[title="a (b)"]`, "a1"},
+		{"descendant", "#main input", "user pass"},
+		{"child", "form > input", "user pass"},
+		{"child excludes grandchildren", "#main > li", ""},
+		{"adjacent sibling", "#li1 + li", "li2"},
+		{"general sibling", "#li2 ~ li", "li3 li4"},
+		{"selector list", "h1, #outside", "title outside"},
+		{"first child", "li:first-child", "li1"},
+		{"last child", "li:last-child", "li4"},
+		{"nth child", "li:nth-child(2)", "li2"},
+		{"nth child odd", "li:nth-child(odd)", "li1 li3"},
+		{"nth child even", "li:nth-child(even)", "li2 li4"},
+		{"nth child formula", "li:nth-child(2n+1)", "li1 li3"},
+		{"nth child negative", "li:nth-child(-n+2)", "li1 li2"},
+		{"not", "li:not(.x)", "li1 li3"},
+		{"not with nested parentheses", "li:not(:nth-child(2))", "li1 li3 li4"},
+		{"not nested in not", "li:not(.item:not(.x))", "li2 li4"},
+		{"not with quoted parenthesis", `a:not([title=")"])`, "a1 a2"},
+		{"pseudo after nested argument", "li:not(:nth-child(1)):last-child", "li4"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := matchedIDs(t, tt.selector); got != tt.want {
+				t.Errorf("%q matched %q, want %q", tt.selector, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSelectorErrors(t *testing.T) {
+	tests := []string{
+		"",
+		"div >",
+		"[",
+		"[type",
+		"[type^]",
+		"#",
+		".",
+		"li:nth-child(2",
+		"li:not(:nth-child(2)",
+		"li:nth-child(x)",
+		"li:hover",
+		"li:not(div p)",
+		`a[title="x]`,
+	}
+
+	for _, selector := range tests {
+		if _, err := compileSelector(selector); err == nil {
+			t.Errorf("compileSelector(%q) succeeded, want an error", selector)
+		}
+	}
+}
+
+func TestClosingParen(t *testing.T) {
+	tests := []struct {
+		input string
+		want  int
+	}{
+		{"2)", 1},
+		{":nth-child(2))", 13},
+		{`[title=")"])`, 11},
+		{`\))`, 2},
+		{"(", -1},
+		{"", -1},
+	}
+
+	for _, tt := range tests {
+		if got := closingParen(tt.input); got != tt.want {
+			t.Errorf("closingParen(%q) = %d, want %d", tt.input, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,155 @@
+package services
+
+import (
+	"bufio"
+	"compress/gzip"
+	"encoding/xml"
+	"io"
+	"net/http"
+	"strings"
+)
+
+const (
+	// maxSitemaps limits how many sitemap files are read, including those listed in indexes
+	maxSitemaps = 20
+
+	// maxSitemapEntries limits how many URLs are collected from sitemaps
+	maxSitemapEntries = 10000
+
+	// maxSitemapSize limits how much of a sitemap file is read (the protocol allows 50MB)
+	maxSitemapSize = 50 << 20
+)
+
+// sitemapEntry represents a page listed in a sitemap
+type sitemapEntry struct {
+	Loc        string
+	Alternates []sitemapAlternate
+}
+
+// sitemapAlternate represents an xhtml:link alternate of a sitemap entry
+type sitemapAlternate struct {
+	Hreflang string
+	Href     string
+}
+
+// sitemapEntries returns the pages listed in the website's sitemaps, read once
+// per analysis. Sitemaps are found in robots.txt, falling back to /sitemap.xml.
+func (c *Crawler) sitemapEntries() []sitemapEntry {
+	c.sitemapOnce.Do(func() {
+		queue := c.robotsSitemaps()
+		if len(queue) == 0 {
+			fallback := *c.pageURL
+			fallback.Path, fallback.RawQuery, fallback.Fragment = "/sitemap.xml", "", ""
+			queue = []string{fallback.String()}
+		}
+
+		seen := map[string]bool{}
+		for len(queue) > 0 && len(seen) < maxSitemaps && len(c.sitemap) < maxSitemapEntries {
+			sitemapURL := queue[0]
+			queue = queue[1:]
+			if seen[sitemapURL] {
+				continue
+			}
+			seen[sitemapURL] = true
+
+			entries, children := c.fetchSitemap(sitemapURL)
+			c.sitemap = append(c.sitemap, entries...)
+			queue = append(queue, children...)
+		}
+		if len(c.sitemap) > maxSitemapEntries {
+			c.sitemap = c.sitemap[:maxSitemapEntries]
+		}
+	})
+	return c.sitemap
+}
+
+// robotsSitemaps returns the sitemap URLs declared in robots.txt
+func (c *Crawler) robotsSitemaps() []string {
+	return c.robotsFile().sitemaps
+}
+
+// fetchSitemap reads a sitemap and returns its pages, or the sitemaps listed
+// in it if it is a sitemap index
+func (c *Crawler) fetchSitemap(sitemapURL string) ([]sitemapEntry, []string) {
+	resp, err := c.doAuthenticated(c.linkClient, "GET", sitemapURL)
+	if err != nil {
+		return nil, nil
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, nil
+	}
+
+	// Gzipped sitemaps are recognized by their magic bytes, since the transport
+	// may already have removed a gzip content encoding
+	buffered := bufio.NewReader(io.LimitReader(resp.Body, maxSitemapSize))
+	var body io.Reader = buffered
+	if magic, err := buffered.Peek(2); err == nil && magic[0] == 0x1f && magic[1] == 0x8b {
+		decoded, err := gzip.NewReader(buffered)
+		if err != nil {
+			return nil, nil
+		}
+		body = decoded
+	}
+
+	// Decode the tokens loosely since sitemaps often have namespace mistakes
+	var entries []sitemapEntry
+	var children []string
+	var current *sitemapEntry
+	isIndex := false
+	decoder := xml.NewDecoder(body)
+	decoder.Strict = false
+	for {
+		token, err := decoder.Token()
+		if err != nil {
+			break
+		}
+		start, ok := token.(xml.StartElement)
+		if !ok {
+			if end, ok := token.(xml.EndElement); ok && end.Name.Local == "url" && current != nil {
+				entries = append(entries, *current)
+				current = nil
+			}
+			continue
+		}
+
+		switch start.Name.Local {
+		case "sitemapindex":
+			isIndex = true
+		case "url":
+			current = &sitemapEntry{}
+		case "loc":
+			var loc string
+			if err := decoder.DecodeElement(&loc, &start); err != nil {
+				continue
+			}
+			loc = strings.TrimSpace(loc)
+			if isIndex {
+				children = append(children, loc)
+			} else if current != nil {
+				current.Loc = loc
+			}
+		case "link":
+			if current == nil {
+				continue
+			}
+			alternate := sitemapAlternate{}
+			rel := ""
+			for _, attr := range start.Attr {
+				switch attr.Name.Local {
+				case "rel":
+					rel = attr.Value
+				case "hreflang":
+					alternate.Hreflang = attr.Value
+				case "href":
+					alternate.Href = attr.Value
+				}
+			}
+			if strings.EqualFold(rel, "alternate") && alternate.Hreflang != "" && alternate.Href != "" {
+				current.Alternates = append(current.Alternates, alternate)
+			}
+		}
+	}
+
+	return entries, children
+}
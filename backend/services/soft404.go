@@ -0,0 +1,210 @@
+package services
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"io"
+	"mime"
+	"net/url"
+	"regexp"
+	"strings"
+	"sync"
+
+	"golang.org/x/net/html"
+)
+
+const (
+	// maxSoft404PageSize limits how much of a page is read to compare it
+	maxSoft404PageSize = 512 << 10
+
+	// soft404Similarity is the shingle similarity above which a page matches the not-found page
+	soft404Similarity = 0.9
+
+	// soft404TitleSimilarity is the similarity required when the titles match as well
+	soft404TitleSimilarity = 0.7
+
+	// minSoft404Shingles is the text needed to compare pages. Pages with less
+	// text, like the shells of single-page apps, all look alike.
+	minSoft404Shingles = 10
+)
+
+// Phrases of not-found pages, matched against the title and main heading. A
+// bare "not found" only counts as the whole title or its first part, so
+// titles like "Fixing 'command not found'" aren't taken for not-found pages.
+var notFoundRegex = regexp.MustCompile(`(?i)^404\b|\berror 404\b|\b404 (?:error|page)\b|` +
+	`\b(?:page|404) not found\b|^not found(?:$|\s*[-|:–—])|` +
+	`page (?:does not|doesn't) exist|page (?:is )?no longer available|` +
+	`nicht gefunden|page introuvable|page non trouvée|no encontrada|non trovata|não encontrada|niet gevonden|` +
+	`nie znaleziono|nie została znaleziona|hittades inte|blev ikke fundet|ikke funnet|ei löytynyt|bulunamadı|` +
+	`не найдена|見つかりません|未找到|找不到|찾을 수 없습니다`)
+
+// notFoundFingerprint describes how a host answers requests for pages that do not exist
+type notFoundFingerprint struct {
+	once     sync.Once
+	soft     bool // The host answers nonexistent paths with a success status
+	finalURL string
+	title    string
+	shingles map[string]bool
+}
+
+// fetchedPage is the parsed result of a GET request used for comparisons
+type fetchedPage struct {
+	finalURL string
+	status   int
+	title    string
+	heading  string
+	shingles map[string]bool
+}
+
+// isSoft404 checks if a link that returned a success status is actually a
+// not-found page, by comparing it to the host's response for a random path
+// and looking for not-found phrases in its title and heading. Only links
+// within the website's scope are checked, so third-party hosts aren't probed.
+func (c *Crawler) isSoft404(link string) bool {
+	linkURL, err := url.Parse(link)
+	if err != nil || !c.inScope(linkURL) {
+		return false
+	}
+	page := c.fetchComparablePage(link)
+	if page == nil || page.status >= 300 {
+		return false
+	}
+	if notFoundRegex.MatchString(page.title) || notFoundRegex.MatchString(page.heading) {
+		return true
+	}
+
+	fingerprint := c.notFoundFingerprint(linkURL)
+	if !fingerprint.soft {
+		return false
+	}
+
+	// Links redirected to the same page as a nonexistent path, like the home page
+	if page.finalURL != normalizePageURL(linkURL) && page.finalURL == fingerprint.finalURL {
+		return true
+	}
+	if len(page.shingles) < minSoft404Shingles || len(fingerprint.shingles) < minSoft404Shingles {
+		return false
+	}
+	similarity := jaccardSimilarity(page.shingles, fingerprint.shingles)
+	if similarity >= soft404Similarity {
+		return true
+	}
+	return page.title != "" && page.title == fingerprint.title && similarity >= soft404TitleSimilarity
+}
+
+// notFoundFingerprint probes a random path of the link's host once per
+// analysis to learn what its not-found page looks like
+func (c *Crawler) notFoundFingerprint(linkURL *url.URL) *notFoundFingerprint {
+	key := linkURL.Scheme + "://" + strings.ToLower(linkURL.Host)
+
+	c.mutex.Lock()
+	if c.notFound == nil {
+		c.notFound = map[string]*notFoundFingerprint{}
+	}
+	fingerprint, ok := c.notFound[key]
+	if !ok {
+		fingerprint = &notFoundFingerprint{}
+		c.notFound[key] = fingerprint
+	}
+	c.mutex.Unlock()
+
+	fingerprint.once.Do(func() {
+		random := make([]byte, 16)
+		if _, err := rand.Read(random); err != nil {
+			return
+		}
+		page := c.fetchComparablePage(key + "/" + hex.EncodeToString(random))
+		if page == nil || page.status >= 300 {
+			return
+		}
+		fingerprint.soft = true
+		fingerprint.finalURL = page.finalURL
+		fingerprint.title = page.title
+		fingerprint.shingles = page.shingles
+	})
+	return fingerprint
+}
+
+// fetchComparablePage fetches an HTML page and extracts what soft-404
+// detection compares. It returns nil for failed requests and non-HTML pages.
+func (c *Crawler) fetchComparablePage(rawURL string) *fetchedPage {
+	resp, err := c.doAuthenticated(c.linkClient, "GET", rawURL)
+	if err != nil {
+		return nil
+	}
+	defer resp.Body.Close()
+
+	mediaType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	if mediaType != "text/html" && mediaType != "application/xhtml+xml" {
+		return nil
+	}
+	doc, err := html.Parse(io.LimitReader(resp.Body, maxSoft404PageSize))
+	if err != nil {
+		return nil
+	}
+
+	page := &fetchedPage{
+		finalURL: normalizePageURL(resp.Request.URL),
+		status:   resp.StatusCode,
+		title:    strings.Join(strings.Fields(c.extractTitle(doc)), " "),
+		shingles: shingles(visibleText(doc), 3),
+	}
+	headingSelector, _ := compileSelector("h1")
+	if heading := headingSelector.MatchFirst(doc); heading != nil {
+		page.heading = strings.Join(strings.Fields(textContent(heading)), " ")
+	}
+	return page
+}
+
+// visibleText returns the text of a document outside scripts, styles and templates
+func visibleText(doc *html.Node) string {
+	var sb strings.Builder
+	var collectFunc func(*html.Node)
+	collectFunc = func(n *html.Node) {
+		if n.Type == html.ElementNode {
+			switch n.Data {
+			case "script", "style", "noscript", "template", "head":
+				return
+			}
+		}
+		if n.Type == html.TextNode {
+			sb.WriteString(n.Data)
+			sb.WriteString(" ")
+		}
+		for child := n.FirstChild; child != nil; child = child.NextSibling {
+			collectFunc(child)
+		}
+	}
+	collectFunc(doc)
+	return sb.String()
+}
+
+// shingles returns the set of lowercase word n-grams of a text
+func shingles(text string, size int) map[string]bool {
+	words := strings.Fields(strings.ToLower(text))
+	set := map[string]bool{}
+	if len(words) < size {
+		if len(words) > 0 {
+			set[strings.Join(words, " ")] = true
+		}
+		return set
+	}
+	for i := 0; i+size <= len(words); i++ {
+		set[strings.Join(words[i:i+size], " ")] = true
+	}
+	return set
+}
+
+// jaccardSimilarity returns the share of shingles two sets have in common
+func jaccardSimilarity(a, b map[string]bool) float64 {
+	if len(a) == 0 && len(b) == 0 {
+		return 1
+	}
+	common := 0
+	for shingle := range a {
+		if b[shingle] {
+			common++
+		}
+	}
+	return float64(common) / float64(len(a)+len(b)-common)
+}
@@ -0,0 +1,562 @@
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/sykell/website-analyzer/models"
+	"golang.org/x/net/html"
+)
+
+// Prefixes stripped from types and property names, so items from all formats
+// use the short schema.org names
+var schemaOrgPrefixes = []string{"http://schema.org/", "https://schema.org/", "schema:"}
+
+// structuredDataRule lists the properties a schema.org type needs for rich results
+type structuredDataRule struct {
+	required    []string
+	anyOf       []string // At least one of these properties is required
+	recommended []string
+	check       func(node map[string]interface{}) []string // Checks nested items, returns error messages
+}
+
+// Validation rules for the types with rich results
+var structuredDataRules = map[string]structuredDataRule{
+	"Product": {
+		required:    []string{"name"},
+		anyOf:       []string{"offers", "review", "aggregateRating"},
+		recommended: []string{"image", "description", "brand", "sku"},
+		check:       checkProductOffers,
+	},
+	"Article": {
+		required:    []string{"headline"},
+		recommended: []string{"image", "datePublished", "dateModified", "author"},
+	},
+	"Organization": {
+		required:    []string{"name"},
+		recommended: []string{"url", "logo"},
+	},
+	"BreadcrumbList": {
+		required: []string{"itemListElement"},
+		check:    checkBreadcrumbItems,
+	},
+	"FAQPage": {
+		required: []string{"mainEntity"},
+		check:    checkFAQQuestions,
+	},
+	"LocalBusiness": {
+		required:    []string{"name", "address"},
+		recommended: []string{"telephone", "url", "image", "openingHoursSpecification", "geo"},
+	},
+}
+
+// Subtypes validated with the rules of their parent type
+var structuredDataAliases = map[string]string{
+	"NewsArticle":             "Article",
+	"BlogPosting":             "Article",
+	"TechArticle":             "Article",
+	"Corporation":             "Organization",
+	"NGO":                     "Organization",
+	"Store":                   "LocalBusiness",
+	"Restaurant":              "LocalBusiness",
+	"FoodEstablishment":       "LocalBusiness",
+	"Hotel":                   "LocalBusiness",
+	"LodgingBusiness":         "LocalBusiness",
+	"MedicalBusiness":         "LocalBusiness",
+	"Dentist":                 "LocalBusiness",
+	"AutomotiveBusiness":      "LocalBusiness",
+	"ProfessionalService":     "LocalBusiness",
+	"LegalService":            "LocalBusiness",
+	"HealthAndBeautyBusiness": "LocalBusiness",
+}
+
+// extractStructuredData extracts JSON-LD, Microdata and RDFa items from the
+// document and validates them against the rich result rules
+func (c *Crawler) extractStructuredData(doc *html.Node) []models.StructuredDataItem {
+	items := []models.StructuredDataItem{}
+	items = append(items, c.extractJSONLD(doc)...)
+	items = append(items, c.extractMicrodata(doc)...)
+	items = append(items, c.extractRDFa(doc)...)
+
+	for i := range items {
+		items[i].WebsiteID = c.website.ID
+		validateStructuredItem(&items[i])
+	}
+
+	return items
+}
+
+// newStructuredItem turns a top-level node into an item
+func newStructuredItem(format string, node map[string]interface{}) models.StructuredDataItem {
+	item := models.StructuredDataItem{
+		Format:     format,
+		Types:      nodeTypes(node),
+		Properties: map[string]interface{}{},
+		Errors:     []models.StructuredDataIssue{},
+		Warnings:   []models.StructuredDataIssue{},
+	}
+	if id, ok := node["@id"].(string); ok {
+		item.ItemID = id
+	}
+	for name, value := range node {
+		if name != "@type" && name != "@id" {
+			item.Properties[name] = value
+		}
+	}
+	return item
+}
+
+// extractJSONLD parses the JSON-LD script blocks of the document
+func (c *Crawler) extractJSONLD(doc *html.Node) []models.StructuredDataItem {
+	var items []models.StructuredDataItem
+	selector, _ := compileSelector("script[type]")
+	for _, script := range selector.MatchAll(doc) {
+		if !strings.EqualFold(strings.TrimSpace(getAttr(script, "type")), "application/ld+json") {
+			continue
+		}
+
+		decoder := json.NewDecoder(strings.NewReader(textContent(script)))
+		decoder.UseNumber()
+		var data interface{}
+		if err := decoder.Decode(&data); err != nil {
+			item := newStructuredItem("json-ld", map[string]interface{}{})
+			item.Errors = append(item.Errors, models.StructuredDataIssue{Message: fmt.Sprintf("Invalid JSON-LD: %v", err)})
+			items = append(items, item)
+			continue
+		}
+
+		// A block holds one item, a list of items or a @graph of items
+		var nodes []interface{}
+		switch value := data.(type) {
+		case []interface{}:
+			nodes = value
+		case map[string]interface{}:
+			if graph, ok := value["@graph"].([]interface{}); ok {
+				nodes = graph
+			} else {
+				nodes = []interface{}{value}
+			}
+		}
+		for _, value := range nodes {
+			if node, ok := normalizeJSONLD(value).(map[string]interface{}); ok {
+				items = append(items, newStructuredItem("json-ld", node))
+			}
+		}
+	}
+	return items
+}
+
+// normalizeJSONLD converts a JSON-LD value to the common graph shape
+func normalizeJSONLD(value interface{}) interface{} {
+	switch value := value.(type) {
+	case map[string]interface{}:
+		if scalar, ok := value["@value"]; ok {
+			return normalizeJSONLD(scalar)
+		}
+		if list, ok := value["@list"]; ok {
+			return normalizeJSONLD(list)
+		}
+		if set, ok := value["@set"]; ok {
+			return normalizeJSONLD(set)
+		}
+		node := map[string]interface{}{}
+		for name, property := range value {
+			switch name {
+			case "@context", "@graph":
+			case "@type":
+				var types []string
+				for _, t := range asList(property) {
+					if t, ok := t.(string); ok {
+						types = append(types, shortName(t))
+					}
+				}
+				node["@type"] = types
+			case "@id":
+				node["@id"] = fmt.Sprint(property)
+			default:
+				node[shortName(name)] = normalizeJSONLD(property)
+			}
+		}
+		return node
+	case []interface{}:
+		list := make([]interface{}, 0, len(value))
+		for _, element := range value {
+			list = append(list, normalizeJSONLD(element))
+		}
+		return list
+	case nil:
+		return ""
+	case string:
+		return value
+	}
+	return fmt.Sprint(value)
+}
+
+// extractMicrodata extracts the top-level Microdata items of the document
+func (c *Crawler) extractMicrodata(doc *html.Node) []models.StructuredDataItem {
+	var items []models.StructuredDataItem
+	ids := map[string]*html.Node{}
+	var topLevel []*html.Node
+	var walkFunc func(*html.Node)
+	walkFunc = func(n *html.Node) {
+		if n.Type == html.ElementNode {
+			if id := getAttr(n, "id"); id != "" {
+				ids[id] = n
+			}
+			_, scope := lookupAttr(n, "itemscope")
+			_, prop := lookupAttr(n, "itemprop")
+			if scope && !prop {
+				topLevel = append(topLevel, n)
+			}
+		}
+		for child := n.FirstChild; child != nil; child = child.NextSibling {
+			walkFunc(child)
+		}
+	}
+	walkFunc(doc)
+
+	for _, n := range topLevel {
+		items = append(items, newStructuredItem("microdata", c.microdataNode(n, ids, map[*html.Node]bool{})))
+	}
+	return items
+}
+
+// microdataNode builds the node of an itemscope element, including the
+// properties of the elements it references with itemref
+func (c *Crawler) microdataNode(scope *html.Node, ids map[string]*html.Node, visited map[*html.Node]bool) map[string]interface{} {
+	visited[scope] = true
+	node := map[string]interface{}{}
+	var types []string
+	for _, t := range strings.Fields(getAttr(scope, "itemtype")) {
+		types = append(types, shortName(t))
+	}
+	node["@type"] = types
+	if id := getAttr(scope, "itemid"); id != "" {
+		node["@id"] = id
+	}
+
+	var collectFunc func(n *html.Node)
+	addFunc := func(n *html.Node) {
+		if names, ok := lookupAttr(n, "itemprop"); ok && !visited[n] {
+			var value interface{}
+			if _, nested := lookupAttr(n, "itemscope"); nested {
+				value = c.microdataNode(n, ids, visited)
+			} else {
+				value = c.structuredValue(n, "")
+			}
+			for _, name := range strings.Fields(names) {
+				addStructuredProperty(node, shortName(name), value)
+			}
+		}
+		// Properties of nested items belong to them
+		if _, nested := lookupAttr(n, "itemscope"); !nested {
+			collectFunc(n)
+		}
+	}
+	collectFunc = func(n *html.Node) {
+		for child := n.FirstChild; child != nil; child = child.NextSibling {
+			if child.Type == html.ElementNode {
+				addFunc(child)
+			}
+		}
+	}
+	collectFunc(scope)
+	for _, ref := range strings.Fields(getAttr(scope, "itemref")) {
+		if referenced, ok := ids[ref]; ok && !visited[referenced] {
+			addFunc(referenced)
+		}
+	}
+
+	return node
+}
+
+// extractRDFa extracts the top-level RDFa items of the document
+func (c *Crawler) extractRDFa(doc *html.Node) []models.StructuredDataItem {
+	var items []models.StructuredDataItem
+	var walkFunc func(*html.Node)
+	walkFunc = func(n *html.Node) {
+		if n.Type == html.ElementNode {
+			_, typed := lookupAttr(n, "typeof")
+			_, prop := lookupAttr(n, "property")
+			if typed && !prop {
+				items = append(items, newStructuredItem("rdfa", c.rdfaNode(n)))
+			}
+		}
+		for child := n.FirstChild; child != nil; child = child.NextSibling {
+			walkFunc(child)
+		}
+	}
+	walkFunc(doc)
+	return items
+}
+
+// rdfaNode builds the node of an element with a typeof attribute
+func (c *Crawler) rdfaNode(scope *html.Node) map[string]interface{} {
+	node := map[string]interface{}{}
+	var types []string
+	for _, t := range strings.Fields(getAttr(scope, "typeof")) {
+		types = append(types, shortName(t))
+	}
+	node["@type"] = types
+	if id := getAttr(scope, "resource"); id != "" {
+		node["@id"] = id
+	} else if id := getAttr(scope, "about"); id != "" {
+		node["@id"] = id
+	}
+
+	var collectFunc func(n *html.Node)
+	collectFunc = func(n *html.Node) {
+		for child := n.FirstChild; child != nil; child = child.NextSibling {
+			if child.Type != html.ElementNode {
+				continue
+			}
+			_, typed := lookupAttr(child, "typeof")
+			if names, ok := lookupAttr(child, "property"); ok {
+				var value interface{}
+				if typed {
+					value = c.rdfaNode(child)
+				} else {
+					value = c.structuredValue(child, "resource")
+				}
+				for _, name := range strings.Fields(names) {
+					addStructuredProperty(node, shortName(name), value)
+				}
+			}
+			// Properties of nested items belong to them
+			if !typed {
+				collectFunc(child)
+			}
+		}
+	}
+	collectFunc(scope)
+
+	return node
+}
+
+// structuredValue returns the value of a Microdata or RDFa property element.
+// Links are resolved against the page URL; uriAttr names the attribute
+// holding an explicit link in the format, if it has one.
+func (c *Crawler) structuredValue(n *html.Node, uriAttr string) string {
+	if content, ok := lookupAttr(n, "content"); ok {
+		return content
+	}
+	if value, ok := lookupAttr(n, uriAttr); ok && uriAttr != "" {
+		return c.resolveStructuredURL(value)
+	}
+	switch n.Data {
+	case "audio", "embed", "iframe", "img", "source", "track", "video":
+		return c.resolveStructuredURL(getAttr(n, "src"))
+	case "a", "area", "link":
+		return c.resolveStructuredURL(getAttr(n, "href"))
+	case "object":
+		return c.resolveStructuredURL(getAttr(n, "data"))
+	case "data", "meter":
+		return getAttr(n, "value")
+	case "time":
+		if datetime, ok := lookupAttr(n, "datetime"); ok {
+			return datetime
+		}
+	}
+	return strings.Join(strings.Fields(textContent(n)), " ")
+}
+
+// resolveStructuredURL resolves a URL value against the page URL
+func (c *Crawler) resolveStructuredURL(rawURL string) string {
+	resolved, err := c.pageURL.Parse(strings.TrimSpace(rawURL))
+	if err != nil {
+		return rawURL
+	}
+	return resolved.String()
+}
+
+// addStructuredProperty adds a value to a node, turning repeated properties into lists
+func addStructuredProperty(node map[string]interface{}, name string, value interface{}) {
+	existing, ok := node[name]
+	if !ok {
+		node[name] = value
+		return
+	}
+	if list, ok := existing.([]interface{}); ok {
+		node[name] = append(list, value)
+		return
+	}
+	node[name] = []interface{}{existing, value}
+}
+
+// shortName strips the schema.org prefix from a type or property name
+func shortName(name string) string {
+	for _, prefix := range schemaOrgPrefixes {
+		if strings.HasPrefix(name, prefix) {
+			return strings.TrimPrefix(name, prefix)
+		}
+	}
+	return name
+}
+
+// validateStructuredItem checks an item against the rules of its types
+func validateStructuredItem(item *models.StructuredDataItem) {
+	node := item.Properties
+	validated := false
+	for _, t := range item.Types {
+		ruleName := t
+		if alias, ok := structuredDataAliases[t]; ok {
+			ruleName = alias
+		}
+		rule, ok := structuredDataRules[ruleName]
+		if !ok {
+			continue
+		}
+		validated = true
+
+		for _, property := range rule.required {
+			if !hasStructuredProperty(node, property) {
+				item.Errors = append(item.Errors, models.StructuredDataIssue{
+					Type: t, Property: property, Message: fmt.Sprintf("Missing required property %s", property),
+				})
+			}
+		}
+		if len(rule.anyOf) > 0 {
+			found := false
+			for _, property := range rule.anyOf {
+				found = found || hasStructuredProperty(node, property)
+			}
+			if !found {
+				item.Errors = append(item.Errors, models.StructuredDataIssue{
+					Type: t, Message: fmt.Sprintf("One of %s is required", strings.Join(rule.anyOf, ", ")),
+				})
+			}
+		}
+		for _, property := range rule.recommended {
+			if !hasStructuredProperty(node, property) {
+				item.Warnings = append(item.Warnings, models.StructuredDataIssue{
+					Type: t, Property: property, Message: fmt.Sprintf("Missing recommended property %s", property),
+				})
+			}
+		}
+		if rule.check != nil {
+			for _, message := range rule.check(node) {
+				item.Errors = append(item.Errors, models.StructuredDataIssue{Type: t, Message: message})
+			}
+		}
+	}
+
+	item.RichResultEligible = validated && len(item.Errors) == 0
+}
+
+// checkProductOffers checks that every offer of a product has a price
+func checkProductOffers(node map[string]interface{}) []string {
+	var messages []string
+	for i, offer := range structuredNodes(node["offers"]) {
+		if containsString(nodeTypes(offer), "AggregateOffer") {
+			if !hasStructuredProperty(offer, "lowPrice") {
+				messages = append(messages, fmt.Sprintf("offers[%d] is missing lowPrice", i))
+			}
+			continue
+		}
+		if !hasStructuredProperty(offer, "price") && !hasStructuredProperty(offer, "priceSpecification") {
+			messages = append(messages, fmt.Sprintf("offers[%d] is missing price", i))
+		}
+	}
+	return messages
+}
+
+// checkBreadcrumbItems checks the position, name and target of every breadcrumb
+func checkBreadcrumbItems(node map[string]interface{}) []string {
+	var messages []string
+	elements := structuredNodes(node["itemListElement"])
+	for i, element := range elements {
+		if !hasStructuredProperty(element, "position") {
+			messages = append(messages, fmt.Sprintf("itemListElement[%d] is missing position", i))
+		}
+		target := structuredNodes(element["item"])
+		if !hasStructuredProperty(element, "name") && (len(target) == 0 || !hasStructuredProperty(target[0], "name")) {
+			messages = append(messages, fmt.Sprintf("itemListElement[%d] is missing name", i))
+		}
+		// The last breadcrumb may omit its URL since it is the current page
+		if i < len(elements)-1 && !hasStructuredProperty(element, "item") {
+			messages = append(messages, fmt.Sprintf("itemListElement[%d] is missing item", i))
+		}
+	}
+	return messages
+}
+
+// checkFAQQuestions checks that every question has a name and an accepted answer with text
+func checkFAQQuestions(node map[string]interface{}) []string {
+	var messages []string
+	for i, question := range structuredNodes(node["mainEntity"]) {
+		if !hasStructuredProperty(question, "name") {
+			messages = append(messages, fmt.Sprintf("mainEntity[%d] is missing name", i))
+		}
+		answers := structuredNodes(question["acceptedAnswer"])
+		if len(answers) == 0 {
+			messages = append(messages, fmt.Sprintf("mainEntity[%d] is missing acceptedAnswer", i))
+		} else if !hasStructuredProperty(answers[0], "text") {
+			messages = append(messages, fmt.Sprintf("mainEntity[%d].acceptedAnswer is missing text", i))
+		}
+	}
+	return messages
+}
+
+// hasStructuredProperty checks if a node has a non-empty value for a property
+func hasStructuredProperty(node map[string]interface{}, name string) bool {
+	for _, value := range asList(node[name]) {
+		switch value := value.(type) {
+		case string:
+			if strings.TrimSpace(value) != "" {
+				return true
+			}
+		case map[string]interface{}:
+			return true
+		}
+	}
+	return false
+}
+
+// structuredNodes returns the nested items of a property value
+func structuredNodes(value interface{}) []map[string]interface{} {
+	var nodes []map[string]interface{}
+	for _, element := range asList(value) {
+		if node, ok := element.(map[string]interface{}); ok {
+			nodes = append(nodes, node)
+		}
+	}
+	return nodes
+}
+
+// nodeTypes returns the types of a node
+func nodeTypes(node map[string]interface{}) []string {
+	types := []string{}
+	switch value := node["@type"].(type) {
+	case []string:
+		types = append(types, value...)
+	case string:
+		types = append(types, value)
+	case []interface{}:
+		for _, t := range value {
+			if t, ok := t.(string); ok {
+				types = append(types, t)
+			}
+		}
+	}
+	sort.Strings(types)
+	return types
+}
+
+// asList returns a property value as a list
+func asList(value interface{}) []interface{} {
+	switch value := value.(type) {
+	case nil:
+		return nil
+	case []interface{}:
+		return value
+	case []string:
+		list := make([]interface{}, len(value))
+		for i, element := range value {
+			list[i] = element
+		}
+		return list
+	}
+	return []interface{}{value}
+}
@@ -0,0 +1,296 @@
+package services
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/sykell/website-analyzer/models"
+	"golang.org/x/net/html"
+)
+
+// extractTestItems extracts the structured data of a page served at https://shop.test/products/
+func extractTestItems(t *testing.T, page string) []models.StructuredDataItem {
+	t.Helper()
+	doc, err := html.Parse(strings.NewReader(page))
+	if err != nil {
+		t.Fatalf("parsing the page failed: %v", err)
+	}
+	crawler := newTestCrawler(t, "https://shop.test/products/", &models.CrawlSettings{})
+	return crawler.extractStructuredData(doc)
+}
+
+func TestExtractJSONLD(t *testing.T) {
+	tests := []struct {
+		name  string
+		page  string
+		types [][]string
+	}{
+		{
+			"single item",
+			`<script type="application/ld+json">{"@context": "https://schema.org", "@type": "Organization", "name": "Shop"}</script>`,
+			[][]string{{"Organization"}},
+		},
+		{
+			"list of items",
+			`<script type=" Application/LD+JSON ">[{"@type": "Organization"}, {"@type": ["Product", "schema:Thing"]}]</script>`,
+			[][]string{{"Organization"}, {"Product", "Thing"}},
+		},
+		{
+			"graph",
+			`<script type="application/ld+json">{"@context": "https://schema.org", "@graph": [{"@type": "https://schema.org/Article"}, {"@type": "WebSite"}]}</script>`,
+			[][]string{{"Article"}, {"WebSite"}},
+		},
+		{
+			"other script types are ignored",
+			`<script type="application/json">{"@type": "Organization"}</script><script>var a = 1;</script>`,
+			nil,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			items := extractTestItems(t, tt.page)
+			var types [][]string
+			for _, item := range items {
+				if item.Format != "json-ld" || item.WebsiteID != 1 {
+					t.Errorf("item has format %q and website %d, want json-ld and 1", item.Format, item.WebsiteID)
+				}
+				types = append(types, item.Types)
+			}
+			if !reflect.DeepEqual(types, tt.types) {
+				t.Errorf("types = %v, want %v", types, tt.types)
+			}
+		})
+	}
+}
+
+func TestExtractJSONLDValues(t *testing.T) {
+	items := extractTestItems(t, `<script type="application/ld+json">{
+		"@type": "Product",
+		"@id": "#product",
+		"http://schema.org/name": {"@value": "Lamp"},
+		"sku": 1234,
+		"gtin": null,
+		"image": {"@list": ["a.jpg", "b.jpg"]},
+		"offers": {"@type": "Offer", "price": 9.5}
+	}</script>`)
+	if len(items) != 1 {
+		t.Fatalf("got %d items, want 1", len(items))
+	}
+	item := items[0]
+
+	if item.ItemID != "#product" {
+		t.Errorf("item ID = %q, want #product", item.ItemID)
+	}
+	want := map[string]interface{}{
+		"name":   "Lamp",
+		"sku":    "1234",
+		"gtin":   "",
+		"image":  []interface{}{"a.jpg", "b.jpg"},
+		"offers": map[string]interface{}{"@type": []string{"Offer"}, "price": "9.5"},
+	}
+	if !reflect.DeepEqual(item.Properties, want) {
+		t.Errorf("properties = %#v, want %#v", item.Properties, want)
+	}
+}
+
+func TestExtractJSONLDInvalid(t *testing.T) {
+	items := extractTestItems(t, `<script type="application/ld+json">{"@type": "Organization",}</script>`)
+	if len(items) != 1 {
+		t.Fatalf("got %d items, want 1", len(items))
+	}
+	item := items[0]
+	if len(item.Errors) != 1 || !strings.HasPrefix(item.Errors[0].Message, "Invalid JSON-LD: ") {
+		t.Errorf("errors = %v, want the JSON error", item.Errors)
+	}
+	if item.RichResultEligible {
+		t.Error("an invalid block is eligible for rich results")
+	}
+}
+
+func TestExtractMicrodata(t *testing.T) {
+	items := extractTestItems(t, `<html><body>
+		<div itemscope itemtype="https://schema.org/Product" itemid="urn:sku:1" itemref="price-info">
+			<h1 itemprop="name">  Desk
+				Lamp </h1>
+			<img itemprop="image" src="../lamp.jpg">
+			<a itemprop="url sameAs" href="lamp">Lamp</a>
+			<div itemprop="brand" itemscope itemtype="https://schema.org/Brand">
+				<span itemprop="name">Acme</span>
+			</div>
+			<time itemprop="releaseDate" datetime="2024-05-01">May</time>
+		</div>
+		<div id="price-info" itemprop="offers" itemscope itemtype="https://schema.org/Offer">
+			<meta itemprop="price" content="19.99">
+			<data itemprop="priceCurrency" value="EUR">Euro</data>
+		</div>
+	</body></html>`)
+	if len(items) != 1 {
+		t.Fatalf("got %d items, want the product only", len(items))
+	}
+	item := items[0]
+
+	if item.Format != "microdata" || !reflect.DeepEqual(item.Types, []string{"Product"}) || item.ItemID != "urn:sku:1" {
+		t.Errorf("item is %s %v with ID %q, want a microdata Product with ID urn:sku:1", item.Format, item.Types, item.ItemID)
+	}
+	want := map[string]interface{}{
+		"name":        "Desk Lamp",
+		"image":       "https://shop.test/lamp.jpg",
+		"url":         "https://shop.test/products/lamp",
+		"sameAs":      "https://shop.test/products/lamp",
+		"brand":       map[string]interface{}{"@type": []string{"Brand"}, "name": "Acme"},
+		"releaseDate": "2024-05-01",
+		"offers":      map[string]interface{}{"@type": []string{"Offer"}, "price": "19.99", "priceCurrency": "EUR"},
+	}
+	if !reflect.DeepEqual(item.Properties, want) {
+		t.Errorf("properties = %#v, want %#v", item.Properties, want)
+	}
+}
+
+func TestExtractMicrodataRepeatedAndCyclic(t *testing.T) {
+	items := extractTestItems(t, `<html><body>
+		<div id="a" itemscope itemtype="https://schema.org/Organization" itemref="a b">
+			<span itemprop="name">Acme</span>
+		</div>
+		<p id="b"><span itemprop="telephone">1</span><span itemprop="telephone">2</span></p>
+	</body></html>`)
+	if len(items) != 1 {
+		t.Fatalf("got %d items, want 1", len(items))
+	}
+	want := map[string]interface{}{"name": "Acme", "telephone": []interface{}{"1", "2"}}
+	if !reflect.DeepEqual(items[0].Properties, want) {
+		t.Errorf("properties = %#v, want %#v", items[0].Properties, want)
+	}
+}
+
+func TestExtractRDFa(t *testing.T) {
+	items := extractTestItems(t, `<html><body>
+		<div vocab="https://schema.org/" typeof="schema:BreadcrumbList" about="#crumbs">
+			<span property="itemListElement" typeof="ListItem">
+				<a property="item" href="/">
+					<span property="name">Home</span>
+				</a>
+				<meta property="position" content="1">
+			</span>
+			<span property="itemListElement" typeof="ListItem">
+				<span property="item" resource="lamps">Lamps</span>
+				<span property="name">Lamps</span>
+				<meta property="position" content="2">
+			</span>
+		</div>
+	</body></html>`)
+	if len(items) != 1 {
+		t.Fatalf("got %d items, want the breadcrumb list only", len(items))
+	}
+	item := items[0]
+
+	if item.Format != "rdfa" || !reflect.DeepEqual(item.Types, []string{"BreadcrumbList"}) || item.ItemID != "#crumbs" {
+		t.Errorf("item is %s %v with ID %q, want an RDFa BreadcrumbList with ID #crumbs", item.Format, item.Types, item.ItemID)
+	}
+	want := map[string]interface{}{
+		"itemListElement": []interface{}{
+			map[string]interface{}{"@type": []string{"ListItem"}, "item": "https://shop.test/", "name": "Home", "position": "1"},
+			map[string]interface{}{"@type": []string{"ListItem"}, "item": "https://shop.test/products/lamps", "name": "Lamps", "position": "2"},
+		},
+	}
+	if !reflect.DeepEqual(item.Properties, want) {
+		t.Errorf("properties = %#v, want %#v", item.Properties, want)
+	}
+	if len(item.Errors) != 0 || !item.RichResultEligible {
+		t.Errorf("errors = %v, want a valid breadcrumb list", item.Errors)
+	}
+}
+
+func TestValidateStructuredItem(t *testing.T) {
+	tests := []struct {
+		name     string
+		types    []string
+		props    string
+		errors   []string
+		warnings []string
+		eligible bool
+	}{
+		{
+			"complete product",
+			[]string{"Product"},
+			`{"name": "Lamp", "image": "a.jpg", "description": "A lamp", "brand": "Acme", "sku": "1",
+				"offers": [{"@type": "Offer", "price": "9"}, {"@type": "AggregateOffer", "lowPrice": "5"}]}`,
+			nil, nil, true,
+		},
+		{
+			"product without offers",
+			[]string{"Product"},
+			`{"name": " ", "image": "a.jpg", "description": "A lamp", "brand": "Acme", "sku": "1"}`,
+			[]string{"Missing required property name", "One of offers, review, aggregateRating is required"},
+			nil, false,
+		},
+		{
+			"offers without prices",
+			[]string{"Product"},
+			`{"name": "Lamp", "image": "a.jpg", "description": "A lamp", "brand": "Acme", "sku": "1",
+				"offers": [{"@type": "Offer"}, {"@type": "AggregateOffer", "highPrice": "5"}]}`,
+			[]string{"offers[0] is missing price", "offers[1] is missing lowPrice"},
+			nil, false,
+		},
+		{
+			"article subtype with warnings",
+			[]string{"BlogPosting"},
+			`{"headline": "News", "author": {"@type": "Person", "name": "Kim"}}`,
+			nil, []string{"Missing recommended property image", "Missing recommended property datePublished", "Missing recommended property dateModified"},
+			true,
+		},
+		{
+			"breadcrumbs",
+			[]string{"BreadcrumbList"},
+			`{"itemListElement": [{"position": "1", "item": {"name": "Home"}}, {"name": "Shop"}, {"position": "3", "name": "Lamp"}]}`,
+			[]string{"itemListElement[1] is missing position", "itemListElement[1] is missing item"},
+			nil, false,
+		},
+		{
+			"faq",
+			[]string{"FAQPage"},
+			`{"mainEntity": [{"name": "Why?", "acceptedAnswer": {"text": "Because"}}, {"acceptedAnswer": {}}, {"name": "How?"}]}`,
+			[]string{"mainEntity[1] is missing name", "mainEntity[1].acceptedAnswer is missing text", "mainEntity[2] is missing acceptedAnswer"},
+			nil, false,
+		},
+		{
+			"type without rules",
+			[]string{"WebSite"},
+			`{}`,
+			nil, nil, false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			items := extractTestItems(t, `<script type="application/ld+json">`+tt.props+`</script>`)
+			if len(items) != 1 {
+				t.Fatalf("got %d items, want 1", len(items))
+			}
+			// Validate the parsed properties again under the types of the case
+			item := items[0]
+			item.Types = tt.types
+			item.Errors = []models.StructuredDataIssue{}
+			item.Warnings = []models.StructuredDataIssue{}
+			validateStructuredItem(&item)
+
+			var errors, warnings []string
+			for _, issue := range item.Errors {
+				errors = append(errors, issue.Message)
+			}
+			for _, issue := range item.Warnings {
+				warnings = append(warnings, issue.Message)
+			}
+			if !reflect.DeepEqual(errors, tt.errors) {
+				t.Errorf("errors = %q, want %q", errors, tt.errors)
+			}
+			if !reflect.DeepEqual(warnings, tt.warnings) {
+				t.Errorf("warnings = %q, want %q", warnings, tt.warnings)
+			}
+			if item.RichResultEligible != tt.eligible {
+				t.Errorf("eligible = %v, want %v", item.RichResultEligible, tt.eligible)
+			}
+		})
+	}
+}
@@ -0,0 +1,298 @@
+package services
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/sykell/website-analyzer/models"
+	"golang.org/x/net/html"
+)
+
+// Bundled technology fingerprints. More rules can be loaded at runtime from
+// the JSON file named by TECHNOLOGY_RULES_PATH, without recompiling.
+//
+//go:embed rules/technologies.json
+var bundledTechnologyRules []byte
+
+// technologyRule describes how to recognize a technology. All patterns are
+// regular expressions; an empty pattern only checks presence, and the first
+// capture group of a matching pattern, if any, is taken as the version.
+type technologyRule struct {
+	Name     string            `json:"name"`
+	Category string            `json:"category"`
+	Implies  []string          `json:"implies"`
+	Headers  map[string]string `json:"headers"`
+	Cookies  map[string]string `json:"cookies"` // A trailing * matches cookie name prefixes
+	Meta     map[string]string `json:"meta"`
+	Scripts  []string          `json:"scripts"`
+	HTML     []string          `json:"html"`
+	DOM      []string          `json:"dom"` // CSS selectors
+
+	headers map[string]*regexp.Regexp
+	cookies map[string]*regexp.Regexp
+	meta    map[string]*regexp.Regexp
+	scripts []*regexp.Regexp
+	html    []*regexp.Regexp
+	dom     []*cssSelector
+}
+
+var (
+	technologyRules     []*technologyRule
+	technologyRulesOnce sync.Once
+)
+
+// loadTechnologyRules compiles the bundled rules and any rules from TECHNOLOGY_RULES_PATH.
+// A rule from the file replaces a bundled rule with the same name. A file that
+// can't be read or has invalid rules is logged and only the bundled rules are used.
+func loadTechnologyRules() []*technologyRule {
+	technologyRulesOnce.Do(func() {
+		rules, err := parseTechnologyRules(bundledTechnologyRules)
+		if err != nil {
+			log.Printf("Invalid bundled technology rules: %v", err)
+		}
+
+		if path := os.Getenv("TECHNOLOGY_RULES_PATH"); path != "" {
+			extra, err := readTechnologyRules(path)
+			if err != nil {
+				log.Printf("Ignoring technology rules from %s: %v", path, err)
+			} else {
+				rules = mergeTechnologyRules(rules, extra)
+			}
+		}
+
+		technologyRules = rules
+		log.Printf("Loaded %d technology rules", len(rules))
+	})
+	return technologyRules
+}
+
+// readTechnologyRules reads and compiles the rules of a JSON file
+func readTechnologyRules(path string) ([]*technologyRule, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return parseTechnologyRules(data)
+}
+
+// parseTechnologyRules decodes and compiles a JSON list of rules
+func parseTechnologyRules(data []byte) ([]*technologyRule, error) {
+	var rules []*technologyRule
+	if err := json.Unmarshal(data, &rules); err != nil {
+		return nil, err
+	}
+	for _, rule := range rules {
+		if err := rule.compile(); err != nil {
+			return nil, err
+		}
+	}
+	return rules, nil
+}
+
+// mergeTechnologyRules adds extra rules, replacing rules with the same name
+func mergeTechnologyRules(rules, extra []*technologyRule) []*technologyRule {
+	index := map[string]int{}
+	for i, rule := range rules {
+		index[rule.Name] = i
+	}
+	for _, rule := range extra {
+		if i, ok := index[rule.Name]; ok {
+			rules[i] = rule
+			continue
+		}
+		index[rule.Name] = len(rules)
+		rules = append(rules, rule)
+	}
+	return rules
+}
+
+// compile compiles the rule's patterns
+func (r *technologyRule) compile() error {
+	compileMap := func(patterns map[string]string) (map[string]*regexp.Regexp, error) {
+		compiled := map[string]*regexp.Regexp{}
+		for key, pattern := range patterns {
+			re, err := regexp.Compile("(?i)" + pattern)
+			if err != nil {
+				return nil, err
+			}
+			compiled[key] = re
+		}
+		return compiled, nil
+	}
+	compileList := func(patterns []string) ([]*regexp.Regexp, error) {
+		var compiled []*regexp.Regexp
+		for _, pattern := range patterns {
+			re, err := regexp.Compile("(?i)" + pattern)
+			if err != nil {
+				return nil, err
+			}
+			compiled = append(compiled, re)
+		}
+		return compiled, nil
+	}
+
+	var err error
+	if r.headers, err = compileMap(r.Headers); err != nil {
+		return fmt.Errorf("invalid header pattern for %s: %w", r.Name, err)
+	}
+	if r.cookies, err = compileMap(r.Cookies); err != nil {
+		return fmt.Errorf("invalid cookie pattern for %s: %w", r.Name, err)
+	}
+	if r.meta, err = compileMap(r.Meta); err != nil {
+		return fmt.Errorf("invalid meta pattern for %s: %w", r.Name, err)
+	}
+	if r.scripts, err = compileList(r.Scripts); err != nil {
+		return fmt.Errorf("invalid script pattern for %s: %w", r.Name, err)
+	}
+	if r.html, err = compileList(r.HTML); err != nil {
+		return fmt.Errorf("invalid html pattern for %s: %w", r.Name, err)
+	}
+	for _, selector := range r.DOM {
+		compiled, err := compileSelector(selector)
+		if err != nil {
+			return fmt.Errorf("invalid dom selector for %s: %w", r.Name, err)
+		}
+		r.dom = append(r.dom, compiled)
+	}
+	return nil
+}
+
+// fingerprintSignals holds everything the rules are matched against
+type fingerprintSignals struct {
+	headers http.Header
+	cookies map[string]string
+	meta    map[string][]string
+	scripts []string
+	html    string
+	doc     *html.Node
+}
+
+// detectTechnologies identifies the site's stack from the page response and document
+func (c *Crawler) detectTechnologies(resp *http.Response, doc *html.Node, htmlContent string) []models.Technology {
+	rules := loadTechnologyRules()
+
+	signals := &fingerprintSignals{
+		headers: resp.Header,
+		cookies: map[string]string{},
+		meta:    map[string][]string{},
+		html:    htmlContent,
+		doc:     doc,
+	}
+	for _, cookie := range c.httpClient.Jar.Cookies(c.pageURL) {
+		signals.cookies[cookie.Name] = cookie.Value
+	}
+	for _, cookie := range resp.Cookies() {
+		signals.cookies[cookie.Name] = cookie.Value
+	}
+	for _, resource := range c.collectResources(doc) {
+		if resource.Kind == "script" {
+			signals.scripts = append(signals.scripts, resource.URL.String())
+		}
+	}
+	metaSelector, _ := compileSelector("meta[name], meta[property]")
+	for _, meta := range metaSelector.MatchAll(doc) {
+		name := getAttr(meta, "name")
+		if name == "" {
+			name = getAttr(meta, "property")
+		}
+		name = strings.ToLower(name)
+		signals.meta[name] = append(signals.meta[name], getAttr(meta, "content"))
+	}
+
+	// Match every rule, then add the technologies they imply
+	detected := map[string]*models.Technology{}
+	byName := map[string]*technologyRule{}
+	for _, rule := range rules {
+		byName[rule.Name] = rule
+		if version, ok := rule.match(signals); ok {
+			detected[rule.Name] = &models.Technology{
+				WebsiteID: c.website.ID,
+				Name:      rule.Name,
+				Category:  rule.Category,
+				Version:   version,
+			}
+		}
+	}
+	var addImplied func(rule *technologyRule)
+	addImplied = func(rule *technologyRule) {
+		for _, name := range rule.Implies {
+			implied, ok := byName[name]
+			if !ok || detected[name] != nil {
+				continue
+			}
+			detected[name] = &models.Technology{WebsiteID: c.website.ID, Name: implied.Name, Category: implied.Category}
+			addImplied(implied)
+		}
+	}
+	for name := range detected {
+		addImplied(byName[name])
+	}
+
+	technologies := []models.Technology{}
+	for _, technology := range detected {
+		technologies = append(technologies, *technology)
+	}
+	sort.Slice(technologies, func(i, j int) bool {
+		if technologies[i].Category != technologies[j].Category {
+			return technologies[i].Category < technologies[j].Category
+		}
+		return technologies[i].Name < technologies[j].Name
+	})
+
+	return technologies
+}
+
+// match checks the rule against the signals and returns the detected version
+func (r *technologyRule) match(signals *fingerprintSignals) (string, bool) {
+	matched := false
+	version := ""
+	check := func(re *regexp.Regexp, value string) {
+		if groups := re.FindStringSubmatch(value); groups != nil {
+			matched = true
+			if version == "" && len(groups) > 1 {
+				version = groups[1]
+			}
+		}
+	}
+
+	for name, re := range r.headers {
+		for _, value := range signals.headers.Values(name) {
+			check(re, value)
+		}
+	}
+	for name, re := range r.cookies {
+		for cookieName, value := range signals.cookies {
+			if cookieName == name || (strings.HasSuffix(name, "*") && strings.HasPrefix(cookieName, strings.TrimSuffix(name, "*"))) {
+				check(re, value)
+			}
+		}
+	}
+	for name, re := range r.meta {
+		for _, value := range signals.meta[strings.ToLower(name)] {
+			check(re, value)
+		}
+	}
+	for _, re := range r.scripts {
+		for _, script := range signals.scripts {
+			check(re, script)
+		}
+	}
+	for _, re := range r.html {
+		check(re, signals.html)
+	}
+	for _, selector := range r.dom {
+		if selector.MatchFirst(signals.doc) != nil {
+			matched = true
+		}
+	}
+
+	return version, matched
+}
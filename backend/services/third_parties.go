@@ -0,0 +1,183 @@
+package services
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sykell/website-analyzer/models"
+	"golang.org/x/net/publicsuffix"
+)
+
+// Bundled list of known tracker and ad network domains
+//
+//go:embed rules/trackers.json
+var bundledTrackers []byte
+
+// trackerInfo describes a known tracker domain
+type trackerInfo struct {
+	Name     string `json:"name"`
+	Category string `json:"category"`
+}
+
+var (
+	trackers     map[string]trackerInfo
+	trackersErr  error
+	trackersOnce sync.Once
+)
+
+// loadTrackers parses the bundled tracker list once
+func loadTrackers() (map[string]trackerInfo, error) {
+	trackersOnce.Do(func() {
+		if err := json.Unmarshal(bundledTrackers, &trackers); err != nil {
+			trackersErr = fmt.Errorf("invalid bundled tracker list: %w", err)
+		}
+	})
+	return trackers, trackersErr
+}
+
+// lookupTracker finds the most specific tracker entry matching a host
+func lookupTracker(list map[string]trackerInfo, host string) (trackerInfo, bool) {
+	for host != "" {
+		if tracker, ok := list[host]; ok {
+			return tracker, true
+		}
+		dot := strings.Index(host, ".")
+		if dot < 0 {
+			break
+		}
+		host = host[dot+1:]
+	}
+	return trackerInfo{}, false
+}
+
+// registrableDomain returns the eTLD+1 of a host, or the host itself for IPs and bare suffixes
+func registrableDomain(host string) string {
+	host = strings.TrimSuffix(strings.ToLower(host), ".")
+	domain, err := publicsuffix.EffectiveTLDPlusOne(host)
+	if err != nil {
+		return host
+	}
+	return domain
+}
+
+// analyzeThirdParties groups the page's third-party subresources by
+// registrable domain and collects the cookies set by the page and its resources
+func (c *Crawler) analyzeThirdParties(resp *http.Response, assets []*assetResponse) ([]models.ThirdPartyDomain, []models.PageCookie, error) {
+	list, err := loadTrackers()
+	if err != nil {
+		return nil, nil, err
+	}
+	siteDomain := registrableDomain(c.pageURL.Hostname())
+
+	// Group the resources by registrable domain
+	byDomain := map[string]*models.ThirdPartyDomain{}
+	for _, asset := range assets {
+		assetURL, err := url.Parse(asset.URL)
+		if err != nil {
+			continue
+		}
+		host := strings.ToLower(assetURL.Hostname())
+		domain := registrableDomain(host)
+		if domain == siteDomain {
+			continue
+		}
+
+		entry, ok := byDomain[domain]
+		if !ok {
+			entry = &models.ThirdPartyDomain{
+				WebsiteID:     c.website.ID,
+				Domain:        truncateString(domain, 255),
+				Hosts:         []string{},
+				ResourceTypes: []string{},
+			}
+			byDomain[domain] = entry
+		}
+		entry.RequestCount++
+		if !containsString(entry.Hosts, host) {
+			entry.Hosts = append(entry.Hosts, host)
+		}
+		if !containsString(entry.ResourceTypes, asset.Kind) {
+			entry.ResourceTypes = append(entry.ResourceTypes, asset.Kind)
+		}
+		if !entry.IsTracker {
+			if tracker, ok := lookupTracker(list, host); ok {
+				entry.IsTracker = true
+				entry.TrackerName = tracker.Name
+				entry.TrackerCategory = tracker.Category
+			}
+		}
+	}
+
+	domains := []models.ThirdPartyDomain{}
+	for _, entry := range byDomain {
+		sort.Strings(entry.Hosts)
+		sort.Strings(entry.ResourceTypes)
+		domains = append(domains, *entry)
+	}
+	sort.Slice(domains, func(i, j int) bool {
+		if domains[i].RequestCount != domains[j].RequestCount {
+			return domains[i].RequestCount > domains[j].RequestCount
+		}
+		return domains[i].Domain < domains[j].Domain
+	})
+
+	// Collect the cookies set by the page and by its subresources
+	cookies := []models.PageCookie{}
+	cookies = append(cookies, c.pageCookies(c.pageURL, resp.Cookies(), siteDomain)...)
+	for _, asset := range assets {
+		if asset.Header == nil {
+			continue
+		}
+		assetURL, err := url.Parse(asset.URL)
+		if err != nil {
+			continue
+		}
+		setCookies := (&http.Response{Header: asset.Header}).Cookies()
+		cookies = append(cookies, c.pageCookies(assetURL, setCookies, siteDomain)...)
+	}
+
+	return domains, cookies, nil
+}
+
+// pageCookies converts the cookies set by a response
+func (c *Crawler) pageCookies(setBy *url.URL, setCookies []*http.Cookie, siteDomain string) []models.PageCookie {
+	var cookies []models.PageCookie
+	for _, cookie := range setCookies {
+		domain := strings.TrimPrefix(strings.ToLower(cookie.Domain), ".")
+		if domain == "" {
+			domain = strings.ToLower(setBy.Hostname())
+		}
+		path := cookie.Path
+		if path == "" {
+			path = "/"
+		}
+
+		pageCookie := models.PageCookie{
+			WebsiteID:  c.website.ID,
+			Name:       truncateString(cookie.Name, 255),
+			Domain:     truncateString(domain, 255),
+			Path:       truncateString(path, 255),
+			Secure:     cookie.Secure,
+			HttpOnly:   cookie.HttpOnly,
+			SameSite:   sameSiteName(cookie.SameSite),
+			ThirdParty: registrableDomain(domain) != siteDomain,
+			SetBy:      truncateString(setBy.String(), 2048),
+		}
+		if cookie.MaxAge > 0 {
+			expires := time.Now().Add(time.Duration(cookie.MaxAge) * time.Second)
+			pageCookie.Expires = &expires
+		} else if !cookie.Expires.IsZero() {
+			expires := cookie.Expires
+			pageCookie.Expires = &expires
+		}
+		cookies = append(cookies, pageCookie)
+	}
+	return cookies
+}
@@ -0,0 +1,55 @@
+package services
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/sykell/website-analyzer/models"
+)
+
+func TestPageCookies(t *testing.T) {
+	crawler := newTestCrawler(t, "https://www.site.test/", &models.CrawlSettings{})
+	header := http.Header{"Set-Cookie": {
+		"session=1; Secure; HttpOnly; SameSite=Lax",
+		"pref=2; Domain=.Site.test; Path=/app",
+		"track=3; Domain=ads.other.test; SameSite=None",
+		strings.Repeat("n", 300) + "=4; Path=/" + strings.Repeat("p", 300),
+	}}
+	setBy, _ := url.Parse("https://www.site.test/" + strings.Repeat("q", 3000))
+	setCookies := (&http.Response{Header: header}).Cookies()
+
+	cookies := crawler.pageCookies(setBy, setCookies, "site.test")
+	if len(cookies) != 4 {
+		t.Fatalf("got %d cookies, want 4", len(cookies))
+	}
+
+	tests := []struct {
+		name, domain, path, sameSite string
+		thirdParty                   bool
+	}{
+		{"session", "www.site.test", "/", "Lax", false},
+		{"pref", "site.test", "/app", "", false},
+		{"track", "ads.other.test", "/", "None", true},
+	}
+	for i, tt := range tests {
+		cookie := cookies[i]
+		if cookie.Name != tt.name || cookie.Domain != tt.domain || cookie.Path != tt.path ||
+			cookie.SameSite != tt.sameSite || cookie.ThirdParty != tt.thirdParty {
+			t.Errorf("cookie %d = %s %s %s %q third party %v, want %s %s %s %q third party %v", i,
+				cookie.Name, cookie.Domain, cookie.Path, cookie.SameSite, cookie.ThirdParty,
+				tt.name, tt.domain, tt.path, tt.sameSite, tt.thirdParty)
+		}
+	}
+	if !cookies[0].Secure || !cookies[0].HttpOnly {
+		t.Error("the Secure and HttpOnly flags of the session cookie are lost")
+	}
+
+	// Long values are truncated to their column sizes
+	long := cookies[3]
+	if len(long.Name) != 255 || len(long.Path) != 255 || len(long.SetBy) != 2048 {
+		t.Errorf("name, path and set_by have %d, %d and %d characters, want 255, 255 and 2048",
+			len(long.Name), len(long.Path), len(long.SetBy))
+	}
+}
@@ -0,0 +1,224 @@
+package services
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/sykell/website-analyzer/models"
+)
+
+// inspectTLS records the certificate chain and TLS parameters of the website's
+// host. Certificates are verified by hand so that expired, self-signed and
+// mismatched certificates are reported instead of failing the connection.
+func (c *Crawler) inspectTLS() *models.TLSReport {
+	report := &models.TLSReport{
+		WebsiteID:    c.website.ID,
+		Host:         c.baseURL.Hostname(),
+		Certificates: []models.CertificateInfo{},
+		InspectedAt:  time.Now(),
+	}
+
+	// Check whether the plain HTTP version of the page redirects to HTTPS
+	report.HTTPSRedirect = c.checkHTTPSRedirect()
+
+	// Sites only served over plain HTTP have no certificate to inspect
+	if c.baseURL.Scheme != "https" && !report.HTTPSRedirect {
+		report.ErrorMessage = "the website is not served over HTTPS"
+		return report
+	}
+
+	port := "443"
+	if c.baseURL.Scheme == "https" && c.baseURL.Port() != "" {
+		// A port given for plain HTTP does not apply to HTTPS
+		port = c.baseURL.Port()
+	}
+	state, err := c.tlsHandshake(net.JoinHostPort(report.Host, port))
+	if err != nil {
+		report.ErrorMessage = err.Error()
+		return report
+	}
+
+	if len(state.PeerCertificates) == 0 {
+		report.ErrorMessage = "server did not present a certificate"
+		return report
+	}
+
+	report.TLSVersion = tls.VersionName(state.Version)
+	report.CipherSuite = tls.CipherSuiteName(state.CipherSuite)
+	report.OCSPStapled = len(state.OCSPResponse) > 0
+
+	for _, cert := range state.PeerCertificates {
+		report.Certificates = append(report.Certificates, certificateInfo(cert))
+	}
+
+	leaf := state.PeerCertificates[0]
+	notAfter := leaf.NotAfter
+	report.NotAfter = &notAfter
+	report.DaysUntilExpiry = models.DaysUntil(leaf.NotAfter)
+	report.Expired = time.Now().After(leaf.NotAfter) || time.Now().Before(leaf.NotBefore)
+	report.SelfSigned = isSelfSigned(leaf)
+
+	// Verify the chain against the system roots and the requested host name
+	intermediates := x509.NewCertPool()
+	for _, cert := range state.PeerCertificates[1:] {
+		intermediates.AddCert(cert)
+	}
+	_, err = leaf.Verify(x509.VerifyOptions{
+		DNSName:       report.Host,
+		Intermediates: intermediates,
+	})
+	if err != nil {
+		report.VerificationError = err.Error()
+
+		var hostnameErr x509.HostnameError
+		var authorityErr x509.UnknownAuthorityError
+		var invalidErr x509.CertificateInvalidError
+		switch {
+		case errors.As(err, &hostnameErr):
+			report.HostnameMismatch = true
+		case errors.As(err, &authorityErr):
+			report.Untrusted = true
+		case errors.As(err, &invalidErr):
+			if invalidErr.Reason == x509.Expired {
+				report.Expired = true
+			} else {
+				report.Untrusted = true
+			}
+		default:
+			report.Untrusted = true
+		}
+	}
+
+	// Verification stops at the first problem, so check the host name separately
+	if leaf.VerifyHostname(report.Host) != nil {
+		report.HostnameMismatch = true
+	}
+
+	return report
+}
+
+// tlsHandshake connects to an HTTPS address through the crawl's proxy and
+// returns the state of the handshake. The connection isn't verified, so only
+// a bare request without the credentials or cookies of the profile is sent.
+func (c *Crawler) tlsHandshake(address string) (*tls.ConnectionState, error) {
+	// The handshake runs on the transport's goroutine
+	var mutex sync.Mutex
+	var state *tls.ConnectionState
+	transport := c.transport.Clone()
+	transport.DisableKeepAlives = true
+	transport.TLSClientConfig = &tls.Config{
+		// Connect without verification; the chain is verified by the caller
+		InsecureSkipVerify: true,
+		VerifyConnection: func(cs tls.ConnectionState) error {
+			mutex.Lock()
+			state = &cs
+			mutex.Unlock()
+			return nil
+		},
+	}
+	client := &http.Client{
+		Transport: transport,
+		Timeout:   c.linkClient.Timeout,
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+
+	req, err := http.NewRequest("HEAD", "https://"+address+"/", nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := client.Do(req)
+	if resp != nil {
+		resp.Body.Close()
+	}
+	// The handshake may have succeeded even when the HTTP exchange didn't
+	mutex.Lock()
+	defer mutex.Unlock()
+	if state == nil {
+		if err == nil {
+			err = errors.New("no TLS handshake took place")
+		}
+		return nil, err
+	}
+	return state, nil
+}
+
+// checkHTTPSRedirect checks if the plain HTTP URL ends up on HTTPS. The request
+// is sent without the crawl profile so no credentials go out in cleartext.
+func (c *Crawler) checkHTTPSRedirect() bool {
+	httpURL := *c.baseURL
+	if httpURL.Scheme != "http" {
+		httpURL.Scheme = "http"
+		httpURL.Host = c.baseURL.Hostname()
+	}
+
+	client := &http.Client{
+		Transport: c.transport,
+		Timeout:   c.linkClient.Timeout,
+	}
+	req, err := http.NewRequest("HEAD", httpURL.String(), nil)
+	if err != nil {
+		return false
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return false
+	}
+	resp.Body.Close()
+
+	return resp.Request.URL.Scheme == "https"
+}
+
+// certificateInfo summarizes a certificate
+func certificateInfo(cert *x509.Certificate) models.CertificateInfo {
+	info := models.CertificateInfo{
+		Subject:            cert.Subject.String(),
+		Issuer:             cert.Issuer.String(),
+		SANs:               append([]string{}, cert.DNSNames...),
+		SerialNumber:       cert.SerialNumber.String(),
+		SignatureAlgorithm: cert.SignatureAlgorithm.String(),
+		IsCA:               cert.IsCA,
+		NotBefore:          cert.NotBefore,
+		NotAfter:           cert.NotAfter,
+		DaysUntilExpiry:    models.DaysUntil(cert.NotAfter),
+	}
+	for _, ip := range cert.IPAddresses {
+		info.SANs = append(info.SANs, ip.String())
+	}
+	for _, uri := range cert.URIs {
+		info.SANs = append(info.SANs, uri.String())
+	}
+
+	switch key := cert.PublicKey.(type) {
+	case *rsa.PublicKey:
+		info.KeyType = "RSA"
+		info.KeySize = key.N.BitLen()
+	case *ecdsa.PublicKey:
+		info.KeyType = "ECDSA"
+		info.KeySize = key.Curve.Params().BitSize
+	case ed25519.PublicKey:
+		info.KeyType = "Ed25519"
+		info.KeySize = 256
+	default:
+		info.KeyType = cert.PublicKeyAlgorithm.String()
+	}
+
+	return info
+}
+
+// isSelfSigned checks if a certificate is signed by its own key
+func isSelfSigned(cert *x509.Certificate) bool {
+	if cert.Subject.String() != cert.Issuer.String() {
+		return false
+	}
+	return cert.CheckSignature(cert.SignatureAlgorithm, cert.RawTBSCertificate, cert.Signature) == nil
+}
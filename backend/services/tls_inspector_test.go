@@ -0,0 +1,100 @@
+package services
+
+import (
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/sykell/website-analyzer/models"
+)
+
+// connectProxy is an HTTP proxy that tunnels CONNECT requests and records their targets
+type connectProxy struct {
+	mutex   sync.Mutex
+	tunnels []string
+}
+
+func (p *connectProxy) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodConnect {
+		http.NotFound(w, r)
+		return
+	}
+	p.mutex.Lock()
+	p.tunnels = append(p.tunnels, r.Host)
+	p.mutex.Unlock()
+
+	target, err := net.Dial("tcp", r.Host)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	conn, _, err := w.(http.Hijacker).Hijack()
+	if err != nil {
+		target.Close()
+		return
+	}
+	io.WriteString(conn, "HTTP/1.1 200 Connection established\r\n\r\n")
+	go func() {
+		io.Copy(target, conn)
+		target.Close()
+	}()
+	io.Copy(conn, target)
+	conn.Close()
+}
+
+func TestInspectTLSThroughProxy(t *testing.T) {
+	site := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer site.Close()
+	proxy := &connectProxy{}
+	proxyServer := httptest.NewServer(proxy)
+	defer proxyServer.Close()
+
+	crawler := newTestCrawler(t, site.URL+"/", &models.CrawlSettings{ProxyURL: proxyServer.URL})
+	report := crawler.inspectTLS()
+
+	if report.ErrorMessage != "" {
+		t.Fatalf("inspection failed: %s", report.ErrorMessage)
+	}
+	proxy.mutex.Lock()
+	tunnels := proxy.tunnels
+	proxy.mutex.Unlock()
+	if len(tunnels) != 1 || tunnels[0] != site.Listener.Addr().String() {
+		t.Errorf("proxy tunneled to %v, want only %s", tunnels, site.Listener.Addr())
+	}
+	if len(report.Certificates) == 0 {
+		t.Error("no certificates were recorded")
+	}
+	if report.TLSVersion == "" || report.CipherSuite == "" {
+		t.Errorf("TLS version %q and cipher suite %q, want both", report.TLSVersion, report.CipherSuite)
+	}
+	// The test server's certificate isn't signed by a trusted root
+	if !report.Untrusted {
+		t.Error("the test certificate isn't reported as untrusted")
+	}
+}
+
+func TestInspectTLSPlainHTTP(t *testing.T) {
+	var requests []string
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests = append(requests, r.Method+" "+r.URL.String())
+	}))
+	defer proxy.Close()
+
+	crawler := newTestCrawler(t, "http://site.test/", &models.CrawlSettings{ProxyURL: proxy.URL})
+	report := crawler.inspectTLS()
+
+	if report.HTTPSRedirect {
+		t.Error("a plain HTTP response is reported as an HTTPS redirect")
+	}
+	if len(report.Certificates) != 0 || report.ErrorMessage == "" {
+		t.Errorf("report has %d certificates and error %q, want none and an explanation",
+			len(report.Certificates), report.ErrorMessage)
+	}
+	// Only the redirect probe is sent, there is no HTTPS connection attempt
+	if len(requests) != 1 || requests[0] != "HEAD http://site.test/" {
+		t.Errorf("proxy received %v, want only the HEAD redirect probe", requests)
+	}
+}
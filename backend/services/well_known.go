@@ -0,0 +1,397 @@
+package services
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sykell/website-analyzer/models"
+)
+
+const (
+	// maxWellKnownSize limits how much of a well-known file is parsed
+	maxWellKnownSize = 5 << 20
+
+	// maxWellKnownErrors limits how many validation errors are kept per file
+	maxWellKnownErrors = 50
+
+	// maxAdsRecords limits how many seller records are stored per ads.txt file
+	maxAdsRecords = 1000
+
+	// maxHumansLines limits how many lines are stored per humans.txt section
+	maxHumansLines = 100
+
+	// Path that must not exist, used to tell whether the site answers every well-known path
+	wellKnownProbePath = "/.well-known/resource-that-should-not-exist-whose-status-code-should-not-be-200"
+)
+
+var (
+	// A humans.txt section header, e.g. /* TEAM */
+	humansSectionRegex = regexp.MustCompile(`^/\*\s*(.*?)\s*\*/$`)
+	// A domain name of an ads.txt record
+	adsDomainRegex = regexp.MustCompile(`^(?i)[a-z0-9](?:[a-z0-9-]*[a-z0-9])?(?:\.[a-z0-9](?:[a-z0-9-]*[a-z0-9])?)+$`)
+)
+
+// Fields defined by RFC 9116, by lowercase name
+var securityTxtFields = map[string]string{
+	"acknowledgments":     "Acknowledgments",
+	"canonical":           "Canonical",
+	"contact":             "Contact",
+	"csaf":                "CSAF",
+	"encryption":          "Encryption",
+	"expires":             "Expires",
+	"hiring":              "Hiring",
+	"policy":              "Policy",
+	"preferred-languages": "Preferred-Languages",
+}
+
+// textFile is a fetched well-known text file
+type textFile struct {
+	finalURL *url.URL
+	body     []byte
+}
+
+// checkWellKnownFiles fetches and validates the security.txt, ads.txt,
+// app-ads.txt, change-password and humans.txt well-known files of the site
+func (c *Crawler) checkWellKnownFiles() []models.WellKnownFile {
+	checks := []func() models.WellKnownFile{
+		c.checkSecurityTxt,
+		func() models.WellKnownFile { return c.checkAdsTxt("ads.txt") },
+		func() models.WellKnownFile { return c.checkAdsTxt("app-ads.txt") },
+		c.checkChangePassword,
+		c.checkHumansTxt,
+	}
+
+	files := make([]models.WellKnownFile, len(checks))
+	var wg sync.WaitGroup
+	for i, check := range checks {
+		wg.Add(1)
+		go func(i int, check func() models.WellKnownFile) {
+			defer wg.Done()
+			files[i] = check()
+		}(i, check)
+	}
+	wg.Wait()
+
+	return files
+}
+
+// newWellKnownFile initializes the result of a well-known file at a path of the site
+func (c *Crawler) newWellKnownFile(name, filePath string) models.WellKnownFile {
+	return models.WellKnownFile{
+		WebsiteID: c.website.ID,
+		Name:      name,
+		URL:       c.siteURL(filePath),
+		Fields:    map[string][]string{},
+		Records:   []models.AdsRecord{},
+		Errors:    []string{},
+	}
+}
+
+// siteURL returns the URL of a path at the root of the analyzed site
+func (c *Crawler) siteURL(filePath string) string {
+	return (&url.URL{Scheme: c.pageURL.Scheme, Host: c.pageURL.Host, Path: filePath}).String()
+}
+
+// addWellKnownError records a validation error, up to maxWellKnownErrors
+func addWellKnownError(file *models.WellKnownFile, format string, args ...interface{}) {
+	switch {
+	case len(file.Errors) < maxWellKnownErrors:
+		file.Errors = append(file.Errors, truncateString(fmt.Sprintf(format, args...), 512))
+	case len(file.Errors) == maxWellKnownErrors:
+		file.Errors = append(file.Errors, "Further errors were not reported")
+	}
+}
+
+// fetchTextFile fetches a well-known text file into the result, marking it
+// found when it's served with status 200 and isn't an HTML page
+func (c *Crawler) fetchTextFile(file *models.WellKnownFile) *textFile {
+	resp, err := c.doAuthenticated(c.linkClient, "GET", file.URL)
+	if err != nil {
+		addWellKnownError(file, "The file could not be fetched: %v", err)
+		return nil
+	}
+	defer resp.Body.Close()
+	file.StatusCode = resp.StatusCode
+	if resp.StatusCode != http.StatusOK {
+		return nil
+	}
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxWellKnownSize))
+	if err != nil {
+		addWellKnownError(file, "The file could not be read: %v", err)
+		return nil
+	}
+	mediaType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	mediaType = strings.ToLower(mediaType)
+
+	// Sites often answer missing files with their HTML error or home page
+	start := strings.ToLower(string(bytes.TrimSpace(body[:minInt(len(body), 512)])))
+	if mediaType == "text/html" || strings.HasPrefix(start, "<!doctype html") || strings.HasPrefix(start, "<html") {
+		addWellKnownError(file, "The server returned an HTML page instead of the file")
+		return nil
+	}
+
+	file.Found = true
+	file.URL = truncateString(resp.Request.URL.String(), 2048)
+	if mediaType != "text/plain" {
+		addWellKnownError(file, "The file is served as %q instead of text/plain", mediaType)
+	}
+	return &textFile{finalURL: resp.Request.URL, body: body}
+}
+
+// checkSecurityTxt fetches security.txt from /.well-known, falling back to
+// the legacy location at the root, and validates it against RFC 9116
+func (c *Crawler) checkSecurityTxt() models.WellKnownFile {
+	file := c.newWellKnownFile("security.txt", "/.well-known/security.txt")
+	fetched := c.fetchTextFile(&file)
+	if fetched == nil {
+		legacy := c.newWellKnownFile("security.txt", "/security.txt")
+		if fetched = c.fetchTextFile(&legacy); fetched == nil {
+			return file
+		}
+		file = legacy
+		addWellKnownError(&file, "security.txt should be served from /.well-known/security.txt")
+	}
+	if fetched.finalURL.Scheme != "https" {
+		addWellKnownError(&file, "security.txt must be served over HTTPS")
+	}
+
+	// Take the message out of an OpenPGP cleartext signature
+	content := strings.ReplaceAll(string(fetched.body), "\r\n", "\n")
+	lineOffset := 0
+	if trimmed := strings.TrimLeft(content, "\n"); strings.HasPrefix(trimmed, "-----BEGIN PGP SIGNED MESSAGE-----") {
+		file.Signed = true
+		lineOffset = len(content) - len(trimmed)
+		// The armor headers end at the first blank line
+		if header, message, found := strings.Cut(trimmed, "\n\n"); found {
+			lineOffset += strings.Count(header, "\n") + 2
+			content = message
+		} else {
+			content = ""
+		}
+		message, _, found := strings.Cut(content, "-----BEGIN PGP SIGNATURE-----")
+		if !found || !strings.Contains(content, "-----END PGP SIGNATURE-----") {
+			addWellKnownError(&file, "The signed message has no signature")
+		}
+		// Undo the dash-escaping of the signed lines
+		lines := strings.Split(message, "\n")
+		for i, line := range lines {
+			lines[i] = strings.TrimPrefix(line, "- ")
+		}
+		content = strings.Join(lines, "\n")
+	}
+
+	// Read the fields, ignoring comments and unknown fields
+	for i, line := range strings.Split(content, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		name, value, found := strings.Cut(line, ":")
+		if !found || strings.ContainsAny(strings.TrimSpace(name), " \t") {
+			addWellKnownError(&file, "Line %d is not a field", lineOffset+i+1)
+			continue
+		}
+		if canonical, ok := securityTxtFields[strings.ToLower(strings.TrimSpace(name))]; ok {
+			file.Fields[canonical] = append(file.Fields[canonical], truncateString(strings.TrimSpace(value), 2048))
+		}
+	}
+
+	// Contact is required and must be a mailto, tel or https URI
+	if len(file.Fields["Contact"]) == 0 {
+		addWellKnownError(&file, "The required Contact field is missing")
+	}
+	for _, name := range []string{"Contact", "Encryption", "Acknowledgments", "Canonical", "Policy", "Hiring", "CSAF"} {
+		for _, value := range file.Fields[name] {
+			uri, err := url.Parse(value)
+			switch {
+			case err != nil || uri.Scheme == "":
+				addWellKnownError(&file, "%s %q is not a URI", name, value)
+			case uri.Scheme == "http":
+				addWellKnownError(&file, "%s %q must use https", name, value)
+			case name == "Contact" && uri.Scheme != "https" && uri.Scheme != "mailto" && uri.Scheme != "tel":
+				addWellKnownError(&file, "Contact %q must be a mailto, tel or https URI", value)
+			}
+		}
+	}
+
+	// Expires is required once, and should be less than a year ahead
+	switch expires := file.Fields["Expires"]; {
+	case len(expires) == 0:
+		addWellKnownError(&file, "The required Expires field is missing")
+	case len(expires) > 1:
+		addWellKnownError(&file, "The Expires field must appear only once")
+	default:
+		expiry, err := time.Parse(time.RFC3339, expires[0])
+		if err != nil {
+			addWellKnownError(&file, "Expires %q is not an RFC 3339 date", expires[0])
+			break
+		}
+		expiry = expiry.UTC()
+		file.Expires = &expiry
+		if expiry.Before(time.Now()) {
+			addWellKnownError(&file, "security.txt expired on %s", expiry.Format("2006-01-02"))
+		} else if expiry.After(time.Now().AddDate(1, 0, 0)) {
+			addWellKnownError(&file, "Expires should be less than a year ahead")
+		}
+	}
+
+	if len(file.Fields["Preferred-Languages"]) > 1 {
+		addWellKnownError(&file, "The Preferred-Languages field must appear only once")
+	}
+
+	// Canonical URIs, when given, must include where the file was fetched from
+	if canonicals := file.Fields["Canonical"]; len(canonicals) > 0 {
+		matched := false
+		for _, canonical := range canonicals {
+			if canonicalURL, err := url.Parse(canonical); err == nil && canonicalURL.String() == fetched.finalURL.String() {
+				matched = true
+			}
+		}
+		if !matched {
+			addWellKnownError(&file, "No Canonical field matches %s", fetched.finalURL.String())
+		}
+	}
+
+	return file
+}
+
+// checkAdsTxt fetches ads.txt or app-ads.txt and validates its seller
+// records and variables
+func (c *Crawler) checkAdsTxt(name string) models.WellKnownFile {
+	file := c.newWellKnownFile(name, "/"+name)
+	fetched := c.fetchTextFile(&file)
+	if fetched == nil {
+		return file
+	}
+
+	// Files saved by some editors start with a byte order mark
+	content := strings.TrimPrefix(string(fetched.body), "\ufeff")
+	for i, line := range strings.Split(content, "\n") {
+		line, _, _ = strings.Cut(line, "#")
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+
+		// Variables, e.g. contact=adops@example.com
+		if key, value, found := strings.Cut(line, "="); found && !strings.Contains(key, ",") {
+			key = strings.ToUpper(strings.TrimSpace(key))
+			file.Fields[key] = append(file.Fields[key], truncateString(strings.TrimSpace(value), 2048))
+			continue
+		}
+
+		// Records, e.g. greenadexchange.com, 12345, DIRECT, d75815a79, with extensions after a semicolon
+		record, _, _ := strings.Cut(line, ";")
+		fields := strings.Split(record, ",")
+		for j := range fields {
+			fields[j] = strings.TrimSpace(fields[j])
+		}
+		if len(fields) < 3 || len(fields) > 4 {
+			addWellKnownError(&file, "Line %d has %d fields instead of 3 or 4", i+1, len(fields))
+			continue
+		}
+		relationship := strings.ToUpper(fields[2])
+		switch {
+		case !adsDomainRegex.MatchString(fields[0]):
+			addWellKnownError(&file, "Line %d has an invalid advertising system domain %q", i+1, fields[0])
+			continue
+		case fields[1] == "":
+			addWellKnownError(&file, "Line %d has no publisher account ID", i+1)
+			continue
+		case relationship != "DIRECT" && relationship != "RESELLER":
+			addWellKnownError(&file, "Line %d has relationship %q instead of DIRECT or RESELLER", i+1, fields[2])
+			continue
+		}
+
+		file.RecordCount++
+		if len(file.Records) < maxAdsRecords {
+			adsRecord := models.AdsRecord{
+				Domain:       truncateString(strings.ToLower(fields[0]), 255),
+				AccountID:    truncateString(fields[1], 255),
+				Relationship: relationship,
+			}
+			if len(fields) == 4 {
+				adsRecord.CertificationAuthorityID = truncateString(fields[3], 255)
+			}
+			file.Records = append(file.Records, adsRecord)
+		}
+	}
+
+	if file.RecordCount == 0 {
+		addWellKnownError(&file, "%s has no seller records", name)
+	}
+	return file
+}
+
+// checkChangePassword checks that /.well-known/change-password leads to a
+// page, and that the site doesn't answer every well-known path with success
+func (c *Crawler) checkChangePassword() models.WellKnownFile {
+	file := c.newWellKnownFile("change-password", "/.well-known/change-password")
+	resp, err := c.doAuthenticated(c.linkClient, "GET", file.URL)
+	if err != nil {
+		addWellKnownError(&file, "The URL could not be fetched: %v", err)
+		return file
+	}
+	io.Copy(io.Discard, io.LimitReader(resp.Body, maxWellKnownSize))
+	resp.Body.Close()
+	file.StatusCode = resp.StatusCode
+	if resp.StatusCode != http.StatusOK {
+		return file
+	}
+	file.Found = true
+	if finalURL := resp.Request.URL.String(); finalURL != file.URL {
+		file.RedirectURL = truncateString(finalURL, 2048)
+	}
+
+	// Password managers only trust the URL if missing well-known resources aren't successful
+	probe, err := c.doAuthenticated(c.linkClient, "GET", c.siteURL(wellKnownProbePath))
+	if err == nil {
+		probe.Body.Close()
+		if probe.StatusCode >= 200 && probe.StatusCode < 300 {
+			addWellKnownError(&file, "The site answers missing well-known URLs with status %d, so change-password can't be trusted", probe.StatusCode)
+		}
+	}
+	return file
+}
+
+// checkHumansTxt fetches humans.txt and reads its sections, e.g. /* TEAM */
+func (c *Crawler) checkHumansTxt() models.WellKnownFile {
+	file := c.newWellKnownFile("humans.txt", "/humans.txt")
+	fetched := c.fetchTextFile(&file)
+	if fetched == nil {
+		return file
+	}
+
+	section := ""
+	for _, line := range strings.Split(strings.TrimPrefix(string(fetched.body), "\ufeff"), "\n") {
+		line = strings.TrimSpace(line)
+		if match := humansSectionRegex.FindStringSubmatch(line); match != nil {
+			section = truncateString(strings.ToUpper(match[1]), 100)
+			continue
+		}
+		if line == "" {
+			continue
+		}
+		// Lines before the first section are kept under an empty name
+		if len(file.Fields[section]) < maxHumansLines {
+			file.Fields[section] = append(file.Fields[section], truncateString(line, 512))
+		}
+	}
+
+	switch {
+	case len(file.Fields) == 0:
+		addWellKnownError(&file, "humans.txt is empty")
+	case len(file.Fields) == 1 && file.Fields[""] != nil:
+		addWellKnownError(&file, "humans.txt has no sections, e.g. /* TEAM */")
+	}
+	return file
+}
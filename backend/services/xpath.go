@@ -0,0 +1,706 @@
+package services
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"golang.org/x/net/html"
+)
+
+// xpathExpr is a compiled XPath 1.0 expression from the subset used by
+// extraction rules: location paths with unions, the common axes, name,
+// text() and node() tests, and predicates with comparisons, and/or and the
+// functions contains, starts-with, ends-with, normalize-space, not,
+// position, last, count and string-length
+type xpathExpr struct {
+	paths []xpathPath
+}
+
+// xpathPath is a location path, e.g. "//div[@class='price']/text()"
+type xpathPath struct {
+	absolute bool
+	steps    []xpathStep
+}
+
+// xpathStep selects nodes along an axis, e.g. "following-sibling::li[2]"
+type xpathStep struct {
+	axis       string
+	test       string // Element or attribute name, "*", "text()" or "node()"
+	predicates []xpathPredicate
+}
+
+// xpathPredicate filters the nodes selected by a step
+type xpathPredicate func(ctx xpathContext) xpathValue
+
+// xpathItem is a node or an attribute selected by an expression
+type xpathItem struct {
+	node *html.Node
+	attr *html.Attribute
+}
+
+// xpathContext is the item a predicate is evaluated for, with its position
+// among the items selected by the step
+type xpathContext struct {
+	item     xpathItem
+	position int
+	size     int
+	eval     *xpathEvaluator
+}
+
+// xpathValue is the result of an expression inside a predicate
+type xpathValue struct {
+	kind  byte // 'n' for node sets, 's' for strings, 'f' for numbers and 'b' for booleans
+	items []xpathItem
+	str   string
+	num   float64
+	bool  bool
+}
+
+// xpathEvaluator evaluates expressions against one document
+type xpathEvaluator struct {
+	root  *html.Node
+	order map[*html.Node]int // Document order of the nodes
+}
+
+// compileXPath parses an XPath expression
+func compileXPath(expression string) (*xpathExpr, error) {
+	tokens, err := tokenizeXPath(expression)
+	if err != nil {
+		return nil, fmt.Errorf("invalid XPath %q: %w", expression, err)
+	}
+	if len(tokens) == 0 {
+		return nil, fmt.Errorf("empty XPath")
+	}
+
+	p := &xpathParser{tokens: tokens}
+	compiled := &xpathExpr{}
+	for {
+		path, err := p.parsePath()
+		if err != nil {
+			return nil, fmt.Errorf("invalid XPath %q: %w", expression, err)
+		}
+		compiled.paths = append(compiled.paths, path)
+		if !p.accept("|") {
+			break
+		}
+	}
+	if !p.done() {
+		return nil, fmt.Errorf("invalid XPath %q: unexpected %q", expression, p.peek())
+	}
+
+	return compiled, nil
+}
+
+// Select returns the nodes and attributes selected from the document, in document order
+func (x *xpathExpr) Select(doc *html.Node) []xpathItem {
+	e := &xpathEvaluator{root: doc, order: map[*html.Node]int{}}
+	var orderFunc func(*html.Node)
+	orderFunc = func(n *html.Node) {
+		e.order[n] = len(e.order)
+		for child := n.FirstChild; child != nil; child = child.NextSibling {
+			orderFunc(child)
+		}
+	}
+	orderFunc(doc)
+
+	var items []xpathItem
+	for _, path := range x.paths {
+		items = append(items, e.evalPath(path, xpathItem{node: doc})...)
+	}
+	return e.sortItems(items)
+}
+
+// evalPath evaluates a location path from a context item
+func (e *xpathEvaluator) evalPath(path xpathPath, context xpathItem) []xpathItem {
+	items := []xpathItem{context}
+	if path.absolute {
+		items = []xpathItem{{node: e.root}}
+	}
+	for _, step := range path.steps {
+		var next []xpathItem
+		for _, item := range items {
+			next = append(next, e.evalStep(step, item)...)
+		}
+		items = e.sortItems(next)
+	}
+	return items
+}
+
+// evalStep selects the items of a step from one context item and filters them by the predicates
+func (e *xpathEvaluator) evalStep(step xpathStep, context xpathItem) []xpathItem {
+	var candidates []xpathItem
+	add := func(n *html.Node) {
+		if n != nil && matchXPathTest(step.test, n) {
+			candidates = append(candidates, xpathItem{node: n})
+		}
+	}
+	var descendantsFunc func(*html.Node)
+	descendantsFunc = func(n *html.Node) {
+		for child := n.FirstChild; child != nil; child = child.NextSibling {
+			add(child)
+			descendantsFunc(child)
+		}
+	}
+
+	// Attributes only have themselves
+	if context.attr != nil {
+		if step.axis == "self" && step.test == "node()" {
+			candidates = append(candidates, context)
+		}
+	} else {
+		n := context.node
+		switch step.axis {
+		case "child":
+			for child := n.FirstChild; child != nil; child = child.NextSibling {
+				add(child)
+			}
+		case "descendant":
+			descendantsFunc(n)
+		case "descendant-or-self":
+			add(n)
+			descendantsFunc(n)
+		case "self":
+			add(n)
+		case "parent":
+			add(n.Parent)
+		case "ancestor":
+			for parent := n.Parent; parent != nil; parent = parent.Parent {
+				add(parent)
+			}
+		case "following-sibling":
+			for sibling := n.NextSibling; sibling != nil; sibling = sibling.NextSibling {
+				add(sibling)
+			}
+		case "preceding-sibling":
+			for sibling := n.PrevSibling; sibling != nil; sibling = sibling.PrevSibling {
+				add(sibling)
+			}
+		case "attribute":
+			if n.Type == html.ElementNode {
+				for i := range n.Attr {
+					if step.test == "*" || step.test == "node()" || strings.EqualFold(n.Attr[i].Key, step.test) {
+						candidates = append(candidates, xpathItem{node: n, attr: &n.Attr[i]})
+					}
+				}
+			}
+		}
+	}
+
+	// Positions count along the axis, so ancestors and preceding siblings count backwards
+	for _, predicate := range step.predicates {
+		var kept []xpathItem
+		for i, item := range candidates {
+			value := predicate(xpathContext{item: item, position: i + 1, size: len(candidates), eval: e})
+			if value.kind == 'f' {
+				if value.num == float64(i+1) {
+					kept = append(kept, item)
+				}
+			} else if value.toBool() {
+				kept = append(kept, item)
+			}
+		}
+		candidates = kept
+	}
+	return candidates
+}
+
+// matchXPathTest checks a node against the node test of a step
+func matchXPathTest(test string, n *html.Node) bool {
+	switch test {
+	case "node()":
+		return n.Type == html.ElementNode || n.Type == html.TextNode || n.Type == html.DocumentNode
+	case "text()":
+		return n.Type == html.TextNode
+	case "*":
+		return n.Type == html.ElementNode
+	}
+	return n.Type == html.ElementNode && strings.EqualFold(n.Data, test)
+}
+
+// sortItems removes duplicate items and sorts them in document order
+func (e *xpathEvaluator) sortItems(items []xpathItem) []xpathItem {
+	type itemKey struct {
+		node *html.Node
+		attr *html.Attribute
+	}
+	seen := map[itemKey]bool{}
+	unique := items[:0]
+	for _, item := range items {
+		key := itemKey{item.node, item.attr}
+		if !seen[key] {
+			seen[key] = true
+			unique = append(unique, item)
+		}
+	}
+	sort.SliceStable(unique, func(i, j int) bool {
+		a, b := unique[i], unique[j]
+		if a.node != b.node {
+			return e.order[a.node] < e.order[b.node]
+		}
+		// An element comes before its attributes
+		return a.attr == nil && b.attr != nil
+	})
+	return unique
+}
+
+// stringValue returns the text of an item
+func (item xpathItem) stringValue() string {
+	if item.attr != nil {
+		return item.attr.Val
+	}
+	return textContent(item.node)
+}
+
+// toString converts a value to a string, using the first node of node sets
+func (v xpathValue) toString() string {
+	switch v.kind {
+	case 'n':
+		if len(v.items) == 0 {
+			return ""
+		}
+		return v.items[0].stringValue()
+	case 'f':
+		return strconv.FormatFloat(v.num, 'f', -1, 64)
+	case 'b':
+		return strconv.FormatBool(v.bool)
+	}
+	return v.str
+}
+
+// toNumber converts a value to a number, NaN if it isn't one
+func (v xpathValue) toNumber() float64 {
+	switch v.kind {
+	case 'f':
+		return v.num
+	case 'b':
+		if v.bool {
+			return 1
+		}
+		return 0
+	}
+	number, err := strconv.ParseFloat(strings.TrimSpace(v.toString()), 64)
+	if err != nil {
+		return math.NaN()
+	}
+	return number
+}
+
+// toBool converts a value to a boolean
+func (v xpathValue) toBool() bool {
+	switch v.kind {
+	case 'n':
+		return len(v.items) > 0
+	case 's':
+		return v.str != ""
+	case 'f':
+		return v.num != 0 && !math.IsNaN(v.num)
+	}
+	return v.bool
+}
+
+// compareXPath compares two values. Node sets match if any of their nodes does.
+func compareXPath(operator string, a, b xpathValue) bool {
+	if a.kind == 'n' {
+		for _, item := range a.items {
+			if compareXPath(operator, xpathValue{kind: 's', str: item.stringValue()}, b) {
+				return true
+			}
+		}
+		return false
+	}
+	if b.kind == 'n' {
+		for _, item := range b.items {
+			if compareXPath(operator, a, xpathValue{kind: 's', str: item.stringValue()}) {
+				return true
+			}
+		}
+		return false
+	}
+
+	if operator == "=" || operator == "!=" {
+		var equal bool
+		switch {
+		case a.kind == 'b' || b.kind == 'b':
+			equal = a.toBool() == b.toBool()
+		case a.kind == 'f' || b.kind == 'f':
+			equal = a.toNumber() == b.toNumber()
+		default:
+			equal = a.toString() == b.toString()
+		}
+		return equal == (operator == "=")
+	}
+
+	x, y := a.toNumber(), b.toNumber()
+	switch operator {
+	case "<":
+		return x < y
+	case "<=":
+		return x <= y
+	case ">":
+		return x > y
+	case ">=":
+		return x >= y
+	}
+	return false
+}
+
+// tokenizeXPath splits an expression into operators, names, literals and numbers
+func tokenizeXPath(expression string) ([]string, error) {
+	var tokens []string
+	for i := 0; i < len(expression); {
+		ch := expression[i]
+		switch {
+		case ch == ' ' || ch == '\t' || ch == '\n' || ch == '\r':
+			i++
+		case strings.HasPrefix(expression[i:], "//"), strings.HasPrefix(expression[i:], ".."),
+			strings.HasPrefix(expression[i:], "::"), strings.HasPrefix(expression[i:], "!="),
+			strings.HasPrefix(expression[i:], "<="), strings.HasPrefix(expression[i:], ">="):
+			tokens = append(tokens, expression[i:i+2])
+			i += 2
+		case ch == '"' || ch == '\'':
+			end := strings.IndexByte(expression[i+1:], ch)
+			if end < 0 {
+				return nil, fmt.Errorf("unterminated string")
+			}
+			tokens = append(tokens, expression[i:i+end+2])
+			i += end + 2
+		case ch >= '0' && ch <= '9' || ch == '.' && i+1 < len(expression) && expression[i+1] >= '0' && expression[i+1] <= '9':
+			start := i
+			for i < len(expression) && (expression[i] >= '0' && expression[i] <= '9' || expression[i] == '.') {
+				i++
+			}
+			tokens = append(tokens, expression[start:i])
+		case strings.IndexByte("/|[]()@,.*=<>", ch) >= 0:
+			tokens = append(tokens, string(ch))
+			i++
+		default:
+			start := i
+			for i < len(expression) {
+				r := rune(expression[i])
+				if r >= 0x80 || unicode.IsLetter(r) || unicode.IsDigit(r) || r == '-' || r == '_' || r == '.' ||
+					r == ':' && !strings.HasPrefix(expression[i:], "::") {
+					i++
+					continue
+				}
+				break
+			}
+			if i == start {
+				return nil, fmt.Errorf("unexpected %q", ch)
+			}
+			tokens = append(tokens, expression[start:i])
+		}
+	}
+	return tokens, nil
+}
+
+// xpathParser parses tokens into paths and predicates
+type xpathParser struct {
+	tokens []string
+	pos    int
+}
+
+func (p *xpathParser) done() bool { return p.pos >= len(p.tokens) }
+
+func (p *xpathParser) peek() string {
+	if p.done() {
+		return ""
+	}
+	return p.tokens[p.pos]
+}
+
+func (p *xpathParser) accept(token string) bool {
+	if p.peek() == token {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+func (p *xpathParser) expect(token string) error {
+	if !p.accept(token) {
+		if p.done() {
+			return fmt.Errorf("expected %q at the end", token)
+		}
+		return fmt.Errorf("expected %q, found %q", token, p.peek())
+	}
+	return nil
+}
+
+// Axes that can be written out with "::"
+var xpathAxes = map[string]bool{
+	"child": true, "descendant": true, "descendant-or-self": true, "self": true, "parent": true,
+	"ancestor": true, "following-sibling": true, "preceding-sibling": true, "attribute": true,
+}
+
+// parsePath parses a location path
+func (p *xpathParser) parsePath() (xpathPath, error) {
+	path := xpathPath{}
+	descendantStep := xpathStep{axis: "descendant-or-self", test: "node()"}
+	switch {
+	case p.accept("//"):
+		path.absolute = true
+		path.steps = append(path.steps, descendantStep)
+	case p.accept("/"):
+		path.absolute = true
+		// A lone "/" selects the document
+		if p.done() || p.peek() == "|" || p.peek() == "]" || p.peek() == ")" {
+			return path, nil
+		}
+	}
+
+	for {
+		step, err := p.parseStep()
+		if err != nil {
+			return path, err
+		}
+		path.steps = append(path.steps, step)
+
+		if p.accept("//") {
+			path.steps = append(path.steps, descendantStep)
+		} else if !p.accept("/") {
+			return path, nil
+		}
+	}
+}
+
+// parseStep parses a step with its predicates
+func (p *xpathParser) parseStep() (xpathStep, error) {
+	step := xpathStep{axis: "child"}
+	switch {
+	case p.accept("."):
+		return xpathStep{axis: "self", test: "node()"}, nil
+	case p.accept(".."):
+		return xpathStep{axis: "parent", test: "node()"}, nil
+	case p.accept("@"):
+		step.axis = "attribute"
+	case xpathAxes[p.peek()] && p.pos+1 < len(p.tokens) && p.tokens[p.pos+1] == "::":
+		step.axis = p.peek()
+		p.pos += 2
+	}
+
+	token := p.peek()
+	switch {
+	case token == "*":
+		p.pos++
+		step.test = "*"
+	case (token == "text" || token == "node") && p.pos+1 < len(p.tokens) && p.tokens[p.pos+1] == "(":
+		p.pos += 2
+		if err := p.expect(")"); err != nil {
+			return step, err
+		}
+		step.test = token + "()"
+	case isXPathName(token):
+		p.pos++
+		step.test = token
+	default:
+		if token == "" {
+			return step, fmt.Errorf("expected a step at the end")
+		}
+		return step, fmt.Errorf("expected a step, found %q", token)
+	}
+
+	for p.accept("[") {
+		predicate, err := p.parseOr()
+		if err != nil {
+			return step, err
+		}
+		if err := p.expect("]"); err != nil {
+			return step, err
+		}
+		step.predicates = append(step.predicates, predicate)
+	}
+	return step, nil
+}
+
+// isXPathName checks if a token is a name rather than an operator or literal
+func isXPathName(token string) bool {
+	if token == "" {
+		return false
+	}
+	r := rune(token[0])
+	return r >= 0x80 || unicode.IsLetter(r) || r == '_'
+}
+
+// parseOr parses expressions joined by "or"
+func (p *xpathParser) parseOr() (xpathPredicate, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.accept("or") {
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		l := left
+		left = func(ctx xpathContext) xpathValue {
+			return xpathValue{kind: 'b', bool: l(ctx).toBool() || right(ctx).toBool()}
+		}
+	}
+	return left, nil
+}
+
+// parseAnd parses comparisons joined by "and"
+func (p *xpathParser) parseAnd() (xpathPredicate, error) {
+	left, err := p.parseComparison()
+	if err != nil {
+		return nil, err
+	}
+	for p.accept("and") {
+		right, err := p.parseComparison()
+		if err != nil {
+			return nil, err
+		}
+		l := left
+		left = func(ctx xpathContext) xpathValue {
+			return xpathValue{kind: 'b', bool: l(ctx).toBool() && right(ctx).toBool()}
+		}
+	}
+	return left, nil
+}
+
+// parseComparison parses an operand, optionally compared to another one
+func (p *xpathParser) parseComparison() (xpathPredicate, error) {
+	left, err := p.parseOperand()
+	if err != nil {
+		return nil, err
+	}
+
+	operator := p.peek()
+	switch operator {
+	case "=", "!=", "<", "<=", ">", ">=":
+		p.pos++
+	default:
+		return left, nil
+	}
+	right, err := p.parseOperand()
+	if err != nil {
+		return nil, err
+	}
+	return func(ctx xpathContext) xpathValue {
+		return xpathValue{kind: 'b', bool: compareXPath(operator, left(ctx), right(ctx))}
+	}, nil
+}
+
+// parseOperand parses a literal, a number, a function call, a parenthesized
+// expression or a location path
+func (p *xpathParser) parseOperand() (xpathPredicate, error) {
+	token := p.peek()
+	switch {
+	case token == "":
+		return nil, fmt.Errorf("expected an expression at the end")
+	case token[0] == '"' || token[0] == '\'':
+		p.pos++
+		value := xpathValue{kind: 's', str: token[1 : len(token)-1]}
+		return func(xpathContext) xpathValue { return value }, nil
+	case token[0] >= '0' && token[0] <= '9' || token[0] == '.' && token != ".." && len(token) > 1:
+		p.pos++
+		number, err := strconv.ParseFloat(token, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid number %q", token)
+		}
+		value := xpathValue{kind: 'f', num: number}
+		return func(xpathContext) xpathValue { return value }, nil
+	case token == "(":
+		p.pos++
+		inner, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		return inner, p.expect(")")
+	case isXPathName(token) && token != "text" && token != "node" && !xpathAxes[token] &&
+		p.pos+1 < len(p.tokens) && p.tokens[p.pos+1] == "(":
+		return p.parseFunction()
+	}
+
+	// Relative paths start at the context item
+	path, err := p.parsePath()
+	if err != nil {
+		return nil, err
+	}
+	return func(ctx xpathContext) xpathValue {
+		context := ctx.item
+		if path.absolute {
+			context = xpathItem{node: ctx.eval.root}
+		}
+		return xpathValue{kind: 'n', items: ctx.eval.evalPath(path, context)}
+	}, nil
+}
+
+// parseFunction parses a call of a supported function
+func (p *xpathParser) parseFunction() (xpathPredicate, error) {
+	name := p.peek()
+	p.pos += 2
+
+	var args []xpathPredicate
+	if !p.accept(")") {
+		for {
+			arg, err := p.parseOr()
+			if err != nil {
+				return nil, err
+			}
+			args = append(args, arg)
+			if p.accept(")") {
+				break
+			}
+			if err := p.expect(","); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	// Functions taking an optional string default to the context item
+	stringArg := func(ctx xpathContext) string {
+		if len(args) == 0 {
+			return ctx.item.stringValue()
+		}
+		return args[0](ctx).toString()
+	}
+
+	arity := map[string][2]int{
+		"contains": {2, 2}, "starts-with": {2, 2}, "ends-with": {2, 2}, "normalize-space": {0, 1},
+		"not": {1, 1}, "position": {0, 0}, "last": {0, 0}, "count": {1, 1}, "string-length": {0, 1},
+	}
+	bounds, ok := arity[name]
+	if !ok {
+		return nil, fmt.Errorf("unsupported function %s()", name)
+	}
+	if len(args) < bounds[0] || len(args) > bounds[1] {
+		return nil, fmt.Errorf("wrong number of arguments for %s()", name)
+	}
+
+	switch name {
+	case "contains", "starts-with", "ends-with":
+		test := map[string]func(string, string) bool{
+			"contains": strings.Contains, "starts-with": strings.HasPrefix, "ends-with": strings.HasSuffix,
+		}[name]
+		return func(ctx xpathContext) xpathValue {
+			return xpathValue{kind: 'b', bool: test(args[0](ctx).toString(), args[1](ctx).toString())}
+		}, nil
+	case "normalize-space":
+		return func(ctx xpathContext) xpathValue {
+			return xpathValue{kind: 's', str: strings.Join(strings.Fields(stringArg(ctx)), " ")}
+		}, nil
+	case "not":
+		return func(ctx xpathContext) xpathValue {
+			return xpathValue{kind: 'b', bool: !args[0](ctx).toBool()}
+		}, nil
+	case "position":
+		return func(ctx xpathContext) xpathValue { return xpathValue{kind: 'f', num: float64(ctx.position)} }, nil
+	case "last":
+		return func(ctx xpathContext) xpathValue { return xpathValue{kind: 'f', num: float64(ctx.size)} }, nil
+	case "count":
+		return func(ctx xpathContext) xpathValue {
+			value := args[0](ctx)
+			return xpathValue{kind: 'f', num: float64(len(value.items))}
+		}, nil
+	default: // string-length
+		return func(ctx xpathContext) xpathValue {
+			return xpathValue{kind: 'f', num: float64(len([]rune(stringArg(ctx))))}
+		}, nil
+	}
+}
@@ -0,0 +1,205 @@
+package services
+
+import (
+	"strings"
+	"testing"
+
+	"golang.org/x/net/html"
+)
+
+const xpathTestPage = `<!DOCTYPE html>
+<html><body>
+<div id="main" class="content">
+  <h1 id="title">  Product   name </h1>
+  <ul id="list">
+    <li id="li1" class="item">One</li>
+    <li id="li2" class="item sale">Two</li>
+    <li id="li3" class="item">Three</li>
+  </ul>
+  <p id="price" data-price="42">Price: <b id="amount">42</b> EUR</p>
+  <p id="empty"></p>
+  <a id="a1" href="https://example.com/a" rel="nofollow">A</a>
+  <a id="a2" href="/b">B</a>
+</div>
+<div id="footer"><span id="copy">(c) 2024</span></div>
+</body></html>`
+
+// selectedItems describes the items an expression selects from the test
+// page: elements by id, attributes as @name=value and text by its content
+func selectedItems(t *testing.T, expression string) string {
+	t.Helper()
+	doc, err := html.Parse(strings.NewReader(xpathTestPage))
+	if err != nil {
+		t.Fatalf("failed to parse test page: %v", err)
+	}
+	compiled, err := compileXPath(expression)
+	if err != nil {
+		t.Fatalf("compileXPath(%q) failed: %v", expression, err)
+	}
+
+	var items []string
+	for _, item := range compiled.Select(doc) {
+		switch {
+		case item.attr != nil:
+			items = append(items, "@"+item.attr.Key+"="+item.attr.Val)
+		case item.node.Type == html.TextNode:
+			items = append(items, "'"+strings.TrimSpace(item.node.Data)+"'")
+		case item.node.Type == html.DocumentNode:
+			items = append(items, "/")
+		case getAttr(item.node, "id") != "":
+			items = append(items, getAttr(item.node, "id"))
+		default:
+			items = append(items, item.node.Data)
+		}
+	}
+	return strings.Join(items, " ")
+}
+
+func TestXPathAxes(t *testing.T) {
+	tests := []struct {
+		name       string
+		expression string
+		want       string
+	}{
+		{"root", "/", "/"},
+		{"absolute path", "/html/body/div", "main footer"},
+		{"descendant shorthand", "//li", "li1 li2 li3"},
+		{"child", "//ul/li", "li1 li2 li3"},
+		{"child axis", "//ul/child::li", "li1 li2 li3"},
+		{"wildcard", "//div[@id='footer']/*", "copy"},
+		{"descendant axis", "//div[@id='footer']/descendant::*", "copy"},
+		{"descendant-or-self axis", "//span/descendant-or-self::*", "copy"},
+		{"self", "//li[@id='li2']/self::li", "li2"},
+		{"self shorthand", "//li[@id='li2']/.", "li2"},
+		{"parent", "//b/parent::p", "price"},
+		{"parent shorthand", "//b/..", "price"},
+		{"ancestor", "//b/ancestor::div", "main"},
+		{"ancestor elements", "//span/ancestor::*", "html body footer"},
+		{"following-sibling", "//li[@id='li1']/following-sibling::li", "li2 li3"},
+		{"preceding-sibling", "//li[@id='li3']/preceding-sibling::li", "li1 li2"},
+		{"union in document order", "//h1 | //ul", "title list"},
+		{"union without duplicates", "//li[1] | //li", "li1 li2 li3"},
+		{"text nodes", "//li/text()", "'One' 'Two' 'Three'"},
+		{"nested descendant", "//div//b", "amount"},
+		{"no match", "//table", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := selectedItems(t, tt.expression); got != tt.want {
+				t.Errorf("%q selected %q, want %q", tt.expression, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestXPathPredicates(t *testing.T) {
+	tests := []struct {
+		name       string
+		expression string
+		want       string
+	}{
+		{"position", "//li[2]", "li2"},
+		{"position per parent", "//div/*[1]", "title copy"},
+		{"reverse axis position", "//li[@id='li3']/preceding-sibling::li[1]", "li2"},
+		{"reverse axis last", "//b/ancestor::*[last()]", "html"},
+		{"attribute presence", "//a[@rel]", "a1"},
+		{"attribute equals", "//li[@class='item sale']", "li2"},
+		{"attribute not equals", "//a[@href!='/b']", "a1"},
+		{"attribute number", "//p[@data-price > 40]", "price"},
+		{"attribute number false", "//p[@data-price < 40]", ""},
+		{"child text equals", "//ul[li='Two']", "list"},
+		{"and", "//li[@class='item' and position()=3]", "li3"},
+		{"or", "//li[@id='li1' or @id='li3']", "li1 li3"},
+		{"parenthesized", "//li[(@id='li1' or @id='li2') and @class='item']", "li1"},
+		{"stacked predicates", "//li[@class='item'][2]", "li3"},
+		{"nested path", "//p[b]", "price"},
+		{"absolute path in predicate", "//li[count(//a)=2][1]", "li1"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := selectedItems(t, tt.expression); got != tt.want {
+				t.Errorf("%q selected %q, want %q", tt.expression, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestXPathFunctions(t *testing.T) {
+	tests := []struct {
+		name       string
+		expression string
+		want       string
+	}{
+		{"contains", "//li[contains(@class, 'sale')]", "li2"},
+		{"starts-with", "//a[starts-with(@href, 'https')]", "a1"},
+		{"ends-with", "//a[ends-with(@href, '/b')]", "a2"},
+		{"normalize-space", "//h1[normalize-space()='Product name']", "title"},
+		{"normalize-space argument", "//div[normalize-space(h1)='Product name']", "main"},
+		{"not", "//li[not(@class='item')]", "li2"},
+		{"not empty", "//p[not(node())]", "empty"},
+		{"position", "//li[position() > 1]", "li2 li3"},
+		{"last", "//li[last()]", "li3"},
+		{"position and last", "//li[position() = last()]", "li3"},
+		{"count", "//ul[count(li) = 3]", "list"},
+		{"string-length", "//li[string-length() = 3]", "li1 li2"},
+		{"string-length argument", "//ul[string-length(li) = 3]", "list"},
+		{"text equals", "//li[text()='Three']", "li3"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := selectedItems(t, tt.expression); got != tt.want {
+				t.Errorf("%q selected %q, want %q", tt.expression, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestXPathAttributes(t *testing.T) {
+	tests := []struct {
+		name       string
+		expression string
+		want       string
+	}{
+		{"attribute", "//a/@href", "@href=https://example.com/a @href=/b"},
+		{"attribute axis", "//a[1]/attribute::rel", "@rel=nofollow"},
+		{"all attributes", "//a[@id='a2']/@*", "@id=a2 @href=/b"},
+		{"attribute with predicate", "//p/@data-price[. > 10]", "@data-price=42"},
+		{"attribute case insensitive", "//P/@DATA-PRICE", "@data-price=42"},
+		{"missing attribute", "//li/@href", ""},
+		{"attribute self", "//a/@rel/self::node()", "@rel=nofollow"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := selectedItems(t, tt.expression); got != tt.want {
+				t.Errorf("%q selected %q, want %q", tt.expression, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestXPathErrors(t *testing.T) {
+	tests := []string{
+		"",
+		"//",
+		"//li[",
+		"//li[1",
+		"//li[@class='x]",
+		"//li[unknown()]",
+		"//li[contains(@class)]",
+		"//li[position(1)]",
+		"//li]",
+		"//li/text(",
+		"//li[@class=]",
+		"#main",
+	}
+
+	for _, expression := range tests {
+		if _, err := compileXPath(expression); err == nil {
+			t.Errorf("compileXPath(%q) succeeded, want an error", expression)
+		}
+	}
+}
@@ -0,0 +1,73 @@
+package utils
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"io"
+)
+
+// Default key used to encrypt secrets at rest when SETTINGS_ENCRYPTION_KEY is not set
+const defaultEncryptionKey = "your-super-secret-settings-key-change-in-production"
+
+// encryptionKey derives a 256-bit AES key from the configured secret
+func encryptionKey() []byte {
+	key := sha256.Sum256([]byte(getEnv("SETTINGS_ENCRYPTION_KEY", defaultEncryptionKey)))
+	return key[:]
+}
+
+// Encrypt encrypts a string with AES-GCM and returns it base64 encoded
+func Encrypt(plaintext string) (string, error) {
+	block, err := aes.NewCipher(encryptionKey())
+	if err != nil {
+		return "", err
+	}
+
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return "", err
+	}
+
+	// Prepend a random nonce to the sealed data
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return "", err
+	}
+	sealed := gcm.Seal(nonce, nonce, []byte(plaintext), nil)
+
+	return base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+// Decrypt decrypts a string produced by Encrypt
+func Decrypt(encoded string) (string, error) {
+	data, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return "", err
+	}
+
+	block, err := aes.NewCipher(encryptionKey())
+	if err != nil {
+		return "", err
+	}
+
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return "", err
+	}
+
+	// Split the nonce from the sealed data
+	if len(data) < gcm.NonceSize() {
+		return "", errors.New("encrypted data is too short")
+	}
+	nonce, sealed := data[:gcm.NonceSize()], data[gcm.NonceSize():]
+
+	plaintext, err := gcm.Open(nil, nonce, sealed, nil)
+	if err != nil {
+		return "", err
+	}
+
+	return string(plaintext), nil
+}
@@ -58,13 +58,13 @@ const mapWebsiteToResult = (website: any): CrawlResult => {
     hasLoginForm: website.link_counts?.has_login_form || false,
     status: mapStatus(website.status),
     createdAt: website.created_at,
-    completedAt: website.status === 'done' ? website.updated_at : undefined,
+    completedAt: website.status === 'done' || website.status === 'not_analyzable' ? website.updated_at : undefined,
     errorMessage: website.error_message || undefined,
   };
 };
 
 // Map backend status to frontend status
-const mapStatus = (status: string): 'queued' | 'running' | 'completed' | 'error' => {
+const mapStatus = (status: string): CrawlResult['status'] => {
   switch (status) {
     case 'done':
       return 'completed';
@@ -72,6 +72,9 @@ const mapStatus = (status: string): 'queued' | 'running' | 'completed' | 'error'
       return 'running';
     case 'error':
       return 'error';
+    // The URL returned content other than HTML, PDF or text
+    case 'not_analyzable':
+      return 'not_analyzable';
     case 'queued':
     default:
       return 'queued';
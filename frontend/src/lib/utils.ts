@@ -4,3 +4,11 @@ import { twMerge } from "tailwind-merge"
 export function cn(...inputs: ClassValue[]) {
   return twMerge(clsx(inputs))
 }
+
+// Human readable label of a crawl status
+export function statusLabel(status: string) {
+  if (status === 'not_analyzable') {
+    return 'Not analyzable'
+  }
+  return status.charAt(0).toUpperCase() + status.slice(1)
+}
@@ -12,7 +12,8 @@ import {
   BarChart3,
   Link2,
   AlertTriangle,
-  RefreshCw
+  RefreshCw,
+  FileQuestion
 } from 'lucide-react';
 import { Button } from '@/components/ui/button';
 import { Card, CardContent, CardHeader, CardTitle, CardDescription, CardFooter } from '@/components/ui/card';
@@ -23,6 +24,7 @@ import { useToast } from '@/components/ui/use-toast';
 import { Tabs, TabsContent, TabsList, TabsTrigger } from "@/components/ui/tabs";
 import { BrokenLink, CrawlDetails as CrawlDetailsType } from '@/types/crawler';
 import { websiteAPI } from '@/lib/api';
+import { statusLabel } from '@/lib/utils';
 
 export default function CrawlDetails() {
   const { id } = useParams<{ id: string }>();
@@ -164,6 +166,8 @@ export default function CrawlDetails() {
         return <Loader2 className="h-5 w-5 animate-spin text-warning" />;
       case 'error':
         return <XCircle className="h-5 w-5 text-destructive" />;
+      case 'not_analyzable':
+        return <FileQuestion className="h-5 w-5 text-muted-foreground" />;
       case 'queued':
         return <Clock className="h-5 w-5 text-primary" />;
       default:
@@ -176,13 +180,14 @@ export default function CrawlDetails() {
       completed: 'default',
       running: 'secondary',
       error: 'destructive',
+      not_analyzable: 'outline',
       queued: 'outline',
     } as const;
 
     return (
       <Badge variant={variants[status as keyof typeof variants] || 'outline'} className="flex items-center gap-1">
         {getStatusIcon(status)}
-        {status.charAt(0).toUpperCase() + status.slice(1)}
+        {statusLabel(status)}
       </Badge>
     );
   };
@@ -393,7 +398,7 @@ export default function CrawlDetails() {
                     <dl className="space-y-2 sm:space-y-3">
                       <div className="flex justify-between items-center">
                         <dt className="text-xs sm:text-sm text-muted-foreground">Status</dt>
-                        <dd className="font-medium text-xs sm:text-sm">{details.status.charAt(0).toUpperCase() + details.status.slice(1)}</dd>
+                        <dd className="font-medium text-xs sm:text-sm">{statusLabel(details.status)}</dd>
                       </div>
                       <div className="flex justify-between items-center">
                         <dt className="text-xs sm:text-sm text-muted-foreground">Total Links</dt>
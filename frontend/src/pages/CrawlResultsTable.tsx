@@ -12,7 +12,8 @@ import {
   MoreHorizontal,
   Link2,
   Search,
-  RefreshCw
+  RefreshCw,
+  FileQuestion
 } from 'lucide-react';
 import { Badge } from '@/components/ui/badge';
 import { Button } from '@/components/ui/button';
@@ -36,6 +37,7 @@ import {
 } from "@/components/ui/dropdown-menu";
 import { Tooltip, TooltipContent, TooltipProvider, TooltipTrigger } from "@/components/ui/tooltip";
 import { CrawlResult, SortField, SortDirection } from '@/types/crawler';
+import { statusLabel } from '@/lib/utils';
 import { useNavigate } from 'react-router-dom';
 import { 
   Pagination,
@@ -142,6 +144,8 @@ export function CrawlResultsTable({
         return <Loader2 className="h-4 w-4 text-warning animate-spin" />;
       case 'error':
         return <XCircle className="h-4 w-4 text-destructive" />;
+      case 'not_analyzable':
+        return <FileQuestion className="h-4 w-4 text-muted-foreground" />;
       case 'queued':
         return <Clock className="h-4 w-4 text-primary" />;
     }
@@ -152,13 +156,14 @@ export function CrawlResultsTable({
       completed: 'default',
       running: 'secondary',
       error: 'destructive',
+      not_analyzable: 'outline',
       queued: 'outline',
     } as const;
 
     return (
       <Badge variant={variants[status]} className="flex items-center gap-1">
         {getStatusIcon(status)}
-        {status.charAt(0).toUpperCase() + status.slice(1)}
+        {statusLabel(status)}
       </Badge>
     );
   };
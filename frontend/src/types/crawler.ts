@@ -15,7 +15,7 @@ export interface CrawlResult {
   externalLinks: number;
   inaccessibleLinks: number;
   hasLoginForm: boolean;
-  status: 'queued' | 'running' | 'completed' | 'error';
+  status: 'queued' | 'running' | 'completed' | 'error' | 'not_analyzable';
   createdAt: string;
   completedAt?: string;
   errorMessage?: string;